@@ -0,0 +1,187 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ImageBuilder builds an image from a build context and pushes it to a registry
+type ImageBuilder interface {
+	// Build builds the image from the build context, which contains the Dockerfile, and pushes it
+	Build(opts BuildOptions) error
+	// Available checks if the builder can build images, e.g. if the docker daemon is reachable
+	Available() error
+}
+
+// BuildOptions are the options of a build of an ImageBuilder
+type BuildOptions struct {
+	// ContextDir is the directory of the build context, the Dockerfile is at its root
+	ContextDir string
+	// ImageName is the name the image is pushed with
+	ImageName string
+	// BuildArgs are the values of the build arguments declared in the Dockerfile
+	BuildArgs map[string]string
+	// Platforms are the platforms the image is built for, e.g. linux/arm64, DefaultPlatform if empty
+	// With more than one platform, a manifest list covering all of them is pushed
+	Platforms []string
+	// ProgressFunc is called with the progress of the build and the push, it may be nil
+	ProgressFunc ProgressFunc
+	// Context cancels the build and the push when it is done, it may be nil
+	Context context.Context
+	// PushRetries is the number of times a push that failed with a transient error is retried, see IsTransientPushError
+	PushRetries int
+}
+
+// DefaultPlatform is the platform images are built for if no platforms are set
+const DefaultPlatform = "linux/amd64"
+
+// multiPlatformBuilder is the name of the buildx builder that builds multi-platform images,
+// as the default docker driver cannot build them
+const multiPlatformBuilder = "knuu-multi-platform"
+
+// platformRegex matches platforms in the format os/arch[/variant], e.g. linux/amd64 or linux/arm/v7
+var platformRegex = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+// ValidatePlatform checks if the platform is in the format os/arch[/variant], e.g. linux/arm64
+func ValidatePlatform(platform string) error {
+	if !platformRegex.MatchString(platform) {
+		return fmt.Errorf("invalid platform '%s', it must be in the format os/arch[/variant], e.g. linux/arm64", platform)
+	}
+	return nil
+}
+
+// ctx returns the context of the build, the background context if none is set
+func (o BuildOptions) ctx() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// platforms returns the platforms of the build, DefaultPlatform if none are set
+func (o BuildOptions) platforms() []string {
+	if len(o.Platforms) == 0 {
+		return []string{DefaultPlatform}
+	}
+	return o.Platforms
+}
+
+// DockerBuilder builds images with docker buildx and pushes them with docker push, it requires a docker daemon
+type DockerBuilder struct{}
+
+// NewDockerBuilder returns a builder using the local docker daemon
+func NewDockerBuilder() *DockerBuilder {
+	return &DockerBuilder{}
+}
+
+// Available checks if docker buildx can reach the docker daemon.
+func (b *DockerBuilder) Available() error {
+	if err := runCommand(exec.Command("docker", "info")); err != nil {
+		return fmt.Errorf("docker daemon is not available: %w", err)
+	}
+	if err := runCommand(exec.Command("docker", "buildx", "version")); err != nil {
+		return fmt.Errorf("docker buildx is not available: %w", err)
+	}
+	return nil
+}
+
+// Build builds the image with docker buildx and pushes it with docker push.
+// Images for more than one platform are built with a buildx builder using the docker-container driver, which pushes the
+// manifest list itself, as it cannot be loaded into the docker daemon.
+func (b *DockerBuilder) Build(opts BuildOptions) error {
+	platforms := opts.platforms()
+	if len(platforms) > 1 {
+		return b.buildMultiPlatform(opts, platforms)
+	}
+
+	// Check if there is an existing builder instance
+	cmd := exec.CommandContext(opts.ctx(), "docker", "buildx", "ls")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to list buildx builders: %w", err)
+	}
+
+	// If no builder instance exists, create a new one
+	if !strings.Contains(string(output), "default") {
+		cmd = exec.CommandContext(opts.ctx(), "docker", "buildx", "create", "--use")
+		err = runCommand(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to create buildx builder: %w", err)
+		}
+	}
+
+	progress := newProgress(opts.ProgressFunc)
+	defer progress.close()
+
+	// Build the Docker image using buildx, with plain progress output to report the progress of the build steps
+	buildArgs := []string{"buildx", "build", "--load", "--progress=plain", "--platform", platforms[0], "-t", opts.ImageName}
+	for _, key := range sortedKeys(opts.BuildArgs) {
+		buildArgs = append(buildArgs, "--build-arg", key+"="+opts.BuildArgs[key])
+	}
+	cmd = exec.CommandContext(opts.ctx(), "docker", append(buildArgs, opts.ContextDir)...)
+	err = runCommandWithProgress(cmd, progress.buildLine)
+	if err != nil {
+		return fmt.Errorf("failed to build image: %w", err)
+	}
+	progress.report(ProgressPhaseBuild, 100)
+	progress.report(ProgressPhaseCommit, 100)
+
+	// Push the Docker image to the registry
+	progress.report(ProgressPhasePush, 0)
+	err = retryPush(opts.ctx(), opts.PushRetries, opts.ImageName, func() error {
+		cmd := exec.CommandContext(opts.ctx(), "docker", "push", opts.ImageName)
+		return runCommandWithProgress(cmd, progress.pushLine)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to push image: %w", err)
+	}
+	progress.report(ProgressPhasePush, 100)
+
+	return nil
+}
+
+// buildMultiPlatform builds the image for all platforms and pushes the manifest list with the multi-platform builder
+func (b *DockerBuilder) buildMultiPlatform(opts BuildOptions, platforms []string) error {
+	// The builder is created once and reused by later builds
+	if err := runCommand(exec.CommandContext(opts.ctx(), "docker", "buildx", "inspect", multiPlatformBuilder)); err != nil {
+		cmd := exec.CommandContext(opts.ctx(), "docker", "buildx", "create", "--name", multiPlatformBuilder, "--driver", "docker-container")
+		if err := runCommand(cmd); err != nil {
+			return fmt.Errorf("failed to create buildx builder for multiple platforms: %w", err)
+		}
+	}
+
+	progress := newProgress(opts.ProgressFunc)
+	defer progress.close()
+
+	buildArgs := []string{"buildx", "build", "--builder", multiPlatformBuilder, "--push", "--progress=plain",
+		"--platform", strings.Join(platforms, ","), "-t", opts.ImageName}
+	for _, key := range sortedKeys(opts.BuildArgs) {
+		buildArgs = append(buildArgs, "--build-arg", key+"="+opts.BuildArgs[key])
+	}
+	// The build and the push are one command, a retry only pushes again, as the build is cached by the builder
+	err := retryPush(opts.ctx(), opts.PushRetries, opts.ImageName, func() error {
+		cmd := exec.CommandContext(opts.ctx(), "docker", append(buildArgs, opts.ContextDir)...)
+		return runCommandWithProgress(cmd, progress.buildLine)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build image for platforms '%s': %w", strings.Join(platforms, "', '"), err)
+	}
+	progress.report(ProgressPhaseBuild, 100)
+	progress.report(ProgressPhaseCommit, 100)
+	progress.report(ProgressPhasePush, 100)
+	return nil
+}
+
+// sortedKeys returns the keys of the map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
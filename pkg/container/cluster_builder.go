@@ -0,0 +1,290 @@
+package container
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// KanikoImage is the image of the kaniko builder, the debug variant as it provides a shell to receive the build context
+	KanikoImage = "gcr.io/kaniko-project/executor:v1.23.2-debug"
+	// BuildKitImage is the image of the buildkit builder
+	BuildKitImage = "moby/buildkit:v0.16.0"
+)
+
+// clusterBuildTimeout is the time a build in the cluster may take, including the upload of the build context and the push
+const clusterBuildTimeout = 30 * time.Minute
+
+// clusterBuildPollInterval is the interval in which the status of the build pod is checked
+const clusterBuildPollInterval = 2 * time.Second
+
+// clusterBuildLogLines is the number of lines of the build logs that are added to the error of a failed build
+const clusterBuildLogLines = 30
+
+// clusterBuildReadyFile is created in the build context directory once it is uploaded, which starts the build
+const clusterBuildReadyFile = ".knuu-ready"
+
+// ClusterBuilder builds images in pods of the cluster and pushes them to the registry, so it does not require a docker daemon
+// The registry must accept pushes from the cluster without credentials, e.g. ttl.sh
+type ClusterBuilder struct {
+	client     *k8s.Client
+	namespace  string
+	labels     map[string]string
+	image      string
+	contextDir string
+	privileged bool
+	// multiPlatform is true if the builder can build images for more than one platform in one build
+	multiPlatform bool
+	command       func(opts BuildOptions, contextDir string) string
+}
+
+// NewKanikoBuilder returns a builder running kaniko in pods of the namespace, labeled with the labels
+func NewKanikoBuilder(client *k8s.Client, namespace string, labels map[string]string) *ClusterBuilder {
+	return &ClusterBuilder{
+		client:    client,
+		namespace: namespace,
+		labels:    labels,
+		image:     KanikoImage,
+		// kaniko ignores its own directory when snapshotting the image, so the build context is not part of the image
+		contextDir: "/kaniko/buildcontext",
+		command:    kanikoCommand,
+	}
+}
+
+// NewBuildKitBuilder returns a builder running buildkit in privileged pods of the namespace, labeled with the labels
+func NewBuildKitBuilder(client *k8s.Client, namespace string, labels map[string]string) *ClusterBuilder {
+	return &ClusterBuilder{
+		client:        client,
+		namespace:     namespace,
+		labels:        labels,
+		image:         BuildKitImage,
+		contextDir:    "/tmp/buildcontext",
+		privileged:    true,
+		multiPlatform: true,
+		command:       buildKitCommand,
+	}
+}
+
+// Available checks if the cluster can be reached to start build pods.
+func (b *ClusterBuilder) Available() error {
+	if b.client == nil || !b.client.IsInitialized() {
+		return fmt.Errorf("kubernetes client is not initialized")
+	}
+	if _, err := b.client.ListPods(b.namespace, b.labels); err != nil {
+		return fmt.Errorf("cannot list pods in namespace '%s': %w", b.namespace, err)
+	}
+	return nil
+}
+
+// Build uploads the build context to a build pod, waits until the pod built and pushed the image, and deletes the pod.
+// Kaniko builds one platform per run, so it cannot build images for more than one platform.
+// BuildKit builds images for other platforms than the one of its node only if emulation is set up on the node.
+// As the push cannot be separated from the build in the pod, a build that failed with a transient push error is run again.
+func (b *ClusterBuilder) Build(opts BuildOptions) error {
+	if len(opts.Platforms) > 1 && !b.multiPlatform {
+		return fmt.Errorf("builder image '%s' cannot build images for more than one platform", b.image)
+	}
+	return retryPush(opts.ctx(), opts.PushRetries, opts.ImageName, func() error {
+		return b.build(opts)
+	})
+}
+
+// build runs one build pod, see Build
+func (b *ClusterBuilder) build(opts BuildOptions) error {
+	progress := newProgress(opts.ProgressFunc)
+	defer progress.close()
+
+	ctx, cancel := context.WithTimeout(opts.ctx(), clusterBuildTimeout)
+	defer cancel()
+
+	name := fmt.Sprintf("knuu-build-%d", time.Now().UnixNano())
+	labels := make(map[string]string, len(b.labels)+1)
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	labels["app"] = name
+
+	// The pod waits for the build context before it builds, as it cannot be mounted from the local machine
+	script := fmt.Sprintf("mkdir -p %s && while [ ! -f %s/%s ]; do sleep 1; done && %s",
+		b.contextDir, b.contextDir, clusterBuildReadyFile, b.command(opts, b.contextDir))
+	_, err := b.client.DeployPod(k8s.PodConfig{
+		Namespace:     b.namespace,
+		Name:          name,
+		Labels:        labels,
+		Image:         b.image,
+		Command:       []string{"sh", "-c", script},
+		RestartPolicy: v1.RestartPolicyNever,
+		Privileged:    b.privileged,
+	}, false)
+	if err != nil {
+		return fmt.Errorf("failed to deploy build pod: %w", err)
+	}
+	defer func() {
+		if err := b.client.DeletePod(b.namespace, name); err != nil {
+			log.Warnf("failed to delete build pod '%s': %v", name, err)
+		}
+	}()
+
+	if err := b.waitForPod(ctx, name, v1.PodRunning); err != nil {
+		return err
+	}
+	progress.report(ProgressPhaseBuild, 0)
+	if err := b.uploadContext(ctx, name, opts.ContextDir); err != nil {
+		return err
+	}
+
+	err = b.waitForPod(ctx, name, v1.PodSucceeded)
+	logs, logsErr := b.client.GetPodLogs(b.namespace, name, name, 0, false)
+	if logsErr != nil {
+		log.Warnf("failed to get logs of build pod '%s': %v", name, logsErr)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs), "\n") {
+		log.Debugf("[%s] %s", name, line)
+	}
+	if err != nil {
+		return fmt.Errorf("%w\nlast lines of the build logs:\n%s", err, tailLines(logs, clusterBuildLogLines))
+	}
+	progress.report(ProgressPhaseBuild, 100)
+	progress.report(ProgressPhaseCommit, 100)
+	progress.report(ProgressPhasePush, 100)
+	return nil
+}
+
+// waitForPod waits until the build pod reaches the phase, or fails
+func (b *ClusterBuilder) waitForPod(ctx context.Context, name string, phase v1.PodPhase) error {
+	ticker := time.NewTicker(clusterBuildPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := b.client.GetPodStatus(b.namespace, name)
+		if err != nil {
+			return fmt.Errorf("failed to get status of build pod '%s': %w", name, err)
+		}
+		if status.Phase == phase || (phase == v1.PodRunning && status.Phase == v1.PodSucceeded) {
+			return nil
+		}
+		if status.Phase == v1.PodFailed {
+			return fmt.Errorf("build pod '%s' failed: %s (exit code %d)", name, status.TerminatedReason, status.ExitCode)
+		}
+		if failure := status.Failure(); failure != "" {
+			return fmt.Errorf("build pod '%s' cannot run: %s", name, failure)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout while waiting for build pod '%s' to be %s: %w", name, phase, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// uploadContext streams the build context as tar archive into the build pod and marks it as ready
+func (b *ClusterBuilder) uploadContext(ctx context.Context, name, contextDir string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTar(writer, contextDir))
+	}()
+	defer reader.Close()
+
+	cmd := []string{"sh", "-c", fmt.Sprintf("tar xf - -C %s && touch %s/%s", b.contextDir, b.contextDir, clusterBuildReadyFile)}
+	if _, err := b.client.RunCommandInPodWithStdin(ctx, b.namespace, name, name, cmd, reader); err != nil {
+		return fmt.Errorf("failed to upload build context to build pod '%s': %w", name, err)
+	}
+	return nil
+}
+
+// writeTar writes the files of the directory as tar archive, with paths relative to the directory
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive build context: %w", err)
+	}
+	return tw.Close()
+}
+
+// kanikoCommand returns the command building and pushing the image with kaniko
+func kanikoCommand(opts BuildOptions, contextDir string) string {
+	args := []string{
+		"/kaniko/executor",
+		"--context=dir://" + contextDir,
+		"--dockerfile=" + contextDir + "/Dockerfile",
+		"--destination=" + shellQuote(opts.ImageName),
+		"--custom-platform=" + shellQuote(opts.platforms()[0]),
+	}
+	for _, key := range sortedKeys(opts.BuildArgs) {
+		args = append(args, "--build-arg="+shellQuote(key+"="+opts.BuildArgs[key]))
+	}
+	return strings.Join(args, " ")
+}
+
+// buildKitCommand returns the command building and pushing the image with buildkit, without a buildkit daemon
+func buildKitCommand(opts BuildOptions, contextDir string) string {
+	args := []string{
+		"buildctl-daemonless.sh", "build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + contextDir,
+		"--local", "dockerfile=" + contextDir,
+		"--opt", "platform=" + shellQuote(strings.Join(opts.platforms(), ",")),
+		"--output", shellQuote("type=image,name=" + opts.ImageName + ",push=true"),
+	}
+	for _, key := range sortedKeys(opts.BuildArgs) {
+		args = append(args, "--opt", shellQuote("build-arg:"+key+"="+opts.BuildArgs[key]))
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes the value for sh
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// tailLines returns the last n lines of the text
+func tailLines(text string, n int) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -6,18 +6,22 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"github.com/celestiaorg/knuu/pkg/log"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
-	"github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
 
+// buildArgKeyRegex matches valid names of build arguments
+var buildArgKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // BuilderFactory is responsible for creating new instances of buildah.Builder
 type BuilderFactory struct {
 	imageNameFrom          string
@@ -25,6 +29,13 @@ type BuilderFactory struct {
 	cli                    *client.Client
 	dockerFileInstructions []string
 	context                string
+	progressFunc           ProgressFunc
+	buildArgs              map[string]string
+	imageBuilder           ImageBuilder
+	platforms              []string
+	pushedFingerprint      string
+	customDockerfile       bool
+	pushRetries            int
 }
 
 // NewBuilderFactory creates a new instance of BuilderFactory.
@@ -38,10 +49,42 @@ func NewBuilderFactory(imageName string, buildContext string) (*BuilderFactory,
 		cli:                    cli,
 		dockerFileInstructions: []string{"FROM " + imageName},
 		context:                buildContext,
+		pushRetries:            DefaultPushRetries,
 	}, nil
 }
 
+// NewBuilderFactoryFromDockerfile creates a new instance of BuilderFactory that builds the image from the content of a
+// Dockerfile instead of a base image. Instructions added to the builder are appended to the Dockerfile.
+func NewBuilderFactoryFromDockerfile(dockerfile string, buildContext string) (*BuilderFactory, error) {
+	if !hasFromInstruction(dockerfile) {
+		return nil, fmt.Errorf("dockerfile has no FROM instruction")
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+	return &BuilderFactory{
+		cli:                    cli,
+		dockerFileInstructions: []string{strings.TrimRight(dockerfile, "\n")},
+		context:                buildContext,
+		customDockerfile:       true,
+		pushRetries:            DefaultPushRetries,
+	}, nil
+}
+
+// hasFromInstruction returns true if the Dockerfile contains a FROM instruction
+func hasFromInstruction(dockerfile string) bool {
+	for _, line := range strings.Split(dockerfile, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 0 && strings.EqualFold(fields[0], "FROM") {
+			return true
+		}
+	}
+	return false
+}
+
 // ImageNameFrom returns the name of the image from which the builder is created.
+// It is empty if the builder builds from a Dockerfile.
 func (f *BuilderFactory) ImageNameFrom() string {
 	return f.imageNameFrom
 }
@@ -62,6 +105,7 @@ func (f *BuilderFactory) AddToBuilder(srcPath, destPath, chown string) error {
 
 // ReadFileFromBuilder reads a file from the given builder's mount point.
 // It returns the file's content or any error encountered.
+// It pulls the pushed image into the local docker daemon, so it requires docker regardless of the image builder.
 func (f *BuilderFactory) ReadFileFromBuilder(filePath string) ([]byte, error) {
 	if f.imageNameTo == "" {
 		return nil, fmt.Errorf("no image name provided, push before reading")
@@ -90,12 +134,12 @@ func (f *BuilderFactory) ReadFileFromBuilder(filePath string) ([]byte, error) {
 		}
 
 		if err := f.cli.ContainerStop(context.Background(), resp.ID, stopOptions); err != nil {
-			logrus.Warnf("failed to stop container: %v", err)
+			log.Warnf("failed to stop container: %v", err)
 		}
 
 		// Remove the container
 		if err := f.cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{}); err != nil {
-			logrus.Warnf("failed to remove container: %v", err)
+			log.Warnf("failed to remove container: %v", err)
 		}
 	}()
 
@@ -146,17 +190,106 @@ func (f *BuilderFactory) SetUser(user string) error {
 	return nil
 }
 
+// SetProgressFunc sets the function that is called with the progress of building and pushing the image.
+// It is called in a separate goroutine, so a slow function does not block the build, but may miss updates.
+// A nil function disables progress reporting.
+func (f *BuilderFactory) SetProgressFunc(fn ProgressFunc) {
+	f.progressFunc = fn
+}
+
+// SetBuildArg sets a build argument, which is declared with ARG after the FROM instruction (or the Dockerfile the builder
+// is created from)
+// and passed with --build-arg when the image is built, so that RUN instructions can reference it.
+// Setting a build argument again overrides its value.
+func (f *BuilderFactory) SetBuildArg(key, value string) error {
+	if !buildArgKeyRegex.MatchString(key) {
+		return fmt.Errorf("invalid build argument name '%s'", key)
+	}
+	if f.buildArgs == nil {
+		f.buildArgs = make(map[string]string)
+	}
+	f.buildArgs[key] = value
+	return nil
+}
+
+// SetImageBuilder sets the builder that builds and pushes the image, by default the local docker daemon is used.
+func (f *BuilderFactory) SetImageBuilder(builder ImageBuilder) {
+	f.imageBuilder = builder
+}
+
+// SetPlatforms sets the platforms the image is built for, e.g. linux/amd64 and linux/arm64.
+// With more than one platform, a manifest list covering all of them is pushed.
+// If no platforms are set, the image is built for DefaultPlatform.
+func (f *BuilderFactory) SetPlatforms(platforms []string) error {
+	for _, platform := range platforms {
+		if err := ValidatePlatform(platform); err != nil {
+			return err
+		}
+	}
+	f.platforms = append([]string(nil), platforms...)
+	return nil
+}
+
+// SetPushRetries sets how often a push that failed with a transient error is retried, with exponential backoff,
+// DefaultPushRetries by default. Errors of authentication or exceeded quotas are never retried.
+func (f *BuilderFactory) SetPushRetries(retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("push retries must not be negative, got '%d'", retries)
+	}
+	f.pushRetries = retries
+	return nil
+}
+
+// Copy returns a copy of the builder with the same instructions, build arguments, platforms and image builder,
+// which builds from the given context, so that changes to either builder do not affect the other.
+// The copy has not pushed an image yet.
+func (f *BuilderFactory) Copy(buildContext string) *BuilderFactory {
+	var buildArgs map[string]string
+	if f.buildArgs != nil {
+		buildArgs = make(map[string]string, len(f.buildArgs))
+		for key, value := range f.buildArgs {
+			buildArgs[key] = value
+		}
+	}
+	return &BuilderFactory{
+		imageNameFrom:          f.imageNameFrom,
+		cli:                    f.cli,
+		dockerFileInstructions: append([]string(nil), f.dockerFileInstructions...),
+		context:                buildContext,
+		progressFunc:           f.progressFunc,
+		buildArgs:              buildArgs,
+		imageBuilder:           f.imageBuilder,
+		platforms:              append([]string(nil), f.platforms...),
+		customDockerfile:       f.customDockerfile,
+		pushRetries:            f.pushRetries,
+	}
+}
+
 // Changed returns true if the builder has been modified, false otherwise.
+// A builder that builds from a Dockerfile is always changed, as there is no image to use without building.
 func (f *BuilderFactory) Changed() bool {
-	return len(f.dockerFileInstructions) > 1
+	return f.customDockerfile || len(f.dockerFileInstructions) > 1
 }
 
 // PushBuilderImage pushes the image from the given builder to a registry.
 // The image is identified by the provided name.
+// The build context is not removed, so that the caller can keep it for debugging.
 func (f *BuilderFactory) PushBuilderImage(imageName string) error {
+	return f.PushBuilderImageContext(context.Background(), imageName)
+}
+
+// PushBuilderImageContext pushes the image from the given builder to a registry, like PushBuilderImage,
+// but cancels the build and the push when the context is done.
+// If the unchanged image was pushed with the same name before, it is not built again.
+func (f *BuilderFactory) PushBuilderImageContext(ctx context.Context, imageName string) error {
 
 	if !f.Changed() {
-		logrus.Debugf("No changes made to image %s, skipping push", f.imageNameFrom)
+		log.Debugf("No changes made to image %s, skipping push", f.imageNameFrom)
+		return nil
+	}
+	fingerprint := f.fingerprint()
+	if imageName == f.imageNameTo && fingerprint == f.pushedFingerprint {
+		log.Debugf("Image %s is already pushed, skipping push", imageName)
 		return nil
 	}
 
@@ -170,49 +303,62 @@ func (f *BuilderFactory) PushBuilderImage(imageName string) error {
 			return fmt.Errorf("failed to create context directory: %w", err)
 		}
 	}
-	dockerFile := strings.Join(f.dockerFileInstructions, "\n")
+	dockerFile := strings.Join(f.dockerFile(), "\n")
 	err := os.WriteFile(dockerFilePath, []byte(dockerFile), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write Dockerfile: %w", err)
 	}
 
-	// Check if there is an existing builder instance
-	cmd := exec.Command("docker", "buildx", "ls")
-	output, err := cmd.Output()
+	err = f.builder().Build(BuildOptions{
+		ContextDir:   f.context,
+		ImageName:    imageName,
+		BuildArgs:    f.buildArgs,
+		Platforms:    f.platforms,
+		ProgressFunc: f.progressFunc,
+		Context:      ctx,
+		PushRetries:  f.pushRetries,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to list buildx builders: %w", err)
+		return err
 	}
+	f.pushedFingerprint = fingerprint
+	return nil
+}
 
-	// If no builder instance exists, create a new one
-	if !strings.Contains(string(output), "default") {
-		cmd = exec.Command("docker", "buildx", "create", "--use")
-		err = runCommand(cmd)
-		if err != nil {
-			return fmt.Errorf("failed to create buildx builder: %w", err)
-		}
+// fingerprint returns a description of everything the image is built from, except the content of the build context,
+// which only changes with new instructions
+func (f *BuilderFactory) fingerprint() string {
+	var b strings.Builder
+	b.WriteString(strings.Join(f.dockerFile(), "\n"))
+	for _, key := range f.buildArgKeys() {
+		fmt.Fprintf(&b, "\n%s=%s", key, f.buildArgs[key])
 	}
+	fmt.Fprintf(&b, "\n%s", strings.Join(f.platforms, ","))
+	return b.String()
+}
 
-	// Build the Docker image using buildx
-	cmd = exec.Command("docker", "buildx", "build", "--load", "--platform", "linux/amd64", "-t", imageName, f.context)
-	err = runCommand(cmd)
-	if err != nil {
-		return fmt.Errorf("failed to build image: %w", err)
+// dockerFile returns the instructions of the Dockerfile, declaring the build arguments after the FROM instruction
+func (f *BuilderFactory) dockerFile() []string {
+	keys := f.buildArgKeys()
+	instructions := make([]string, 0, len(f.dockerFileInstructions)+len(keys))
+	instructions = append(instructions, f.dockerFileInstructions[0])
+	for _, key := range keys {
+		instructions = append(instructions, "ARG "+key)
 	}
+	return append(instructions, f.dockerFileInstructions[1:]...)
+}
 
-	// Push the Docker image to the registry
-	cmd = exec.Command("docker", "push", imageName)
-	err = runCommand(cmd)
-	if err != nil {
-		return fmt.Errorf("failed to push image: %w", err)
-	}
+// buildArgKeys returns the names of the build arguments in sorted order, so that the Dockerfile is always the same
+func (f *BuilderFactory) buildArgKeys() []string {
+	return sortedKeys(f.buildArgs)
+}
 
-	// Remove the context directory
-	err = os.RemoveAll(f.context)
-	if err != nil {
-		return fmt.Errorf("failed to remove context directory: %w", err)
+// builder returns the image builder of the factory, docker if none is set
+func (f *BuilderFactory) builder() ImageBuilder {
+	if f.imageBuilder == nil {
+		return NewDockerBuilder()
 	}
-
-	return nil
+	return f.imageBuilder
 }
 
 func runCommand(cmd *exec.Cmd) error {
@@ -225,3 +371,15 @@ func runCommand(cmd *exec.Cmd) error {
 	}
 	return nil
 }
+
+// runCommandWithProgress runs the command like runCommand, calling onLine with every line of its output
+func runCommandWithProgress(cmd *exec.Cmd, onLine func(line string)) error {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&stdout, &lineWriter{onLine: onLine})
+	cmd.Stderr = io.MultiWriter(&stderr, &lineWriter{onLine: onLine})
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("command failed: %s\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+	}
+	return nil
+}
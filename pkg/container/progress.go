@@ -0,0 +1,153 @@
+package container
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Phases reported to a ProgressFunc
+const (
+	// ProgressPhaseBuild is reported while the instructions of the image are executed, each of them adding a layer
+	ProgressPhaseBuild = "build"
+	// ProgressPhaseCommit is reported while the built layers are committed to the image
+	ProgressPhaseCommit = "commit"
+	// ProgressPhasePush is reported while the layers of the image are pushed to the registry
+	ProgressPhasePush = "push"
+)
+
+// progressBufferSize is the number of progress updates that are buffered for a slow ProgressFunc, further updates are dropped
+const progressBufferSize = 64
+
+// ProgressFunc is called with the phase (see ProgressPhaseBuild, ProgressPhaseCommit and ProgressPhasePush)
+// and the percentage of the phase that is done, between 0 and 100
+type ProgressFunc func(phase string, percent float64)
+
+// buildStepRegex matches the steps of the plain progress output of buildx, e.g. '#7 [2/4] ADD ...' or '#7 [stage-0 2/4] ADD ...'
+var buildStepRegex = regexp.MustCompile(`^#\d+ \[(?:\S+ )?(\d+)/(\d+)\]`)
+
+// progress reports progress updates to a ProgressFunc without blocking the build
+// The updates are called in order in a separate goroutine, and dropped if the ProgressFunc cannot keep up
+type progress struct {
+	updates chan progressUpdate
+	mu      sync.Mutex
+	closed  bool
+
+	// linesMu guards the parsing of lines, which are written by the stdout and stderr of a command concurrently
+	linesMu sync.Mutex
+	// layers are the layers seen in the output of the push, and if they are pushed
+	layers map[string]bool
+}
+
+// progressUpdate is a single call of a ProgressFunc
+type progressUpdate struct {
+	phase   string
+	percent float64
+}
+
+// newProgress starts reporting progress updates to fn, which may be nil
+func newProgress(fn ProgressFunc) *progress {
+	p := &progress{
+		layers: make(map[string]bool),
+	}
+	if fn == nil {
+		return p
+	}
+	p.updates = make(chan progressUpdate, progressBufferSize)
+	go func() {
+		for update := range p.updates {
+			fn(update.phase, update.percent)
+		}
+	}()
+	return p
+}
+
+// report queues a progress update, or drops it if the buffer is full
+func (p *progress) report(phase string, percent float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.updates == nil || p.closed {
+		return
+	}
+	select {
+	case p.updates <- progressUpdate{phase: phase, percent: percent}:
+	default:
+	}
+}
+
+// close stops reporting after the queued updates are delivered, without waiting for them
+func (p *progress) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.updates == nil || p.closed {
+		return
+	}
+	p.closed = true
+	close(p.updates)
+}
+
+// buildLine reports the progress of a line of the plain progress output of buildx
+func (p *progress) buildLine(line string) {
+	p.linesMu.Lock()
+	defer p.linesMu.Unlock()
+	if match := buildStepRegex.FindStringSubmatch(line); match != nil {
+		step, _ := strconv.Atoi(match[1])
+		total, _ := strconv.Atoi(match[2])
+		if total > 0 {
+			p.report(ProgressPhaseBuild, float64(step-1)*100/float64(total))
+		}
+		return
+	}
+	if strings.Contains(line, "exporting to image") {
+		p.report(ProgressPhaseBuild, 100)
+		p.report(ProgressPhaseCommit, 0)
+	}
+}
+
+// pushLine reports the progress of a line of the output of docker push, e.g. '5f70bf18a086: Pushed'
+func (p *progress) pushLine(line string) {
+	p.linesMu.Lock()
+	defer p.linesMu.Unlock()
+	layer, status, found := strings.Cut(strings.TrimSpace(line), ": ")
+	if !found || strings.Contains(layer, " ") {
+		return
+	}
+	switch {
+	case status == "Preparing" || status == "Waiting":
+		if _, ok := p.layers[layer]; !ok {
+			p.layers[layer] = false
+		}
+	case status == "Pushed" || status == "Layer already exists" || strings.HasPrefix(status, "Mounted from"):
+		p.layers[layer] = true
+	default:
+		return
+	}
+	pushed := 0
+	for _, done := range p.layers {
+		if done {
+			pushed++
+		}
+	}
+	p.report(ProgressPhasePush, float64(pushed)*100/float64(len(p.layers)))
+}
+
+// lineWriter calls a function with every line written to it
+type lineWriter struct {
+	onLine func(line string)
+	buf    []byte
+}
+
+// Write calls the function with every complete line of the data, buffering incomplete lines
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		index := bytes.IndexByte(w.buf, '\n')
+		if index < 0 {
+			return len(data), nil
+		}
+		w.onLine(strings.TrimRight(string(w.buf[:index]), "\r"))
+		w.buf = w.buf[index+1:]
+	}
+}
@@ -0,0 +1,103 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+)
+
+// DefaultPushRetries is the number of times a push that failed with a transient error is retried by default
+const DefaultPushRetries = 3
+
+// pushRetryBaseDelay is the delay before the first retry of a push, it is doubled for every further retry
+var pushRetryBaseDelay = 2 * time.Second
+
+// ErrPushUnauthorized is wrapped by the error of a push the registry rejected because of missing or invalid credentials,
+// retrying the push or the test run does not fix it
+var ErrPushUnauthorized = errors.New("registry rejected the credentials of the push")
+
+// ErrPushNetwork is wrapped by the error of a push that failed with a transient error, e.g. a timeout or a 5xx status of
+// the registry, for every retry, so that the test run may succeed when it is retried later
+var ErrPushNetwork = errors.New("registry is unreachable or failing")
+
+// authPushErrors are parts of the output of pushes the registry rejected because of missing or invalid credentials
+var authPushErrors = []string{
+	"unauthorized", "forbidden", "denied", "authentication required", "status code 401", "status code 403",
+}
+
+// quotaPushErrors are parts of the output of pushes the registry rejected because a quota or rate limit is exceeded
+var quotaPushErrors = []string{
+	"quota", "toomanyrequests", "too many requests", "status code 429",
+}
+
+// transientPushErrors are parts of the output of failed pushes that are caused by the network or the registry and may succeed when retried
+var transientPushErrors = []string{
+	"500 internal server error", "502 bad gateway", "503 service unavailable", "504 gateway timeout",
+	"status code 500", "status code 502", "status code 503", "status code 504",
+	"connection reset", "connection refused", "broken pipe", "i/o timeout", "tls handshake timeout",
+	"unexpected eof", "server misbehaving", "timeout exceeded",
+}
+
+// IsTransientPushError returns true if the push failed because of the network or a server error of the registry (5xx),
+// but not because of authentication (401, 403) or an exceeded quota, which retrying does not fix
+func IsTransientPushError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	if containsAny(message, authPushErrors) || containsAny(message, quotaPushErrors) {
+		return false
+	}
+	return containsAny(message, transientPushErrors)
+}
+
+// isAuthPushError returns true if the registry rejected the push because of missing or invalid credentials
+func isAuthPushError(err error) bool {
+	return containsAny(strings.ToLower(err.Error()), authPushErrors)
+}
+
+// containsAny returns true if the message contains any of the parts
+func containsAny(message string, parts []string) bool {
+	for _, part := range parts {
+		if strings.Contains(message, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPush calls push until it succeeds, fails with an error that is not transient, the retries are exhausted or the context is done
+// The delay between attempts starts at pushRetryBaseDelay and doubles with every attempt
+// Authentication errors are wrapped with ErrPushUnauthorized, and transient errors that persist through all retries with ErrPushNetwork
+func retryPush(ctx context.Context, retries int, imageName string, push func() error) error {
+	delay := pushRetryBaseDelay
+	attempts := retries + 1
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = push()
+		if err == nil {
+			return nil
+		}
+		if isAuthPushError(err) {
+			return fmt.Errorf("%w: %w", ErrPushUnauthorized, err)
+		}
+		if !IsTransientPushError(err) {
+			return err
+		}
+		if attempt == attempts {
+			break
+		}
+		log.Warnf("Pushing image %s failed with a transient error (attempt %d/%d), retrying in %s: %v", imageName, attempt, attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("giving up pushing image %s after %d attempts: %w", imageName, attempt, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return fmt.Errorf("%w: giving up pushing image %s after %d attempts: %w", ErrPushNetwork, imageName, attempts, err)
+}
@@ -0,0 +1,60 @@
+// Package helm provides utilities for rendering Helm charts with the helm CLI.
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+)
+
+// Template renders the chart with the given values and returns the resulting multi-document YAML manifest.
+// If repoURL is empty, the chart is resolved by helm, e.g. as a local path or an OCI reference.
+// Custom resource definitions of the chart are not rendered.
+func Template(releaseName, namespace, repoURL, chart, version string, values map[string]interface{}) ([]byte, error) {
+	if _, err := exec.LookPath("helm"); err != nil {
+		return nil, fmt.Errorf("helm CLI not found in PATH: %w", err)
+	}
+
+	args := []string{"template", releaseName, chart, "--namespace", namespace}
+	if repoURL != "" {
+		args = append(args, "--repo", repoURL)
+	}
+	if version != "" {
+		args = append(args, "--version", version)
+	}
+
+	if len(values) > 0 {
+		// JSON is valid YAML, so the values can be passed to helm as values file
+		valuesBytes, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal values: %w", err)
+		}
+		valuesFile, err := os.CreateTemp("", "knuu-values-*.json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create values file: %w", err)
+		}
+		defer os.Remove(valuesFile.Name())
+		if _, err := valuesFile.Write(valuesBytes); err != nil {
+			valuesFile.Close()
+			return nil, fmt.Errorf("failed to write values file: %w", err)
+		}
+		if err := valuesFile.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close values file: %w", err)
+		}
+		args = append(args, "--values", valuesFile.Name())
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("helm", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	log.Debugf("Rendering chart %s with: helm %v", chart, args)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to render chart %s: %s\nstderr: %s", chart, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
@@ -2,75 +2,192 @@
 package k8s
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// Clientset is a global variable that holds a kubernetes clientset.
-var clientset *kubernetes.Clientset
+// Client is a Kubernetes client bound to the namespace all resources are deployed to.
+type Client struct {
+	clientset      kubernetes.Interface
+	dynamicClient  dynamic.Interface
+	config         *rest.Config
+	namespace      string
+	restMapper     meta.ResettableRESTMapper
+	restMapperOnce sync.Once
+	cache          *apiCache
+	cacheMu        sync.RWMutex
+}
 
-// namespace is the current namespace in use by the Kubernetes client.
-var namespace = ""
+// NewClient sets up a Kubernetes client with the appropriate configuration.
+// If namespace is empty, the namespace of the pod (in a cluster), the KNUU_NAMESPACE environment variable or 'test' is used.
+func NewClient(namespace string) (*Client, error) {
+	return NewClientWithRateLimit(namespace, 0, 0)
+}
 
-// Initialize sets up the Kubernetes client with the appropriate configuration.
-func Initialize() error {
+// NewClientWithRateLimit sets up a Kubernetes client like NewClient, which sends at most qps requests per second to the API server
+// on average and at most burst requests at once, see WithRateLimit.
+func NewClientWithRateLimit(namespace string, qps float32, burst int) (*Client, error) {
 	k8sConfig, err := getClusterConfig()
 	if err != nil {
-		return fmt.Errorf("retrieving the Kubernetes config: %w", err)
+		return nil, fmt.Errorf("retrieving the Kubernetes config: %w", err)
+	}
+	return NewClientFromConfig(WithRateLimit(k8sConfig, qps, burst), namespace)
+}
+
+// WithRateLimit returns a copy of the config with the client-side rate limit of requests to the API server.
+// If qps or burst is zero, the default of client-go (5 requests per second and bursts of 10) is kept, which throttles
+// tests with many instances.
+func WithRateLimit(k8sConfig *rest.Config, qps float32, burst int) *rest.Config {
+	k8sConfig = rest.CopyConfig(k8sConfig)
+	if qps > 0 {
+		k8sConfig.QPS = qps
+	}
+	if burst > 0 {
+		k8sConfig.Burst = burst
 	}
+	return k8sConfig
+}
 
-	clientset, err = kubernetes.NewForConfig(k8sConfig)
+// NewClientFromConfig sets up a Kubernetes client for the cluster of the given config, e.g. as returned by LoadConfig.
+// If namespace is empty, the default namespace is used as in NewClient.
+func NewClientFromConfig(k8sConfig *rest.Config, namespace string) (*Client, error) {
+	clientset, err := kubernetes.NewForConfig(k8sConfig)
 	if err != nil {
-		return fmt.Errorf("creating clientset for Kubernetes: %w", err)
+		return nil, fmt.Errorf("creating clientset for Kubernetes: %w", err)
 	}
 
-	// Check if the program is running in a Kubernetes cluster environment
-	if isClusterEnvironment() {
-		// Read the namespace from the pod's spec
-		namespaceBytes, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	dynamicClient, err := dynamic.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating dynamic client for Kubernetes: %w", err)
+	}
+
+	if namespace == "" {
+		namespace, err = defaultNamespace()
 		if err != nil {
-			return fmt.Errorf("reading namespace from pod's spec: %w", err)
+			return nil, err
 		}
-		setNamespace(string(namespaceBytes))
-	} else {
-		// Read the namespace from KNUU_NAMESPACE environment variable
-		if os.Getenv("KNUU_NAMESPACE") != "" {
-			setNamespace(os.Getenv("KNUU_NAMESPACE"))
-		} else {
-			setNamespace("test")
+	}
+
+	return &Client{
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		config:        k8sConfig,
+		namespace:     namespace,
+	}, nil
+}
+
+// NewClientWithClientset sets up a Kubernetes client using the given clientset, e.g. a fake clientset in unit tests.
+// The dynamic client is optional, without it manifests cannot be applied and pod metrics cannot be read.
+// Executing commands in pods and port forwarding require a REST config, so they are not supported by such a client.
+// If namespace is empty, the default namespace is used as in NewClient.
+func NewClientWithClientset(clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string) (*Client, error) {
+	if clientset == nil {
+		return nil, fmt.Errorf("clientset must not be nil")
+	}
+
+	if namespace == "" {
+		var err error
+		namespace, err = defaultNamespace()
+		if err != nil {
+			return nil, err
 		}
 	}
-	return nil
+
+	return &Client{
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		namespace:     namespace,
+	}, nil
+}
+
+// LoadConfig loads the Kubernetes config from the given kubeconfig file, using the given context.
+// If the path is empty, the default kubeconfig is used, if the context is empty, the current context is used.
+func LoadConfig(kubeconfigPath, contextName string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfigPath != "" {
+		loadingRules.ExplicitPath = kubeconfigPath
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+	k8sConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading the Kubernetes config: %w", err)
+	}
+	return k8sConfig, nil
 }
 
 // IsInitialized checks if the Kubernetes clientset has been initialized.
-func IsInitialized() bool {
-	return clientset != nil
+func (c *Client) IsInitialized() bool {
+	return c != nil && c.clientset != nil
 }
 
-// Namespace returns the current namespace in use.
-func Namespace() string {
-	return namespace
+// Namespace returns the namespace of the client.
+func (c *Client) Namespace() string {
+	if c == nil {
+		return ""
+	}
+	return c.namespace
 }
 
 // Clientset returns the Kubernetes clientset.
-func Clientset() *kubernetes.Clientset {
-	return clientset
+func (c *Client) Clientset() kubernetes.Interface {
+	return c.clientset
 }
 
-// setNamespace updates the namespace to the provided string.
-func setNamespace(newNamespace string) {
-	namespace = newNamespace
+// DynamicClient returns the dynamic Kubernetes client, used for resources that are not known at compile time, e.g. from manifests.
+func (c *Client) DynamicClient() dynamic.Interface {
+	return c.dynamicClient
+}
+
+// checkDynamicClient returns an error if the client has no dynamic client.
+func (c *Client) checkDynamicClient() error {
+	if c.dynamicClient == nil {
+		return fmt.Errorf("the Kubernetes client has no dynamic client")
+	}
+	return nil
+}
+
+// checkConfig returns an error if the client has no REST config, which is required to stream from pods.
+func (c *Client) checkConfig() error {
+	if c.config == nil {
+		return fmt.Errorf("the Kubernetes client has no REST config, which is required to stream from pods")
+	}
+	return nil
+}
+
+// defaultNamespace returns the namespace used if none is given.
+func defaultNamespace() (string, error) {
+	// Check if the program is running in a Kubernetes cluster environment
+	if IsClusterEnvironment() {
+		// Read the namespace from the pod's spec
+		namespaceBytes, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err != nil {
+			return "", fmt.Errorf("reading namespace from pod's spec: %w", err)
+		}
+		return string(namespaceBytes), nil
+	}
+	// Read the namespace from KNUU_NAMESPACE environment variable
+	if os.Getenv("KNUU_NAMESPACE") != "" {
+		return os.Getenv("KNUU_NAMESPACE"), nil
+	}
+	return "test", nil
 }
 
 // isClusterEnvironment checks if the program is running in a Kubernetes cluster.
-func isClusterEnvironment() bool {
+func IsClusterEnvironment() bool {
 	tokenPath := "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	certPath := "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
 
@@ -87,7 +204,7 @@ func isClusterEnvironment() bool {
 // getClusterConfig returns the appropriate Kubernetes cluster configuration.
 func getClusterConfig() (*rest.Config, error) {
 	// Check if the program is running in a Kubernetes cluster environment
-	if isClusterEnvironment() {
+	if IsClusterEnvironment() {
 		return rest.InClusterConfig()
 	}
 
@@ -100,3 +217,35 @@ func getClusterConfig() (*rest.Config, error) {
 func isNotFound(err error) bool {
 	return apierrs.IsNotFound(err)
 }
+
+// IsNotFoundError checks if the error is a NotFound error, e.g. of deleting an object that was already deleted.
+func IsNotFoundError(err error) bool {
+	return apierrs.IsNotFound(err)
+}
+
+// IsAlreadyExistsError checks if the error is an AlreadyExists error, e.g. of creating an object that was created by a previous attempt.
+func IsAlreadyExistsError(err error) bool {
+	return apierrs.IsAlreadyExists(err)
+}
+
+// IsRetriableError checks if the error is transient and the request may succeed when retried.
+// Timeouts, throttling (429), server errors (5xx) and connection errors are considered retriable,
+// while validation and other client errors (4xx) are not.
+func IsRetriableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if apierrs.IsTooManyRequests(err) || apierrs.IsServerTimeout(err) || apierrs.IsTimeout(err) ||
+		apierrs.IsInternalError(err) || apierrs.IsServiceUnavailable(err) || apierrs.IsUnexpectedServerError(err) {
+		return true
+	}
+	var status apierrs.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= http.StatusInternalServerError
+	}
+	if errors.Is(err, context.DeadlineExceeded) || utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
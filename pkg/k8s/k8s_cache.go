@@ -0,0 +1,137 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheSyncTimeout is the time StartCache waits for the initial list of the pods and services.
+const cacheSyncTimeout = time.Minute
+
+// apiCache is an informer cache of the pods and services in a namespace that carry a set of labels.
+type apiCache struct {
+	namespace string
+	selector  labels.Selector
+	pods      corelisters.PodLister
+	services  corelisters.ServiceLister
+	stop      chan struct{}
+}
+
+// StartCache starts an informer cache of the pods and services in the namespace of the client that carry all of the labels,
+// e.g. the labels of a test run, so that ListPods and GetServiceIP for objects with these labels are served from memory
+// instead of the API server. The cache is updated by a watch, so a read right after a change may not reflect it yet.
+// Services missing from the cache are read from the API server. A running cache is replaced.
+func (c *Client) StartCache(cacheLabels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	selector := labels.SelectorFromSet(cacheLabels)
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0,
+		informers.WithNamespace(c.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector.String()
+		}),
+	)
+	podInformer := factory.Core().V1().Pods()
+	serviceInformer := factory.Core().V1().Services()
+	// The informers must be requested before the factory is started, so that it starts them
+	podsSynced := podInformer.Informer().HasSynced
+	servicesSynced := serviceInformer.Informer().HasSynced
+
+	stop := make(chan struct{})
+	factory.Start(stop)
+	ctx, cancel := context.WithTimeout(context.Background(), cacheSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(ctx.Done(), podsSynced, servicesSynced) {
+		close(stop)
+		return fmt.Errorf("timeout while waiting for the cache of pods and services with labels %s to sync", selector)
+	}
+
+	c.cacheMu.Lock()
+	previous := c.cache
+	c.cache = &apiCache{
+		namespace: c.namespace,
+		selector:  selector,
+		pods:      podInformer.Lister(),
+		services:  serviceInformer.Lister(),
+		stop:      stop,
+	}
+	c.cacheMu.Unlock()
+	if previous != nil {
+		close(previous.stop)
+	}
+	log.Debugf("Started cache of pods and services with labels %s in namespace %s", selector, c.namespace)
+	return nil
+}
+
+// StopCache stops the informer cache started by StartCache, so that all reads go to the API server again.
+func (c *Client) StopCache() {
+	if c == nil {
+		return
+	}
+	c.cacheMu.Lock()
+	apiCache := c.cache
+	c.cache = nil
+	c.cacheMu.Unlock()
+	if apiCache != nil {
+		close(apiCache.stop)
+		log.Debugf("Stopped cache of pods and services in namespace %s", apiCache.namespace)
+	}
+}
+
+// cacheFor returns the cache if it covers all objects with the labels in the namespace, i.e. if the labels include
+// the labels of the cache, or nil otherwise.
+func (c *Client) cacheFor(namespace string, objectLabels map[string]string) *apiCache {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+	if c.cache == nil || c.cache.namespace != namespace || !c.cache.selector.Matches(labels.Set(objectLabels)) {
+		return nil
+	}
+	return c.cache
+}
+
+// cachedPods returns the pods with the labels from the cache, sorted by name, and false if the cache does not cover them.
+func (c *Client) cachedPods(namespace string, podLabels map[string]string) ([]v1.Pod, bool) {
+	apiCache := c.cacheFor(namespace, podLabels)
+	if apiCache == nil {
+		return nil, false
+	}
+	cached, err := apiCache.pods.Pods(namespace).List(labels.SelectorFromSet(podLabels))
+	if err != nil {
+		return nil, false
+	}
+	// The cached objects are shared, so copies are returned
+	pods := make([]v1.Pod, 0, len(cached))
+	for _, pod := range cached {
+		pods = append(pods, *pod.DeepCopy())
+	}
+	sort.Slice(pods, func(a, b int) bool {
+		return pods[a].Name < pods[b].Name
+	})
+	return pods, true
+}
+
+// cachedService returns the service from the cache, and false if there is no cache or the service is not in it.
+func (c *Client) cachedService(namespace, name string) (*v1.Service, bool) {
+	c.cacheMu.RLock()
+	apiCache := c.cache
+	c.cacheMu.RUnlock()
+	if apiCache == nil || apiCache.namespace != namespace {
+		return nil, false
+	}
+	svc, err := apiCache.services.Services(namespace).Get(name)
+	if err != nil {
+		return nil, false
+	}
+	return svc.DeepCopy(), true
+}
@@ -0,0 +1,47 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// CreateOrUpdateClusterRole creates a clusterRole with the given rules, or replaces the rules of an existing clusterRole.
+// It returns the clusterRole, e.g. to make it the owner of other cluster-scoped objects.
+func (c *Client) CreateOrUpdateClusterRole(name string, labels map[string]string, rules []rbacv1.PolicyRule) (*rbacv1.ClusterRole, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	clusterRole, err := c.clientset.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{})
+	if isNotFound(err) {
+		clusterRole = &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+			Rules: rules,
+		}
+		return c.clientset.RbacV1().ClusterRoles().Create(ctx, clusterRole, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	clusterRole.Rules = rules
+	return c.clientset.RbacV1().ClusterRoles().Update(ctx, clusterRole, metav1.UpdateOptions{})
+}
+
+// DeleteClusterRole deletes a clusterRole, the objects it owns are deleted by the garbage collector.
+func (c *Client) DeleteClusterRole(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return c.clientset.RbacV1().ClusterRoles().Delete(ctx, name, metav1.DeleteOptions{})
+}
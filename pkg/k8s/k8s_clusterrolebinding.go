@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// CreateClusterRoleBinding creates a clusterRoleBinding of the cluster role to the service account in the namespace
+func (c *Client) CreateClusterRoleBinding(name string, labels map[string]string, clusterRole, serviceAccount, namespace string) error {
+	return c.createClusterRoleBinding(name, labels, clusterRole, serviceAccount, namespace, nil)
+}
+
+// CreateClusterRoleBindingOwnedBy creates a clusterRoleBinding of the cluster role to the service account in the namespace,
+// which is owned by the cluster role, so that it is deleted by the garbage collector together with the cluster role
+func (c *Client) CreateClusterRoleBindingOwnedBy(name string, labels map[string]string, clusterRole *rbacv1.ClusterRole, serviceAccount, namespace string) error {
+	owner := metav1.NewControllerRef(clusterRole, rbacv1.SchemeGroupVersion.WithKind("ClusterRole"))
+	return c.createClusterRoleBinding(name, labels, clusterRole.Name, serviceAccount, namespace, owner)
+}
+
+// createClusterRoleBinding creates a clusterRoleBinding of the cluster role to the service account in the namespace,
+// which is owned by the owner if it is not nil
+func (c *Client) createClusterRoleBinding(name string, labels map[string]string, clusterRole, serviceAccount, namespace string, owner *metav1.OwnerReference) error {
+
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterRole,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      serviceAccount,
+				Namespace: namespace,
+			},
+		},
+	}
+
+	if owner != nil {
+		clusterRoleBinding.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	if _, err := c.clientset.RbacV1().ClusterRoleBindings().Create(ctx, clusterRoleBinding, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteClusterRoleBinding deletes a clusterRoleBinding
+func (c *Client) DeleteClusterRoleBinding(name string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	if err := c.clientset.RbacV1().ClusterRoleBindings().Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -3,7 +3,7 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"github.com/sirupsen/logrus"
+	"github.com/celestiaorg/knuu/pkg/log"
 	appv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -11,14 +11,14 @@ import (
 )
 
 // DaemonSetExists checks if a daemonset exists.
-func DaemonSetExists(namespace, name string) (bool, error) {
+func (c *Client) DaemonSetExists(namespace, name string) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return false, fmt.Errorf("knuu is not initialized")
 	}
-	_, err := Clientset().AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	_, err := c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		if isNotFound(err) {
 			return false, nil
@@ -29,14 +29,14 @@ func DaemonSetExists(namespace, name string) (bool, error) {
 }
 
 // GetDaemonSet retrieves a daemonset.
-func GetDaemonSet(namespace, name string) (*appv1.DaemonSet, error) {
+func (c *Client) GetDaemonSet(namespace, name string) (*appv1.DaemonSet, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	ds, err := Clientset().AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	ds, err := c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error getting daemonset %s: %w", name, err)
 	}
@@ -44,7 +44,7 @@ func GetDaemonSet(namespace, name string) (*appv1.DaemonSet, error) {
 }
 
 // CreateDaemonSet creates a new daemonset.
-func CreateDaemonSet(namespace, name string, labels map[string]string, initContainers []v1.Container, containers []v1.Container) (*appv1.DaemonSet, error) {
+func (c *Client) CreateDaemonSet(namespace, name string, labels map[string]string, initContainers []v1.Container, containers []v1.Container) (*appv1.DaemonSet, error) {
 
 	ds, err := prepareDaemonSet(namespace, name, labels, initContainers, containers)
 	if err != nil {
@@ -54,19 +54,19 @@ func CreateDaemonSet(namespace, name string, labels map[string]string, initConta
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	created, err := Clientset().AppsV1().DaemonSets(namespace).Create(ctx, ds, metav1.CreateOptions{})
+	created, err := c.clientset.AppsV1().DaemonSets(namespace).Create(ctx, ds, metav1.CreateOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error creating daemonset %s: %w", name, err)
 	}
-	logrus.Debugf("DaemonSet %s created in namespace %s", name, namespace)
+	log.Debugf("DaemonSet %s created in namespace %s", name, namespace)
 	return created, nil
 }
 
 // UpdateDaemonSet updates an existing daemonset.
-func UpdateDaemonSet(namespace, name string, labels map[string]string, initContainers []v1.Container, containers []v1.Container) (*appv1.DaemonSet, error) {
+func (c *Client) UpdateDaemonSet(namespace, name string, labels map[string]string, initContainers []v1.Container, containers []v1.Container) (*appv1.DaemonSet, error) {
 
 	ds, err := prepareDaemonSet(namespace, name, labels, initContainers, containers)
 	if err != nil {
@@ -76,29 +76,29 @@ func UpdateDaemonSet(namespace, name string, labels map[string]string, initConta
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	updated, err := Clientset().AppsV1().DaemonSets(namespace).Update(ctx, ds, metav1.UpdateOptions{})
+	updated, err := c.clientset.AppsV1().DaemonSets(namespace).Update(ctx, ds, metav1.UpdateOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error updating daemonset %s: %w", name, err)
 	}
-	logrus.Debugf("DaemonSet %s updated in namespace %s", name, namespace)
+	log.Debugf("DaemonSet %s updated in namespace %s", name, namespace)
 	return updated, nil
 }
 
 // DeleteDaemonSet deletes an existing daemonset.
-func DeleteDaemonSet(namespace, name string) error {
+func (c *Client) DeleteDaemonSet(namespace, name string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if err := Clientset().AppsV1().DaemonSets(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+	if err := c.clientset.AppsV1().DaemonSets(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
 		return fmt.Errorf("error deleting daemonset %s: %w", name, err)
 	}
-	logrus.Debugf("DaemonSet %s deleted in namespace %s", name, namespace)
+	log.Debugf("DaemonSet %s deleted in namespace %s", name, namespace)
 	return nil
 }
 
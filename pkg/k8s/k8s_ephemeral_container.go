@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AddEphemeralContainer attaches an ephemeral container with the given image and command to the running pod, targeting
+// the process namespace of the target container, and waits until it is running or the context is done.
+func (c *Client) AddEphemeralContainer(ctx context.Context, namespace, podName, targetContainer, name, image string, command []string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	pod, err := c.getPod(namespace, podName)
+	if err != nil {
+		return err
+	}
+
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, v1.EphemeralContainer{
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:    name,
+			Image:   image,
+			Command: command,
+			Stdin:   true,
+			TTY:     true,
+		},
+		TargetContainerName: targetContainer,
+	})
+	updateCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+	pod, err = c.clientset.CoreV1().Pods(namespace).UpdateEphemeralContainers(updateCtx, podName, pod, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error adding ephemeral container %s to pod %s: %w", name, podName, err)
+	}
+	log.Debugf("Ephemeral container %s added to pod %s in namespace %s", name, podName, namespace)
+
+	return waitFor(ctx, pod.Labels, c.clientset.CoreV1().Pods(namespace).Watch, func() (bool, error) {
+		pod, err := c.getPod(namespace, podName)
+		if err != nil {
+			return false, err
+		}
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name != name {
+				continue
+			}
+			if status.State.Terminated != nil {
+				return false, fmt.Errorf("ephemeral container %s terminated: %s", name, status.State.Terminated.Reason)
+			}
+			if waiting := status.State.Waiting; waiting != nil && (waiting.Reason == "ErrImagePull" || waiting.Reason == "ImagePullBackOff") {
+				return false, fmt.Errorf("ephemeral container %s cannot pull image %s: %s", name, image, waiting.Message)
+			}
+			return status.State.Running != nil, nil
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,200 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// WatchEvents streams the events of the objects with the given names in the given namespace.
+// Events that already exist are sent first, followed by new and updated ones.
+// The events of every object are watched with a field selector on its name, and a watch that is closed by the API server
+// is re-established from the last received event, so that the stream only ends when the context is done.
+// The returned channel is closed when the context is done.
+func (c *Client) WatchEvents(ctx context.Context, namespace string, objectNames []string) (<-chan v1.Event, error) {
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	watchers := make([]watch.Interface, 0, len(objectNames))
+	for _, name := range objectNames {
+		watcher, err := c.watchEventsOf(ctx, namespace, name, "")
+		if err != nil {
+			for _, started := range watchers {
+				started.Stop()
+			}
+			return nil, fmt.Errorf("error watching events of %s in namespace %s: %w", name, namespace, err)
+		}
+		watchers = append(watchers, watcher)
+	}
+
+	events := make(chan v1.Event)
+	var wg sync.WaitGroup
+	for j, name := range objectNames {
+		wg.Add(1)
+		go func(name string, watcher watch.Interface) {
+			defer wg.Done()
+			c.streamEventsOf(ctx, namespace, name, watcher, events)
+		}(name, watchers[j])
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
+
+// watchEventsOf watches the events of the object with the given name, starting after the given resource version if it is not empty.
+func (c *Client) watchEventsOf(ctx context.Context, namespace, name, resourceVersion string) (watch.Interface, error) {
+	return c.clientset.CoreV1().Events(namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("involvedObject.name", name).String(),
+		ResourceVersion: resourceVersion,
+	})
+}
+
+// streamEventsOf sends the events of the watcher to the channel until the context is done.
+// If the watch is closed, it is re-established from the last received event, or from the start if the watch failed,
+// in which case events that were already sent are skipped.
+func (c *Client) streamEventsOf(ctx context.Context, namespace, name string, watcher watch.Interface, events chan<- v1.Event) {
+	// sent are the resource versions of the events that were sent, by the UID of the event
+	sent := make(map[types.UID]string)
+	resourceVersion := ""
+	for {
+		resourceVersion = forwardEvents(ctx, name, watcher, events, sent, resourceVersion)
+		watcher.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			var err error
+			watcher, err = c.watchEventsOf(ctx, namespace, name, resourceVersion)
+			if err == nil {
+				break
+			}
+			log.Debugf("Cannot re-establish watch for events of %s, retrying: %v", name, err)
+			// The resource version may be too old to resume from, so the next watch starts from the beginning
+			resourceVersion = ""
+		}
+		log.Debugf("Watch for events of %s was closed, re-established it", name)
+	}
+}
+
+// forwardEvents sends the added and modified events of the object with the given name from the watcher to the channel,
+// until the watch is closed or fails or the context is done.
+// It returns the resource version to resume the watch from, which is empty if the watch failed.
+func forwardEvents(ctx context.Context, name string, watcher watch.Interface, events chan<- v1.Event, sent map[types.UID]string, resourceVersion string) string {
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion
+		case result, ok := <-watcher.ResultChan():
+			if !ok {
+				return resourceVersion
+			}
+			if result.Type == watch.Error {
+				return ""
+			}
+			if result.Type != watch.Added && result.Type != watch.Modified {
+				continue
+			}
+			event, ok := result.Object.(*v1.Event)
+			// Field selectors are not supported by every client, e.g. fake clientsets
+			if !ok || event.InvolvedObject.Name != name {
+				continue
+			}
+			resourceVersion = event.ResourceVersion
+			if event.UID != "" && sent[event.UID] == event.ResourceVersion {
+				continue
+			}
+			select {
+			case events <- *event:
+				sent[event.UID] = event.ResourceVersion
+			case <-ctx.Done():
+				return resourceVersion
+			}
+		}
+	}
+}
+
+// GetWarningEvents returns the warning events of the object with the given name in the given namespace.
+func (c *Client) GetWarningEvents(namespace, objectName string) ([]v1.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	events, err := c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.name=%s,type=%s", objectName, v1.EventTypeWarning),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing events of %s in namespace %s: %w", objectName, namespace, err)
+	}
+
+	// Field selectors are not supported by every client, e.g. fake clientsets
+	warnings := make([]v1.Event, 0, len(events.Items))
+	for _, event := range events.Items {
+		if event.InvolvedObject.Name == objectName && event.Type == v1.EventTypeWarning {
+			warnings = append(warnings, event)
+		}
+	}
+	return warnings, nil
+}
+
+// GetEvents returns the events of the objects with the given names in the given namespace, oldest first.
+func (c *Client) GetEvents(namespace string, objectNames []string) ([]v1.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	events, err := c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing events in namespace %s: %w", namespace, err)
+	}
+
+	matching := make([]v1.Event, 0, len(events.Items))
+	for _, event := range events.Items {
+		if containsName(objectNames, event.InvolvedObject.Name) {
+			matching = append(matching, event)
+		}
+	}
+	sort.SliceStable(matching, func(a, b int) bool {
+		return eventTime(matching[a]).Before(eventTime(matching[b]))
+	})
+	return matching, nil
+}
+
+// eventTime returns when the event last occurred
+func eventTime(event v1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// containsName checks if the given name is part of the names
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
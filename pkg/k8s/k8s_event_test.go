@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testEvent returns an event of the object with the given name
+func testEvent(uid, resourceVersion, objectName, reason string) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: uid, Namespace: "default", UID: types.UID(uid), ResourceVersion: resourceVersion},
+		InvolvedObject: v1.ObjectReference{Name: objectName},
+		Reason:         reason,
+	}
+}
+
+func TestWatchEventsSelectsByNameAndReestablishesWatches(t *testing.T) {
+	type watchRequest struct {
+		fieldSelector   string
+		resourceVersion string
+	}
+	var mu sync.Mutex
+	var requests []watchRequest
+	watchers := map[string][]*watch.FakeWatcher{
+		"involvedObject.name=pod-0": {watch.NewFakeWithChanSize(2, false), watch.NewFakeWithChanSize(2, false), watch.NewFakeWithChanSize(2, false)},
+		"involvedObject.name=pod-1": {watch.NewFakeWithChanSize(2, false)},
+	}
+	// pod-0: the first watch is closed by the API server, the second fails, and the third starts from the beginning again
+	watchers["involvedObject.name=pod-0"][0].Add(testEvent("a", "1", "pod-0", "Scheduled"))
+	watchers["involvedObject.name=pod-0"][0].Stop()
+	watchers["involvedObject.name=pod-0"][1].Error(&metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonExpired})
+	watchers["involvedObject.name=pod-0"][2].Add(testEvent("a", "1", "pod-0", "Scheduled"))
+	watchers["involvedObject.name=pod-0"][2].Add(testEvent("b", "2", "pod-0", "Started"))
+	// Fake clientsets do not support field selectors, so events of other objects must be skipped
+	watchers["involvedObject.name=pod-1"][0].Add(testEvent("c", "3", "other", "Ignored"))
+	watchers["involvedObject.name=pod-1"][0].Add(testEvent("d", "4", "pod-1", "Pulled"))
+
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependWatchReactor("events", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		restrictions := action.(k8stesting.WatchAction).GetWatchRestrictions()
+		selector := restrictions.Fields.String()
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, watchRequest{fieldSelector: selector, resourceVersion: restrictions.ResourceVersion})
+		if len(watchers[selector]) == 0 {
+			// Further watches stay open without events
+			return true, watch.NewFake(), nil
+		}
+		watcher := watchers[selector][0]
+		watchers[selector] = watchers[selector][1:]
+		return true, watcher, nil
+	})
+	client, err := NewClientWithClientset(clientset, nil, "default")
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events, err := client.WatchEvents(ctx, "default", []string{"pod-0", "pod-1"})
+	if err != nil {
+		t.Fatalf("watching events: %v", err)
+	}
+	reasons := map[string]int{}
+	for len(reasons) < 3 {
+		select {
+		case event := <-events:
+			reasons[event.Reason]++
+		case <-ctx.Done():
+			t.Fatalf("received events %v before the timeout", reasons)
+		}
+	}
+	cancel()
+	for range events {
+	}
+
+	for _, reason := range []string{"Scheduled", "Started", "Pulled"} {
+		if reasons[reason] != 1 {
+			t.Errorf("expected event '%s' once, got %d", reason, reasons[reason])
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var pod0 []watchRequest
+	for _, request := range requests {
+		if request.fieldSelector == "involvedObject.name=pod-0" {
+			pod0 = append(pod0, request)
+		}
+	}
+	if len(pod0) < 3 {
+		t.Fatalf("expected the watch of pod-0 to be re-established twice, got requests %v", requests)
+	}
+	// The closed watch is resumed from the last event, the failed one from the beginning
+	if pod0[0].resourceVersion != "" || pod0[1].resourceVersion != "1" || pod0[2].resourceVersion != "" {
+		t.Errorf("unexpected resource versions of the watches of pod-0: %v", pod0)
+	}
+}
@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CreateHorizontalPodAutoscaler creates a HorizontalPodAutoscaler scaling the StatefulSet with the given name on its average CPU utilization.
+func (c *Client) CreateHorizontalPodAutoscaler(namespace, name string, labels map[string]string, statefulSetName string, minReplicas, maxReplicas, targetCPUPercent int32) error {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "StatefulSet",
+				Name:       statefulSetName,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: maxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{
+				{
+					Type: autoscalingv2.ResourceMetricSourceType,
+					Resource: &autoscalingv2.ResourceMetricSource{
+						Name: v1.ResourceCPU,
+						Target: autoscalingv2.MetricTarget{
+							Type:               autoscalingv2.UtilizationMetricType,
+							AverageUtilization: &targetCPUPercent,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	if _, err := c.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Create(ctx, hpa, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("error creating HorizontalPodAutoscaler %s: %w", name, err)
+	}
+
+	log.Debugf("HorizontalPodAutoscaler %s created in namespace %s", name, namespace)
+	return nil
+}
+
+// DeleteHorizontalPodAutoscaler deletes a HorizontalPodAutoscaler if it exists.
+func (c *Client) DeleteHorizontalPodAutoscaler(namespace, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	err := c.clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting HorizontalPodAutoscaler %s: %w", name, err)
+	}
+
+	log.Debugf("HorizontalPodAutoscaler %s deleted in namespace %s", name, namespace)
+	return nil
+}
@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IngressConfig contains the specifications for creating a new Ingress object
+type IngressConfig struct {
+	Namespace   string            // Kubernetes namespace of the Ingress
+	Name        string            // Name of the Ingress
+	Labels      map[string]string // Labels to apply to the Ingress
+	Annotations map[string]string // Annotations to apply to the Ingress, e.g. for the ingress controller
+	Host        string            // Host the Ingress routes
+	PathPrefix  string            // Path prefix the Ingress routes, defaults to "/" if empty
+	ServiceName string            // Name of the service the Ingress routes to
+	ServicePort int               // Port of the service the Ingress routes to
+	TLSSecret   string            // Name of an existing secret with the TLS certificate of the host, no TLS if empty
+	ClassName   string            // IngressClass of the Ingress, the cluster's default class if empty
+}
+
+// DeployIngress creates the Ingress, or updates it if it already exists.
+func (c *Client) DeployIngress(config IngressConfig) (*networkingv1.Ingress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+
+	ingress := prepareIngress(config)
+	ingresses := c.clientset.NetworkingV1().Ingresses(config.Namespace)
+	existing, err := ingresses.Get(ctx, config.Name, metav1.GetOptions{})
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("error getting ingress %s: %w", config.Name, err)
+	}
+
+	var deployed *networkingv1.Ingress
+	if err == nil {
+		ingress.ResourceVersion = existing.ResourceVersion
+		deployed, err = ingresses.Update(ctx, ingress, metav1.UpdateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error updating ingress %s: %w", config.Name, err)
+		}
+		log.Debugf("Ingress %s updated in namespace %s", config.Name, config.Namespace)
+		return deployed, nil
+	}
+
+	deployed, err = ingresses.Create(ctx, ingress, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating ingress %s: %w", config.Name, err)
+	}
+	log.Debugf("Ingress %s created in namespace %s", config.Name, config.Namespace)
+	return deployed, nil
+}
+
+// DeleteIngress deletes an Ingress if it exists.
+func (c *Client) DeleteIngress(namespace, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	err := c.clientset.NetworkingV1().Ingresses(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting ingress %s: %w", name, err)
+	}
+
+	log.Debugf("Ingress %s deleted in namespace %s", name, namespace)
+	return nil
+}
+
+// prepareIngress prepares an Ingress routing the host and path prefix to the port of the service.
+func prepareIngress(config IngressConfig) *networkingv1.Ingress {
+	pathPrefix := config.PathPrefix
+	if pathPrefix == "" {
+		pathPrefix = "/"
+	}
+	pathType := networkingv1.PathTypePrefix
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   config.Namespace,
+			Name:        config.Name,
+			Labels:      config.Labels,
+			Annotations: config.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: config.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     pathPrefix,
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: config.ServiceName,
+											Port: networkingv1.ServiceBackendPort{
+												Number: int32(config.ServicePort),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if config.ClassName != "" {
+		className := config.ClassName
+		ingress.Spec.IngressClassName = &className
+	}
+	if config.TLSSecret != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{
+				Hosts:      []string{config.Host},
+				SecretName: config.TLSSecret,
+			},
+		}
+	}
+	return ingress
+}
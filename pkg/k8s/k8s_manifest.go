@@ -0,0 +1,168 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// fieldManager is the field manager used for server-side apply.
+const fieldManager = "knuu"
+
+// AppliedObject identifies an object that was applied from a manifest.
+type AppliedObject struct {
+	GroupVersionKind schema.GroupVersionKind
+	Resource         schema.GroupVersionResource
+	Namespace        string
+	Name             string
+	// Hook is the value of the Helm hook annotation of the object, empty if it is not a Helm hook.
+	Hook string
+}
+
+// String returns the object in the form 'kind/name'.
+func (o AppliedObject) String() string {
+	return fmt.Sprintf("%s/%s", o.GroupVersionKind.Kind, o.Name)
+}
+
+// DecodeManifest decodes a multi-document YAML or JSON manifest into unstructured objects.
+// Empty documents are skipped and lists are expanded into their items.
+func DecodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		var content map[string]interface{}
+		if err := decoder.Decode(&content); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("error decoding manifest: %w", err)
+		}
+		if len(content) == 0 {
+			continue
+		}
+		obj := &unstructured.Unstructured{Object: content}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("error decoding list %s: %w", obj.GetKind(), err)
+			}
+			for i := range list.Items {
+				objects = append(objects, &list.Items[i])
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	for _, obj := range objects {
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("manifest contains an object without kind or apiVersion")
+		}
+		if obj.GetName() == "" {
+			return nil, fmt.Errorf("manifest contains a %s without name", obj.GetKind())
+		}
+	}
+	return objects, nil
+}
+
+// ApplyObject applies the object server-side into the given namespace and adds the given labels to it.
+// Cluster-scoped objects and objects of other namespaces are rejected, so that nothing leaks outside the namespace.
+func (c *Client) ApplyObject(namespace string, obj *unstructured.Unstructured, labels map[string]string) (*AppliedObject, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	if err := c.checkDynamicClient(); err != nil {
+		return nil, err
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.getRESTMapping(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return nil, fmt.Errorf("cannot apply cluster-scoped %s/%s, only namespaced objects are allowed", gvk.Kind, obj.GetName())
+	}
+	if obj.GetNamespace() != "" && obj.GetNamespace() != namespace {
+		return nil, fmt.Errorf("cannot apply %s/%s to namespace %s, only namespace %s is allowed", gvk.Kind, obj.GetName(), obj.GetNamespace(), namespace)
+	}
+	obj.SetNamespace(namespace)
+
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	for key, value := range labels {
+		objLabels[key] = value
+	}
+	obj.SetLabels(objLabels)
+
+	_, err = c.dynamicClient.Resource(mapping.Resource).Namespace(namespace).Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	if err != nil {
+		return nil, fmt.Errorf("error applying %s/%s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	log.Debugf("Applied %s/%s in namespace %s", gvk.Kind, obj.GetName(), namespace)
+	return &AppliedObject{
+		GroupVersionKind: gvk,
+		Resource:         mapping.Resource,
+		Namespace:        namespace,
+		Name:             obj.GetName(),
+		Hook:             obj.GetAnnotations()[helmHookAnnotation],
+	}, nil
+}
+
+// DeleteObject deletes an applied object if it exists.
+func (c *Client) DeleteObject(obj AppliedObject) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	if err := c.checkDynamicClient(); err != nil {
+		return err
+	}
+	err := c.dynamicClient.Resource(obj.Resource).Namespace(obj.Namespace).Delete(ctx, obj.Name, metav1.DeleteOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting %s: %w", obj, err)
+	}
+
+	log.Debugf("Deleted %s in namespace %s", obj, obj.Namespace)
+	return nil
+}
+
+// getRESTMapping returns the resource of the given kind.
+// The discovery information is refreshed once if the kind is unknown, e.g. because a CRD was installed recently.
+func (c *Client) getRESTMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	// The rest mapper maps kinds to resources, it is created on first use
+	c.restMapperOnce.Do(func() {
+		c.restMapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(c.clientset.Discovery()))
+	})
+	mapping, err := c.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		c.restMapper.Reset()
+		mapping, err = c.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding resource of kind %s: %w", gvk, err)
+	}
+	return mapping, nil
+}
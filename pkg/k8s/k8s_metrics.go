@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrMetricsUnavailable is returned if the metrics.k8s.io API is not served, e.g. because metrics-server is not installed.
+var ErrMetricsUnavailable = errors.New("metrics API (metrics.k8s.io) is not available, is metrics-server installed?")
+
+// podMetricsResource is the resource of the pod metrics of metrics-server.
+var podMetricsResource = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+
+// PodMetrics is the current resource usage of a pod, summed over its containers.
+type PodMetrics struct {
+	Name      string
+	CPU       resource.Quantity
+	Memory    resource.Quantity
+	Timestamp time.Time
+}
+
+// GetPodMetrics returns the current resource usage of the pods with the given labels.
+// It returns ErrMetricsUnavailable if the metrics API is not served.
+func (c *Client) GetPodMetrics(namespace string, labels map[string]string) ([]PodMetrics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	if err := c.checkDynamicClient(); err != nil {
+		return nil, err
+	}
+	list, err := c.dynamicClient.Resource(podMetricsResource).Namespace(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: k8slabels.SelectorFromSet(labels).String(),
+	})
+	if err != nil {
+		if isNotFound(err) || apierrs.IsServiceUnavailable(err) || meta.IsNoMatchError(err) {
+			return nil, fmt.Errorf("%w: %v", ErrMetricsUnavailable, err)
+		}
+		return nil, fmt.Errorf("error getting pod metrics in namespace %s: %w", namespace, err)
+	}
+
+	metrics := make([]PodMetrics, 0, len(list.Items))
+	for _, item := range list.Items {
+		podMetrics, err := parsePodMetrics(item)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, podMetrics)
+	}
+	return metrics, nil
+}
+
+// parsePodMetrics sums the usage of all containers of a PodMetrics object.
+func parsePodMetrics(item unstructured.Unstructured) (PodMetrics, error) {
+	podMetrics := PodMetrics{Name: item.GetName()}
+	if timestamp, found, _ := unstructured.NestedString(item.Object, "timestamp"); found {
+		podMetrics.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
+	}
+
+	containers, _, err := unstructured.NestedSlice(item.Object, "containers")
+	if err != nil {
+		return podMetrics, fmt.Errorf("error parsing metrics of pod %s: %w", item.GetName(), err)
+	}
+	for _, container := range containers {
+		containerMap, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		usage, _, _ := unstructured.NestedStringMap(containerMap, "usage")
+		for name, target := range map[string]*resource.Quantity{"cpu": &podMetrics.CPU, "memory": &podMetrics.Memory} {
+			value, ok := usage[name]
+			if !ok {
+				continue
+			}
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil {
+				return podMetrics, fmt.Errorf("error parsing %s usage '%s' of pod %s: %w", name, value, item.GetName(), err)
+			}
+			target.Add(quantity)
+		}
+	}
+	return podMetrics, nil
+}
@@ -0,0 +1,60 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CreateNamespace creates a namespace with the given labels.
+// It returns false without error if the namespace already exists.
+func (c *Client) CreateNamespace(name string, labels map[string]string) (bool, error) {
+	namespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return false, fmt.Errorf("knuu is not initialized")
+	}
+	if _, err := c.clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil {
+		if apierrs.IsAlreadyExists(err) {
+			log.Debugf("Namespace %s already exists", name)
+			return false, nil
+		}
+		return false, fmt.Errorf("error creating namespace %s: %w", name, err)
+	}
+
+	log.Debugf("Namespace %s created", name)
+	return true, nil
+}
+
+// DeleteNamespace deletes a namespace and all resources in it if it exists.
+func (c *Client) DeleteNamespace(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	err := c.clientset.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting namespace %s: %w", name, err)
+	}
+
+	log.Debugf("Namespace %s deleted", name)
+	return nil
+}
@@ -9,7 +9,7 @@ import (
 )
 
 // CreateNetworkPolicy creates a new NetworkPolicy resource.
-func CreateNetworkPolicy(namespace string, name string, selectorMap map[string]string, ingressSelectorMap map[string]string, egressSelectorMap map[string]string) error {
+func (c *Client) CreateNetworkPolicy(namespace string, name string, labels map[string]string, selectorMap map[string]string, ingressSelectorMap map[string]string, egressSelectorMap map[string]string) error {
 	var ingress []v1.NetworkPolicyIngressRule
 	if ingressSelectorMap != nil {
 		ingress = []v1.NetworkPolicyIngressRule{
@@ -44,6 +44,7 @@ func CreateNetworkPolicy(namespace string, name string, selectorMap map[string]s
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: namespace,
 			Name:      name,
+			Labels:    labels,
 		},
 		Spec: v1.NetworkPolicySpec{
 			PodSelector: metav1.LabelSelector{
@@ -61,10 +62,10 @@ func CreateNetworkPolicy(namespace string, name string, selectorMap map[string]s
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	np, err := Clientset().NetworkingV1().NetworkPolicies(namespace).Create(ctx, np, metav1.CreateOptions{})
+	np, err := c.clientset.NetworkingV1().NetworkPolicies(namespace).Create(ctx, np, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("error creating network policy %s: %w", name, err)
 	}
@@ -73,14 +74,14 @@ func CreateNetworkPolicy(namespace string, name string, selectorMap map[string]s
 }
 
 // DeleteNetworkPolicy removes a NetworkPolicy resource.
-func DeleteNetworkPolicy(namespace string, name string) error {
+func (c *Client) DeleteNetworkPolicy(namespace string, name string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	err := Clientset().NetworkingV1().NetworkPolicies(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	err := c.clientset.NetworkingV1().NetworkPolicies(namespace).Delete(ctx, name, metav1.DeleteOptions{})
 	if err != nil {
 		return fmt.Errorf("error deleting network policy %s: %w", name, err)
 	}
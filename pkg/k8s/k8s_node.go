@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrEvictionBlocked is returned if an eviction would violate a PodDisruptionBudget.
+var ErrEvictionBlocked = errors.New("eviction is blocked by a PodDisruptionBudget")
+
+// EvictPod evicts a pod using the eviction API, like a node drain, so that PodDisruptionBudgets are respected.
+// It returns an error wrapping ErrEvictionBlocked if the eviction would violate a PodDisruptionBudget.
+func (c *Client) EvictPod(namespace, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+	err := c.clientset.CoreV1().Pods(namespace).EvictV1(ctx, eviction)
+	switch {
+	case err == nil:
+	case apierrs.IsTooManyRequests(err):
+		return fmt.Errorf("error evicting pod %s: %w: %v", name, ErrEvictionBlocked, err)
+	case apierrs.IsForbidden(err):
+		return fmt.Errorf("permission denied to evict pod %s, which requires the permission to create pods/eviction: %w", name, err)
+	default:
+		return fmt.Errorf("error evicting pod %s: %w", name, err)
+	}
+
+	log.Debugf("Pod %s evicted in namespace %s", name, namespace)
+	return nil
+}
+
+// SetNodeUnschedulable cordons (or uncordons) a node, so that no new pods are scheduled on it.
+// Nodes are cluster-scoped, so this requires the cluster-scoped permission to patch nodes.
+func (c *Client) SetNodeUnschedulable(name string, unschedulable bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	patch := []byte(fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable))
+	_, err := c.clientset.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		if apierrs.IsForbidden(err) {
+			return fmt.Errorf("permission denied to patch node %s, which requires the cluster-scoped permission to patch nodes: %w", name, err)
+		}
+		return fmt.Errorf("error setting node %s unschedulable to %t: %w", name, unschedulable, err)
+	}
+
+	log.Debugf("Node %s unschedulable set to %t", name, unschedulable)
+	return nil
+}
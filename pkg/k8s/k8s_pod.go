@@ -9,10 +9,11 @@ import (
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/transport/spdy"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/celestiaorg/knuu/pkg/log"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -20,15 +21,15 @@ import (
 )
 
 // getPod retrieves a pod from the given namespace and logs any errors.
-func getPod(namespace, name string) (*v1.Pod, error) {
+func (c *Client) getPod(namespace, name string) (*v1.Pod, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	pod, err := Clientset().CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	pod, err := c.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pod %s: %w", name, err)
 	}
@@ -36,8 +37,107 @@ func getPod(namespace, name string) (*v1.Pod, error) {
 	return pod, nil
 }
 
+// ListPods returns the pods with the given labels in the given namespace, sorted by name.
+// They are read from the cache if it covers them, see StartCache.
+func (c *Client) ListPods(namespace string, labels map[string]string) ([]v1.Pod, error) {
+	if pods, ok := c.cachedPods(namespace, labels); ok {
+		return pods, nil
+	}
+	return c.listPods(namespace, labels)
+}
+
+// listPods returns the pods with the given labels in the given namespace from the API server, sorted by name.
+func (c *Client) listPods(namespace string, labels map[string]string) ([]v1.Pod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: labels}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
+	}
+	sort.Slice(pods.Items, func(a, b int) bool {
+		return pods.Items[a].Name < pods.Items[b].Name
+	})
+	return pods.Items, nil
+}
+
+// WaitPodsAreDeleted waits until no pod with the given labels exists anymore or the context is done.
+// Pods with a termination grace period exist until their containers have terminated.
+func (c *Client) WaitPodsAreDeleted(ctx context.Context, namespace string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.CoreV1().Pods(namespace).Watch, func() (bool, error) {
+		// The cache may not have received the event that triggered the check yet
+		pods, err := c.listPods(namespace, labels)
+		if err != nil {
+			return false, err
+		}
+		return len(pods) == 0, nil
+	})
+}
+
+// GetPodLogs returns the last tailLines lines of the logs of a container within a pod, or all lines if tailLines is not positive.
+// If previous is true, the logs of the previous run of the container are returned, e.g. of a container that crashed.
+func (c *Client) GetPodLogs(namespace, podName, containerName string, tailLines int64, previous bool) (string, error) {
+	return c.getPodLogs(namespace, podName, containerName, tailLines, previous, false)
+}
+
+// GetPodLogsWithTimestamps returns the logs of a container within a pod like GetPodLogs, with the RFC3339 timestamp
+// at which every line was written as its prefix, e.g. to interleave the logs of several containers.
+func (c *Client) GetPodLogsWithTimestamps(namespace, podName, containerName string, tailLines int64, previous bool) (string, error) {
+	return c.getPodLogs(namespace, podName, containerName, tailLines, previous, true)
+}
+
+// getPodLogs returns the logs of a container within a pod.
+func (c *Client) getPodLogs(namespace, podName, containerName string, tailLines int64, previous, timestamps bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return "", fmt.Errorf("knuu is not initialized")
+	}
+	options := &v1.PodLogOptions{
+		Container:  containerName,
+		Previous:   previous,
+		Timestamps: timestamps,
+	}
+	if tailLines > 0 {
+		options.TailLines = &tailLines
+	}
+	logs, err := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, options).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs of container %s in pod %s: %w", containerName, podName, err)
+	}
+	return string(logs), nil
+}
+
+// GetPodContainerNames returns the names of the init, regular and ephemeral containers of a pod, in this order.
+func (c *Client) GetPodContainerNames(namespace, podName string) ([]string, error) {
+	pod, err := c.getPod(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, container := range pod.Spec.InitContainers {
+		names = append(names, container.Name)
+	}
+	for _, container := range pod.Spec.Containers {
+		names = append(names, container.Name)
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		names = append(names, container.Name)
+	}
+	return names, nil
+}
+
 // DeployPod creates a new pod in the given namespace if it doesn't already exist.
-func DeployPod(podConfig PodConfig, init bool) (*v1.Pod, error) {
+func (c *Client) DeployPod(podConfig PodConfig, init bool) (*v1.Pod, error) {
 	// Prepare the pod
 	pod, err := preparePod(podConfig, init)
 	if err != nil {
@@ -48,10 +148,10 @@ func DeployPod(podConfig PodConfig, init bool) (*v1.Pod, error) {
 	defer cancel()
 
 	// Try to create the pod
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	createdPod, err := Clientset().CoreV1().Pods(podConfig.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	createdPod, err := c.clientset.CoreV1().Pods(podConfig.Namespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create pod: %v", err)
 	}
@@ -64,6 +164,9 @@ type Volume struct {
 	Path  string
 	Size  string
 	Owner int64
+	// SubPath is the directory of the persistent volume claim that is mounted at Path.
+	// If it is empty, a directory derived from Path is used.
+	SubPath string
 }
 
 // NewVolume creates a new volume with the given path, size and owner.
@@ -75,43 +178,124 @@ func NewVolume(path, size string, owner int64) *Volume {
 	}
 }
 
+// NewVolumeWithSubPath creates a new volume that mounts the given directory of the persistent volume claim.
+func NewVolumeWithSubPath(path, subPath, size string, owner int64) *Volume {
+	return &Volume{
+		Path:    path,
+		Size:    size,
+		Owner:   owner,
+		SubPath: subPath,
+	}
+}
+
+// MountSubPath returns the directory of the persistent volume claim that is mounted for the volume.
+func (v *Volume) MountSubPath() string {
+	if v.SubPath != "" {
+		return v.SubPath
+	}
+	return strings.TrimLeft(v.Path, "/")
+}
+
+// HostPathVolume represents a file or directory of the node mounted into the pod.
+type HostPathVolume struct {
+	HostPath  string
+	MountPath string
+	Type      *v1.HostPathType
+}
+
+// NewHostPathVolume creates a new host path volume with the given host path, mount path and type.
+func NewHostPathVolume(hostPath, mountPath string, hostPathType *v1.HostPathType) *HostPathVolume {
+	return &HostPathVolume{
+		HostPath:  hostPath,
+		MountPath: mountPath,
+		Type:      hostPathType,
+	}
+}
+
+// ClaimVolume represents an existing PersistentVolumeClaim mounted into the pod, e.g. one shared with other pods.
+type ClaimVolume struct {
+	ClaimName string
+	MountPath string
+}
+
+// NewClaimVolume creates a new claim volume mounting the given PersistentVolumeClaim at the given path.
+func NewClaimVolume(claimName, mountPath string) *ClaimVolume {
+	return &ClaimVolume{
+		ClaimName: claimName,
+		MountPath: mountPath,
+	}
+}
+
 // PodConfig contains the specifications for creating a new Pod object
 type PodConfig struct {
-	Namespace          string            // Kubernetes namespace of the Pod
-	Name               string            // Name to assign to the Pod
-	Labels             map[string]string // Labels to apply to the Pod
-	Image              string            // Name of the Docker image to use for the container
-	Command            []string          // Command to run in the container
-	Args               []string          // Arguments to pass to the command in the container
-	Env                map[string]string // Environment variables to set in the container
-	Volumes            []*Volume         // Volumes to mount in the Pod
-	MemoryRequest      string            // Memory request for the container
-	MemoryLimit        string            // Memory limit for the container
-	CPURequest         string            // CPU request for the container
-	ServiceAccountName string            // ServiceAccount to assign to Pod
+	Namespace               string                        // Kubernetes namespace of the Pod
+	Name                    string                        // Name to assign to the Pod
+	Labels                  map[string]string             // Labels to apply to the Pod
+	Annotations             map[string]string             // Annotations to apply to the Pod, e.g. for Prometheus scraping
+	Image                   string                        // Name of the Docker image to use for the container
+	Command                 []string                      // Command to run in the container
+	Args                    []string                      // Arguments to pass to the command in the container
+	Env                     map[string]string             // Environment variables to set in the container
+	Volumes                 []*Volume                     // Volumes to mount in the Pod
+	HostPathVolumes         []*HostPathVolume             // Host paths to mount in the Pod
+	ClaimVolumes            []*ClaimVolume                // Existing PersistentVolumeClaims to mount in the Pod
+	MemoryRequest           string                        // Memory request for the container
+	MemoryLimit             string                        // Memory limit for the container
+	CPURequest              string                        // CPU request for the container
+	CPULimit                string                        // CPU limit for the container
+	EphemeralStorageRequest string                        // Ephemeral storage request for the container
+	EphemeralStorageLimit   string                        // Ephemeral storage limit for the container
+	ExtendedResources       map[string]string             // Extended resources (e.g. nvidia.com/gpu) requested and limited for the container
+	ServiceAccountName      string                        // ServiceAccount to assign to Pod
+	PriorityClassName       string                        // PriorityClass to assign to Pod
+	RestartPolicy           v1.RestartPolicy              // RestartPolicy of the Pod, defaults to Always if empty
+	DNSPolicy               v1.DNSPolicy                  // DNSPolicy of the Pod, defaults to None if a DNSConfig is set and to ClusterFirst otherwise
+	DNSConfig               *v1.PodDNSConfig              // DNSConfig of the Pod
+	TopologySpread          []v1.TopologySpreadConstraint // Constraints spreading the Pods of a workload across nodes or zones
+	Affinity                *v1.Affinity                  // Affinity of the Pod, e.g. pod anti-affinity
+	Sidecars                []*SidecarConfig              // Sidecar containers running next to the main container
+	PodNameEnv              string                        // Environment variable set to the name of the Pod, e.g. to be referenced as $(VAR) in the command, none if empty
+	ReadinessProbe          *v1.Probe                     // Readiness probe of the main container, none if nil
+	HostNetwork             bool                          // Run the Pod in the network namespace of its node
+	HostPorts               []v1.ContainerPort            // Ports of the main container that are bound to ports of its node
+	VolumeClaimName         string                        // Existing PersistentVolumeClaim of the Volumes, which is not initialized with the image content, the Name if empty
+	Privileged              bool                          // Run the main container privileged, e.g. to build images in the cluster
+	ShareProcessNamespace   bool                          // Share a single process namespace between all containers of the Pod
+}
+
+// SidecarConfig contains the specifications for a sidecar container running next to the main container of a Pod.
+type SidecarConfig struct {
+	Name             string            // Name of the sidecar container
+	Image            string            // Name of the Docker image to use for the sidecar container
+	Command          []string          // Command to run in the sidecar container, the entrypoint of the image if empty
+	Args             []string          // Arguments to pass to the entrypoint of the sidecar container
+	Env              map[string]string // Environment variables to set in the sidecar container
+	MainEnv          map[string]string // Environment variables to set in the main container, unless it sets them itself
+	MemoryRequest    string            // Memory request for the sidecar container
+	MemoryLimit      string            // Memory limit for the sidecar container
+	CPURequest       string            // CPU request for the sidecar container
+	SharedVolumePath string            // Path of an emptyDir volume mounted in both the sidecar and the main container, none if empty
+	VolumePath       string            // Path of an emptyDir volume mounted only in the sidecar container, none if empty
+	Capabilities     []v1.Capability   // Linux capabilities added to the sidecar container, e.g. NET_ADMIN
 }
 
 // ReplacePodWithGracePeriod replaces a pod in the given namespace and returns the new Pod object with a grace period.
-func ReplacePodWithGracePeriod(podConfig PodConfig, gracePeriod *int64) (*v1.Pod, error) {
+func (c *Client) ReplacePodWithGracePeriod(podConfig PodConfig, gracePeriod *int64) (*v1.Pod, error) {
 	// Log a debug message to indicate that we are replacing a pod
-	logrus.Debugf("Replacing pod %s", podConfig.Name)
+	log.Debugf("Replacing pod %s", podConfig.Name)
 
 	// Delete the existing pod (if any)
-	if err := DeletePodWithGracePeriod(podConfig.Namespace, podConfig.Name, gracePeriod); err != nil {
+	if err := c.DeletePodWithGracePeriod(podConfig.Namespace, podConfig.Name, gracePeriod); err != nil {
 		return nil, fmt.Errorf("failed to delete pod: %v", err)
 	}
 
 	// Wait for the pod to be fully deleted
-	for {
-		_, err := getPod(podConfig.Namespace, podConfig.Name)
-		if err != nil {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
+	if err := c.WaitPodIsDeleted(context.Background(), podConfig.Namespace, podConfig.Name, podConfig.Labels); err != nil {
+		return nil, fmt.Errorf("failed to wait for pod to be deleted: %v", err)
 	}
 
 	// Deploy the new pod
-	pod, err := DeployPod(podConfig, false)
+	pod, err := c.DeployPod(podConfig, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to deploy pod: %v", err)
 	}
@@ -121,14 +305,14 @@ func ReplacePodWithGracePeriod(podConfig PodConfig, gracePeriod *int64) (*v1.Pod
 }
 
 // ReplacePod replaces a pod in the given namespace and returns the new Pod object.
-func ReplacePod(podConfig PodConfig) (*v1.Pod, error) {
-	return ReplacePodWithGracePeriod(podConfig, nil)
+func (c *Client) ReplacePod(podConfig PodConfig) (*v1.Pod, error) {
+	return c.ReplacePodWithGracePeriod(podConfig, nil)
 }
 
 // IsPodRunning returns true if all containers in the pod are running.
-func IsPodRunning(namespace, name string) (bool, error) {
+func (c *Client) IsPodRunning(namespace, name string) (bool, error) {
 	// Get the pod from Kubernetes API server
-	pod, err := getPod(namespace, name)
+	pod, err := c.getPod(namespace, name)
 	if err != nil {
 		return false, fmt.Errorf("failed to get pod: %v", err)
 	}
@@ -143,19 +327,212 @@ func IsPodRunning(namespace, name string) (bool, error) {
 	return true, nil
 }
 
+// WaitPodIsDeleted waits until the pod does not exist anymore or the context is done.
+// The pod is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitPodIsDeleted(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.CoreV1().Pods(namespace).Watch, func() (bool, error) {
+		_, err := c.getPod(namespace, name)
+		if err != nil {
+			if isNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	})
+}
+
+// GetPodSchedulingFailure returns why the pod cannot be scheduled, or an empty string if it is not unschedulable.
+func (c *Client) GetPodSchedulingFailure(namespace, name string) (string, error) {
+	pod, err := c.getPod(namespace, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod: %w", err)
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse && condition.Reason == v1.PodReasonUnschedulable {
+			return condition.Message, nil
+		}
+	}
+	return "", nil
+}
+
+// GetPodEvictionMessage returns why the pod was evicted, or an empty string if it was not evicted.
+func (c *Client) GetPodEvictionMessage(namespace, name string) (string, error) {
+	pod, err := c.getPod(namespace, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod: %w", err)
+	}
+	if pod.Status.Phase == v1.PodFailed && pod.Status.Reason == "Evicted" {
+		return pod.Status.Message, nil
+	}
+	return "", nil
+}
+
+// PodStatus summarizes the status of a pod, e.g. to report why it is not running.
+type PodStatus struct {
+	Phase               v1.PodPhase // Phase of the pod
+	Reason              string      // Reason of the pod's status, e.g. Evicted
+	Message             string      // Message of the pod's status
+	WaitingContainer    string      // Name of the first waiting container
+	WaitingReason       string      // Reason of the first waiting container, e.g. ImagePullBackOff or CrashLoopBackOff
+	WaitingMessage      string      // Message of the first waiting container
+	TerminatedContainer string      // Name of the first container that terminated with an error
+	TerminatedReason    string      // Reason of the first container that terminated with an error, e.g. OOMKilled
+	ExitCode            int32       // Exit code of the first container that terminated with an error
+}
+
+// nonRecoverableWaitingReasons are the reasons of waiting containers that do not resolve without changing the pod.
+var nonRecoverableWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImageNeverPull":          true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"CrashLoopBackOff":           true,
+}
+
+// String returns a readable description of the status.
+func (s *PodStatus) String() string {
+	description := fmt.Sprintf("phase %s", s.Phase)
+	if s.Reason != "" {
+		description += fmt.Sprintf(", reason %s", s.Reason)
+	}
+	if s.Message != "" {
+		description += fmt.Sprintf(" (%s)", s.Message)
+	}
+	if s.WaitingReason != "" {
+		description += fmt.Sprintf(", container %s waiting: %s", s.WaitingContainer, s.WaitingReason)
+		if s.WaitingMessage != "" {
+			description += fmt.Sprintf(" (%s)", s.WaitingMessage)
+		}
+	}
+	if s.TerminatedReason != "" {
+		description += fmt.Sprintf(", container %s terminated: %s (exit code %d)", s.TerminatedContainer, s.TerminatedReason, s.ExitCode)
+	}
+	return description
+}
+
+// Failure returns why the pod cannot become running, or an empty string if it may still become running.
+// A pod cannot become running if it failed or if a container is waiting for a reason that does not resolve by itself,
+// e.g. ImagePullBackOff, CreateContainerConfigError or CrashLoopBackOff.
+func (s *PodStatus) Failure() string {
+	if s.Phase == v1.PodFailed {
+		return s.String()
+	}
+	if nonRecoverableWaitingReasons[s.WaitingReason] {
+		failure := fmt.Sprintf("container %s is waiting: %s", s.WaitingContainer, s.WaitingReason)
+		if s.WaitingMessage != "" {
+			failure += fmt.Sprintf(" (%s)", s.WaitingMessage)
+		}
+		if s.TerminatedReason != "" {
+			failure += fmt.Sprintf(", last terminated: %s (exit code %d)", s.TerminatedReason, s.ExitCode)
+		}
+		return failure
+	}
+	return ""
+}
+
+// GetPodStatus returns the status of the pod, including the reasons of waiting and failed containers.
+func (c *Client) GetPodStatus(namespace, name string) (*PodStatus, error) {
+	pod, err := c.getPod(namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod: %w", err)
+	}
+	return NewPodStatus(pod), nil
+}
+
+// NewPodStatus returns the status of the pod like GetPodStatus, e.g. of a pod returned by ListPods.
+func NewPodStatus(pod *v1.Pod) *PodStatus {
+	status := &PodStatus{
+		Phase:   pod.Status.Phase,
+		Reason:  pod.Status.Reason,
+		Message: pod.Status.Message,
+	}
+	// Init containers come first, as the other containers wait for them
+	containerStatuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, containerStatus := range containerStatuses {
+		if waiting := containerStatus.State.Waiting; waiting != nil && status.WaitingReason == "" {
+			status.WaitingContainer = containerStatus.Name
+			status.WaitingReason = waiting.Reason
+			status.WaitingMessage = waiting.Message
+		}
+		terminated := containerStatus.State.Terminated
+		if terminated == nil {
+			terminated = containerStatus.LastTerminationState.Terminated
+		}
+		if terminated != nil && terminated.ExitCode != 0 && status.TerminatedReason == "" {
+			status.TerminatedContainer = containerStatus.Name
+			status.TerminatedReason = terminated.Reason
+			status.ExitCode = terminated.ExitCode
+		}
+	}
+	return status
+}
+
 // RunCommandInPod runs a command in a container within a pod.
-func RunCommandInPod(namespace, podName, containerName string, cmd []string) (string, error) {
+func (c *Client) RunCommandInPod(namespace, podName, containerName string, cmd []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	return c.runCommandInPod(ctx, namespace, podName, containerName, cmd, nil)
+}
+
+// RunCommandInPodWithStdin runs a command in a container within a pod, streaming the given reader to its stdin.
+// The stdin is streamed, so it is not loaded into memory at once.
+func (c *Client) RunCommandInPodWithStdin(ctx context.Context, namespace, podName, containerName string, cmd []string, stdin io.Reader) (string, error) {
+	return c.runCommandInPod(ctx, namespace, podName, containerName, cmd, stdin)
+}
+
+// StreamCommandInPod runs a command in a container within a pod, streaming its stdout to the given writer until the command
+// exits or the context is done.
+// The stdout is streamed, so it is not loaded into memory at once. The stderr only fails the command if it exits with an error,
+// in which case it is part of the returned error.
+func (c *Client) StreamCommandInPod(ctx context.Context, namespace, podName, containerName string, cmd []string, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	if err := c.streamCommandInPod(ctx, namespace, podName, containerName, cmd, nil, stdout, &stderr); err != nil {
+		if stderr.Len() != 0 {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return err
+	}
+	return nil
+}
+
+// runCommandInPod runs a command in a container within a pod, attaching the stdin if it is not nil.
+func (c *Client) runCommandInPod(ctx context.Context, namespace, podName, containerName string, cmd []string, stdin io.Reader) (string, error) {
+	// Execute the command and capture the output and error streams
+	var stdout, stderr bytes.Buffer
+	if err := c.streamCommandInPod(ctx, namespace, podName, containerName, cmd, stdin, &stdout, &stderr); err != nil {
+		return "", err
+	}
+
+	// Check if there were any errors on the error stream
+	if stderr.Len() != 0 {
+		return "", fmt.Errorf("error while executing command: %s", stderr.String())
+	}
+
+	return stdout.String(), nil
+}
+
+// streamCommandInPod runs a command in a container within a pod, attaching the stdin if it is not nil and streaming its output to the writers.
+func (c *Client) streamCommandInPod(ctx context.Context, namespace, podName, containerName string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	// Get the pod object
-	_, err := getPod(namespace, podName)
+	_, err := c.getPod(namespace, podName)
 	if err != nil {
-		return "", fmt.Errorf("failed to get pod: %v", err)
+		return fmt.Errorf("failed to get pod: %v", err)
 	}
 
 	// Construct the request for executing the command in the specified container
-	if !IsInitialized() {
-		return "", fmt.Errorf("knuu is not initialized")
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
 	}
-	req := Clientset().CoreV1().RESTClient().Post().
+	if err := c.checkConfig(); err != nil {
+		return err
+	}
+	req := c.clientset.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(podName).
 		Namespace(namespace).
@@ -163,48 +540,34 @@ func RunCommandInPod(namespace, podName, containerName string, cmd []string) (st
 		VersionedParams(&v1.PodExecOptions{
 			Command:   cmd,
 			Container: containerName,
-			Stdin:     false,
+			Stdin:     stdin != nil,
 			Stdout:    true,
 			Stderr:    true,
 			TTY:       false,
 		}, scheme.ParameterCodec)
 
 	// Create an executor for the command execution
-	k8sConfig, err := getClusterConfig()
-	if err != nil {
-		return "", fmt.Errorf("failed to get k8s config: %v", err)
-	}
-	exec, err := remotecommand.NewSPDYExecutor(k8sConfig, "POST", req.URL())
+	exec, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
 	if err != nil {
-		return "", fmt.Errorf("failed to create Executor: %v", err)
+		return fmt.Errorf("failed to create Executor: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
-
-	// Execute the command and capture the output and error streams
-	var stdout, stderr bytes.Buffer
 	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdout: &stdout,
-		Stderr: &stderr,
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
 		Tty:    false,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to execute command: %v", err)
-	}
-
-	// Check if there were any errors on the error stream
-	if stderr.Len() != 0 {
-		return "", fmt.Errorf("error while executing command: %s", stderr.String())
+		return fmt.Errorf("failed to execute command: %v", err)
 	}
-
-	return stdout.String(), nil
+	return nil
 }
 
 // DeletePodWithGracePeriod deletes a pod with the given name in the specified namespace.
-func DeletePodWithGracePeriod(namespace, name string, gracePeriodSeconds *int64) error {
+func (c *Client) DeletePodWithGracePeriod(namespace, name string, gracePeriodSeconds *int64) error {
 	// Get the Pod object from the API server
-	_, err := getPod(namespace, name)
+	_, err := c.getPod(namespace, name)
 	if err != nil {
 		// If the pod does not exist, skip and return without error
 		return nil
@@ -214,13 +577,13 @@ func DeletePodWithGracePeriod(namespace, name string, gracePeriodSeconds *int64)
 	defer cancel()
 
 	// Delete the pod using the Kubernetes client API
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
 	deleteOptions := metav1.DeleteOptions{
 		GracePeriodSeconds: gracePeriodSeconds,
 	}
-	if err := Clientset().CoreV1().Pods(namespace).Delete(ctx, name, deleteOptions); err != nil {
+	if err := c.clientset.CoreV1().Pods(namespace).Delete(ctx, name, deleteOptions); err != nil {
 		return fmt.Errorf("failed to delete pod %s: %v", name, err)
 	}
 
@@ -228,8 +591,8 @@ func DeletePodWithGracePeriod(namespace, name string, gracePeriodSeconds *int64)
 }
 
 // DeletePod deletes a pod with the given name in the specified namespace.
-func DeletePod(namespace, name string) error {
-	return DeletePodWithGracePeriod(namespace, name, nil)
+func (c *Client) DeletePod(namespace, name string) error {
+	return c.DeletePodWithGracePeriod(namespace, name, nil)
 }
 
 // buildEnv builds an environment variable configuration for a Pod based on the given map of key-value pairs.
@@ -242,9 +605,9 @@ func buildEnv(envMap map[string]string) []v1.EnvVar {
 	return envVars
 }
 
-// buildPodVolumes generates a volume configuration for a pod based on the given name.
+// buildPodVolumes generates a volume configuration for a pod based on the given name, mounting the given claim.
 // If the volumes amount is zero, returns an empty slice.
-func buildPodVolumes(name string, volumesAmount int) ([]v1.Volume, error) {
+func buildPodVolumes(name, claimName string, volumesAmount int) ([]v1.Volume, error) {
 	if volumesAmount == 0 {
 		return []v1.Volume{}, nil
 	}
@@ -253,7 +616,7 @@ func buildPodVolumes(name string, volumesAmount int) ([]v1.Volume, error) {
 		Name: name,
 		VolumeSource: v1.VolumeSource{
 			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
-				ClaimName: name,
+				ClaimName: claimName,
 			},
 		},
 	}
@@ -274,13 +637,114 @@ func buildContainerVolumes(name string, volumes []*Volume) ([]v1.VolumeMount, er
 		containerVolumes = append(containerVolumes, v1.VolumeMount{
 			Name:      name,
 			MountPath: volume.Path,
-			SubPath:   strings.TrimLeft(volume.Path, "/"),
+			SubPath:   volume.MountSubPath(),
 		})
 	}
 
 	return containerVolumes, nil
 }
 
+// buildHostPathVolumes generates the pod volumes and the container volume mounts for the given host path volumes.
+func buildHostPathVolumes(hostPathVolumes []*HostPathVolume) ([]v1.Volume, []v1.VolumeMount) {
+	podVolumes := make([]v1.Volume, 0, len(hostPathVolumes))
+	volumeMounts := make([]v1.VolumeMount, 0, len(hostPathVolumes))
+	for i, hostPathVolume := range hostPathVolumes {
+		name := fmt.Sprintf("hostpath-%d", i)
+		podVolumes = append(podVolumes, v1.Volume{
+			Name: name,
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{
+					Path: hostPathVolume.HostPath,
+					Type: hostPathVolume.Type,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, v1.VolumeMount{
+			Name:      name,
+			MountPath: hostPathVolume.MountPath,
+		})
+	}
+	return podVolumes, volumeMounts
+}
+
+// buildClaimVolumes generates the pod volumes and the container volume mounts for the given claim volumes.
+func buildClaimVolumes(claimVolumes []*ClaimVolume) ([]v1.Volume, []v1.VolumeMount) {
+	podVolumes := make([]v1.Volume, 0, len(claimVolumes))
+	volumeMounts := make([]v1.VolumeMount, 0, len(claimVolumes))
+	for i, claimVolume := range claimVolumes {
+		name := fmt.Sprintf("claim-%d", i)
+		podVolumes = append(podVolumes, v1.Volume{
+			Name: name,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claimVolume.ClaimName,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, v1.VolumeMount{
+			Name:      name,
+			MountPath: claimVolume.MountPath,
+		})
+	}
+	return podVolumes, volumeMounts
+}
+
+// buildSidecars generates the sidecar containers, and the pod volumes and main container volume mounts they share.
+func buildSidecars(sidecars []*SidecarConfig) ([]v1.Container, []v1.Volume, []v1.VolumeMount, error) {
+	var containers []v1.Container
+	var podVolumes []v1.Volume
+	var mainVolumeMounts []v1.VolumeMount
+	for _, sidecar := range sidecars {
+		resources, err := buildResources(sidecar.MemoryRequest, sidecar.MemoryLimit, sidecar.CPURequest, "", "", "", nil)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to build resources of sidecar '%s': %v", sidecar.Name, err)
+		}
+		container := v1.Container{
+			Name:      sidecar.Name,
+			Image:     sidecar.Image,
+			Command:   sidecar.Command,
+			Args:      sidecar.Args,
+			Env:       buildEnv(sidecar.Env),
+			Resources: resources,
+		}
+		if len(sidecar.Capabilities) != 0 {
+			container.SecurityContext = &v1.SecurityContext{
+				Capabilities: &v1.Capabilities{Add: sidecar.Capabilities},
+			}
+		}
+		if sidecar.SharedVolumePath != "" {
+			volumeName := fmt.Sprintf("%s-shared", sidecar.Name)
+			podVolumes = append(podVolumes, v1.Volume{
+				Name: volumeName,
+				VolumeSource: v1.VolumeSource{
+					EmptyDir: &v1.EmptyDirVolumeSource{},
+				},
+			})
+			volumeMount := v1.VolumeMount{
+				Name:      volumeName,
+				MountPath: sidecar.SharedVolumePath,
+			}
+			container.VolumeMounts = []v1.VolumeMount{volumeMount}
+			mainVolumeMounts = append(mainVolumeMounts, volumeMount)
+		}
+		if sidecar.VolumePath != "" {
+			volumeName := fmt.Sprintf("%s-data", sidecar.Name)
+			podVolumes = append(podVolumes, v1.Volume{
+				Name: volumeName,
+				VolumeSource: v1.VolumeSource{
+					EmptyDir: &v1.EmptyDirVolumeSource{},
+				},
+			})
+			container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+				Name:      volumeName,
+				MountPath: sidecar.VolumePath,
+			})
+		}
+		containers = append(containers, container)
+	}
+	return containers, podVolumes, mainVolumeMounts, nil
+}
+
 // buildInitContainerVolumes generates a volume mount configuration for an init container based on the given name and volumes.
 func buildInitContainerVolumes(name string, volumes []*Volume) ([]v1.VolumeMount, error) {
 	if len(volumes) == 0 {
@@ -305,7 +769,8 @@ func buildInitContainerCommand(name string, volumes []*Volume) ([]string, error)
 
 	var command []string = []string{"sh", "-c"} // initialize the command slice with the required shell interpreter
 	for _, volume := range volumes {
-		cmd := fmt.Sprintf("mkdir -p /knuu/%s && cp -r %s/* /knuu/%s && chown -R %d:%d /knuu/*", volume.Path, volume.Path, volume.Path, volume.Owner, volume.Owner)
+		subPath := volume.MountSubPath()
+		cmd := fmt.Sprintf("mkdir -p /knuu/%s && cp -r %s/* /knuu/%s && chown -R %d:%d /knuu/*", subPath, volume.Path, subPath, volume.Owner, volume.Owner)
 		command = append(command, cmd) // add each command to the command slice
 	}
 
@@ -313,7 +778,8 @@ func buildInitContainerCommand(name string, volumes []*Volume) ([]string, error)
 }
 
 // buildResources generates a resource configuration for a container based on the given CPU and memory requests and limits.
-func buildResources(memoryRequest string, memoryLimit string, cpuRequest string) (v1.ResourceRequirements, error) {
+// Extended resources are added to both requests and limits, as Kubernetes requires them to be equal.
+func buildResources(memoryRequest string, memoryLimit string, cpuRequest string, cpuLimit string, ephemeralStorageRequest string, ephemeralStorageLimit string, extendedResources map[string]string) (v1.ResourceRequirements, error) {
 	resources := v1.ResourceRequirements{}
 
 	memoryRequestQuantity, err := resource.ParseQuantity(memoryRequest)
@@ -346,9 +812,66 @@ func buildResources(memoryRequest string, memoryLimit string, cpuRequest string)
 		},
 	}
 
+	// The CPU limit is only set if requested, as a zero limit would throttle the container completely
+	if cpuLimit != "" {
+		cpuLimitQuantity, err := resource.ParseQuantity(cpuLimit)
+		if err != nil {
+			return resources, fmt.Errorf("failed to parse CPU limit quantity '%s': %v", cpuLimit, err)
+		}
+		resources.Limits[v1.ResourceCPU] = cpuLimitQuantity
+	}
+
+	// Ephemeral storage is only set if requested, as it is not supported by all clusters
+	if ephemeralStorageRequest != "" {
+		ephemeralStorageRequestQuantity, err := resource.ParseQuantity(ephemeralStorageRequest)
+		if err != nil {
+			return resources, fmt.Errorf("failed to parse ephemeral storage request quantity '%s': %v", ephemeralStorageRequest, err)
+		}
+		resources.Requests[v1.ResourceEphemeralStorage] = ephemeralStorageRequestQuantity
+	}
+	if ephemeralStorageLimit != "" {
+		ephemeralStorageLimitQuantity, err := resource.ParseQuantity(ephemeralStorageLimit)
+		if err != nil {
+			return resources, fmt.Errorf("failed to parse ephemeral storage limit quantity '%s': %v", ephemeralStorageLimit, err)
+		}
+		resources.Limits[v1.ResourceEphemeralStorage] = ephemeralStorageLimitQuantity
+	}
+
+	for name, quantity := range extendedResources {
+		extendedQuantity, err := resource.ParseQuantity(quantity)
+		if err != nil {
+			return resources, fmt.Errorf("failed to parse quantity '%s' of extended resource '%s': %v", quantity, name, err)
+		}
+		resources.Requests[v1.ResourceName(name)] = extendedQuantity
+		resources.Limits[v1.ResourceName(name)] = extendedQuantity
+	}
+
 	return resources, nil
 }
 
+// ValidateRestartPolicy checks if the restart policy is valid and supported by the given kind of workload.
+// StatefulSets and DaemonSets only support Always, while Jobs only support Never and OnFailure.
+func ValidateRestartPolicy(kind string, policy v1.RestartPolicy) error {
+	switch policy {
+	case v1.RestartPolicyAlways, v1.RestartPolicyOnFailure, v1.RestartPolicyNever:
+	default:
+		return fmt.Errorf("unknown restart policy '%s'", policy)
+	}
+	switch kind {
+	case "StatefulSet", "DaemonSet":
+		if policy != v1.RestartPolicyAlways {
+			return fmt.Errorf("restart policy '%s' is not supported by a %s, only '%s' is", policy, kind, v1.RestartPolicyAlways)
+		}
+	case "Job":
+		if policy == v1.RestartPolicyAlways {
+			return fmt.Errorf("restart policy '%s' is not supported by a %s, only '%s' and '%s' are", policy, kind, v1.RestartPolicyNever, v1.RestartPolicyOnFailure)
+		}
+	default:
+		return fmt.Errorf("unknown workload kind '%s'", kind)
+	}
+	return nil
+}
+
 // preparePodSpec prepares a pod spec configuration.
 func preparePodSpec(spec PodConfig, init bool) (v1.PodSpec, error) {
 	name := spec.Name
@@ -362,7 +885,11 @@ func preparePodSpec(spec PodConfig, init bool) (v1.PodSpec, error) {
 	podEnv := buildEnv(env)
 
 	// Build pod volumes from the given map
-	podVolumes, err := buildPodVolumes(name, len(volumes))
+	claimName := name
+	if spec.VolumeClaimName != "" {
+		claimName = spec.VolumeClaimName
+	}
+	podVolumes, err := buildPodVolumes(name, claimName, len(volumes))
 	if err != nil {
 		return v1.PodSpec{}, fmt.Errorf("failed to build pod volumes: %v", err)
 	}
@@ -373,8 +900,19 @@ func preparePodSpec(spec PodConfig, init bool) (v1.PodSpec, error) {
 		return v1.PodSpec{}, fmt.Errorf("failed to build container volumes: %v", err)
 	}
 
+	// Host path volumes are mounted directly and do not need to be initialized
+	hostPathPodVolumes, hostPathContainerVolumes := buildHostPathVolumes(spec.HostPathVolumes)
+	podVolumes = append(podVolumes, hostPathPodVolumes...)
+	containerVolumes = append(containerVolumes, hostPathContainerVolumes...)
+
+	// Claim volumes are owned by someone else, so they are not initialized either
+	claimPodVolumes, claimContainerVolumes := buildClaimVolumes(spec.ClaimVolumes)
+	podVolumes = append(podVolumes, claimPodVolumes...)
+	containerVolumes = append(containerVolumes, claimContainerVolumes...)
+
 	var initContainers []v1.Container
-	if len(volumes) > 0 && init {
+	// An existing claim keeps its content, instead of being overwritten with the content of the image
+	if len(volumes) > 0 && init && spec.VolumeClaimName == "" {
 		// Build init containers volumes and command from the given map
 		initContainerVolumes, err := buildInitContainerVolumes(name, volumes)
 		if err != nil {
@@ -400,26 +938,91 @@ func preparePodSpec(spec PodConfig, init bool) (v1.PodSpec, error) {
 		}
 	}
 
+	// Sidecars may set environment variables of the main container, but never override the ones it sets itself
+	if len(spec.Sidecars) > 0 {
+		mergedEnv := make(map[string]string)
+		for _, sidecar := range spec.Sidecars {
+			for key, val := range sidecar.MainEnv {
+				mergedEnv[key] = val
+			}
+		}
+		for key, val := range env {
+			mergedEnv[key] = val
+		}
+		podEnv = buildEnv(mergedEnv)
+	}
+
+	// The name of a statefulSet's pod is only known when it is created, so it is set by the downward API
+	if spec.PodNameEnv != "" {
+		podEnv = append(podEnv, v1.EnvVar{
+			Name: spec.PodNameEnv,
+			ValueFrom: &v1.EnvVarSource{
+				FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		})
+	}
+
+	sidecarContainers, sidecarPodVolumes, sidecarContainerVolumes, err := buildSidecars(spec.Sidecars)
+	if err != nil {
+		return v1.PodSpec{}, fmt.Errorf("failed to build sidecars: %v", err)
+	}
+	podVolumes = append(podVolumes, sidecarPodVolumes...)
+	containerVolumes = append(containerVolumes, sidecarContainerVolumes...)
+
+	dnsPolicy := spec.DNSPolicy
+	if dnsPolicy == "" && spec.DNSConfig != nil {
+		dnsPolicy = v1.DNSNone
+	}
+	// Without it, a pod in the host network resolves with the DNS of its node instead of the cluster DNS
+	if dnsPolicy == "" && spec.HostNetwork {
+		dnsPolicy = v1.DNSClusterFirstWithHostNet
+	}
+	if dnsPolicy == v1.DNSNone && (spec.DNSConfig == nil || len(spec.DNSConfig.Nameservers) == 0) {
+		return v1.PodSpec{}, fmt.Errorf("DNS policy '%s' requires a DNS config with at least one nameserver", v1.DNSNone)
+	}
+
 	var resources v1.ResourceRequirements
-	resources, err = buildResources(spec.MemoryRequest, spec.MemoryLimit, spec.CPURequest)
+	resources, err = buildResources(spec.MemoryRequest, spec.MemoryLimit, spec.CPURequest, spec.CPULimit, spec.EphemeralStorageRequest, spec.EphemeralStorageLimit, spec.ExtendedResources)
 	if err != nil {
 		return v1.PodSpec{}, fmt.Errorf("failed to build resources: %v", err)
 	}
 
+	var securityContext *v1.SecurityContext
+	if spec.Privileged {
+		privileged := true
+		securityContext = &v1.SecurityContext{Privileged: &privileged}
+	}
+
+	var shareProcessNamespace *bool
+	if spec.ShareProcessNamespace {
+		shareProcessNamespace = &spec.ShareProcessNamespace
+	}
+
 	podSpec := v1.PodSpec{
-		ServiceAccountName: spec.ServiceAccountName,
-		InitContainers:     initContainers,
-		Containers: []v1.Container{
+		ServiceAccountName:        spec.ServiceAccountName,
+		PriorityClassName:         spec.PriorityClassName,
+		RestartPolicy:             spec.RestartPolicy,
+		DNSPolicy:                 dnsPolicy,
+		DNSConfig:                 spec.DNSConfig,
+		TopologySpreadConstraints: spec.TopologySpread,
+		Affinity:                  spec.Affinity,
+		HostNetwork:               spec.HostNetwork,
+		ShareProcessNamespace:     shareProcessNamespace,
+		InitContainers:            initContainers,
+		Containers: append([]v1.Container{
 			{
-				Name:         name,
-				Image:        image,
-				Command:      command,
-				Args:         args,
-				Env:          podEnv,
-				VolumeMounts: containerVolumes,
-				Resources:    resources,
+				Name:            name,
+				Image:           image,
+				Command:         command,
+				Args:            args,
+				Env:             podEnv,
+				VolumeMounts:    containerVolumes,
+				Resources:       resources,
+				ReadinessProbe:  spec.ReadinessProbe,
+				Ports:           spec.HostPorts,
+				SecurityContext: securityContext,
 			},
-		},
+		}, sidecarContainers...),
 		Volumes: podVolumes,
 	}
 
@@ -440,44 +1043,42 @@ func preparePod(spec PodConfig, init bool) (*v1.Pod, error) {
 	// Construct the Pod object using the above data
 	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: namespace,
-			Name:      name,
-			Labels:    labels,
+			Namespace:   namespace,
+			Name:        name,
+			Labels:      labels,
+			Annotations: spec.Annotations,
 		},
 		Spec: podSpec,
 	}
 
-	logrus.Debugf("Prepared pod %s in namespace %s", name, namespace)
+	log.Debugf("Prepared pod %s in namespace %s", name, namespace)
 
 	return pod, nil
 }
 
 // PortForwardPod forwards a local port to a port on a pod.
-func PortForwardPod(namespace string, podName string, localPort int, remotePort int) error {
+func (c *Client) PortForwardPod(namespace string, podName string, localPort int, remotePort int) error {
 	// Get the pod object
-	_, err := getPod(namespace, podName)
+	_, err := c.getPod(namespace, podName)
 	if err != nil {
 		return fmt.Errorf("failed to get pod: %v", err)
 	}
 
-	// Get a config to talk to the apiserver
-	restconfig, err := getClusterConfig()
-	if err != nil {
-		return fmt.Errorf("failed to get cluster config: %v", err)
-	}
-
 	// Setup the port forwarding
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	url := Clientset().CoreV1().RESTClient().Post().
+	if err := c.checkConfig(); err != nil {
+		return err
+	}
+	url := c.clientset.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Namespace(namespace).
 		Name(podName).
 		SubResource("portforward").
 		URL()
 
-	transport, upgrader, err := spdy.RoundTripperFor(restconfig)
+	transport, upgrader, err := spdy.RoundTripperFor(c.config)
 	if err != nil {
 		return fmt.Errorf("failed to create round tripper: %v", err)
 	}
@@ -498,14 +1099,14 @@ func PortForwardPod(namespace string, podName string, localPort int, remotePort
 	if stderr != nil {
 		return fmt.Errorf("failed to port forward: %v", stderr)
 	}
-	logrus.Debugf("Port forwarding from %d to %d", localPort, remotePort)
-	logrus.Debugf("Port forwarding stdout: %v", stdout)
+	log.Debugf("Port forwarding from %d to %d", localPort, remotePort)
+	log.Debugf("Port forwarding stdout: %v", stdout)
 
 	// Start the port forwarding
 	go func() {
 		if err := pf.ForwardPorts(); err != nil {
 			// Handle error
-			logrus.Errorf("Error forwarding ports: %v", err)
+			log.Errorf("Error forwarding ports: %v", err)
 		}
 	}()
 
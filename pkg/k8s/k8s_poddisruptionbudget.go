@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// CreatePodDisruptionBudget creates a PodDisruptionBudget selecting the pods with the given labels.
+func (c *Client) CreatePodDisruptionBudget(namespace, name string, labels, selectorMap map[string]string, minAvailable intstr.IntOrString) error {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectorMap,
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	if _, err := c.clientset.PolicyV1().PodDisruptionBudgets(namespace).Create(ctx, pdb, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("error creating PodDisruptionBudget %s: %w", name, err)
+	}
+
+	log.Debugf("PodDisruptionBudget %s created in namespace %s", name, namespace)
+	return nil
+}
+
+// DeletePodDisruptionBudget deletes a PodDisruptionBudget if it exists.
+func (c *Client) DeletePodDisruptionBudget(namespace, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	err := c.clientset.PolicyV1().PodDisruptionBudgets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting PodDisruptionBudget %s: %w", name, err)
+	}
+
+	log.Debugf("PodDisruptionBudget %s deleted in namespace %s", name, namespace)
+	return nil
+}
@@ -0,0 +1,27 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PriorityClassExists checks if a PriorityClass exists.
+func (c *Client) PriorityClassExists(name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return false, fmt.Errorf("knuu is not initialized")
+	}
+	_, err := c.clientset.SchedulingV1().PriorityClasses().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting PriorityClass %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -2,17 +2,18 @@ package k8s
 
 import (
     "context"
+    "errors"
     "fmt"
     "time"
 
-    "github.com/sirupsen/logrus"
+    "github.com/celestiaorg/knuu/pkg/log"
     v1 "k8s.io/api/core/v1"
     "k8s.io/apimachinery/pkg/api/resource"
     metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // createPersistentVolumeClaim deploys a PersistentVolumeClaim if it does not exist.
-func createPersistentVolumeClaim(namespace, name string, labels map[string]string, size resource.Quantity, accessModes []v1.PersistentVolumeAccessMode) error {
+func (c *Client) createPersistentVolumeClaim(namespace, name string, labels map[string]string, size resource.Quantity, accessModes []v1.PersistentVolumeAccessMode) error {
 	pvc := &v1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: namespace,
@@ -32,21 +33,21 @@ func createPersistentVolumeClaim(namespace, name string, labels map[string]strin
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if _, err := Clientset().CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+	if _, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
 		return err
 	}
 
-	logrus.Debugf("PersistentVolumeClaim %s created", name)
+	log.Debugf("PersistentVolumeClaim %s created", name)
 	return nil
 }
 
 // deletePersistentVolumeClaim deletes a PersistentVolumeClaim if it exists.
-func deletePersistentVolumeClaim(namespace, name string) error {
+func (c *Client) deletePersistentVolumeClaim(namespace, name string) error {
 	// Get the pvc object from the API server
-	_, err := getPersistentVolumeClaim(namespace, name)
+	_, err := c.getPersistentVolumeClaim(namespace, name)
 	if err != nil {
 		// If the pvc does not exist, skip and return without error
 		return nil
@@ -55,44 +56,174 @@ func deletePersistentVolumeClaim(namespace, name string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if err := Clientset().CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+	if err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
 		return fmt.Errorf("error deleting PersistentVolumeClaim %s: %w", name, err)
 	}
 
-	logrus.Debugf("PersistentVolumeClaim %s deleted", name)
+	log.Debugf("PersistentVolumeClaim %s deleted", name)
 	return nil
 }
 
 // getPersistentVolumeClaim retrieves a PersistentVolumeClaim.
-func getPersistentVolumeClaim(namespace, name string) (*v1.PersistentVolumeClaim, error) {
+func (c *Client) getPersistentVolumeClaim(namespace, name string) (*v1.PersistentVolumeClaim, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	pv, err := Clientset().CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	pv, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
 	return pv, nil
 }
 
+// WaitPersistentVolumeClaimIsDeleted waits until the PersistentVolumeClaim does not exist anymore or the context is done.
+// A claim with finalizers, e.g. because it is still used by a pod, is only deleted once they are removed.
+func (c *Client) WaitPersistentVolumeClaimIsDeleted(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.CoreV1().PersistentVolumeClaims(namespace).Watch, func() (bool, error) {
+		_, err := c.getPersistentVolumeClaim(namespace, name)
+		if err != nil {
+			if isNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	})
+}
+
+// GetPersistentVolumeClaimFinalizers returns the finalizers of a PersistentVolumeClaim.
+func (c *Client) GetPersistentVolumeClaimFinalizers(namespace, name string) ([]string, error) {
+	pvc, err := c.getPersistentVolumeClaim(namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting PersistentVolumeClaim %s: %w", name, err)
+	}
+	return pvc.Finalizers, nil
+}
+
 // DeployPersistentVolumeClaim creates a new PersistentVolumeClaim in the specified namespace.
-func DeployPersistentVolumeClaim(namespace, name string, labels map[string]string, size resource.Quantity) {
+func (c *Client) DeployPersistentVolumeClaim(namespace, name string, labels map[string]string, size resource.Quantity) error {
 	accessModes := []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
-	if err := createPersistentVolumeClaim(namespace, name, labels, size, accessModes); err != nil {
-		logrus.Fatalf("Error creating PersistentVolumeClaim %s: %v", name, err)
+	if err := c.createPersistentVolumeClaim(namespace, name, labels, size, accessModes); err != nil {
+		return fmt.Errorf("error creating PersistentVolumeClaim %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeploySharedPersistentVolumeClaim creates a new ReadWriteMany PersistentVolumeClaim in the specified namespace, which can be mounted by multiple pods on any node.
+func (c *Client) DeploySharedPersistentVolumeClaim(namespace, name string, labels map[string]string, size resource.Quantity) error {
+	accessModes := []v1.PersistentVolumeAccessMode{v1.ReadWriteMany}
+	if err := c.createPersistentVolumeClaim(namespace, name, labels, size, accessModes); err != nil {
+		return fmt.Errorf("error creating PersistentVolumeClaim %s: %w", name, err)
 	}
+	return nil
 }
 
 // DeletePersistentVolumeClaim deletes the PersistentVolumeClaim with the specified name in the specified namespace.
-func DeletePersistentVolumeClaim(namespace, name string) {
-	if err := deletePersistentVolumeClaim(namespace, name); err != nil {
-		logrus.Fatalf("Error deleting PersistentVolumeClaim %s: %v", name, err)
+func (c *Client) DeletePersistentVolumeClaim(namespace, name string) error {
+	if err := c.deletePersistentVolumeClaim(namespace, name); err != nil {
+		return fmt.Errorf("error deleting PersistentVolumeClaim %s: %w", name, err)
+	}
+	return nil
+}
+
+// WaitPersistentVolumeClaimIsBound waits until the PersistentVolumeClaim is bound or the context is done.
+// The PersistentVolumeClaim is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitPersistentVolumeClaimIsBound(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.CoreV1().PersistentVolumeClaims(namespace).Watch, func() (bool, error) {
+		pvc, err := c.getPersistentVolumeClaim(namespace, name)
+		if err != nil {
+			return false, err
+		}
+		if pvc.Status.Phase == v1.ClaimLost {
+			return false, fmt.Errorf("PersistentVolumeClaim %s lost its underlying volume", name)
+		}
+		return pvc.Status.Phase == v1.ClaimBound, nil
+	})
+}
+
+// GetPersistentVolumeClaimPhase returns the phase of a PersistentVolumeClaim.
+func (c *Client) GetPersistentVolumeClaimPhase(namespace, name string) (v1.PersistentVolumeClaimPhase, error) {
+	pvc, err := c.getPersistentVolumeClaim(namespace, name)
+	if err != nil {
+		return "", fmt.Errorf("error getting PersistentVolumeClaim %s: %w", name, err)
+	}
+	return pvc.Status.Phase, nil
+}
+
+// ErrVolumeExpansionNotSupported is returned if the StorageClass of a PersistentVolumeClaim does not allow volume expansion.
+var ErrVolumeExpansionNotSupported = errors.New("volume expansion is not allowed by the StorageClass")
+
+// GetPersistentVolumeClaimCapacity returns the storage capacity of a bound PersistentVolumeClaim, which can exceed its request.
+func (c *Client) GetPersistentVolumeClaimCapacity(namespace, name string) (resource.Quantity, error) {
+	pvc, err := c.getPersistentVolumeClaim(namespace, name)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("error getting PersistentVolumeClaim %s: %w", name, err)
+	}
+	return pvc.Status.Capacity[v1.ResourceStorage], nil
+}
+
+// ExpandPersistentVolumeClaim increases the requested storage of a PersistentVolumeClaim.
+// It returns an error wrapping ErrVolumeExpansionNotSupported if the StorageClass of the PersistentVolumeClaim does not allow volume expansion, and fails if the size is not larger than the current request.
+func (c *Client) ExpandPersistentVolumeClaim(namespace, name string, size resource.Quantity) error {
+	pvc, err := c.getPersistentVolumeClaim(namespace, name)
+	if err != nil {
+		return fmt.Errorf("error getting PersistentVolumeClaim %s: %w", name, err)
+	}
+
+	current := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if size.Cmp(current) <= 0 {
+		return fmt.Errorf("new size %s of PersistentVolumeClaim %s must be larger than the current size %s", size.String(), name, current.String())
+	}
+
+	storageClassName := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClassName = *pvc.Spec.StorageClassName
+	}
+	allowed, err := c.StorageClassAllowsExpansion(storageClassName)
+	if err != nil {
+		return fmt.Errorf("error checking StorageClass of PersistentVolumeClaim %s: %w", name, err)
+	}
+	if !allowed {
+		return fmt.Errorf("StorageClass '%s' of PersistentVolumeClaim %s: %w", storageClassName, name, ErrVolumeExpansionNotSupported)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	pvc.Spec.Resources.Requests[v1.ResourceStorage] = size
+	if _, err := c.clientset.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, pvc, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error expanding PersistentVolumeClaim %s: %w", name, err)
+	}
+
+	log.Debugf("PersistentVolumeClaim %s expanded to %s", name, size.String())
+	return nil
+}
+
+// WaitPersistentVolumeClaimIsResized waits until the capacity of the PersistentVolumeClaim reaches the given size or the context is done.
+// The PersistentVolumeClaim is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitPersistentVolumeClaimIsResized(ctx context.Context, namespace, name string, labels map[string]string, size resource.Quantity) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
 	}
+	return waitFor(ctx, labels, c.clientset.CoreV1().PersistentVolumeClaims(namespace).Watch, func() (bool, error) {
+		pvc, err := c.getPersistentVolumeClaim(namespace, name)
+		if err != nil {
+			return false, err
+		}
+		capacity, ok := pvc.Status.Capacity[v1.ResourceStorage]
+		return ok && capacity.Cmp(size) >= 0, nil
+	})
 }
@@ -9,7 +9,7 @@ import (
 )
 
 // CreateRole creates a role
-func CreateRole(name, namespace string, labels map[string]string, apiGroups, resources, verbs []string) error {
+func (c *Client) CreateRole(name, namespace string, labels map[string]string, apiGroups, resources, verbs []string) error {
 
 	role := &rbacv1.Role{
 		ObjectMeta: metav1.ObjectMeta{
@@ -29,10 +29,35 @@ func CreateRole(name, namespace string, labels map[string]string, apiGroups, res
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if _, err := Clientset().RbacV1().Roles(namespace).Create(ctx, role, metav1.CreateOptions{}); err != nil {
+	if _, err := c.clientset.RbacV1().Roles(namespace).Create(ctx, role, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateRoleWithRules creates a role with the given rules
+func (c *Client) CreateRoleWithRules(name, namespace string, labels map[string]string, rules []rbacv1.PolicyRule) error {
+
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Rules: rules,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	if _, err := c.clientset.RbacV1().Roles(namespace).Create(ctx, role, metav1.CreateOptions{}); err != nil {
 		return err
 	}
 
@@ -40,15 +65,15 @@ func CreateRole(name, namespace string, labels map[string]string, apiGroups, res
 }
 
 // DeleteRole deletes a role
-func DeleteRole(name, namespace string) error {
+func (c *Client) DeleteRole(name, namespace string) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if err := Clientset().RbacV1().Roles(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+	if err := c.clientset.RbacV1().Roles(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
 		return err
 	}
 
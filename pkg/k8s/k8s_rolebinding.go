@@ -9,7 +9,7 @@ import (
 )
 
 // CreateRoleBinding creates a roleBinding
-func CreateRoleBinding(name, namespace string, labels map[string]string, role, serviceAccount string) error {
+func (c *Client) CreateRoleBinding(name, namespace string, labels map[string]string, role, serviceAccount string) error {
 
 	roleBinding := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
@@ -33,10 +33,10 @@ func CreateRoleBinding(name, namespace string, labels map[string]string, role, s
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if _, err := Clientset().RbacV1().RoleBindings(namespace).Create(ctx, roleBinding, metav1.CreateOptions{}); err != nil {
+	if _, err := c.clientset.RbacV1().RoleBindings(namespace).Create(ctx, roleBinding, metav1.CreateOptions{}); err != nil {
 		return err
 	}
 
@@ -44,15 +44,15 @@ func CreateRoleBinding(name, namespace string, labels map[string]string, role, s
 }
 
 // DeleteRoleBinding deletes a roleBinding
-func DeleteRoleBinding(name, namespace string) error {
+func (c *Client) DeleteRoleBinding(name, namespace string) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if err := Clientset().RbacV1().RoleBindings(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+	if err := c.clientset.RbacV1().RoleBindings(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
 		return err
 	}
 
@@ -4,7 +4,7 @@ import (
     "context"
     "errors"
     "fmt"
-    "github.com/sirupsen/logrus"
+    "github.com/celestiaorg/knuu/pkg/log"
     "time"
 
     v1 "k8s.io/api/core/v1"
@@ -12,25 +12,54 @@ import (
     "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// ServiceOptions contains optional specifications of a Service
+type ServiceOptions struct {
+	Type                          v1.ServiceType                      // Type of the Service, ClusterIP if empty
+	SessionAffinity               v1.ServiceAffinity                  // Session affinity of the Service, None if empty
+	SessionAffinityTimeoutSeconds *int32                              // Timeout of ClientIP session affinity, the Kubernetes default if nil
+	InternalTrafficPolicy         v1.ServiceInternalTrafficPolicyType // Internal traffic policy of the Service, Cluster if empty
+	ExternalTrafficPolicy         v1.ServiceExternalTrafficPolicyType // External traffic policy of the Service, only applied to NodePort and LoadBalancer Services
+	TargetPortsTCP                map[int]int                         // Container ports targeted by TCP service ports, ports without an entry target the same port
+	TargetPortsUDP                map[int]int                         // Container ports targeted by UDP service ports, ports without an entry target the same port
+}
+
 // GetService retrieves a service.
-func GetService(namespace, name string) (*v1.Service, error) {
+func (c *Client) GetService(namespace, name string) (*v1.Service, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	svc, err := Clientset().CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	svc, err := c.clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error getting service %s: %w", name, err)
 	}
 	return svc, nil
 }
 
+// WaitServiceIsDeleted waits until the service does not exist anymore or the context is done.
+// The service is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitServiceIsDeleted(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.CoreV1().Services(namespace).Watch, func() (bool, error) {
+		_, err := c.GetService(namespace, name)
+		if err != nil {
+			if isNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	})
+}
+
 // DeployService deploys a service if it does not exist.
-func DeployService(namespace, name string, labels, selectorMap map[string]string, portsTCP []int, portsUDP []int) (*v1.Service, error) {
+func (c *Client) DeployService(namespace, name string, labels, selectorMap map[string]string, portsTCP []int, portsUDP []int, opts ServiceOptions) (*v1.Service, error) {
 
-	svc, err := prepareService(namespace, name, labels, selectorMap, portsTCP, portsUDP)
+	svc, err := prepareService(namespace, name, labels, selectorMap, portsTCP, portsUDP, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing service %s: %w", name, err)
 	}
@@ -38,21 +67,21 @@ func DeployService(namespace, name string, labels, selectorMap map[string]string
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	serv, err := Clientset().CoreV1().Services(namespace).Create(ctx, svc, metav1.CreateOptions{})
+	serv, err := c.clientset.CoreV1().Services(namespace).Create(ctx, svc, metav1.CreateOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error creating service %s: %w", name, err)
 	}
-	logrus.Debugf("Service %s deployed in namespace %s", name, namespace)
+	log.Debugf("Service %s deployed in namespace %s", name, namespace)
 	return serv, nil
 }
 
 // PatchService patches an existing service.
-func PatchService(namespace, name string, labels, selectorMap map[string]string, portsTCP, portsUDP []int) error {
+func (c *Client) PatchService(namespace, name string, labels, selectorMap map[string]string, portsTCP, portsUDP []int, opts ServiceOptions) error {
 
-	svc, err := prepareService(namespace, name, labels, selectorMap, portsTCP, portsUDP)
+	svc, err := prepareService(namespace, name, labels, selectorMap, portsTCP, portsUDP, opts)
 	if err != nil {
 		return fmt.Errorf("error preparing service %s: %w", name, err)
 	}
@@ -60,43 +89,47 @@ func PatchService(namespace, name string, labels, selectorMap map[string]string,
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	_, err = Clientset().CoreV1().Services(namespace).Update(ctx, svc, metav1.UpdateOptions{})
+	_, err = c.clientset.CoreV1().Services(namespace).Update(ctx, svc, metav1.UpdateOptions{})
 	if err != nil {
 		return fmt.Errorf("error patching service %s: %w", name, err)
 	}
 
-	logrus.Debugf("Service %s patched in namespace %s", name, namespace)
+	log.Debugf("Service %s patched in namespace %s", name, namespace)
 	return nil
 }
 
 // DeleteService deletes a service if it exists.
-func DeleteService(namespace, name string) error {
+// A service that does not exist is skipped without reading it first, which saves a request per instance on cleanup.
+func (c *Client) DeleteService(namespace, name string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	_, err := GetService(namespace, name)
-	if err != nil {
-		return fmt.Errorf("error getting service %s: %w", name, err)
-	}
-
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	err = Clientset().CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	err := c.clientset.CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{})
 	if err != nil {
+		// If the service does not exist, skip and return without error
+		if isNotFound(err) {
+			return nil
+		}
 		return fmt.Errorf("error deleting service %s: %w", name, err)
 	}
 
-	logrus.Debugf("Service %s deleted in namespace %s", name, namespace)
+	log.Debugf("Service %s deleted in namespace %s", name, namespace)
 	return nil
 }
 
 // GetServiceIP retrieves the IP address of a service.
-func GetServiceIP(namespace, name string) (string, error) {
-	svc, err := GetService(namespace, name)
+func (c *Client) GetServiceIP(namespace, name string) (string, error) {
+	// The cluster IP of a service never changes, so it can be read from the cache, see StartCache
+	if svc, ok := c.cachedService(namespace, name); ok {
+		return svc.Spec.ClusterIP, nil
+	}
+	svc, err := c.GetService(namespace, name)
 	if err != nil {
 		return "", fmt.Errorf("error getting service %s: %w", name, err)
 	}
@@ -104,6 +137,7 @@ func GetServiceIP(namespace, name string) (string, error) {
 }
 
 // buildPorts constructs a list of ServicePort objects from the given TCP and UDP port lists.
+// The names of the ports include the protocol, as they must be unique within the Service even if a port number is used for both TCP and UDP, e.g. for DNS.
 func buildPorts(tcpPorts, udpPorts []int) []v1.ServicePort {
 	ports := make([]v1.ServicePort, 0, len(tcpPorts)+len(udpPorts))
 	for _, port := range tcpPorts {
@@ -127,7 +161,7 @@ func buildPorts(tcpPorts, udpPorts []int) []v1.ServicePort {
 
 // prepareService constructs a new Service object with the specified parameters.
 func prepareService(namespace, name string, labels, selectorMap map[string]string,
-	tcpPorts, udpPorts []int) (*v1.Service, error) {
+	tcpPorts, udpPorts []int, opts ServiceOptions) (*v1.Service, error) {
 	if namespace == "" {
 		return nil, errors.New("namespace is required")
 	}
@@ -158,5 +192,41 @@ func prepareService(namespace, name string, labels, selectorMap map[string]strin
 			Type:     v1.ServiceTypeClusterIP,
 		},
 	}
+	applyServiceOptions(svc, opts)
 	return svc, nil
 }
+
+// applyServiceOptions sets the optional specifications on the Service.
+func applyServiceOptions(svc *v1.Service, opts ServiceOptions) {
+	if opts.Type != "" {
+		svc.Spec.Type = opts.Type
+	}
+	if opts.SessionAffinity != "" {
+		svc.Spec.SessionAffinity = opts.SessionAffinity
+	}
+	if opts.SessionAffinity == v1.ServiceAffinityClientIP && opts.SessionAffinityTimeoutSeconds != nil {
+		timeoutSeconds := *opts.SessionAffinityTimeoutSeconds
+		svc.Spec.SessionAffinityConfig = &v1.SessionAffinityConfig{
+			ClientIP: &v1.ClientIPConfig{
+				TimeoutSeconds: &timeoutSeconds,
+			},
+		}
+	}
+	if opts.InternalTrafficPolicy != "" {
+		policy := opts.InternalTrafficPolicy
+		svc.Spec.InternalTrafficPolicy = &policy
+	}
+	for j, port := range svc.Spec.Ports {
+		targetPorts := opts.TargetPortsTCP
+		if port.Protocol == v1.ProtocolUDP {
+			targetPorts = opts.TargetPortsUDP
+		}
+		if targetPort, ok := targetPorts[int(port.Port)]; ok {
+			svc.Spec.Ports[j].TargetPort = intstr.FromInt(targetPort)
+		}
+	}
+	// The API server rejects an external traffic policy on other Service types
+	if opts.ExternalTrafficPolicy != "" && (svc.Spec.Type == v1.ServiceTypeNodePort || svc.Spec.Type == v1.ServiceTypeLoadBalancer) {
+		svc.Spec.ExternalTrafficPolicy = opts.ExternalTrafficPolicy
+	}
+}
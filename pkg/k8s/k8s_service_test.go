@@ -0,0 +1,72 @@
+package k8s
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestClient returns a client of the fake clientset in the namespace 'default'
+func newTestClient(t *testing.T, clientset *fake.Clientset) *Client {
+	t.Helper()
+	client, err := NewClientWithClientset(clientset, nil, "default")
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	return client
+}
+
+func TestDeleteService(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"}})
+	var verbs []string
+	clientset.PrependReactor("*", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		verbs = append(verbs, action.GetVerb())
+		if action.GetVerb() == "delete" && action.(k8stesting.DeleteAction).GetName() == "forbidden" {
+			return true, nil, apierrs.NewForbidden(schema.GroupResource{Resource: "services"}, "forbidden", nil)
+		}
+		return false, nil, nil
+	})
+	client := newTestClient(t, clientset)
+
+	if err := client.DeleteService("default", "existing"); err != nil {
+		t.Errorf("deleting existing service: %v", err)
+	}
+	if _, err := clientset.Tracker().Get(v1.SchemeGroupVersion.WithResource("services"), "default", "existing"); !apierrs.IsNotFound(err) {
+		t.Errorf("expected the service to be deleted, got %v", err)
+	}
+	if err := client.DeleteService("default", "missing"); err != nil {
+		t.Errorf("expected deleting a missing service to be skipped, got %v", err)
+	}
+	if err := client.DeleteService("default", "forbidden"); !apierrs.IsForbidden(err) {
+		t.Errorf("expected the error of deleting the service, got %v", err)
+	}
+	for _, verb := range verbs {
+		if verb != "delete" {
+			t.Errorf("expected services to be deleted without other requests, got '%s'", verb)
+		}
+	}
+}
+
+func TestDeployServiceWithSamePortOnTCPAndUDP(t *testing.T) {
+	client := newTestClient(t, fake.NewSimpleClientset())
+	service, err := client.DeployService("default", "dns", nil, map[string]string{"app": "dns"}, []int{53}, []int{53}, ServiceOptions{})
+	if err != nil {
+		t.Fatalf("deploying service: %v", err)
+	}
+	expected := map[string]v1.Protocol{"tcp-53": v1.ProtocolTCP, "udp-53": v1.ProtocolUDP}
+	if len(service.Spec.Ports) != len(expected) {
+		t.Fatalf("expected %d ports, got %+v", len(expected), service.Spec.Ports)
+	}
+	for _, port := range service.Spec.Ports {
+		if protocol, ok := expected[port.Name]; !ok || protocol != port.Protocol || port.Port != 53 {
+			t.Errorf("unexpected port '%s' with protocol '%s' and number %d", port.Name, port.Protocol, port.Port)
+		}
+		delete(expected, port.Name)
+	}
+}
@@ -9,7 +9,7 @@ import (
 )
 
 // CreateServiceAccount creates a service account
-func CreateServiceAccount(name, namespace string, labels map[string]string) error {
+func (c *Client) CreateServiceAccount(name, namespace string, labels map[string]string) error {
 
 	serviceAccount := &v1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
@@ -22,10 +22,10 @@ func CreateServiceAccount(name, namespace string, labels map[string]string) erro
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if _, err := Clientset().CoreV1().ServiceAccounts(namespace).Create(ctx, serviceAccount, metav1.CreateOptions{}); err != nil {
+	if _, err := c.clientset.CoreV1().ServiceAccounts(namespace).Create(ctx, serviceAccount, metav1.CreateOptions{}); err != nil {
 		return err
 	}
 
@@ -33,17 +33,36 @@ func CreateServiceAccount(name, namespace string, labels map[string]string) erro
 }
 
 // DeleteServiceAccount deletes a service account
-func DeleteServiceAccount(name, namespace string) error {
+func (c *Client) DeleteServiceAccount(name, namespace string) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
-	if err := Clientset().CoreV1().ServiceAccounts(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+	if err := c.clientset.CoreV1().ServiceAccounts(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ServiceAccountExists checks if a service account exists
+func (c *Client) ServiceAccountExists(name, namespace string) (bool, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return false, fmt.Errorf("knuu is not initialized")
+	}
+	if _, err := c.clientset.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
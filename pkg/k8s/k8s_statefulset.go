@@ -5,22 +5,24 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/celestiaorg/knuu/pkg/log"
 	appv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 )
 
 // getStatefulSet retrieves a statefulSet from the given namespace and logs any errors.
-func getStatefulSet(namespace, name string) (*appv1.StatefulSet, error) {
+func (c *Client) getStatefulSet(namespace, name string) (*appv1.StatefulSet, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	statefulset, err := Clientset().AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	statefulset, err := c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get statefulSet %s: %w", name, err)
 	}
@@ -28,8 +30,33 @@ func getStatefulSet(namespace, name string) (*appv1.StatefulSet, error) {
 	return statefulset, nil
 }
 
+// GetStatefulSet returns the statefulSet with the given name in the given namespace.
+func (c *Client) GetStatefulSet(namespace, name string) (*appv1.StatefulSet, error) {
+	return c.getStatefulSet(namespace, name)
+}
+
+// GetWorkloadLabels returns the labels of the statefulSet or, if there is none, the service with the given name in the given namespace.
+// It returns nil if neither exists.
+func (c *Client) GetWorkloadLabels(namespace, name string) (map[string]string, error) {
+	statefulSet, err := c.getStatefulSet(namespace, name)
+	if err == nil {
+		return statefulSet.Labels, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+	service, err := c.GetService(namespace, name)
+	if err == nil {
+		return service.Labels, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+	return nil, nil
+}
+
 // DeployStatefulSet creates a new statefulSet in the given namespace if it doesn't already exist.
-func DeployStatefulSet(statefulSetConfig StatefulSetConfig, init bool) (*appv1.StatefulSet, error) {
+func (c *Client) DeployStatefulSet(statefulSetConfig StatefulSetConfig, init bool) (*appv1.StatefulSet, error) {
 	// Prepare the pod
 	statefulSet, err := prepareStatefulSet(statefulSetConfig, init)
 	if err != nil {
@@ -40,12 +67,12 @@ func DeployStatefulSet(statefulSetConfig StatefulSetConfig, init bool) (*appv1.S
 	defer cancel()
 
 	// Try to create the statefulSet
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return nil, fmt.Errorf("knuu is not initialized")
 	}
-	createdStatefulSet, err := Clientset().AppsV1().StatefulSets(statefulSetConfig.Namespace).Create(ctx, statefulSet, metav1.CreateOptions{})
+	createdStatefulSet, err := c.clientset.AppsV1().StatefulSets(statefulSetConfig.Namespace).Create(ctx, statefulSet, metav1.CreateOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create statefulSet: %v", err)
+		return nil, fmt.Errorf("failed to create statefulSet: %w", err)
 	}
 
 	return createdStatefulSet, nil
@@ -53,34 +80,32 @@ func DeployStatefulSet(statefulSetConfig StatefulSetConfig, init bool) (*appv1.S
 
 // StatefulSetConfig contains the specifications for creating a new StatefulSet object
 type StatefulSetConfig struct {
-	Name      string            // Name of the statefulSet
-	Namespace string            // Namespace of the statefulSet
-	Labels    map[string]string // Labels to apply to the statefulSet
-	Replicas  int32             // Number of replicas
-	PodConfig PodConfig         // Pod configuration
+	Name           string                          // Name of the statefulSet
+	Namespace      string                          // Namespace of the statefulSet
+	Labels         map[string]string               // Labels to apply to the statefulSet
+	ExtraLabels    map[string]string               // Labels to apply to the statefulSet only, not to its selector and pods
+	Replicas       int32                           // Number of replicas
+	UpdateStrategy appv1.StatefulSetUpdateStrategy // Update strategy, defaults to RollingUpdate if empty
+	PodConfig      PodConfig                       // Pod configuration
 }
 
 // ReplaceStatefulSetWithGracePeriod replaces a statefulSet in the given namespace and returns the new statefulSet object with a grace period.
-func ReplaceStatefulSetWithGracePeriod(statefulSetConfig StatefulSetConfig, gracePeriod *int64) (*appv1.StatefulSet, error) {
+func (c *Client) ReplaceStatefulSetWithGracePeriod(statefulSetConfig StatefulSetConfig, gracePeriod *int64) (*appv1.StatefulSet, error) {
 	// Log a debug message to indicate that we are replacing a pod
-	logrus.Debugf("Replacing statefulSet %s", statefulSetConfig.Name)
+	log.Debugf("Replacing statefulSet %s", statefulSetConfig.Name)
 
 	// Delete the existing pod (if any)
-	if err := DeleteStatefulSetWithGracePeriod(statefulSetConfig.Namespace, statefulSetConfig.Name, gracePeriod); err != nil {
+	if err := c.DeleteStatefulSetWithGracePeriod(statefulSetConfig.Namespace, statefulSetConfig.Name, gracePeriod); err != nil {
 		return nil, fmt.Errorf("failed to delete statefulSet: %v", err)
 	}
 
 	// Wait for the pod to be fully deleted
-	for {
-		_, err := getStatefulSet(statefulSetConfig.Namespace, statefulSetConfig.Name)
-		if err != nil {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
+	if err := c.WaitStatefulSetIsDeleted(context.Background(), statefulSetConfig.Namespace, statefulSetConfig.Name, statefulSetConfig.Labels); err != nil {
+		return nil, fmt.Errorf("failed to wait for statefulSet to be deleted: %v", err)
 	}
 
 	// Deploy the new pod
-	statefulSet, err := DeployStatefulSet(statefulSetConfig, false)
+	statefulSet, err := c.DeployStatefulSet(statefulSetConfig, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to deploy statefulSet: %v", err)
 	}
@@ -90,15 +115,15 @@ func ReplaceStatefulSetWithGracePeriod(statefulSetConfig StatefulSetConfig, grac
 }
 
 // ReplaceStatefulSet replaces a statefulSet in the given namespace and returns the new StatefulSet object.
-func ReplaceStatefulSet(statefulSetConfig StatefulSetConfig) (*appv1.StatefulSet, error) {
-	return ReplaceStatefulSetWithGracePeriod(statefulSetConfig, nil)
+func (c *Client) ReplaceStatefulSet(statefulSetConfig StatefulSetConfig) (*appv1.StatefulSet, error) {
+	return c.ReplaceStatefulSetWithGracePeriod(statefulSetConfig, nil)
 }
 
 // IsStatefulSetRunning returns true if the statefulSet is running.
-func IsStatefulSetRunning(namespace, name string) (bool, error) {
+func (c *Client) IsStatefulSetRunning(namespace, name string) (bool, error) {
 
 	// Get the statefulSet from Kubernetes API server
-	statefulSet, err := getStatefulSet(namespace, name)
+	statefulSet, err := c.getStatefulSet(namespace, name)
 	if err != nil {
 		return false, fmt.Errorf("failed to get pod: %v", err)
 	}
@@ -107,35 +132,86 @@ func IsStatefulSetRunning(namespace, name string) (bool, error) {
 	return statefulSet.Status.ReadyReplicas == *statefulSet.Spec.Replicas, nil
 }
 
-// DeleteStatefulSetWithGracePeriod deletes a statefulSet with the given name in the specified namespace.
-func DeleteStatefulSetWithGracePeriod(namespace, name string, gracePeriodSeconds *int64) error {
-	// Get the statefulSet object from the API server
-	_, err := getStatefulSet(namespace, name)
-	if err != nil {
-		// If the statefulSet does not exist, skip and return without error
-		return nil
+// WaitStatefulSetIsRunning waits until the statefulSet is running or the context is done.
+// The statefulSet is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitStatefulSetIsRunning(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.AppsV1().StatefulSets(namespace).Watch, func() (bool, error) {
+		statefulSet, err := c.getStatefulSet(namespace, name)
+		if err != nil {
+			if isNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return statefulSet.Status.ReadyReplicas == *statefulSet.Spec.Replicas, nil
+	})
+}
+
+// WaitStatefulSetIsStopped waits until the statefulSet is not running anymore or the context is done.
+// The statefulSet is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitStatefulSetIsStopped(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.AppsV1().StatefulSets(namespace).Watch, func() (bool, error) {
+		statefulSet, err := c.getStatefulSet(namespace, name)
+		if err != nil {
+			if isNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return statefulSet.Status.ReadyReplicas != *statefulSet.Spec.Replicas, nil
+	})
+}
+
+// WaitStatefulSetIsDeleted waits until the statefulSet does not exist anymore or the context is done.
+// The statefulSet is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitStatefulSetIsDeleted(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
 	}
+	return waitFor(ctx, labels, c.clientset.AppsV1().StatefulSets(namespace).Watch, func() (bool, error) {
+		_, err := c.getStatefulSet(namespace, name)
+		if err != nil {
+			if isNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	})
+}
 
+// DeleteStatefulSetWithGracePeriod deletes a statefulSet with the given name in the specified namespace.
+// A statefulSet that does not exist is skipped without reading it first, which saves a request per instance on cleanup.
+func (c *Client) DeleteStatefulSetWithGracePeriod(namespace, name string, gracePeriodSeconds *int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
 	// Delete the statefulSet using the Kubernetes client API
-	if !IsInitialized() {
+	if !c.IsInitialized() {
 		return fmt.Errorf("knuu is not initialized")
 	}
 	deleteOptions := metav1.DeleteOptions{
 		GracePeriodSeconds: gracePeriodSeconds,
 	}
-	if err := Clientset().AppsV1().StatefulSets(namespace).Delete(ctx, name, deleteOptions); err != nil {
-		return fmt.Errorf("failed to delete statefulSet %s: %v", name, err)
+	if err := c.clientset.AppsV1().StatefulSets(namespace).Delete(ctx, name, deleteOptions); err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete statefulSet %s: %w", name, err)
 	}
 
 	return nil
 }
 
 // DeleteStatefulSet deletes a statefulSet with the given name in the specified namespace.
-func DeleteStatefulSet(namespace, name string) error {
-	return DeleteStatefulSetWithGracePeriod(namespace, name, nil)
+func (c *Client) DeleteStatefulSet(namespace, name string) error {
+	return c.DeleteStatefulSetWithGracePeriod(namespace, name, nil)
 }
 
 // preparePod prepares a pod configuration.
@@ -146,40 +222,218 @@ func prepareStatefulSet(statefulSetConfig StatefulSetConfig, init bool) (*appv1.
 	replicas := statefulSetConfig.Replicas
 	podConfig := statefulSetConfig.PodConfig
 
+	if podConfig.RestartPolicy != "" {
+		if err := ValidateRestartPolicy("StatefulSet", podConfig.RestartPolicy); err != nil {
+			return nil, fmt.Errorf("invalid pod config: %w", err)
+		}
+	}
+
 	podSpec, err := preparePodSpec(podConfig, init)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare pod spec: %w", err)
 	}
 
+	// Extra labels can change without rolling out the pods or breaking the immutable selector
+	objectLabels := make(map[string]string, len(labels)+len(statefulSetConfig.ExtraLabels))
+	for key, val := range labels {
+		objectLabels[key] = val
+	}
+	for key, val := range statefulSetConfig.ExtraLabels {
+		objectLabels[key] = val
+	}
+
 	// Construct the StatefulSet object using the above data
 	statefulSet := &appv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: namespace,
 			Name:      name,
-			Labels:    labels,
+			Labels:    objectLabels,
 		},
 		Spec: appv1.StatefulSetSpec{
-			Replicas:    &replicas,
-			Selector:    &metav1.LabelSelector{MatchLabels: labels},
-			ServiceName: name,
+			Replicas:       &replicas,
+			Selector:       &metav1.LabelSelector{MatchLabels: labels},
+			ServiceName:    name,
+			UpdateStrategy: statefulSetConfig.UpdateStrategy,
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Namespace: namespace,
-					Name:      name,
-					Labels:    labels,
+					Namespace:   namespace,
+					Name:        name,
+					Labels:      labels,
+					Annotations: statefulSetConfig.PodConfig.Annotations,
 				},
 				Spec: podSpec,
 			},
 		},
 	}
 
-	logrus.Debugf("Prepared statefulSet %s in namespace %s", name, namespace)
+	log.Debugf("Prepared statefulSet %s in namespace %s", name, namespace)
 
 	return statefulSet, nil
 }
 
+// RestartStatefulSet triggers a rollout of the statefulSet's pods by bumping an annotation of the pod template.
+// The pods are replaced according to the update strategy of the statefulSet.
+func (c *Client) RestartStatefulSet(ctx context.Context, namespace, name string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":"%s"}}}}}`, time.Now().Format(time.RFC3339))
+	_, err := c.clientset.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to restart statefulSet %s: %w", name, err)
+	}
+
+	log.Debugf("Restarted statefulSet %s in namespace %s", name, namespace)
+	return nil
+}
+
+// UpdateStatefulSetEnv sets the environment variables of a container of the statefulSet's pod template and triggers a rollout.
+// Environment variables set from other sources (e.g. the downward API) are kept, and the pods keep their volumes.
+// The rollout is triggered even if the environment variables did not change.
+func (c *Client) UpdateStatefulSetEnv(ctx context.Context, namespace, name, containerName string, env map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	statefulSets := c.clientset.AppsV1().StatefulSets(namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		statefulSet, err := statefulSets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for j := range statefulSet.Spec.Template.Spec.Containers {
+			container := &statefulSet.Spec.Template.Spec.Containers[j]
+			if container.Name != containerName {
+				continue
+			}
+			found = true
+			newEnv := buildEnv(env)
+			for _, envVar := range container.Env {
+				if envVar.ValueFrom != nil {
+					if _, ok := env[envVar.Name]; !ok {
+						newEnv = append(newEnv, envVar)
+					}
+				}
+			}
+			container.Env = newEnv
+		}
+		if !found {
+			return fmt.Errorf("container %s not found", containerName)
+		}
+
+		if statefulSet.Spec.Template.Annotations == nil {
+			statefulSet.Spec.Template.Annotations = map[string]string{}
+		}
+		statefulSet.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().Format(time.RFC3339)
+
+		_, err = statefulSets.Update(ctx, statefulSet, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update environment of statefulSet %s: %w", name, err)
+	}
+
+	log.Debugf("Updated environment of statefulSet %s in namespace %s", name, namespace)
+	return nil
+}
+
+// SetStatefulSetLabel sets a label of the statefulSet, without changing its selector or pods.
+func (c *Client) SetStatefulSetLabel(namespace, name, key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, key, value)
+	_, err := c.clientset.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to set label %s of statefulSet %s: %w", key, name, err)
+	}
+
+	log.Debugf("Set label %s of statefulSet %s in namespace %s to %s", key, name, namespace, value)
+	return nil
+}
+
+// WaitStatefulSetIsUpdated waits until the pods of the statefulSet run its current pod template and are ready, or the context is done.
+// Pods below the partition of a rolling update are not awaited, as they are not updated.
+// The statefulSet is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitStatefulSetIsUpdated(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.AppsV1().StatefulSets(namespace).Watch, func() (bool, error) {
+		statefulSet, err := c.getStatefulSet(namespace, name)
+		if err != nil {
+			return false, err
+		}
+		replicas := *statefulSet.Spec.Replicas
+		expectedUpdated := replicas
+		if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+			expectedUpdated -= *rollingUpdate.Partition
+		}
+		return statefulSet.Status.ObservedGeneration >= statefulSet.Generation &&
+			statefulSet.Status.UpdatedReplicas >= expectedUpdated &&
+			statefulSet.Status.ReadyReplicas == replicas, nil
+	})
+}
+
+// ScaleStatefulSet sets the number of replicas of the statefulSet.
+// The PersistentVolumeClaims used by the statefulSet are kept when scaling down, also when scaling to zero.
+func (c *Client) ScaleStatefulSet(ctx context.Context, namespace, name string, replicas int32) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	statefulSets := c.clientset.AppsV1().StatefulSets(namespace)
+	scale, err := statefulSets.GetScale(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("statefulSet %s not found in namespace %s: %w", name, namespace, err)
+		}
+		return fmt.Errorf("failed to get scale of statefulSet %s: %w", name, err)
+	}
+	scale.Spec.Replicas = replicas
+	if _, err := statefulSets.UpdateScale(ctx, name, scale, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to scale statefulSet %s: %w", name, err)
+	}
+
+	log.Debugf("Scaled statefulSet %s in namespace %s to %d replicas", name, namespace, replicas)
+	return nil
+}
+
+// WaitStatefulSetIsScaled waits until the statefulSet has exactly the desired number of pods and all of them are ready, or the context is done.
+// The statefulSet is watched using the given labels, falling back to polling if watching is not permitted.
+func (c *Client) WaitStatefulSetIsScaled(ctx context.Context, namespace, name string, labels map[string]string) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return waitFor(ctx, labels, c.clientset.AppsV1().StatefulSets(namespace).Watch, func() (bool, error) {
+		statefulSet, err := c.getStatefulSet(namespace, name)
+		if err != nil {
+			return false, err
+		}
+		replicas := *statefulSet.Spec.Replicas
+		return statefulSet.Status.ObservedGeneration >= statefulSet.Generation &&
+			statefulSet.Status.Replicas == replicas &&
+			statefulSet.Status.ReadyReplicas == replicas, nil
+	})
+}
+
+// ListStatefulSetPods returns the pods selected by the statefulSet with the given name in the given namespace, sorted by name.
+func (c *Client) ListStatefulSetPods(namespace, name string) ([]v1.Pod, error) {
+	statefulSet, err := c.getStatefulSet(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if statefulSet.Spec.Selector == nil {
+		return nil, fmt.Errorf("statefulSet %s has no selector", name)
+	}
+	return c.ListPods(namespace, statefulSet.Spec.Selector.MatchLabels)
+}
+
 // GetFirstPod returns the first pod of a statefulset.
-func GetFirstPodFromStatefulSet(namespace, name string) (*v1.Pod, error) {
+func (c *Client) GetFirstPodFromStatefulSet(namespace, name string) (*v1.Pod, error) {
 	podName := fmt.Sprintf("%s-0", name)
-	return getPod(namespace, podName)
+	return c.getPod(namespace, podName)
 }
@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultStorageClassAnnotation marks the default StorageClass of a cluster.
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// StorageClassAllowsExpansion checks if volumes of the StorageClass can be expanded.
+// If the name is empty, the default StorageClass of the cluster is checked.
+func (c *Client) StorageClassAllowsExpansion(name string) (bool, error) {
+	storageClass, err := c.getStorageClass(name)
+	if err != nil {
+		return false, err
+	}
+	return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
+
+// StorageClassBindsImmediately checks if volumes of the StorageClass are provisioned when the PersistentVolumeClaim is created,
+// rather than when the first pod using it is scheduled.
+// If the name is empty, the default StorageClass of the cluster is checked.
+func (c *Client) StorageClassBindsImmediately(name string) (bool, error) {
+	storageClass, err := c.getStorageClass(name)
+	if err != nil {
+		return false, err
+	}
+	return storageClass.VolumeBindingMode == nil || *storageClass.VolumeBindingMode == storagev1.VolumeBindingImmediate, nil
+}
+
+// getStorageClass retrieves a StorageClass, or the default StorageClass of the cluster if the name is empty.
+func (c *Client) getStorageClass(name string) (*storagev1.StorageClass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if !c.IsInitialized() {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	if name == "" {
+		storageClasses, err := c.clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error listing StorageClasses: %w", err)
+		}
+		for i := range storageClasses.Items {
+			if storageClasses.Items[i].Annotations[defaultStorageClassAnnotation] == "true" {
+				return &storageClasses.Items[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no default StorageClass found")
+	}
+
+	storageClass, err := c.clientset.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting StorageClass %s: %w", name, err)
+	}
+	return storageClass, nil
+}
@@ -0,0 +1,86 @@
+package k8s
+
+import (
+	"context"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// pollInterval is the interval used when waiting by polling instead of watching
+const pollInterval = 1 * time.Second
+
+// watchFunc starts a watch for resources matching the given options
+type watchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+
+// conditionFunc returns true if the awaited condition is met
+type conditionFunc func() (bool, error)
+
+// waitFor waits until the condition is met or the context is done.
+// The condition is re-evaluated whenever a resource selected by the labels changes.
+// If the watch cannot be established (e.g. because of missing permissions) it falls back to polling.
+// If the watch is closed by the API server it is re-established.
+func waitFor(ctx context.Context, labels map[string]string, watchFn watchFunc, condition conditionFunc) error {
+	selector := k8slabels.SelectorFromSet(labels).String()
+	for {
+		watcher, err := watchFn(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			log.Debugf("Cannot watch resources with labels '%s', falling back to polling: %v", selector, err)
+			return pollUntil(ctx, condition)
+		}
+		done, err := waitForWatchEvents(ctx, watcher, condition)
+		watcher.Stop()
+		if err != nil || done {
+			return err
+		}
+
+		log.Debugf("Watch for resources with labels '%s' was closed, re-establishing it", selector)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// waitForWatchEvents evaluates the condition on every event of the watcher.
+// It returns false without error if the watch was closed before the condition was met.
+func waitForWatchEvents(ctx context.Context, watcher watch.Interface, condition conditionFunc) (bool, error) {
+	// Evaluate once after the watch is established, so that changes before it are not missed
+	if done, err := condition(); err != nil || done {
+		return done, err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case result, ok := <-watcher.ResultChan():
+			if !ok || result.Type == watch.Error {
+				return false, nil
+			}
+			if done, err := condition(); err != nil || done {
+				return done, err
+			}
+		}
+	}
+}
+
+// pollUntil evaluates the condition on a fixed interval until it is met or the context is done.
+func pollUntil(ctx context.Context, condition conditionFunc) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if done, err := condition(); err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
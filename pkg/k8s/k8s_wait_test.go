@@ -0,0 +1,162 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testWatches returns a fake clientset whose pod watches are served by the function, which gets the number of the watch
+func testWatches(serve func(n int) (watch.Interface, error)) (*fake.Clientset, *int32) {
+	clientset := fake.NewSimpleClientset()
+	var watches int32
+	clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watcher, err := serve(int(atomic.AddInt32(&watches, 1)))
+		return true, watcher, err
+	})
+	return clientset, &watches
+}
+
+func TestWaitForReestablishesClosedWatch(t *testing.T) {
+	var ready atomic.Bool
+	second := watch.NewFake()
+	defer second.Stop()
+	clientset, watches := testWatches(func(n int) (watch.Interface, error) {
+		if n == 1 {
+			// The API server closes the first watch before the condition is met
+			first := watch.NewFake()
+			go first.Stop()
+			return first, nil
+		}
+		ready.Store(true)
+		return second, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := waitFor(ctx, map[string]string{"app": "test"}, clientset.CoreV1().Pods("default").Watch, func() (bool, error) {
+		return ready.Load(), nil
+	})
+	if err != nil {
+		t.Fatalf("waiting: %v", err)
+	}
+	if n := atomic.LoadInt32(watches); n != 2 {
+		t.Errorf("expected the closed watch to be re-established once, got %d watches", n)
+	}
+}
+
+func TestWaitForReestablishesWatchAfterErrorEvent(t *testing.T) {
+	var ready atomic.Bool
+	clientset, watches := testWatches(func(n int) (watch.Interface, error) {
+		watcher := watch.NewFake()
+		if n == 1 {
+			go watcher.Error(&metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonExpired})
+			return watcher, nil
+		}
+		ready.Store(true)
+		return watcher, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := waitFor(ctx, nil, clientset.CoreV1().Pods("default").Watch, func() (bool, error) {
+		return ready.Load(), nil
+	})
+	if err != nil {
+		t.Fatalf("waiting: %v", err)
+	}
+	if n := atomic.LoadInt32(watches); n != 2 {
+		t.Errorf("expected the watch to be re-established after the error event, got %d watches", n)
+	}
+}
+
+func TestWaitForFallsBackToPolling(t *testing.T) {
+	forbidden := apierrs.NewForbidden(schema.GroupResource{Resource: "pods"}, "", errors.New("watch is not permitted"))
+	clientset, watches := testWatches(func(n int) (watch.Interface, error) {
+		return nil, forbidden
+	})
+
+	var mu sync.Mutex
+	checks := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := waitFor(ctx, nil, clientset.CoreV1().Pods("default").Watch, func() (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		checks++
+		return checks == 2, nil
+	})
+	if err != nil {
+		t.Fatalf("waiting: %v", err)
+	}
+	if n := atomic.LoadInt32(watches); n != 1 {
+		t.Errorf("expected polling after the watch failed, got %d watches", n)
+	}
+	if checks != 2 {
+		t.Errorf("expected the condition to be polled until it is met, got %d checks", checks)
+	}
+}
+
+func TestWaitForWatchEvents(t *testing.T) {
+	t.Run("condition met by event", func(t *testing.T) {
+		watcher := watch.NewFakeWithChanSize(1, false)
+		defer watcher.Stop()
+		watcher.Modify(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}})
+		// The condition is evaluated once when the watch is established and again for the event
+		checks := 0
+		done, err := waitForWatchEvents(context.Background(), watcher, func() (bool, error) {
+			checks++
+			return checks == 2, nil
+		})
+		if err != nil || !done {
+			t.Errorf("expected the condition to be met, got %v, %v", done, err)
+		}
+	})
+
+	t.Run("channel closed", func(t *testing.T) {
+		watcher := watch.NewFake()
+		go watcher.Stop()
+		done, err := waitForWatchEvents(context.Background(), watcher, func() (bool, error) {
+			return false, nil
+		})
+		if err != nil || done {
+			t.Errorf("expected the closed watch to be reported without error, got %v, %v", done, err)
+		}
+	})
+
+	t.Run("condition error", func(t *testing.T) {
+		watcher := watch.NewFake()
+		defer watcher.Stop()
+		conditionErr := errors.New("condition failed")
+		_, err := waitForWatchEvents(context.Background(), watcher, func() (bool, error) {
+			return false, conditionErr
+		})
+		if !errors.Is(err, conditionErr) {
+			t.Errorf("expected the error of the condition, got %v", err)
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		watcher := watch.NewFake()
+		defer watcher.Stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := waitForWatchEvents(ctx, watcher, func() (bool, error) {
+			return false, nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the error of the context, got %v", err)
+		}
+	})
+}
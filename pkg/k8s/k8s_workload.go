@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// helmHookAnnotation marks resources that are Helm hooks.
+const helmHookAnnotation = "helm.sh/hook"
+
+// WaitObjectsAreReady waits until all workloads (Deployments, StatefulSets, DaemonSets and Jobs) among the objects are ready.
+// Other objects are considered ready once they exist.
+// If the context is done, the error names the objects that are still pending.
+// If a Job fails, its failure (and whether it is a Helm hook) is returned immediately.
+// A Helm hook Job that no longer exists is considered done, as it is deleted by its deletion policy once it finished.
+func (c *Client) WaitObjectsAreReady(ctx context.Context, objects []AppliedObject) error {
+	if !c.IsInitialized() {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	if err := c.checkDynamicClient(); err != nil {
+		return err
+	}
+
+	var pending []string
+	err := pollUntil(ctx, func() (bool, error) {
+		pending = pending[:0]
+		for _, obj := range objects {
+			ready, current, err := c.isObjectReady(ctx, obj)
+			if err != nil {
+				return false, err
+			}
+			if !ready {
+				pending = append(pending, describeObject(obj, current))
+			}
+		}
+		return len(pending) == 0, nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return fmt.Errorf("timed out waiting for pending resources: %s", strings.Join(pending, ", "))
+	}
+	return err
+}
+
+// isObjectReady checks if the object exists and, if it is a workload, if it is ready.
+// It returns the current object, which is nil if it could not be read.
+func (c *Client) isObjectReady(ctx context.Context, obj AppliedObject) (bool, *unstructured.Unstructured, error) {
+	current, err := c.dynamicClient.Resource(obj.Resource).Namespace(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return obj.GroupVersionKind.Kind == "Job" && obj.Hook != "", nil, nil
+		}
+		if ctx.Err() != nil {
+			return false, nil, ctx.Err()
+		}
+		log.Debugf("Error getting %s: %v", obj, err)
+		return false, nil, nil
+	}
+	ready, err := isWorkloadReady(obj, current)
+	return ready, current, err
+}
+
+// isWorkloadReady checks if the current object is ready, objects that are not workloads are always ready.
+func isWorkloadReady(obj AppliedObject, current *unstructured.Unstructured) (bool, error) {
+
+	switch obj.GroupVersionKind.Kind {
+	case "Deployment", "StatefulSet":
+		replicas, found, _ := unstructured.NestedInt64(current.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		readyReplicas, _, _ := unstructured.NestedInt64(current.Object, "status", "readyReplicas")
+		observedGeneration, _, _ := unstructured.NestedInt64(current.Object, "status", "observedGeneration")
+		return observedGeneration >= current.GetGeneration() && readyReplicas >= replicas, nil
+	case "DaemonSet":
+		desired, _, _ := unstructured.NestedInt64(current.Object, "status", "desiredNumberScheduled")
+		ready, _, _ := unstructured.NestedInt64(current.Object, "status", "numberReady")
+		observedGeneration, _, _ := unstructured.NestedInt64(current.Object, "status", "observedGeneration")
+		return observedGeneration >= current.GetGeneration() && ready >= desired, nil
+	case "Job":
+		if message, failed := jobFailure(current); failed {
+			return false, fmt.Errorf("%s failed: %s", describeObject(obj, current), message)
+		}
+		succeeded, _, _ := unstructured.NestedInt64(current.Object, "status", "succeeded")
+		return succeeded > 0, nil
+	}
+	return true, nil
+}
+
+// jobFailure returns the message of the Failed condition of a Job, if it has failed.
+func jobFailure(job *unstructured.Unstructured) (string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(job.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Failed" && condition["status"] == "True" {
+			return fmt.Sprintf("%v: %v", condition["reason"], condition["message"]), true
+		}
+	}
+	return "", false
+}
+
+// describeObject describes the object for error messages, naming Helm hooks as such.
+// The hook of the current object is preferred, as it may have changed since the object was applied.
+func describeObject(obj AppliedObject, current *unstructured.Unstructured) string {
+	hook := obj.Hook
+	if current != nil {
+		hook = current.GetAnnotations()[helmHookAnnotation]
+	}
+	if hook != "" {
+		return fmt.Sprintf("%s hook %s", hook, obj)
+	}
+	return obj.String()
+}
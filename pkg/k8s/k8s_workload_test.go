@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// jobs is the resource of Jobs
+var jobs = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+
+// testJob returns an applied Job and its object in the namespace 'default', which is a Helm hook if hook is not empty
+func testJob(name, hook string, succeeded int64) (AppliedObject, *unstructured.Unstructured) {
+	job := &unstructured.Unstructured{}
+	job.SetAPIVersion("batch/v1")
+	job.SetKind("Job")
+	job.SetNamespace("default")
+	job.SetName(name)
+	if hook != "" {
+		job.SetAnnotations(map[string]string{helmHookAnnotation: hook})
+	}
+	_ = unstructured.SetNestedField(job.Object, succeeded, "status", "succeeded")
+	applied := AppliedObject{
+		GroupVersionKind: job.GroupVersionKind(),
+		Resource:         jobs,
+		Namespace:        "default",
+		Name:             name,
+		Hook:             hook,
+	}
+	return applied, job
+}
+
+// newTestDynamicClient returns a client of a fake clientset and a fake dynamic client with the objects
+func newTestDynamicClient(t *testing.T, objects ...runtime.Object) *Client {
+	t.Helper()
+	client, err := NewClientWithClientset(fake.NewSimpleClientset(), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...), "default")
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	return client
+}
+
+func TestWaitObjectsAreReadyTreatsDeletedHookJobsAsDone(t *testing.T) {
+	// The hook Job was deleted by its deletion policy after it succeeded
+	deletedHook, _ := testJob("migrate", "pre-install", 1)
+	succeeded, succeededJob := testJob("seed", "", 1)
+	client := newTestDynamicClient(t, succeededJob)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitObjectsAreReady(ctx, []AppliedObject{deletedHook, succeeded}); err != nil {
+		t.Fatalf("expected deleted hook Job to be done, got %v", err)
+	}
+}
+
+func TestWaitObjectsAreReadyNamesPendingHooks(t *testing.T) {
+	pendingHook, pendingHookJob := testJob("migrate", "pre-install", 0)
+	// The hook is read from the current object, even if it was not known when the object was applied
+	pendingHook.Hook = ""
+	deleted, _ := testJob("seed", "", 0)
+	client := newTestDynamicClient(t, pendingHookJob)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := client.WaitObjectsAreReady(ctx, []AppliedObject{pendingHook, deleted})
+	if err == nil {
+		t.Fatal("expected error waiting for pending Jobs")
+	}
+	for _, pending := range []string{"pre-install hook Job/migrate", "Job/seed"} {
+		if !strings.Contains(err.Error(), pending) {
+			t.Errorf("expected error to name '%s' as pending, got %v", pending, err)
+		}
+	}
+}
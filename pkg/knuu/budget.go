@@ -0,0 +1,164 @@
+package knuu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultTeardownReserve is the time reserved for the teardown before the deadline of the global timeout
+const defaultTeardownReserve = 2 * time.Minute
+
+// ErrBudgetExhausted is returned by Start and StartAll if less than the teardown reserve is left until the deadline of the
+// global timeout, see SetGlobalTimeout
+var ErrBudgetExhausted = errors.New("test budget exhausted")
+
+// globalTimeout is the global timeout set by SetGlobalTimeout, zero if there is none
+var globalTimeout time.Duration
+
+// teardownReserve is the teardown reserve set by SetTeardownReserve, if zero the default is used
+var teardownReserve time.Duration
+
+// budgetDumpDir is the directory the instances are dumped to when the budget is exhausted, set by SetBudgetDumpDir
+var budgetDumpDir string
+
+// SetGlobalTimeout sets the time the whole test may take, counted from the initialization of knuu, e.g. the timeout of the CI job
+// Once less than the teardown reserve (see SetTeardownReserve) is left, starting instances fails with ErrBudgetExhausted,
+// and all instances of the session are dumped (see SetBudgetDumpDir) and destroyed, so that the teardown finishes before the test is killed
+// As instances must not be used concurrently, the teardown is done by the next call of Start, StartAll or Destroy of the test
+// This function can only be called before knuu is initialized
+func SetGlobalTimeout(timeout time.Duration) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the global timeout is only allowed before knuu is initialized")
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("global timeout must be positive, got '%s'", timeout)
+	}
+	globalTimeout = timeout
+	log.Debugf("Set global timeout to '%s'", timeout)
+	return nil
+}
+
+// SetTeardownReserve sets the time reserved for the teardown before the deadline of the global timeout, 2 minutes by default
+// This function can only be called before knuu is initialized
+func SetTeardownReserve(reserve time.Duration) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the teardown reserve is only allowed before knuu is initialized")
+	}
+	if reserve <= 0 {
+		return fmt.Errorf("teardown reserve must be positive, got '%s'", reserve)
+	}
+	teardownReserve = reserve
+	log.Debugf("Set teardown reserve to '%s'", reserve)
+	return nil
+}
+
+// SetBudgetDumpDir sets the directory the instances are dumped to (see DumpAll) before they are destroyed because the budget of
+// the global timeout is exhausted, by default a directory named after the identifier in the temporary directory is used
+func SetBudgetDumpDir(dir string) {
+	budgetDumpDir = dir
+	if defaultKnuu != nil {
+		defaultKnuu.budgetDumpDir = dir
+	}
+}
+
+// TimeLeft returns the time left until the deadline of the global timeout of the default session, see Knuu.TimeLeft
+func TimeLeft() time.Duration {
+	return defaultKnuu.TimeLeft()
+}
+
+// TimeLeft returns the time left until the deadline of the global timeout of the session, which is negative once it is passed
+// Without a global timeout, the maximum duration is returned
+func (k *Knuu) TimeLeft() time.Duration {
+	if k == nil || k.deadline.IsZero() {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Until(k.deadline)
+}
+
+// startBudget starts the timer that tears the session down when only the teardown reserve is left until the deadline
+func (k *Knuu) startBudget(timeout, reserve time.Duration) error {
+	if reserve == 0 {
+		reserve = defaultTeardownReserve
+	}
+	if reserve >= timeout {
+		return fmt.Errorf("teardown reserve '%s' must be less than the global timeout '%s'", reserve, timeout)
+	}
+	k.deadline = time.Now().Add(timeout)
+	k.teardownReserve = reserve
+	k.budgetTimer = time.AfterFunc(timeout-reserve, func() {
+		k.budgetExhausted.Store(true)
+		log.Warnf("Only '%s' left until the deadline of the global timeout, all instances are torn down by the next start or destroy", k.TimeLeft().Round(time.Second))
+	})
+	return nil
+}
+
+// stopBudget stops the timer of the global timeout, so that the session is not torn down after it was cleaned up
+func (k *Knuu) stopBudget() {
+	if k.budgetTimer != nil {
+		k.budgetTimer.Stop()
+	}
+}
+
+// checkBudget returns an error wrapping ErrBudgetExhausted if less than the teardown reserve is left until the deadline
+func (k *Knuu) checkBudget() error {
+	if k == nil || k.deadline.IsZero() {
+		return nil
+	}
+	if left := k.TimeLeft(); k.budgetExhausted.Load() || left < k.teardownReserve {
+		return fmt.Errorf("%w: '%s' left until the deadline, '%s' are reserved for the teardown", ErrBudgetExhausted, left.Round(time.Second), k.teardownReserve)
+	}
+	return nil
+}
+
+// teardownIfBudgetExhausted tears the session down with teardownExhaustedBudget if the budget of the global timeout is exhausted
+// It is called by Start, StartAll and Destroy, so that the instances are torn down by the goroutine of the test that uses them
+// The teardown is only done once, also if it destroys the instance whose Destroy called it
+func (k *Knuu) teardownIfBudgetExhausted() {
+	if k == nil || k.checkBudget() == nil {
+		return
+	}
+	if !k.budgetTornDown.CompareAndSwap(false, true) {
+		return
+	}
+	k.teardownExhaustedBudget()
+}
+
+// teardownExhaustedBudget dumps and destroys all instances of the session and cleans it up
+// Errors are only logged, as the teardown is done on behalf of another call
+func (k *Knuu) teardownExhaustedBudget() {
+	log.Warnf("Only '%s' left until the deadline of the global timeout, tearing down all instances", k.TimeLeft().Round(time.Second))
+
+	dir := k.budgetDumpDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "knuu-dumps", k.Identifier())
+	}
+	if err := k.DumpAll(dir); err != nil {
+		log.Warnf("Error dumping instances before teardown: %v", err)
+	} else {
+		log.Warnf("Dumped all instances to '%s'", dir)
+	}
+
+	k.instancesMu.Lock()
+	instances := make([]*Instance, 0, len(k.instances))
+	for _, instance := range k.instances {
+		if instance.IsInState(Started, Stopped) {
+			instances = append(instances, instance)
+		}
+	}
+	k.instancesMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.TimeLeft())
+	defer cancel()
+	if err := DestroyAll(ctx, instances...); err != nil {
+		log.Warnf("Error destroying instances before the deadline: %v", err)
+	}
+	if err := k.CleanUp(); err != nil {
+		log.Warnf("Error cleaning up before the deadline: %v", err)
+	}
+}
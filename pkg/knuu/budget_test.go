@@ -0,0 +1,70 @@
+package knuu
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExhaustedBudgetIsTornDownByStart(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	started := newTestInstance(t, k, "started", 8080)
+	other := newTestInstance(t, k, "other", 8080)
+	committed := newTestInstance(t, k, "committed", 8080)
+	for _, instance := range []*Instance{started, other} {
+		if err := instance.Start(); err != nil {
+			t.Fatalf("starting instance: %v", err)
+		}
+	}
+
+	// The timer of the global timeout only marks the budget as exhausted
+	if err := k.startBudget(time.Hour, time.Minute); err != nil {
+		t.Fatalf("starting budget: %v", err)
+	}
+	k.budgetDumpDir = t.TempDir()
+	k.stopBudget()
+	k.budgetExhausted.Store(true)
+	if !other.IsInState(Started) {
+		t.Fatalf("instance was torn down before it was used")
+	}
+
+	if err := committed.Start(); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("expected starting instance to fail with ErrBudgetExhausted, got %v", err)
+	}
+	for _, instance := range []*Instance{started, other} {
+		if !instance.IsInState(Destroyed) {
+			t.Errorf("instance '%s' was not torn down by Start, state %s", instance.name, instance.state.String())
+		}
+	}
+	if err := started.Destroy(); err != nil {
+		t.Errorf("destroying torn down instance: %v", err)
+	}
+}
+
+func TestExhaustedBudgetIsTornDownByDestroy(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	started := newTestInstance(t, k, "started", 8080)
+	other := newTestInstance(t, k, "other", 8080)
+	for _, instance := range []*Instance{started, other} {
+		if err := instance.Start(); err != nil {
+			t.Fatalf("starting instance: %v", err)
+		}
+	}
+	if err := k.startBudget(time.Hour, time.Minute); err != nil {
+		t.Fatalf("starting budget: %v", err)
+	}
+	k.budgetDumpDir = t.TempDir()
+	k.stopBudget()
+	k.budgetExhausted.Store(true)
+
+	// Destroying one instance tears down the session once, including the instance itself
+	if err := started.Destroy(); err != nil {
+		t.Fatalf("destroying instance: %v", err)
+	}
+	if !other.IsInState(Destroyed) {
+		t.Errorf("instance '%s' was not torn down by Destroy, state %s", other.name, other.state.String())
+	}
+	if !k.budgetTornDown.Load() {
+		t.Errorf("session was not marked as torn down")
+	}
+}
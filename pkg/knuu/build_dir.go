@@ -0,0 +1,68 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"os"
+	"path/filepath"
+)
+
+// buildDirRoot is the root of the build directories set by SetBuildDirRoot, if empty the knuu directory in the temporary directory is used
+var buildDirRoot string
+
+// keepBuildDirs is true if the build directories of all instances should be kept
+var keepBuildDirs bool
+
+// SetBuildDirRoot sets the directory in which the build directories of the instances are created, instead of the temporary directory
+// e.g. if the temporary directory is too small for the build contexts, or to inspect them between runs
+// Every instance gets its own directory below the root, so the root can be shared by concurrent processes
+// The root is created if it does not exist, and it must be writable, which is checked when knuu is initialized
+// This function can only be called before knuu is initialized
+func SetBuildDirRoot(path string) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the build dir root is only allowed before knuu is initialized")
+	}
+	if path == "" {
+		return fmt.Errorf("build dir root must not be empty")
+	}
+	buildDirRoot = path
+	log.Debugf("Set build dir root to '%s'", path)
+	return nil
+}
+
+// SetKeepBuildDirs sets whether the build directories of all instances are kept, see Instance.SetKeepBuildDir
+func SetKeepBuildDirs(keep bool) {
+	keepBuildDirs = keep
+	if defaultKnuu != nil {
+		defaultKnuu.SetKeepBuildDirs(keep)
+	}
+}
+
+// SetKeepBuildDirs sets whether the build directories of all instances of the session are kept, see Instance.SetKeepBuildDir
+func (k *Knuu) SetKeepBuildDirs(keep bool) {
+	k.keepBuildDirs = keep
+}
+
+// BuildDirRoot returns the directory in which the build directories of the instances of the session are created
+func (k *Knuu) BuildDirRoot() string {
+	if k == nil || k.buildDirRoot == "" {
+		return filepath.Join(os.TempDir(), "knuu")
+	}
+	return k.buildDirRoot
+}
+
+// validateBuildDirRoot creates the root of the build directories if it does not exist and checks that it is writable
+func validateBuildDirRoot(root string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("cannot create build dir root '%s': %w", root, err)
+	}
+	file, err := os.CreateTemp(root, ".knuu-write-check-")
+	if err != nil {
+		return fmt.Errorf("build dir root '%s' is not writable: %w", root, err)
+	}
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return fmt.Errorf("cannot remove write check of build dir root '%s': %w", root, err)
+	}
+	return nil
+}
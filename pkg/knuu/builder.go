@@ -0,0 +1,163 @@
+package knuu
+
+import (
+	"errors"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/container"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"time"
+)
+
+// ErrPushUnauthorized is wrapped by the error of building an image if the registry rejected the credentials of the push,
+// so that CI can fail the job instead of retrying it
+var ErrPushUnauthorized = container.ErrPushUnauthorized
+
+// ErrPushNetwork is wrapped by the error of building an image if the push failed with transient errors, e.g. timeouts of the
+// registry, for every retry (see SetPushRetries), so that CI can retry the job
+var ErrPushNetwork = container.ErrPushNetwork
+
+// ErrBuildTimeout is wrapped by the error of building an image if the build and the push took longer than the build timeout,
+// see SetBuildTimeout
+var ErrBuildTimeout = errors.New("build timeout exceeded")
+
+// Builder is the backend that builds the images of instances whose image is modified, e.g. by AddFile or ExecuteCommand
+type Builder int
+
+const (
+	// BuilderDocker builds images with the local docker daemon, it is the default
+	BuilderDocker Builder = iota
+	// BuilderKaniko builds images with kaniko in pods of the namespace, so it does not require a docker daemon
+	BuilderKaniko
+	// BuilderBuildKit builds images with buildkit in privileged pods of the namespace, so it does not require a docker daemon
+	BuilderBuildKit
+)
+
+// String returns the name of the builder
+func (b Builder) String() string {
+	switch b {
+	case BuilderDocker:
+		return "docker"
+	case BuilderKaniko:
+		return "kaniko"
+	case BuilderBuildKit:
+		return "buildkit"
+	default:
+		return fmt.Sprintf("Builder(%d)", int(b))
+	}
+}
+
+// builder is the builder set by SetBuilder, docker if none is set
+var builder Builder
+
+// builderSet is true if the builder was chosen with SetBuilder, so that its availability is checked during initialization
+var builderSet bool
+
+// pushRetries is the number of retries of pushes set by SetPushRetries
+var pushRetries = container.DefaultPushRetries
+
+// SetBuilder sets the backend that builds and pushes the images of instances to the registry
+// The in-cluster builders upload the build context to a pod, stream its logs at debug level and delete it after the build,
+// so they can be used where no docker daemon is available, e.g. in CI runners
+// The availability of the builder is checked during initialization, so that an unavailable builder fails early
+// This function can only be called before knuu is initialized
+func SetBuilder(b Builder) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the builder is only allowed before knuu is initialized")
+	}
+	if err := b.validate(); err != nil {
+		return err
+	}
+	builder = b
+	builderSet = true
+	log.Debugf("Set builder to '%s'", b)
+	return nil
+}
+
+// SetPushRetries sets how often pushing an image that failed with a transient error (e.g. a 5xx status of the registry or
+// a connection reset) is retried, with exponential backoff starting at 2 seconds, 3 times by default
+// Errors of authentication (401, 403) or exceeded quotas are never retried
+// It applies to the images of instances whose image is set afterwards
+func SetPushRetries(retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("push retries must not be negative, got '%d'", retries)
+	}
+	pushRetries = retries
+	if defaultKnuu != nil {
+		return defaultKnuu.SetPushRetries(retries)
+	}
+	return nil
+}
+
+// SetPushRetries sets how often pushing an image of the session is retried, see SetPushRetries
+func (k *Knuu) SetPushRetries(retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("push retries must not be negative, got '%d'", retries)
+	}
+	k.pushRetries = retries
+	log.Debugf("Set push retries to '%d'", retries)
+	return nil
+}
+
+// buildTimeout is the build timeout set by SetBuildTimeout, zero if there is none
+var buildTimeout time.Duration
+
+// SetBuildTimeout sets the time the build and the push of the image of an instance may take, including the retries of the push,
+// so that a hung push fails the build with ErrBuildTimeout instead of blocking the test, zero disables it
+// The image of a timed out build is pushed with a new tag by the next build, so that a partially pushed tag is never used
+func SetBuildTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("build timeout must not be negative, got '%s'", timeout)
+	}
+	buildTimeout = timeout
+	if defaultKnuu != nil {
+		return defaultKnuu.SetBuildTimeout(timeout)
+	}
+	return nil
+}
+
+// SetBuildTimeout sets the time the build and the push of an image of the session may take, see SetBuildTimeout
+func (k *Knuu) SetBuildTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("build timeout must not be negative, got '%s'", timeout)
+	}
+	k.buildTimeout = timeout
+	log.Debugf("Set build timeout to '%s'", timeout)
+	return nil
+}
+
+// validate checks if the builder is known
+func (b Builder) validate() error {
+	switch b {
+	case BuilderDocker, BuilderKaniko, BuilderBuildKit:
+		return nil
+	default:
+		return fmt.Errorf("unknown builder '%s'", b)
+	}
+}
+
+// imageBuilder returns the implementation of the builder of the session
+func (k *Knuu) imageBuilder() container.ImageBuilder {
+	switch k.builder {
+	case BuilderKaniko:
+		return container.NewKanikoBuilder(k.client(), k.Namespace(), k.labels())
+	case BuilderBuildKit:
+		return container.NewBuildKitBuilder(k.client(), k.Namespace(), k.labels())
+	default:
+		return container.NewDockerBuilder()
+	}
+}
+
+// configureBuilderFactory sets the image builder and the push retries of the session on the builder factory of an instance
+func (k *Knuu) configureBuilderFactory(factory *container.BuilderFactory) {
+	factory.SetImageBuilder(k.imageBuilder())
+	// The retries of the session are validated when they are set
+	_ = factory.SetPushRetries(k.pushRetries)
+}
+
+// checkBuilder checks if the builder of the session can build images
+func (k *Knuu) checkBuilder() error {
+	if err := k.imageBuilder().Available(); err != nil {
+		return fmt.Errorf("builder '%s' is not available: %w", k.builder, err)
+	}
+	return nil
+}
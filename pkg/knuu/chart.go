@@ -0,0 +1,126 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/helm"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"path"
+	"time"
+)
+
+// chartReadyTimeout is the time to wait for the workloads of a chart to be ready
+const chartReadyTimeout = 10 * time.Minute
+
+// ChartRelease is a Helm chart installed into the test namespace
+type ChartRelease struct {
+	knuu    *Knuu
+	name    string
+	chart   string
+	objects []AppliedObject
+}
+
+// InstallChart installs a Helm chart into the namespace of the default session, see Knuu.InstallChart
+func InstallChart(repoURL, chart, version string, values map[string]interface{}) (*ChartRelease, error) {
+	if defaultKnuu == nil {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	return defaultKnuu.InstallChart(repoURL, chart, version, values)
+}
+
+// InstallChart renders a Helm chart with the helm CLI and applies it into the test namespace with the knuu labels
+// It waits until the workloads of the chart (including Helm hook Jobs) are ready
+// The chart is uninstalled by CleanUp or Uninstall
+// If repoURL is empty, chart can be a local path or an OCI reference
+// Charts must only contain namespaced resources, custom resource definitions are not installed
+func (k *Knuu) InstallChart(repoURL, chart, version string, values map[string]interface{}) (*ChartRelease, error) {
+	chartName := path.Base(chart)
+	if len(chartName) > maxInstanceNameLength {
+		chartName = chartName[:maxInstanceNameLength]
+	}
+	name, err := generateK8sName(chartName)
+	if err != nil {
+		return nil, fmt.Errorf("error generating release name for chart '%s': %w", chart, err)
+	}
+	release := &ChartRelease{
+		knuu:  k,
+		name:  name,
+		chart: chart,
+	}
+
+	manifest, err := helm.Template(name, k.Namespace(), repoURL, chart, version, values)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering chart '%s': %w", chart, err)
+	}
+	release.objects, err = k.ApplyManifest(manifest)
+	if err != nil {
+		return release, fmt.Errorf("error installing chart '%s': %w", chart, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), chartReadyTimeout)
+	defer cancel()
+	if err := k.k8sClient.WaitObjectsAreReady(ctx, release.objects); err != nil {
+		return release, fmt.Errorf("error waiting for chart '%s' to be ready: %w", chart, err)
+	}
+
+	log.Debugf("Installed chart '%s' as release '%s' with %d objects", chart, name, len(release.objects))
+	return release, nil
+}
+
+// Name returns the release name of the chart, which most charts use as prefix of their resource names
+func (r *ChartRelease) Name() string {
+	return r.name
+}
+
+// Objects returns the objects installed by the chart
+func (r *ChartRelease) Objects() []AppliedObject {
+	return r.objects
+}
+
+// GetServiceEndpoint returns the endpoint ('ip:port') of the given port of a service installed by the chart
+// The service name can be given with or without the release name prefix
+func (r *ChartRelease) GetServiceEndpoint(service string, port int) (string, error) {
+	name := service
+	if !r.hasService(name) {
+		name = fmt.Sprintf("%s-%s", r.name, service)
+		if !r.hasService(name) {
+			return "", fmt.Errorf("chart '%s' has no service '%s'", r.chart, service)
+		}
+	}
+
+	svc, err := r.knuu.k8sClient.GetService(r.knuu.Namespace(), name)
+	if err != nil {
+		return "", fmt.Errorf("error getting service '%s': %w", name, err)
+	}
+	for _, servicePort := range svc.Spec.Ports {
+		if int(servicePort.Port) == port {
+			return fmt.Sprintf("%s:%d", svc.Spec.ClusterIP, port), nil
+		}
+	}
+	return "", fmt.Errorf("service '%s' of chart '%s' does not expose port %d", name, r.chart, port)
+}
+
+// Uninstall deletes all objects of the chart
+func (r *ChartRelease) Uninstall() error {
+	for j := len(r.objects) - 1; j >= 0; j-- {
+		obj := r.objects[j]
+		if err := r.knuu.retryAPICall(fmt.Sprintf("deleting %s", obj), func() error {
+			return r.knuu.k8sClient.DeleteObject(obj)
+		}); err != nil {
+			return fmt.Errorf("error uninstalling chart '%s': %w", r.chart, err)
+		}
+		r.knuu.untrackAppliedObject(obj)
+	}
+	log.Debugf("Uninstalled chart '%s' (release '%s')", r.chart, r.name)
+	return nil
+}
+
+// hasService checks if the chart installed a service with the given name
+func (r *ChartRelease) hasService(name string) bool {
+	for _, obj := range r.objects {
+		if obj.GroupVersionKind.Kind == "Service" && obj.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -7,7 +7,12 @@ type Executor struct {
 }
 
 func NewExecutor() (*Executor, error) {
-	instance, err := NewInstance("executor")
+	return defaultKnuu.NewExecutor()
+}
+
+// NewExecutor creates a new executor in the session
+func (k *Knuu) NewExecutor() (*Executor, error) {
+	instance, err := k.NewInstance("executor")
 	if err != nil {
 		return nil, fmt.Errorf("error creating instance '%v':", err)
 	}
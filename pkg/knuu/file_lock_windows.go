@@ -0,0 +1,15 @@
+package knuu
+
+import (
+	"os"
+)
+
+// lockFile does not lock the file on Windows, where build directories are only protected by being unique per instance
+func lockFile(file *os.File) error {
+	return nil
+}
+
+// unlockFile does nothing on Windows, see lockFile
+func unlockFile(file *os.File) error {
+	return nil
+}
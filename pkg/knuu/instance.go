@@ -1,50 +1,133 @@
 package knuu
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/celestiaorg/knuu/pkg/container"
 	"github.com/celestiaorg/knuu/pkg/k8s"
-	"github.com/sirupsen/logrus"
-	"io"
+	"github.com/celestiaorg/knuu/pkg/log"
 	appv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// updateEnvironmentTimeout is the time UpdateEnvironmentVariables waits for the restarted pods to be ready
+const updateEnvironmentTimeout = 5 * time.Minute
+
+// podFailureCheckInterval is the interval in which waiting for an instance checks if its pod failed
+const podFailureCheckInterval = 2 * time.Second
+
 // Instance represents a instance
 type Instance struct {
-	name                  string
-	imageName             string
-	k8sName               string
-	state                 InstanceState
-	instanceType          InstanceType
-	kubernetesService     *v1.Service
-	builderFactory        *container.BuilderFactory
-	kubernetesStatefulSet *appv1.StatefulSet
-	portsTCP              []int
-	portsUDP              []int
-	command               []string
-	args                  []string
-	env                   map[string]string
-	volumes               []*k8s.Volume
-	memoryRequest         string
-	memoryLimit           string
-	cpuRequest            string
-	serviceAccountName    string
-}
-
-// NewInstance creates a new instance of the Instance struct
+	name                    string
+	imageName               string
+	k8sName                 string
+	state                   InstanceState
+	instanceType            InstanceType
+	externalHost            string
+	kubernetesService       *v1.Service
+	serviceOptions          k8s.ServiceOptions
+	builderFactory          *container.BuilderFactory
+	kubernetesStatefulSet   *appv1.StatefulSet
+	portsTCP                []int
+	portsUDP                []int
+	command                 []string
+	args                    []string
+	commands                [][]string
+	env                     map[string]string
+	volumes                 []*k8s.Volume
+	hostPathVolumes         []*k8s.HostPathVolume
+	claimVolumes            []*k8s.ClaimVolume
+	sharedVolumes           []*SharedVolume
+	memoryRequest           string
+	memoryLimit             string
+	cpuRequest              string
+	cpuLimit                string
+	ephemeralStorageRequest string
+	ephemeralStorageLimit   string
+	extendedResources       map[string]string
+	serviceAccountName      string
+	createServiceAccount    bool
+	priorityClassName       string
+	restartPolicy           v1.RestartPolicy
+	dnsPolicy               v1.DNSPolicy
+	dnsConfig               *v1.PodDNSConfig
+	replicas                int32
+	updateStrategy          appv1.StatefulSetUpdateStrategy
+	podDisruptionBudget     *intstr.IntOrString
+	autoscaler              *autoscaler
+	cordonedNodes           []string
+	portForwards            map[int]int
+	portForwardsMu          sync.Mutex
+	portMappingsTCP         map[int]int
+	portMappingsUDP         map[int]int
+	shareProcessNamespace   bool
+	podAnnotations          map[string]string
+	onNameCollision         NameCollisionPolicy
+	fileChecksums           map[string]string
+	files                   []*instanceFile
+	imageEnv                map[string]string
+	user                    string
+	knuu                    *Knuu
+	dependencies            []*Instance
+	metricsPort             int
+	metricsPath             string
+	metricsForwards         map[int]int
+	observability           *ObsConfig
+	logLevel                *log.Level
+	stateHistory            []StateTransition
+	stateHistoryMu          sync.Mutex
+	ingress                 *instanceIngress
+	lifetime                instanceLifetime
+	pool                    string
+	topologySpreadKey       string
+	topologySpreadMaxSkew   int32
+	podAntiAffinity         *bool
+	readinessCheck          *ReadinessCheck
+	capture                 *instanceCapture
+	buildDir                string
+	keepBuildDir            bool
+	timeOffset              time.Duration
+	faketimeInstalled       bool
+	envFromInstances        map[string]instanceEndpoint
+	roleRules               []rbacv1.PolicyRule
+	clusterRoles            []string
+	hostNetwork             bool
+	hostPortsTCP            map[int]int
+	retainVolume            bool
+	adoptedVolume           string
+}
+
+// NewInstance creates a new instance of the Instance struct in the default session
 func NewInstance(name string) (*Instance, error) {
-	// Generate a UUID for this instance
+	return defaultKnuu.NewInstance(name)
+}
+
+// NewInstance creates a new instance of the Instance struct in the session
+func (k *Knuu) NewInstance(name string) (*Instance, error) {
+	if err := validateInstanceName(name); err != nil {
+		return nil, err
+	}
 
+	// Generate a UUID for this instance
 	k8sName, err := generateK8sName(name)
 	if err != nil {
 		return nil, fmt.Errorf("error generating k8s name for instance '%s': %w", name, err)
 	}
 	// Create the instance
-	return &Instance{
+	instance := &Instance{
 		name:               name,
 		k8sName:            k8sName,
 		imageName:          "",
@@ -56,66 +139,108 @@ func NewInstance(name string) (*Instance, error) {
 		args:               make([]string, 0),
 		env:                make(map[string]string),
 		volumes:            make([]*k8s.Volume, 0),
+		hostPathVolumes:    make([]*k8s.HostPathVolume, 0),
 		memoryRequest:      "",
 		memoryLimit:        "",
 		cpuRequest:         "",
+		extendedResources:  make(map[string]string),
 		serviceAccountName: "default",
-	}, nil
+		replicas:           1,
+		fileChecksums:      make(map[string]string),
+		files:              make([]*instanceFile, 0),
+		imageEnv:           make(map[string]string),
+		knuu:               k,
+	}
+	k.registerInstance(instance)
+	return instance, nil
 }
 
 // SetImage sets the image of the instance.
+// The image must have a tag or digest, e.g. 'alpine:3.18'.
 // When calling in state 'Started', make sure to call AddVolume() before.
 // It is only allowed in the 'None' and 'Started' states.
 func (i *Instance) SetImage(image string) error {
 	// Check if setting the image is allowed in the current state
 	if !i.IsInState(None, Started) {
-		return fmt.Errorf("setting image is only allowed in state 'None' and 'Started'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting image", None, Started)
+	}
+	if err := validateImageName(image); err != nil {
+		return err
 	}
-
-	var err error
 
 	// Handle each state accordingly
 	switch i.state {
 	case None:
-		// Use the builder to build a new image
+		// Use the builder to build a new image, in a build directory that is unique to this instance
+		if err := i.createBuildDir(); err != nil {
+			return err
+		}
 		factory, err := container.NewBuilderFactory(image, i.getBuildDir())
 		//builder, storage, err := container.NewBuilder(context, image)
 		if err != nil {
 			return fmt.Errorf("error creating builder: %s", err.Error())
 		}
+		i.session().configureBuilderFactory(factory)
 		i.builderFactory = factory
-		i.state = Preparing
+		i.setState(Preparing)
 	case Started:
+		command, args, podNameEnv, err := i.renderCommandAndArgs()
+		if err != nil {
+			return err
+		}
 
 		// Generate the pod configuration
 		podConfig := k8s.PodConfig{
-			Namespace:          k8s.Namespace(),
-			Name:               i.k8sName,
-			Labels:             i.kubernetesStatefulSet.Labels,
-			Image:              image,
-			Command:            i.command,
-			Args:               i.args,
-			Env:                i.env,
-			Volumes:            i.volumes,
-			MemoryRequest:      i.memoryRequest,
-			MemoryLimit:        i.memoryLimit,
-			CPURequest:         i.cpuRequest,
-			ServiceAccountName: i.serviceAccountName,
+			Namespace:               i.namespace(),
+			Name:                    i.k8sName,
+			Labels:                  i.kubernetesStatefulSet.Labels,
+			Image:                   image,
+			Command:                 command,
+			Args:                    args,
+			Env:                     i.env,
+			Volumes:                 i.volumes,
+			HostPathVolumes:         i.hostPathVolumes,
+			ClaimVolumes:            i.claimVolumes,
+			MemoryRequest:           i.memoryRequest,
+			MemoryLimit:             i.memoryLimit,
+			CPURequest:              i.cpuRequest,
+			CPULimit:                i.cpuLimit,
+			EphemeralStorageRequest: i.ephemeralStorageRequest,
+			EphemeralStorageLimit:   i.ephemeralStorageLimit,
+			ExtendedResources:       i.extendedResources,
+			ServiceAccountName:      i.serviceAccountName,
+			PriorityClassName:       i.priorityClassName,
+			RestartPolicy:           i.restartPolicy,
+			DNSPolicy:               i.dnsPolicy,
+			DNSConfig:               i.dnsConfig,
+			TopologySpread:          i.topologySpread(),
+			Affinity:                i.affinity(),
+			Sidecars:                i.sidecars(),
+			PodNameEnv:              podNameEnv,
+			ReadinessProbe:          i.readinessProbe(),
+			HostNetwork:             i.hostNetwork,
+			ShareProcessNamespace:   i.shareProcessNamespace,
+			Annotations:             i.podAnnotations,
+			HostPorts:               i.hostPorts(),
+			VolumeClaimName:         i.adoptedVolume,
 		}
 		// Generate the statefulset configuration
 		statefulSetConfig := k8s.StatefulSetConfig{
-			Namespace: k8s.Namespace(),
-			Name:      i.k8sName,
-			Labels:    i.kubernetesStatefulSet.Labels,
-			Replicas:  1,
-			PodConfig: podConfig,
+			Namespace:      i.namespace(),
+			Name:           i.k8sName,
+			Labels:         i.kubernetesStatefulSet.Labels,
+			Replicas:       i.replicas,
+			UpdateStrategy: i.updateStrategy,
+			ExtraLabels:    i.lifetimeLabels(),
+			PodConfig:      podConfig,
 		}
 
 		// Replace the pod with a new one, using the given image
-		_, err = k8s.ReplaceStatefulSet(statefulSetConfig)
+		statefulSet, err := i.k8sClient().ReplaceStatefulSet(statefulSetConfig)
 		if err != nil {
 			return fmt.Errorf("error replacing pod: %s", err.Error())
 		}
+		i.kubernetesStatefulSet = statefulSet
 		i.WaitInstanceIsRunning()
 	}
 
@@ -128,59 +253,105 @@ func (i *Instance) SetImage(image string) error {
 func (i *Instance) SetImageInstant(image string) error {
 	// Check if setting the image is allowed in the current state
 	if !i.IsInState(Started) {
-		return fmt.Errorf("setting image is only allowed in state 'Started'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting image", Started)
+	}
+	if err := validateImageName(image); err != nil {
+		return err
+	}
+	command, args, podNameEnv, err := i.renderCommandAndArgs()
+	if err != nil {
+		return err
 	}
 
 	// Generate the pod configuration
 	podConfig := k8s.PodConfig{
-		Namespace:          k8s.Namespace(),
-		Name:               i.k8sName,
-		Labels:             i.kubernetesStatefulSet.Labels,
-		Image:              image,
-		Command:            i.command,
-		Args:               i.args,
-		Env:                i.env,
-		Volumes:            i.volumes,
-		MemoryRequest:      i.memoryRequest,
-		MemoryLimit:        i.memoryLimit,
-		CPURequest:         i.cpuRequest,
-		ServiceAccountName: i.serviceAccountName,
+		Namespace:               i.namespace(),
+		Name:                    i.k8sName,
+		Labels:                  i.kubernetesStatefulSet.Labels,
+		Image:                   image,
+		Command:                 command,
+		Args:                    args,
+		Env:                     i.env,
+		Volumes:                 i.volumes,
+		HostPathVolumes:         i.hostPathVolumes,
+		ClaimVolumes:            i.claimVolumes,
+		MemoryRequest:           i.memoryRequest,
+		MemoryLimit:             i.memoryLimit,
+		CPURequest:              i.cpuRequest,
+		CPULimit:                i.cpuLimit,
+		EphemeralStorageRequest: i.ephemeralStorageRequest,
+		EphemeralStorageLimit:   i.ephemeralStorageLimit,
+		ExtendedResources:       i.extendedResources,
+		ServiceAccountName:      i.serviceAccountName,
+		PriorityClassName:       i.priorityClassName,
+		RestartPolicy:           i.restartPolicy,
+		DNSPolicy:               i.dnsPolicy,
+		DNSConfig:               i.dnsConfig,
+		TopologySpread:          i.topologySpread(),
+		Affinity:                i.affinity(),
+		Sidecars:                i.sidecars(),
+		PodNameEnv:              podNameEnv,
+		ReadinessProbe:          i.readinessProbe(),
+		HostNetwork:             i.hostNetwork,
+		ShareProcessNamespace:   i.shareProcessNamespace,
+		Annotations:             i.podAnnotations,
+		HostPorts:               i.hostPorts(),
+		VolumeClaimName:         i.adoptedVolume,
 	}
 	// Generate the statefulset configuration
 	statefulSetConfig := k8s.StatefulSetConfig{
-		Namespace: k8s.Namespace(),
-		Name:      i.k8sName,
-		Labels:    i.kubernetesStatefulSet.Labels,
-		Replicas:  1,
-		PodConfig: podConfig,
+		Namespace:      i.namespace(),
+		Name:           i.k8sName,
+		Labels:         i.kubernetesStatefulSet.Labels,
+		Replicas:       i.replicas,
+		UpdateStrategy: i.updateStrategy,
+		ExtraLabels:    i.lifetimeLabels(),
+		PodConfig:      podConfig,
 	}
 
 	// Replace the pod with a new one, using the given image
 	gracePeriod := int64(1)
-	_, err := k8s.ReplaceStatefulSetWithGracePeriod(statefulSetConfig, &gracePeriod)
+	statefulSet, err := i.k8sClient().ReplaceStatefulSetWithGracePeriod(statefulSetConfig, &gracePeriod)
 	if err != nil {
 		return fmt.Errorf("error replacing pod: %s", err.Error())
 	}
+	i.kubernetesStatefulSet = statefulSet
 	i.WaitInstanceIsRunning()
 
 	return nil
 }
 
 // SetCommand sets the command to run in the instance
+// The placeholders {{.ServiceName}}, {{.Namespace}} and {{.PodName}} are expanded when the instance is deployed
+// It cannot be combined with SetCommands
 // This function can only be called when the instance is in state 'Preparing' or 'Committed'
 func (i *Instance) SetCommand(command ...string) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("setting command is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting command", Preparing, Committed)
+	}
+	if err := i.errCommandsSet("set command"); err != nil {
+		return err
+	}
+	if err := validateCommandTemplates(command); err != nil {
+		return fmt.Errorf("error setting command of instance '%s': %w", i.name, err)
 	}
 	i.command = command
 	return nil
 }
 
 // SetArgs sets the arguments passed to the instance
+// The placeholders {{.ServiceName}}, {{.Namespace}} and {{.PodName}} are expanded when the instance is deployed
+// It cannot be combined with SetCommands
 // This function can only be called in the states 'Preparing' or 'Committed'
 func (i *Instance) SetArgs(args ...string) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("setting args is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting args", Preparing, Committed)
+	}
+	if err := i.errCommandsSet("set args"); err != nil {
+		return err
+	}
+	if err := validateCommandTemplates(args); err != nil {
+		return fmt.Errorf("error setting args of instance '%s': %w", i.name, err)
 	}
 	i.args = args
 	return nil
@@ -190,14 +361,16 @@ func (i *Instance) SetArgs(args ...string) error {
 // This function can be called in the states 'Preparing' and 'Committed'
 func (i *Instance) AddPortTCP(port int) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("adding port is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("adding port", Preparing, Committed)
 	}
-	validatePort(port)
-	if i.isTCPPortRegistered(port) {
+	if err := validatePort(port); err != nil {
+		return err
+	}
+	if i.isTCPServicePort(port) {
 		return fmt.Errorf("TCP port '%d' is already in registered", port)
 	}
 	i.portsTCP = append(i.portsTCP, port)
-	logrus.Debugf("Added TCP port '%d' to instance '%s'", port, i.name)
+	i.logger().Debugf("Added TCP port '%d' to instance '%s'", port, i.name)
 	return nil
 }
 
@@ -205,23 +378,30 @@ func (i *Instance) AddPortTCP(port int) error {
 // This function can only be called in the state 'Started'
 func (i *Instance) PortForwardTCP(port int) (int, error) {
 	if !i.IsInState(Started) {
-		return -1, fmt.Errorf("random port forwarding is only allowed in state 'Started'. Current state is '%s'", i.state.String())
+		return -1, i.errInvalidStateTransition("random port forwarding", Started)
 	}
-	validatePort(port)
-	if !i.isTCPPortRegistered(port) {
+	if err := validatePort(port); err != nil {
+		return -1, err
+	}
+	if !i.isTCPContainerPort(port) {
 		return -1, fmt.Errorf("TCP port '%d' is not registered", port)
 	}
-	// Get a random port on the host
-	localPort, err := getFreePortTCP()
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
 	if err != nil {
-		return -1, fmt.Errorf("error getting free port: %v", err)
+		return -1, fmt.Errorf("error getting pod from statefulset '%s': %v", i.k8sName, err)
 	}
-	// Forward the port
-	pod, err := k8s.GetFirstPodFromStatefulSet(k8s.Namespace(), i.k8sName)
+	// Reserve a random port on the host, which is held until right before the port forwarding binds it,
+	// as the port forwarding cannot take over the listener
+	reserved, err := ReserveLocalPort()
 	if err != nil {
-		return -1, fmt.Errorf("error getting pod from statefulset '%s': %v", i.k8sName, err)
+		return -1, fmt.Errorf("error getting free port: %v", err)
+	}
+	localPort := reserved.Port
+	if err := reserved.Release(); err != nil {
+		return -1, err
 	}
-	err = k8s.PortForwardPod(k8s.Namespace(), pod.Name, localPort, port)
+	// Forward the port
+	err = i.k8sClient().PortForwardPod(i.namespace(), pod.Name, localPort, port)
 	if err != nil {
 		return -1, fmt.Errorf("error forwarding port: %v", err)
 	}
@@ -229,17 +409,20 @@ func (i *Instance) PortForwardTCP(port int) (int, error) {
 }
 
 // AddPortUDP adds a UDP port to the instance
+// A port number can be added as TCP and UDP port at the same time, e.g. for DNS or QUIC
 // This function can be called in the states 'Preparing' and 'Committed'
 func (i *Instance) AddPortUDP(port int) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("adding port is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("adding port", Preparing, Committed)
 	}
-	validatePort(port)
-	if i.isUDPPortRegistered(port) {
+	if err := validatePort(port); err != nil {
+		return err
+	}
+	if i.isUDPServicePort(port) {
 		return fmt.Errorf("UDP port '%d' is already in registered", port)
 	}
 	i.portsUDP = append(i.portsUDP, port)
-	logrus.Debugf("Added UDP port '%d' to instance '%s'", port, i.k8sName)
+	i.logger().Debugf("Added UDP port '%d' to instance '%s'", port, i.k8sName)
 	return nil
 }
 
@@ -247,7 +430,7 @@ func (i *Instance) AddPortUDP(port int) error {
 // This function can only be called in the states 'Preparing' and 'Started'
 func (i *Instance) ExecuteCommand(command ...string) (string, error) {
 	if !i.IsInState(Preparing, Started) {
-		return "", fmt.Errorf("executing command is only allowed in state 'Preparing' or 'Started'. Current state is '%s'", i.state.String())
+		return "", i.errInvalidStateTransition("executing command", Preparing, Started)
 	}
 	if i.IsInState(Preparing) {
 		output, err := i.builderFactory.ExecuteCmdInBuilder(command)
@@ -256,11 +439,11 @@ func (i *Instance) ExecuteCommand(command ...string) (string, error) {
 		}
 		return output, nil
 	} else if i.IsInState(Started) {
-		pod, err := k8s.GetFirstPodFromStatefulSet(k8s.Namespace(), i.k8sName)
+		pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
 		if err != nil {
 			return "", fmt.Errorf("error getting pod from statefulset '%s': %v", i.k8sName, err)
 		}
-		output, err := k8s.RunCommandInPod(k8s.Namespace(), pod.Name, i.k8sName, command)
+		output, err := i.k8sClient().RunCommandInPod(i.namespace(), pod.Name, i.k8sName, command)
 		if err != nil {
 			return "", fmt.Errorf("error executing command '%s' in started instance '%s': %v", command, i.k8sName, err)
 		}
@@ -276,10 +459,12 @@ func (i *Instance) ExecuteCommand(command ...string) (string, error) {
 // This function can only be called in the state 'Preparing'
 func (i *Instance) AddFile(src string, dest string, chown string) error {
 	if !i.IsInState(Preparing) {
-		return fmt.Errorf("adding file is only allowed in state 'Preparing'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("adding file", Preparing)
 	}
 
-	i.validateFileArgs(src, dest, chown)
+	if err := i.validateFileArgs(src, dest, chown); err != nil {
+		return err
+	}
 
 	// check if src exists (either as file or as folder)
 	if _, err := os.Stat(src); os.IsNotExist(err) {
@@ -287,36 +472,22 @@ func (i *Instance) AddFile(src string, dest string, chown string) error {
 	}
 
 	// copy file to build dir
-	dstPath := filepath.Join(i.getBuildDir(), dest)
-
-	// make sure dir exists
-	err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("error creating directory: %w", err)
-	}
-	// Create destination file making sure the path is writeable.
-	dst, err := os.Create(dstPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file '%s': %w", dstPath, err)
-	}
-	defer dst.Close()
-
-	// Open source file for reading.
-	srcFile, err := os.Open(src)
+	var checksum string
+	err := i.withBuildDirLock(func() error {
+		var err error
+		checksum, err = i.copyToBuildDir(src, dest)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to open source file '%s': %w", src, err)
+		return err
 	}
-	defer srcFile.Close()
-
-	// Copy the contents from source file to destination file
-	_, err = io.Copy(dst, srcFile)
-	if err != nil {
-		return fmt.Errorf("failed to copy from source '%s' to destination '%s': %w", src, dstPath, err)
+	if err := i.addFileToBuilder(src, dest, chown); err != nil {
+		return err
 	}
+	i.fileChecksums[dest] = checksum
+	i.files = append(i.files, &instanceFile{src: src, dest: dest, chown: chown})
 
-	i.addFileToBuilder(src, dest, chown)
-
-	logrus.Debugf("Added file '%s' to instance '%s'", dest, i.name)
+	i.logger().Debugf("Added file '%s' to instance '%s'", dest, i.name)
 	return nil
 }
 
@@ -324,10 +495,12 @@ func (i *Instance) AddFile(src string, dest string, chown string) error {
 // This function can only be called in the state 'Preparing'
 func (i *Instance) AddFolder(src string, dest string, chown string) error {
 	if !i.IsInState(Preparing) {
-		return fmt.Errorf("adding folder is only allowed in state 'Preparing'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("adding folder", Preparing)
 	}
 
-	i.validateFileArgs(src, dest, chown)
+	if err := i.validateFileArgs(src, dest, chown); err != nil {
+		return err
+	}
 
 	// check if src exists (should be a folder)
 	srcInfo, err := os.Stat(src)
@@ -363,7 +536,7 @@ func (i *Instance) AddFolder(src string, dest string, chown string) error {
 		return fmt.Errorf("error copying folder '%s' to instance '%s': %w", src, i.name, err)
 	}
 
-	logrus.Debugf("Added folder '%s' to instance '%s'", dest, i.name)
+	i.logger().Debugf("Added folder '%s' to instance '%s'", dest, i.name)
 	return nil
 }
 
@@ -371,7 +544,7 @@ func (i *Instance) AddFolder(src string, dest string, chown string) error {
 // This function can only be called in the state 'Preparing'
 func (i *Instance) AddFileBytes(bytes []byte, dest string, chown string) error {
 	if !i.IsInState(Preparing) {
-		return fmt.Errorf("adding file is only allowed in state 'Preparing'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("adding file", Preparing)
 	}
 
 	// create a temporary file
@@ -390,47 +563,274 @@ func (i *Instance) AddFileBytes(bytes []byte, dest string, chown string) error {
 	}
 
 	// use AddFile to copy the temp file to the destination
-	return i.AddFile(tmpfile.Name(), dest, chown)
+	if err := i.AddFile(tmpfile.Name(), dest, chown); err != nil {
+		return err
+	}
+	// keep the content, as the temporary file is removed
+	file := i.files[len(i.files)-1]
+	file.src = ""
+	file.content = bytes
+	return nil
+}
+
+// AddFileToRunningInstance copies a file into the running instance and changes its owner to chown
+// The file is streamed as a tar archive into the instance, so the instance's image needs to contain 'tar'
+// This function can only be called in the state 'Started'
+func (i *Instance) AddFileToRunningInstance(src string, dest string, chown string) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("adding file to running instance", Started)
+	}
+	if err := i.validateFileArgs(src, dest, chown); err != nil {
+		return err
+	}
+	srcInfo, err := os.Stat(src)
+	if os.IsNotExist(err) || (err == nil && srcInfo.IsDir()) {
+		return fmt.Errorf("src '%s' does not exist or is a directory", src)
+	}
+	if err := i.copyToRunningInstance(src, dest, chown); err != nil {
+		return fmt.Errorf("error copying file '%s' to instance '%s': %w", src, i.k8sName, err)
+	}
+	i.logger().Debugf("Added file '%s' to running instance '%s'", dest, i.name)
+	return nil
+}
+
+// AddFolderToRunningInstance copies a folder into the running instance and changes the owner of its content to chown
+// The folder is streamed as a tar archive into the instance, so the instance's image needs to contain 'tar'
+// This function can only be called in the state 'Started'
+func (i *Instance) AddFolderToRunningInstance(src string, dest string, chown string) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("adding folder to running instance", Started)
+	}
+	if err := i.validateFileArgs(src, dest, chown); err != nil {
+		return err
+	}
+	srcInfo, err := os.Stat(src)
+	if os.IsNotExist(err) || (err == nil && !srcInfo.IsDir()) {
+		return fmt.Errorf("src '%s' does not exist or is not a directory", src)
+	}
+	if err := i.copyToRunningInstance(src, dest, chown); err != nil {
+		return fmt.Errorf("error copying folder '%s' to instance '%s': %w", src, i.k8sName, err)
+	}
+	i.logger().Debugf("Added folder '%s' to running instance '%s'", dest, i.name)
+	return nil
+}
+
+// VerifyFiles verifies that the files added to the instance are unchanged in the running instance
+// The SHA-256 checksum of every file added with AddFile, AddFolder or AddFileBytes is compared to the checksum of the file in the instance
+// This function can only be called in the state 'Started'
+func (i *Instance) VerifyFiles() error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("verifying files", Started)
+	}
+
+	dests := make([]string, 0, len(i.fileChecksums))
+	for dest := range i.fileChecksums {
+		dests = append(dests, dest)
+	}
+	sort.Strings(dests)
+
+	var problems []string
+	for _, dest := range dests {
+		expected := i.fileChecksums[dest]
+		// Print 'missing' instead of failing, to distinguish missing files from errors
+		output, err := i.ExecuteCommand("sh", "-c", `if [ -f "$1" ]; then sha256sum "$1"; else echo missing; fi`, "sh", dest)
+		if err != nil {
+			return fmt.Errorf("error computing checksum of file '%s' in instance '%s': %w", dest, i.k8sName, err)
+		}
+		fields := strings.Fields(output)
+		switch {
+		case len(fields) == 0:
+			return fmt.Errorf("error computing checksum of file '%s' in instance '%s': no output", dest, i.k8sName)
+		case fields[0] == "missing":
+			problems = append(problems, fmt.Sprintf("file '%s' is missing", dest))
+		case fields[0] != expected:
+			problems = append(problems, fmt.Sprintf("file '%s' has checksum '%s', expected '%s'", dest, fields[0], expected))
+		}
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("verifying files of instance '%s' failed: %s", i.k8sName, strings.Join(problems, "; "))
+	}
+
+	i.logger().Debugf("Verified %d files in instance '%s'", len(dests), i.name)
+	return nil
 }
 
 // SetUser sets the user for the instance
 // This function can only be called in the state 'Preparing'
 func (i *Instance) SetUser(user string) error {
 	if !i.IsInState(Preparing) {
-		return fmt.Errorf("setting user is only allowed in state 'Preparing'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting user", Preparing)
 	}
 	err := i.builderFactory.SetUser(user)
 	if err != nil {
 		return fmt.Errorf("error setting user '%s' for instance '%s': %w", user, i.name, err)
 	}
-	logrus.Debugf("Set user '%s' for instance '%s'", user, i.name)
+	i.user = user
+	i.logger().Debugf("Set user '%s' for instance '%s'", user, i.name)
 	return nil
 }
 
+// SetBuildProgress sets a function that is called with the progress of building and pushing the image of the instance in Commit
+// It is called with the phase (container.ProgressPhaseBuild, container.ProgressPhaseCommit or container.ProgressPhasePush)
+// and the percentage of the phase that is done, e.g. to emit heartbeats in CI while a large image is pushed
+// The function is called in a separate goroutine and does not block the build, updates are dropped if it is slow
+// A nil function disables progress reporting
+// This function can only be called in the state 'Preparing'
+func (i *Instance) SetBuildProgress(fn container.ProgressFunc) error {
+	if !i.IsInState(Preparing) {
+		return i.errInvalidStateTransition("setting build progress", Preparing)
+	}
+	i.builderFactory.SetProgressFunc(fn)
+	i.logger().Debugf("Set build progress function for instance '%s'", i.name)
+	return nil
+}
+
+// SetBuildArg sets a build argument of the instance's image, which RUN instructions (see ExecuteCommand) can reference as $KEY
+// Setting a build argument again overrides its value
+// An error of the builder, e.g. about an invalid reference to an argument, is returned by Commit
+// This function can only be called in the state 'Preparing'
+func (i *Instance) SetBuildArg(key, value string) error {
+	if !i.IsInState(Preparing) {
+		return i.errInvalidStateTransition("setting build arg", Preparing)
+	}
+	if key == "" {
+		return fmt.Errorf("build arg key must not be empty")
+	}
+	if err := i.builderFactory.SetBuildArg(key, value); err != nil {
+		return fmt.Errorf("error setting build arg '%s' for instance '%s': %w", key, i.name, err)
+	}
+	i.logger().Debugf("Set build arg '%s' for instance '%s'", key, i.name)
+	return nil
+}
+
+// SetBuildPlatforms sets the platforms the instance's image is built for, e.g. linux/amd64 and linux/arm64
+// With more than one platform, a manifest list covering all of them is pushed, so that the image runs on nodes of all platforms
+// If no platforms are set, the image is built for linux/amd64 as before
+// The image is only built if it is modified, otherwise the platforms of the base image are used
+// This function can only be called in the state 'Preparing'
+func (i *Instance) SetBuildPlatforms(platforms []string) error {
+	if !i.IsInState(Preparing) {
+		return i.errInvalidStateTransition("setting build platforms", Preparing)
+	}
+	if err := i.builderFactory.SetPlatforms(platforms); err != nil {
+		return fmt.Errorf("error setting build platforms for instance '%s': %w", i.name, err)
+	}
+	i.logger().Debugf("Set build platforms to '%s' for instance '%s'", strings.Join(platforms, "', '"), i.name)
+	return nil
+}
+
+// SetKeepBuildDir sets whether the local build directory of the instance is kept, e.g. to debug the build context
+// By default, it is removed after the image is built and pushed in Commit, and any leftover is removed by Destroy
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetKeepBuildDir(keep bool) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting keep build dir", Preparing, Committed)
+	}
+	i.keepBuildDir = keep
+	i.logger().Debugf("Set keep build dir to '%t' in instance '%s'", keep, i.name)
+	return nil
+}
+
+// BuildDir returns the local build directory of the instance, which contains the build context of its image
+// It is created below the build dir root (see SetBuildDirRoot) when the image is set, and removed after the image is built
+// and pushed in Commit, unless it is kept with SetKeepBuildDir or SetKeepBuildDirs
+// This function can be called in all states
+func (i *Instance) BuildDir() string {
+	return i.getBuildDir()
+}
+
+// StatefulSet returns a copy of the StatefulSet of the instance as it was created or last updated by knuu, e.g. to inspect
+// the generated ports, resources and environment variables, or nil if it was not deployed yet
+// This function can be called in all states
+func (i *Instance) StatefulSet() *appv1.StatefulSet {
+	return i.kubernetesStatefulSet.DeepCopy()
+}
+
+// Service returns a copy of the Service of the instance as it was created or last patched by knuu, or nil if it was not deployed yet
+// This function can be called in all states
+func (i *Instance) Service() *v1.Service {
+	return i.kubernetesService.DeepCopy()
+}
+
+// SetLogLevel sets the level of the log messages about the instance, e.g. "debug" or "warn"
+// It overrides the package-wide level set by SetLogLevel, so that one instance can be logged more verbosely than the others
+// This function can be called in all states
+func (i *Instance) SetLogLevel(level string) error {
+	parsedLevel, err := log.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("error setting log level of instance '%s': %w", i.name, err)
+	}
+	i.logLevel = &parsedLevel
+	i.logger().Debugf("Set log level of instance '%s' to '%s'", i.name, parsedLevel.String())
+	return nil
+}
+
+// Build builds the instance's image and pushes it, and returns the reference of the pushed image
+// The image can be used with SetImage by many other instances, so that they are started from the same image without
+// building it again; if the image is not modified, the reference of the base image is returned
+// Committing the instance afterwards does not build the image again, unless it was modified in between
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) Build(ctx context.Context) (string, error) {
+	if !i.IsInState(Preparing, Committed) {
+		return "", i.errInvalidStateTransition("building", Preparing, Committed)
+	}
+	if i.IsInState(Committed) {
+		return i.imageName, nil
+	}
+	return i.buildImage(ctx)
+}
+
+// buildImage builds and pushes the image of the instance if it is modified, and returns the name of the image to deploy
+func (i *Instance) buildImage(ctx context.Context) (string, error) {
+	if !i.builderFactory.Changed() {
+		i.logger().Debugf("No need to build and push image for instance '%s'", i.name)
+		return i.builderFactory.ImageNameFrom(), nil
+	}
+	// TODO: To speed up the process, the image name could be dependent on the hash of the image
+	imageName, err := i.getImageRegistry()
+	if err != nil {
+		return "", fmt.Errorf("error getting image registry: %w", err)
+	}
+	buildCtx := ctx
+	timeout := i.session().buildTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		buildCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err = i.withBuildDirLock(func() error {
+		return i.builderFactory.PushBuilderImageContext(buildCtx, imageName)
+	})
+	if err != nil && buildCtx.Err() != nil {
+		// The tag may be partially pushed, so the next build pushes the image with a new tag
+		i.imageName = ""
+		if ctx.Err() == nil {
+			return "", fmt.Errorf("%w: error pushing image for instance '%s' within '%s': %w", ErrBuildTimeout, i.name, timeout, err)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("error pushing image for instance '%s': %w", i.name, err)
+	}
+	i.imageName = imageName
+	i.logger().Debugf("Pushed image for instance '%s'", i.name)
+	return imageName, nil
+}
+
 // Commit commits the instance
 // This function can only be called in the state 'Preparing'
 func (i *Instance) Commit() error {
 	if !i.IsInState(Preparing) {
-		return fmt.Errorf("committing is only allowed in state 'Preparing'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("committing", Preparing)
 	}
-	if i.builderFactory.Changed() {
-		// TODO: To speed up the process, the image name could be dependent on the hash of the image
-		imageName, err := i.getImageRegistry()
-		if err != nil {
-			return fmt.Errorf("error getting image registry: %w", err)
-		}
-		err = i.builderFactory.PushBuilderImage(imageName)
-		if err != nil {
-			return fmt.Errorf("error pushing image for instance '%s': %w", i.name, err)
-		}
-		i.imageName = imageName
-		logrus.Debugf("Pushed image for instance '%s'", i.name)
-	} else {
-		i.imageName = i.builderFactory.ImageNameFrom()
-		logrus.Debugf("No need to build and push image for instance '%s'", i.name)
+	imageName, err := i.buildImage(context.Background())
+	if err != nil {
+		return err
 	}
-	i.state = Committed
-	logrus.Debugf("Set state of instance '%s' to '%s'", i.name, i.state.String())
+	i.imageName = imageName
+	if err := i.removeBuildDir(); err != nil {
+		return err
+	}
+	i.setState(Committed)
 
 	return nil
 }
@@ -439,19 +839,151 @@ func (i *Instance) Commit() error {
 // The owner of the volume is set to 0, if you want to set a custom owner use AddVolumeWithOwner
 // This function can only be called in the states 'Preparing' and 'Committed'
 func (i *Instance) AddVolume(path string, size string) error {
-	i.AddVolumeWithOwner(path, size, 0)
-	return nil
+	return i.AddVolumeWithOwner(path, size, 0)
 }
 
 // AddVolumeWithOwner adds a volume to the instance with the given owner
 // This function can only be called in the states 'Preparing' and 'Committed'
 func (i *Instance) AddVolumeWithOwner(path string, size string, owner int64) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("adding volume is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("adding volume", Preparing, Committed)
+	}
+	if err := validateVolumeSize(size); err != nil {
+		return fmt.Errorf("invalid size of volume '%s': %w", path, err)
 	}
 	volume := k8s.NewVolume(path, size, owner)
 	i.volumes = append(i.volumes, volume)
-	logrus.Debugf("Added volume '%s' with size '%s' and owner '%d' to instance '%s'", path, size, owner, i.name)
+	i.logger().Debugf("Added volume '%s' with size '%s' and owner '%d' to instance '%s'", path, size, owner, i.name)
+	return nil
+}
+
+// AddVolumeWithSubPath adds a volume to the instance that mounts only the given directory of the instance's persistent volume claim
+// All volumes of an instance share one claim, so volumes with the same subPath share their content
+// The subPath must be a relative path and must not contain '..'
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddVolumeWithSubPath(path, subPath, size string, owner int64) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding volume", Preparing, Committed)
+	}
+	if err := validateSubPath(subPath); err != nil {
+		return fmt.Errorf("invalid subPath for volume '%s': %w", path, err)
+	}
+	if err := validateVolumeSize(size); err != nil {
+		return fmt.Errorf("invalid size of volume '%s': %w", path, err)
+	}
+	volume := k8s.NewVolumeWithSubPath(path, subPath, size, owner)
+	i.volumes = append(i.volumes, volume)
+	i.logger().Debugf("Added volume '%s' with subPath '%s', size '%s' and owner '%d' to instance '%s'", path, subPath, size, owner, i.name)
+	return nil
+}
+
+// SetVolumeRetention sets if the persistent volume claim of the instance's volumes is kept when the instance is destroyed,
+// e.g. to inspect the data afterwards or to mount it in another instance with AdoptVolume
+// CAUTION: A retained claim and its storage are leaked until they are deleted manually. The claim keeps the labels of the instance,
+// including 'test-run-id', so it can be deleted with e.g. 'kubectl delete pvc -l test-run-id=<id>', and the timeout handler
+// of the session still deletes it when the session times out
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetVolumeRetention(retain bool) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting volume retention", Preparing, Committed, Started)
+	}
+	i.retainVolume = retain
+	i.logger().Debugf("Set volume retention to '%t' in instance '%s'", retain, i.name)
+	return nil
+}
+
+// AdoptVolume mounts the volumes of the instance from the existing persistent volume claim instead of creating one,
+// e.g. a claim retained with SetVolumeRetention by another instance
+// The volumes added with AddVolume are mounted from their subPaths of the claim, so the instance must add the same volumes
+// as the instance that created it. The content of the claim is kept, it is not initialized with the content of the image
+// The claim is deleted when the instance is destroyed, unless it is retained with SetVolumeRetention
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AdoptVolume(pvcName string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adopting volume", Preparing, Committed)
+	}
+	if errs := validation.IsDNS1123Subdomain(pvcName); len(errs) != 0 {
+		return fmt.Errorf("invalid persistent volume claim name '%s': %s", pvcName, strings.Join(errs, ", "))
+	}
+	i.adoptedVolume = pvcName
+	i.logger().Debugf("Adopted persistent volume '%s' in instance '%s'", pvcName, i.name)
+	return nil
+}
+
+// AddHostPathVolume mounts the given file or directory of the node at the given path in the instance
+// The hostPathType is optional and can be used to check the host path before mounting it, e.g. v1.HostPathDirectory
+// CAUTION: The content of the host path is only the same for all pods on single-node clusters (e.g. kind or minikube),
+// or if the pods are pinned to a node via a NodeSelector. On other clusters pods will see different content or fail to start.
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddHostPathVolume(hostPath, mountPath string, hostPathType *v1.HostPathType) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding host path volume", Preparing, Committed)
+	}
+	if !path.IsAbs(hostPath) {
+		return fmt.Errorf("host path '%s' must be absolute", hostPath)
+	}
+	if !path.IsAbs(mountPath) {
+		return fmt.Errorf("mount path '%s' must be absolute", mountPath)
+	}
+	i.hostPathVolumes = append(i.hostPathVolumes, k8s.NewHostPathVolume(hostPath, mountPath, hostPathType))
+	i.logger().Debugf("Added host path volume '%s' at '%s' to instance '%s'", hostPath, mountPath, i.name)
+	return nil
+}
+
+// ExpandVolume increases the size of the volume mounted at the given path and waits for the resize to complete
+// The storage class of the instance's volume must allow volume expansion, otherwise an error wrapping ErrVolumeExpansionNotSupported is returned,
+// and the new size must be larger than the current one
+// This function can only be called in the state 'Started'
+func (i *Instance) ExpandVolume(mountPath string, newSize string) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("expanding volume", Started)
+	}
+	newQuantity, err := resource.ParseQuantity(newSize)
+	if err != nil {
+		return fmt.Errorf("error parsing size '%s': %w", newSize, err)
+	}
+
+	// All volumes of the instance share one persistent volume claim, so its size is the sum of all volumes
+	var volume *k8s.Volume
+	totalSize := resource.Quantity{}
+	for _, v := range i.volumes {
+		if v.Path == mountPath {
+			volume = v
+			totalSize.Add(newQuantity)
+			continue
+		}
+		size, err := resource.ParseQuantity(v.Size)
+		if err != nil {
+			return fmt.Errorf("error parsing size '%s' of volume '%s': %w", v.Size, v.Path, err)
+		}
+		totalSize.Add(size)
+	}
+	if volume == nil {
+		return fmt.Errorf("no volume mounted at '%s' in instance '%s'", mountPath, i.name)
+	}
+	currentQuantity, err := resource.ParseQuantity(volume.Size)
+	if err != nil {
+		return fmt.Errorf("error parsing size '%s' of volume '%s': %w", volume.Size, mountPath, err)
+	}
+	if newQuantity.Cmp(currentQuantity) <= 0 {
+		return fmt.Errorf("new size '%s' of volume '%s' must be larger than the current size '%s'", newSize, mountPath, volume.Size)
+	}
+
+	err = i.k8sClient().ExpandPersistentVolumeClaim(i.namespace(), i.volumeClaimName(), totalSize)
+	if err != nil {
+		return fmt.Errorf("error expanding volume '%s' of instance '%s': %w", mountPath, i.k8sName, err)
+	}
+
+	// Resizing is done by the storage provider and might take a while
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	err = i.k8sClient().WaitPersistentVolumeClaimIsResized(ctx, i.namespace(), i.volumeClaimName(), i.getLabels(), totalSize)
+	if err != nil {
+		return fmt.Errorf("error waiting for volume '%s' of instance '%s' to be resized: %w", mountPath, i.k8sName, err)
+	}
+
+	volume.Size = newSize
+	i.logger().Debugf("Expanded volume '%s' to '%s' in instance '%s'", mountPath, newSize, i.name)
 	return nil
 }
 
@@ -459,11 +991,21 @@ func (i *Instance) AddVolumeWithOwner(path string, size string, owner int64) err
 // This function can only be called in the states 'Preparing' and 'Committed'
 func (i *Instance) SetMemory(request string, limit string) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("setting memory is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting memory", Preparing, Committed)
+	}
+	if request != "" {
+		if _, err := resource.ParseQuantity(request); err != nil {
+			return fmt.Errorf("invalid memory request '%s': %w", request, err)
+		}
+	}
+	if limit != "" {
+		if _, err := resource.ParseQuantity(limit); err != nil {
+			return fmt.Errorf("invalid memory limit '%s': %w", limit, err)
+		}
 	}
 	i.memoryRequest = request
 	i.memoryLimit = limit
-	logrus.Debugf("Set memory to '%s' and limit to '%s' in instance '%s'", request, limit, i.name)
+	i.logger().Debugf("Set memory to '%s' and limit to '%s' in instance '%s'", request, limit, i.name)
 	return nil
 }
 
@@ -471,32 +1013,120 @@ func (i *Instance) SetMemory(request string, limit string) error {
 // This function can only be called in the states 'Preparing' and 'Committed'
 func (i *Instance) SetCPU(request string) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("setting cpu is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting cpu", Preparing, Committed)
+	}
+	if request != "" {
+		if _, err := resource.ParseQuantity(request); err != nil {
+			return fmt.Errorf("invalid cpu request '%s': %w", request, err)
+		}
 	}
 	i.cpuRequest = request
-	logrus.Debugf("Set cpu to '%s' in instance '%s'", request, i.name)
+	i.logger().Debugf("Set cpu to '%s' in instance '%s'", request, i.name)
 	return nil
 }
 
-// SetEnvironmentVariable sets the given environment variable in the instance
+// SetCPULimit sets the CPU limit of the instance, an empty limit removes it
 // This function can only be called in the states 'Preparing' and 'Committed'
-func (i *Instance) SetEnvironmentVariable(key string, value string) error {
+func (i *Instance) SetCPULimit(limit string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting cpu limit", Preparing, Committed)
+	}
+	if limit != "" {
+		if _, err := resource.ParseQuantity(limit); err != nil {
+			return fmt.Errorf("invalid cpu limit '%s': %w", limit, err)
+		}
+	}
+	i.cpuLimit = limit
+	i.logger().Debugf("Set cpu limit to '%s' in instance '%s'", limit, i.name)
+	return nil
+}
+
+// SetEphemeralStorage sets the ephemeral storage request and limit of the instance
+// Empty values are not set, exceeding the limit gets the instance evicted
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetEphemeralStorage(request string, limit string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting ephemeral storage", Preparing, Committed)
+	}
+	if request != "" {
+		if _, err := resource.ParseQuantity(request); err != nil {
+			return fmt.Errorf("invalid ephemeral storage request '%s': %w", request, err)
+		}
+	}
+	if limit != "" {
+		if _, err := resource.ParseQuantity(limit); err != nil {
+			return fmt.Errorf("invalid ephemeral storage limit '%s': %w", limit, err)
+		}
+	}
+	i.ephemeralStorageRequest = request
+	i.ephemeralStorageLimit = limit
+	i.logger().Debugf("Set ephemeral storage to '%s' and limit to '%s' in instance '%s'", request, limit, i.name)
+	return nil
+}
+
+// SetExtendedResource sets the quantity of an extended resource (e.g. 'nvidia.com/gpu') requested by the instance
+// Extended resources are set as both request and limit
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetExtendedResource(name string, quantity string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting extended resource", Preparing, Committed)
+	}
+	if errs := validation.IsQualifiedName(name); len(errs) != 0 || !strings.Contains(name, "/") {
+		return fmt.Errorf("invalid extended resource name '%s': must be a domain-prefixed name like 'nvidia.com/gpu'", name)
+	}
+	if _, err := resource.ParseQuantity(quantity); err != nil {
+		return fmt.Errorf("invalid quantity '%s' of extended resource '%s': %w", quantity, name, err)
+	}
+	i.extendedResources[name] = quantity
+	i.logger().Debugf("Set extended resource '%s' to '%s' in instance '%s'", name, quantity, i.name)
+	return nil
+}
+
+// SetPodAnnotation sets an annotation of the instance's pods, e.g. to configure agents that discover pods by their annotations
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetPodAnnotation(key, value string) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("setting environment variable is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting pod annotation", Preparing, Committed)
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+		return fmt.Errorf("invalid pod annotation key '%s': %s", key, strings.Join(errs, ", "))
+	}
+	if i.podAnnotations == nil {
+		i.podAnnotations = make(map[string]string)
+	}
+	i.podAnnotations[key] = value
+	i.logger().Debugf("Set pod annotation '%s' to '%s' in instance '%s'", key, value, i.name)
+	return nil
+}
+
+// SetEnvironmentVariable sets the given environment variable in the instance
+// In the state 'Started', the variable takes effect when the instance is restarted with Restart
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetEnvironmentVariable(key string, value string) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting environment variable", Preparing, Committed, Started)
 	}
 	if i.state == Preparing {
 		i.builderFactory.SetEnvVar(key, value)
-	} else if i.state == Committed {
+		i.imageEnv[key] = value
+	} else {
 		i.env[key] = value
 	}
-	logrus.Debugf("Set environment variable '%s' to '%s' in instance '%s'", key, value, i.name)
+	i.logger().Debugf("Set environment variable '%s' to '%s' in instance '%s'", key, value, i.name)
 	return nil
 }
 
 // GetIP returns the IP of the instance
 // This function can only be called in the states 'Preparing' and 'Started'
 func (i *Instance) GetIP() (string, error) {
-	svc, _ := k8s.GetService(k8s.Namespace(), i.k8sName)
+	if i.isExternal() {
+		return i.externalHost, nil
+	}
+	// The IP of an existing service is read first, as it may be served from the cache (see SetAPICache)
+	if ip, err := i.k8sClient().GetServiceIP(i.namespace(), i.k8sName); err == nil {
+		return ip, nil
+	}
+	svc, _ := i.k8sClient().GetService(i.namespace(), i.k8sName)
 	if svc == nil {
 		// Service does not exist, so we need to deploy it
 		err := i.deployService()
@@ -505,7 +1135,7 @@ func (i *Instance) GetIP() (string, error) {
 		}
 	}
 
-	ip, err := k8s.GetServiceIP(k8s.Namespace(), i.k8sName)
+	ip, err := i.k8sClient().GetServiceIP(i.namespace(), i.k8sName)
 	if err != nil {
 		return "", fmt.Errorf("error getting IP of service '%s': %w", i.k8sName, err)
 	}
@@ -517,7 +1147,7 @@ func (i *Instance) GetIP() (string, error) {
 // This function can only be called in the states 'Preparing' and 'Committed'
 func (i *Instance) GetFileBytes(file string) ([]byte, error) {
 	if !i.IsInState(Preparing, Committed) {
-		return nil, fmt.Errorf("getting file is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return nil, i.errInvalidStateTransition("getting file", Preparing, Committed)
 	}
 
 	bytes, err := i.builderFactory.ReadFileFromBuilder(file)
@@ -527,27 +1157,284 @@ func (i *Instance) GetFileBytes(file string) ([]byte, error) {
 	return bytes, nil
 }
 
-// SetServiceAccount sets the service account of the instance
+// SetPriorityClassName sets the priority class of the instance's pods
+// The priority class must exist in the cluster when the instance is started
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetPriorityClassName(name string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting priority class name", Preparing, Committed)
+	}
+	if name == "" {
+		return fmt.Errorf("priority class name must not be empty")
+	}
+	i.priorityClassName = name
+	i.logger().Debugf("Set priority class name to '%s' in instance '%s'", name, i.name)
+	return nil
+}
+
+// SetRestartPolicy sets the restart policy of the instance's pods
+// Instances run as StatefulSets, which only support the 'Always' restart policy, so other policies are rejected
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetRestartPolicy(policy v1.RestartPolicy) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting restart policy", Preparing, Committed)
+	}
+	if err := k8s.ValidateRestartPolicy("StatefulSet", policy); err != nil {
+		return fmt.Errorf("error setting restart policy of instance '%s': %w", i.name, err)
+	}
+	i.restartPolicy = policy
+	i.logger().Debugf("Set restart policy to '%s' in instance '%s'", policy, i.name)
+	return nil
+}
+
+// SetDNSPolicy sets the DNS policy of the instance's pods
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetDNSPolicy(policy v1.DNSPolicy) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting DNS policy", Preparing, Committed)
+	}
+	switch policy {
+	case v1.DNSClusterFirst, v1.DNSClusterFirstWithHostNet, v1.DNSDefault, v1.DNSNone:
+	default:
+		return fmt.Errorf("unknown DNS policy '%s'", policy)
+	}
+	i.dnsPolicy = policy
+	i.logger().Debugf("Set DNS policy to '%s' in instance '%s'", policy, i.name)
+	return nil
+}
+
+// SetDNSConfig sets the nameservers, search domains and resolver options of the instance's pods
+// Unless a DNS policy is set with SetDNSPolicy, the DNS policy is set to 'None', so that only the given config is used
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetDNSConfig(nameservers, searches []string, options ...v1.PodDNSConfigOption) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting DNS config", Preparing, Committed)
+	}
+	for _, nameserver := range nameservers {
+		if net.ParseIP(nameserver) == nil {
+			return fmt.Errorf("nameserver '%s' is not a valid IP address", nameserver)
+		}
+	}
+	i.dnsConfig = &v1.PodDNSConfig{
+		Nameservers: nameservers,
+		Searches:    searches,
+		Options:     options,
+	}
+	i.logger().Debugf("Set DNS config with nameservers '%v' and searches '%v' in instance '%s'", nameservers, searches, i.name)
+	return nil
+}
+
+// SetReplicas sets the number of replicas of the instance
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetReplicas(replicas int32) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting replicas", Preparing, Committed)
+	}
+	if replicas < 1 {
+		return fmt.Errorf("replicas must be at least 1, got '%d'", replicas)
+	}
+	i.replicas = replicas
+	i.logger().Debugf("Set replicas to '%d' in instance '%s'", replicas, i.name)
+	return nil
+}
+
+// SetUpdateStrategy sets how the pods of the instance are replaced when the instance is updated
+// Use appv1.OnDeleteStatefulSetStrategyType to only replace pods when they are deleted,
+// or appv1.RollingUpdateStatefulSetStrategyType with a partition to only update the pods with an ordinal greater than or equal to the partition
+// The partition must be zero for the OnDelete strategy
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetUpdateStrategy(strategyType appv1.StatefulSetUpdateStrategyType, partition int32) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting update strategy", Preparing, Committed)
+	}
+	if partition < 0 {
+		return fmt.Errorf("partition must not be negative, got '%d'", partition)
+	}
+	switch strategyType {
+	case appv1.RollingUpdateStatefulSetStrategyType:
+		i.updateStrategy = appv1.StatefulSetUpdateStrategy{
+			Type: strategyType,
+			RollingUpdate: &appv1.RollingUpdateStatefulSetStrategy{
+				Partition: &partition,
+			},
+		}
+	case appv1.OnDeleteStatefulSetStrategyType:
+		if partition != 0 {
+			return fmt.Errorf("partition is only supported by the '%s' update strategy", appv1.RollingUpdateStatefulSetStrategyType)
+		}
+		i.updateStrategy = appv1.StatefulSetUpdateStrategy{
+			Type: strategyType,
+		}
+	default:
+		return fmt.Errorf("unknown update strategy '%s'", strategyType)
+	}
+	i.logger().Debugf("Set update strategy to '%s' with partition '%d' in instance '%s'", strategyType, partition, i.name)
+	return nil
+}
+
+// RollingRestart triggers a rollout of the instance's pods according to its update strategy
+// This function can only be called in the state 'Started'
+func (i *Instance) RollingRestart(ctx context.Context) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("rolling restart", Started)
+	}
+	err := i.k8sClient().RestartStatefulSet(ctx, i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error restarting instance '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Triggered rolling restart of instance '%s'", i.k8sName)
+	i.resetPortForwards()
+	// The restart is recorded in the state history as a transition from 'Started' to 'Started'
+	i.setState(Started)
+	return nil
+}
+
+// Restart applies the environment variables set since the instance was started and replaces its pods
+// The pods are replaced according to the update strategy and keep their persistent volumes
+// With the OnDelete strategy, the pods are deleted to be replaced; with a partition, only the pods at or above it are replaced
+// It returns once the replaced pods are ready or the context is done
+// This function can only be called in the state 'Started'
+func (i *Instance) Restart(ctx context.Context) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("restarting", Started)
+	}
+	env := make(map[string]string)
+	for _, sidecar := range i.sidecars() {
+		for key, val := range sidecar.MainEnv {
+			env[key] = val
+		}
+	}
+	for key, val := range i.env {
+		env[key] = val
+	}
+	err := i.k8sClient().UpdateStatefulSetEnv(ctx, i.namespace(), i.k8sName, i.k8sName, env)
+	if err != nil {
+		return fmt.Errorf("error restarting instance '%s': %w", i.k8sName, err)
+	}
+
+	if i.updateStrategy.Type == appv1.OnDeleteStatefulSetStrategyType {
+		for ordinal := int32(0); ordinal < i.replicas; ordinal++ {
+			podName := fmt.Sprintf("%s-%d", i.k8sName, ordinal)
+			if err := i.k8sClient().DeletePod(i.namespace(), podName); err != nil {
+				return fmt.Errorf("error deleting pod '%s' of instance '%s': %w", podName, i.k8sName, err)
+			}
+		}
+	}
+
+	err = i.k8sClient().WaitStatefulSetIsUpdated(ctx, i.namespace(), i.k8sName, i.getLabels())
+	if err != nil {
+		return fmt.Errorf("error waiting for restart of instance '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Restarted instance '%s'", i.k8sName)
+	i.resetPortForwards()
+	// The restart is recorded in the state history as a transition from 'Started' to 'Started'
+	i.setState(Started)
+	return nil
+}
+
+// UpdateEnvironmentVariable sets the given environment variable in the running instance and restarts its pods to apply it
+// It waits until the restarted pods are ready, see UpdateEnvironmentVariables
+// This function can only be called in the state 'Started'
+func (i *Instance) UpdateEnvironmentVariable(key, value string) error {
+	return i.UpdateEnvironmentVariables(map[string]string{key: value})
+}
+
+// UpdateEnvironmentVariables sets the given environment variables in the running instance and restarts its pods once to apply all of them
+// The pods are replaced as by Restart and keep their persistent volumes, it waits until they are ready
+// This function can only be called in the state 'Started'
+func (i *Instance) UpdateEnvironmentVariables(env map[string]string) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("updating environment variables", Started)
+	}
+	if len(env) == 0 {
+		return fmt.Errorf("no environment variables to update in instance '%s'", i.name)
+	}
+
+	for key, val := range env {
+		i.env[key] = val
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), updateEnvironmentTimeout)
+	defer cancel()
+	if err := i.Restart(ctx); err != nil {
+		return fmt.Errorf("error updating environment variables of instance '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Updated %d environment variables in instance '%s'", len(env), i.name)
+	return nil
+}
+
+// Scale sets the number of replicas of the running instance and waits until exactly that many pods are ready, or the context is done
+// Scaling to zero pauses the instance and scaling back up resumes it, the persistent volumes of the instance are kept
+// This function can only be called in the state 'Started'
+func (i *Instance) Scale(ctx context.Context, replicas int32) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("scaling", Started)
+	}
+	if replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got '%d'", replicas)
+	}
+	err := i.k8sClient().ScaleStatefulSet(ctx, i.namespace(), i.k8sName, replicas)
+	if err != nil {
+		return fmt.Errorf("error scaling instance '%s', which must be backed by a statefulSet: %w", i.k8sName, err)
+	}
+	i.replicas = replicas
+
+	err = i.k8sClient().WaitStatefulSetIsScaled(ctx, i.namespace(), i.k8sName, i.getLabels())
+	if err != nil {
+		return fmt.Errorf("error waiting for instance '%s' to be scaled to '%d' replicas: %w", i.k8sName, replicas, err)
+	}
+	i.logger().Debugf("Scaled instance '%s' to '%d' replicas", i.k8sName, replicas)
+	return nil
+}
+
+// SetPodDisruptionBudget sets the minimum number (or percentage) of pods of the instance that must stay available during voluntary disruptions
+// The PodDisruptionBudget is created when the instance is started and deleted when it is destroyed
+// As a budget only makes sense with more than one replica, starting the instance fails if SetReplicas was not used to set more than one replica
 // This function can only be called in the states 'Preparing' and 'Committed'
-func (i *Instance) SetServiceAccount(serviceAccount string) error {
+func (i *Instance) SetPodDisruptionBudget(minAvailable intstr.IntOrString) error {
 	if !i.IsInState(Preparing, Committed) {
-		return fmt.Errorf("setting service account is only allowed in state 'Preparing' or 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("setting pod disruption budget", Preparing, Committed)
 	}
-	i.serviceAccountName = serviceAccount
-	logrus.Debugf("Set service account to '%s' in instance '%s'", serviceAccount, i.name)
+	value, err := intstr.GetScaledValueFromIntOrPercent(&minAvailable, 100, true)
+	if err != nil {
+		return fmt.Errorf("invalid minAvailable '%s': %w", minAvailable.String(), err)
+	}
+	if value < 1 {
+		return fmt.Errorf("minAvailable must be greater than zero, got '%s'", minAvailable.String())
+	}
+	i.podDisruptionBudget = &minAvailable
+	i.logger().Debugf("Set pod disruption budget with minAvailable '%s' in instance '%s'", minAvailable.String(), i.name)
 	return nil
 }
 
 // Start starts the instance
+// If the instance has dependencies (see DependsOn), it first waits until they are running
+// Environment variables set with SetEnvironmentVariableFromInstance are resolved once the dependencies are running
 // This function can only be called in the state 'Committed'
 func (i *Instance) Start() error {
 	if !i.IsInState(Committed, Stopped) {
-		return fmt.Errorf("starting is only allowed in state 'Committed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("starting", Committed, Stopped)
+	}
+	if err := i.session().checkBudget(); err != nil {
+		i.session().teardownIfBudgetExhausted()
+		return fmt.Errorf("cannot start instance '%s': %w", i.name, err)
+	}
+	if err := i.checkEnvFromInstances(); err != nil {
+		return err
+	}
+	if err := i.waitForDependencies(); err != nil {
+		return err
+	}
+	if err := i.resolveEnvFromInstances(); err != nil {
+		return err
 	}
 	if i.state == Committed {
-		if len(i.portsTCP) != 0 || len(i.portsUDP) != 0 {
-			logrus.Debugf("Ports not empty, deploying service for instance '%s'", i.k8sName)
-			svc, _ := k8s.GetService(k8s.Namespace(), i.k8sName)
+		if err := i.resolveNameCollision(); err != nil {
+			return err
+		}
+		if i.hasServicePorts() {
+			i.logger().Debugf("Ports not empty, deploying service for instance '%s'", i.k8sName)
+			svc, _ := i.k8sClient().GetService(i.namespace(), i.k8sName)
 			if svc == nil {
 				err := i.deployService()
 				if err != nil {
@@ -566,18 +1453,52 @@ func (i *Instance) Start() error {
 				return fmt.Errorf("error deploying volume for instance '%s': %w", i.k8sName, err)
 			}
 		}
+		if i.podDisruptionBudget != nil {
+			err := i.deployPodDisruptionBudget()
+			if err != nil {
+				return fmt.Errorf("error deploying pod disruption budget for instance '%s': %w", i.k8sName, err)
+			}
+		}
+		if i.autoscaler != nil {
+			if err := i.deployAutoscaler(); err != nil {
+				return err
+			}
+		}
+		if err := i.deployRBAC(); err != nil {
+			return fmt.Errorf("error deploying RBAC for instance '%s': %w", i.k8sName, err)
+		}
+		if i.ingress != nil {
+			err := i.deployIngress()
+			if err != nil {
+				return fmt.Errorf("error deploying ingress for instance '%s': %w", i.k8sName, err)
+			}
+		}
 	}
+	// The lifetime starts before deploying the pod, so that its statefulSet is labeled with the expiration
+	i.startLifetime()
 	err := i.deployPod()
 	if err != nil {
+		i.stopLifetime()
 		return fmt.Errorf("error deploying pod for instance '%s': %w", i.k8sName, err)
 	}
-	i.state = Started
-	logrus.Debugf("Set state of instance '%s' to '%s'", i.k8sName, i.state.String())
+	i.setState(Started)
 
-	err = i.WaitInstanceIsRunning()
+	// The volume is only awaited after deploying the pod, as it might only be bound to its first consumer
+	if len(i.volumes) != 0 {
+		err = i.waitVolumeIsBound()
+		if err != nil {
+			return fmt.Errorf("error waiting for volume of instance '%s': %w", i.k8sName, err)
+		}
+	}
+
+	// With a readiness check, the instance is only running once the check passes
+	err = i.WaitInstanceIsRunningWithTimeout(i.readinessTimeout())
 	if err != nil {
 		return fmt.Errorf("error waiting for instance '%s' to be running: %w", i.k8sName, err)
 	}
+	if err := i.verifyTimeOffset(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -586,33 +1507,146 @@ func (i *Instance) Start() error {
 // This function can only be called in the state 'Started'
 func (i *Instance) IsRunning() (bool, error) {
 	if !i.IsInState(Started, Stopped) {
-		return false, fmt.Errorf("checking if instance is running is only allowed in state 'Started'. Current state is '%s'", i.state.String())
+		return false, i.errInvalidStateTransition("checking if instance is running", Started, Stopped)
 	}
-	return k8s.IsStatefulSetRunning(k8s.Namespace(), i.k8sName)
+	if i.isExternal() {
+		return true, nil
+	}
+	return i.k8sClient().IsStatefulSetRunning(i.namespace(), i.k8sName)
 }
 
-// WaitInstanceIsRunning waits until the instance is running
+// WaitInstanceIsRunning waits until the instance is running, for at most the default timeout (see SetDefaultTimeout)
 // This function can only be called in the state 'Started'
 func (i *Instance) WaitInstanceIsRunning() error {
+	return i.WaitInstanceIsRunningContext(context.Background())
+}
+
+// WaitInstanceIsRunningWithTimeout waits until the instance is running, for at most the given duration
+// This function can only be called in the state 'Started'
+func (i *Instance) WaitInstanceIsRunningWithTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return i.WaitInstanceIsRunningContext(ctx)
+}
+
+// WaitInstanceIsRunningContext waits until the instance is running or the context is done
+// If the pod of the instance fails or a container cannot start (e.g. ImagePullBackOff or CrashLoopBackOff), it returns immediately
+// If the instance is not running in time, the error reports the status of the pod and the last warning event
+// This function can only be called in the state 'Started'
+func (i *Instance) WaitInstanceIsRunningContext(ctx context.Context) error {
 	if !i.IsInState(Started) {
-		return fmt.Errorf("waiting for instance is only allowed in state 'Started'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("waiting for instance", Started)
 	}
-	timeout := time.After(1 * time.Minute)
-	tick := time.Tick(1 * time.Second)
+	// External instances are not deployed by knuu, so there is nothing to wait for
+	if i.isExternal() {
+		return nil
+	}
+	ctx, cancel := i.session().withDefaultTimeout(ctx)
+	defer cancel()
+	podName := fmt.Sprintf("%s-0", i.k8sName)
 
-	for {
-		select {
-		case <-timeout:
-			return fmt.Errorf("timeout while waiting for instance '%s' to be running", i.k8sName)
-		case <-tick:
-			running, err := i.IsRunning()
-			if err != nil {
-				return fmt.Errorf("error checking if instance '%s' is running: %w", i.k8sName, err)
+	// Watch the events of the instance to be able to report why it is not running
+	var (
+		lastWarning   *Event
+		lastWarningMu sync.Mutex
+	)
+	events, err := i.WatchEvents(ctx)
+	if err != nil {
+		i.logger().Debugf("Cannot watch events of instance '%s': %v", i.k8sName, err)
+	} else {
+		go func() {
+			for event := range events {
+				if event.Type == v1.EventTypeWarning {
+					warning := event
+					lastWarningMu.Lock()
+					lastWarning = &warning
+					lastWarningMu.Unlock()
+				}
+			}
+		}()
+	}
+
+	// A failed pod is not restarted by the statefulSet and some container failures do not resolve by themselves,
+	// so waiting for them would only burn the timeout
+	var (
+		failure   string
+		failureMu sync.Mutex
+	)
+	go func() {
+		ticker := time.NewTicker(podFailureCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
 			}
-			if running {
-				return nil
+			// The pods of all instances are read with one request, as many instances may be waited for at once
+			pod, err := i.session().testRunPod(podName)
+			if err != nil || pod == nil {
+				continue
 			}
+			status := k8s.NewPodStatus(pod)
+			if status.Failure() == "" {
+				continue
+			}
+			failureMu.Lock()
+			if status.Phase == v1.PodFailed && status.Reason == "Evicted" {
+				failure = describeEviction(status.Message)
+			} else {
+				failure = status.Failure()
+			}
+			failureMu.Unlock()
+			cancel()
+			return
+		}
+	}()
+
+	err = i.k8sClient().WaitStatefulSetIsRunning(ctx, i.namespace(), i.k8sName, i.getLabels())
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		failureMu.Lock()
+		defer failureMu.Unlock()
+		if failure != "" {
+			return fmt.Errorf("instance '%s' cannot become running: %s", i.k8sName, failure)
+		}
+
+		// A pod that cannot be scheduled (e.g. because no node has the requested resources,
+		// or because of a required pod anti-affinity) is the most likely cause
+		for ordinal := int32(0); ordinal < i.replicas; ordinal++ {
+			replicaPodName := fmt.Sprintf("%s-%d", i.k8sName, ordinal)
+			schedulingFailure, schedulingErr := i.k8sClient().GetPodSchedulingFailure(i.namespace(), replicaPodName)
+			if schedulingErr == nil && schedulingFailure != "" {
+				if conflict := i.hostPortConflict(schedulingFailure); conflict != "" {
+					schedulingFailure += ", " + conflict
+				}
+				return fmt.Errorf("timeout while waiting for instance '%s' to be running, pod '%s' cannot be scheduled: %s", i.k8sName, replicaPodName, schedulingFailure)
+			}
+		}
+		evictionMessage, evictionErr := i.k8sClient().GetPodEvictionMessage(i.namespace(), podName)
+		if evictionErr == nil && evictionMessage != "" {
+			return fmt.Errorf("timeout while waiting for instance '%s' to be running, %s", i.k8sName, describeEviction(evictionMessage))
+		}
+		lastWarningMu.Lock()
+		defer lastWarningMu.Unlock()
+		if lastWarning != nil && lastWarning.Reason == "Evicted" {
+			return fmt.Errorf("timeout while waiting for instance '%s' to be running, %s", i.k8sName, describeEviction(lastWarning.Message))
+		}
+
+		if failure := i.readinessFailure(lastWarning); failure != "" {
+			return fmt.Errorf("timeout while waiting for instance '%s' to be running, %s", i.k8sName, failure)
+		}
+
+		reason := "pod does not exist"
+		if status, statusErr := i.k8sClient().GetPodStatus(i.namespace(), podName); statusErr == nil {
+			reason = status.String()
+		}
+		if lastWarning != nil {
+			reason += fmt.Sprintf(", last warning event: %s: %s", lastWarning.Reason, lastWarning.Message)
 		}
+		return fmt.Errorf("timeout while waiting for instance '%s' to be running, %s", i.k8sName, reason)
+	}
+	if err != nil {
+		return fmt.Errorf("error checking if instance '%s' is running: %w", i.k8sName, err)
 	}
 
 	return nil
@@ -623,12 +1657,12 @@ func (i *Instance) WaitInstanceIsRunning() error {
 // This function can only be called in the state 'Started'
 func (i *Instance) DisableNetwork() error {
 	if !i.IsInState(Started) {
-		return fmt.Errorf("disabling network is only allowed in state 'Started'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("disabling network", Started)
 	}
 	executorSelectorMap := map[string]string{
 		"type": ExecutorInstance.String(),
 	}
-	err := k8s.CreateNetworkPolicy(k8s.Namespace(), i.k8sName, i.getLabels(), executorSelectorMap, executorSelectorMap)
+	err := i.k8sClient().CreateNetworkPolicy(i.namespace(), i.k8sName, i.getLabels(), i.getLabels(), executorSelectorMap, executorSelectorMap)
 	if err != nil {
 		return fmt.Errorf("error disabling network for instance '%s': %w", i.k8sName, err)
 	}
@@ -639,29 +1673,26 @@ func (i *Instance) DisableNetwork() error {
 // This function can only be called in the state 'Started'
 func (i *Instance) EnableNetwork() error {
 	if !i.IsInState(Started) {
-		return fmt.Errorf("enabling network is only allowed in state 'Started'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("enabling network", Started)
 	}
-	err := k8s.DeleteNetworkPolicy(k8s.Namespace(), i.k8sName)
+	err := i.k8sClient().DeleteNetworkPolicy(i.namespace(), i.k8sName)
 	if err != nil {
 		return fmt.Errorf("error enabling network for instance '%s': %w", i.k8sName, err)
 	}
 	return nil
 }
 
-// WaitInstanceIsStopped waits until the instance is not running anymore
+// WaitInstanceIsStopped waits until the instance is not running anymore, for at most the default timeout (see SetDefaultTimeout)
 // This function can only be called in the state 'Stopped'
 func (i *Instance) WaitInstanceIsStopped() error {
 	if !i.IsInState(Stopped) {
-		return fmt.Errorf("waiting for instance is only allowed in state 'Stopped'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("waiting for instance", Stopped)
 	}
-	for {
-		running, err := i.IsRunning()
-		if !running {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("error checking if instance '%s' is running: %w", i.k8sName, err)
-		}
+	ctx, cancel := i.session().withDefaultTimeout(context.Background())
+	defer cancel()
+	err := i.k8sClient().WaitStatefulSetIsStopped(ctx, i.namespace(), i.k8sName, i.getLabels())
+	if err != nil {
+		return fmt.Errorf("error checking if instance '%s' is running: %w", i.k8sName, err)
 	}
 
 	return nil
@@ -672,14 +1703,14 @@ func (i *Instance) WaitInstanceIsStopped() error {
 // This function can only be called in the state 'Started'
 func (i *Instance) Stop() error {
 	if !i.IsInState(Started) {
-		return fmt.Errorf("stopping is only allowed in state 'Started'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("stopping", Started)
 	}
 	err := i.destroyPod()
 	if err != nil {
 		return fmt.Errorf("error destroying pod for instance '%s': %w", i.k8sName, err)
 	}
-	i.state = Stopped
-	logrus.Debugf("Set state of instance '%s' to '%s'", i.k8sName, i.state.String())
+	i.resetPortForwards()
+	i.setState(Stopped)
 
 	return nil
 }
@@ -687,16 +1718,33 @@ func (i *Instance) Stop() error {
 // Destroy destroys the instance
 // This function can only be called in the state 'Started' or 'Destroyed'
 func (i *Instance) Destroy() error {
+	// If the budget of the global timeout is exhausted, all instances are destroyed, including this one
+	i.session().teardownIfBudgetExhausted()
 	if !i.IsInState(Started, Stopped, Destroyed) {
-		return fmt.Errorf("destroying is only allowed in state 'Started' or 'Destroyed'. Current state is '%s'", i.state.String())
+		return i.errInvalidStateTransition("destroying", Started, Stopped, Destroyed)
 	}
-	if i.state == Destroyed {
+	if i.state == Destroyed || i.isExternal() {
 		return nil
 	}
+	i.stopLifetime()
+	i.warnLostCapture()
+	// A cordoned node does not prevent the deletion of the instance, so it is only logged if it cannot be uncordoned
+	if err := i.uncordonNodes(); err != nil {
+		i.logger().Warnf("%v", err)
+	}
+	if err := i.removeBuildDir(); err != nil {
+		return err
+	}
 	err := i.destroyPod()
 	if err != nil {
 		return fmt.Errorf("error destroying pod for instance '%s': %w", i.k8sName, err)
 	}
+	if err := i.destroyServiceAccount(); err != nil {
+		return fmt.Errorf("error destroying service account for instance '%s': %w", i.k8sName, err)
+	}
+	if err := i.releaseSharedVolumes(); err != nil {
+		return fmt.Errorf("error releasing shared volumes of instance '%s': %w", i.k8sName, err)
+	}
 	if len(i.volumes) != 0 {
 		err := i.destroyVolume()
 		if err != nil {
@@ -707,18 +1755,74 @@ func (i *Instance) Destroy() error {
 	if err != nil {
 		return fmt.Errorf("error destroying service for instance '%s': %w", i.k8sName, err)
 	}
+	if i.podDisruptionBudget != nil {
+		err := i.destroyPodDisruptionBudget()
+		if err != nil {
+			return fmt.Errorf("error destroying pod disruption budget for instance '%s': %w", i.k8sName, err)
+		}
+	}
+	if i.autoscaler != nil {
+		if err := i.destroyAutoscaler(); err != nil {
+			return err
+		}
+	}
+	if err := i.destroyRBAC(); err != nil {
+		return fmt.Errorf("error destroying RBAC for instance '%s': %w", i.k8sName, err)
+	}
+	if i.ingress != nil {
+		err := i.destroyIngress()
+		if err != nil {
+			return fmt.Errorf("error destroying ingress for instance '%s': %w", i.k8sName, err)
+		}
+	}
 
-	i.state = Destroyed
-	logrus.Debugf("Set state of instance '%s' to '%s'", i.k8sName, i.state.String())
+	i.setState(Destroyed)
+	// Destroyed instances are not exported anymore, so the session does not keep them for its whole lifetime
+	i.session().unregisterInstance(i)
 
 	return nil
 }
 
+// WaitForDeleted waits until the statefulSet, the pods, the service and the persistent volume claim of the instance
+// are removed from the API server, e.g. before creating an instance with the same name
+// Destroy only issues the deletions, so pods with a termination grace period and claims with finalizers may still exist
+// This function can only be called in the state 'Destroyed'
+func (i *Instance) WaitForDeleted(ctx context.Context) error {
+	if !i.IsInState(Destroyed) {
+		return i.errInvalidStateTransition("waiting for deletion", Destroyed)
+	}
+	ctx, cancel := i.session().withDefaultTimeout(ctx)
+	defer cancel()
+	labels := i.serviceSelector()
+
+	if err := i.k8sClient().WaitStatefulSetIsDeleted(ctx, i.namespace(), i.k8sName, labels); err != nil {
+		return fmt.Errorf("error waiting for statefulSet '%s' to be deleted: %w", i.k8sName, err)
+	}
+	if err := i.k8sClient().WaitPodsAreDeleted(ctx, i.namespace(), labels); err != nil {
+		return fmt.Errorf("error waiting for pods of instance '%s' to be deleted: %w", i.k8sName, err)
+	}
+	if err := i.k8sClient().WaitServiceIsDeleted(ctx, i.namespace(), i.k8sName, labels); err != nil {
+		return fmt.Errorf("error waiting for service '%s' to be deleted: %w", i.k8sName, err)
+	}
+	// A retained volume is not deleted
+	if len(i.volumes) != 0 && !i.retainVolume {
+		claimName := i.volumeClaimName()
+		if err := i.k8sClient().WaitPersistentVolumeClaimIsDeleted(ctx, i.namespace(), claimName, labels); err != nil {
+			if finalizers, finalizersErr := i.k8sClient().GetPersistentVolumeClaimFinalizers(i.namespace(), claimName); finalizersErr == nil && len(finalizers) != 0 {
+				return fmt.Errorf("error waiting for persistent volume claim '%s' to be deleted, it has the finalizers '%s': %w", claimName, strings.Join(finalizers, "', '"), err)
+			}
+			return fmt.Errorf("error waiting for persistent volume claim '%s' to be deleted: %w", claimName, err)
+		}
+	}
+	i.logger().Debugf("Instance '%s' is deleted", i.k8sName)
+	return nil
+}
+
 // Clone creates a clone of the instance
 // This function can only be called in the state 'Committed'
 func (i *Instance) Clone() (*Instance, error) {
 	if !i.IsInState(Committed) {
-		return nil, fmt.Errorf("cloning is only allowed in state 'Committed'. Current state is '%s'", i.state.String())
+		return nil, i.errInvalidStateTransition("cloning", Committed)
 	}
 
 	newK8sName, err := generateK8sName(i.name)
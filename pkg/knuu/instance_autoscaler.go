@@ -0,0 +1,75 @@
+package knuu
+
+import (
+	"fmt"
+)
+
+// autoscaler is the configuration of the HorizontalPodAutoscaler of an instance
+type autoscaler struct {
+	minReplicas      int32
+	maxReplicas      int32
+	targetCPUPercent int32
+}
+
+// SetAutoscaler scales the instance between minReplicas and maxReplicas to keep the average CPU utilization of its pods
+// at targetCPUPercent of their CPU request
+// The HorizontalPodAutoscaler targets the StatefulSet of the instance, it is created when the instance is started and
+// deleted when it is destroyed
+// As the utilization is relative to the CPU request, starting the instance fails if SetCPU was not used to set it
+// Instances are always backed by a StatefulSet, so they can be autoscaled, but Scale is overridden by the autoscaler
+// The cluster must provide the resource metrics API, e.g. with the metrics-server, for the pods to be scaled
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetAutoscaler(minReplicas, maxReplicas int32, targetCPUPercent int32) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting autoscaler", Preparing, Committed)
+	}
+	if minReplicas < 1 {
+		return fmt.Errorf("min replicas of autoscaler must be at least 1, got '%d'", minReplicas)
+	}
+	if maxReplicas < minReplicas {
+		return fmt.Errorf("max replicas of autoscaler must be at least min replicas '%d', got '%d'", minReplicas, maxReplicas)
+	}
+	if targetCPUPercent < 1 {
+		return fmt.Errorf("target CPU percent of autoscaler must be at least 1, got '%d'", targetCPUPercent)
+	}
+	i.autoscaler = &autoscaler{
+		minReplicas:      minReplicas,
+		maxReplicas:      maxReplicas,
+		targetCPUPercent: targetCPUPercent,
+	}
+	i.logger().Debugf("Set autoscaler with '%d' to '%d' replicas at '%d%%' CPU in instance '%s'", minReplicas, maxReplicas, targetCPUPercent, i.name)
+	return nil
+}
+
+// deployAutoscaler deploys the HorizontalPodAutoscaler of the instance
+func (i *Instance) deployAutoscaler() error {
+	if i.cpuRequest == "" {
+		return fmt.Errorf("an autoscaler requires a CPU request, set it with SetCPU in instance '%s'", i.k8sName)
+	}
+	a := i.autoscaler
+	err := i.k8sClient().CreateHorizontalPodAutoscaler(i.namespace(), i.k8sName, i.getLabels(), i.k8sName, a.minReplicas, a.maxReplicas, a.targetCPUPercent)
+	if err != nil {
+		return fmt.Errorf("error creating autoscaler for instance '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Deployed autoscaler for instance '%s'", i.k8sName)
+	return nil
+}
+
+// destroyAutoscaler destroys the HorizontalPodAutoscaler of the instance
+func (i *Instance) destroyAutoscaler() error {
+	err := i.k8sClient().DeleteHorizontalPodAutoscaler(i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error deleting autoscaler for instance '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Destroyed autoscaler for instance '%s'", i.k8sName)
+	return nil
+}
+
+// cloneAutoscaler returns a copy of the autoscaler for a clone of the instance
+func (i *Instance) cloneAutoscaler() *autoscaler {
+	if i.autoscaler == nil {
+		return nil
+	}
+	a := *i.autoscaler
+	return &a
+}
@@ -0,0 +1,247 @@
+package knuu
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// captureSidecarName is the container name of the packet capture sidecar
+	captureSidecarName = "pcap"
+	// captureDefaultImage is the image of the packet capture sidecar if none is configured, it must provide sh, tcpdump and tar
+	captureDefaultImage = "nicolaka/netshoot:v0.11"
+	// captureDir is the path of the emptyDir volume of the sidecar the capture files are written to
+	captureDir = "/knuu-capture"
+	// captureFile is the name of the capture files, tcpdump appends the number of the file when rotating
+	captureFile = "capture.pcap"
+	// captureDefaultMaxFileSizeMB is the size in MB after which a capture file is rotated, if not configured
+	captureDefaultMaxFileSizeMB = 100
+	// captureDefaultMaxFiles is the number of capture files that are kept, if not configured
+	captureDefaultMaxFiles = 10
+)
+
+// captureScript runs tcpdump in the background and keeps the sidecar running after tcpdump is stopped,
+// so that the capture files can still be downloaded and the sidecar is not restarted, which would overwrite them
+// The arguments are the maximum file size in MB, the number of files and the filter
+const captureScript = `tcpdump -i any -U -Z root -w "` + captureDir + `/` + captureFile + `" -C "$1" -W "$2" ${3:+"$3"} &
+pid=$!
+echo "$pid" > /tmp/knuu-tcpdump.pid
+trap 'kill -TERM "$pid"; wait "$pid"; exit 0' TERM INT
+wait "$pid"
+touch /tmp/knuu-tcpdump.stopped
+while true; do sleep 3600 & wait $!; done
+`
+
+// captureStopScript stops tcpdump and waits until it has written the capture files
+const captureStopScript = `kill -TERM "$(cat /tmp/knuu-tcpdump.pid)" 2>/dev/null
+while [ ! -e /tmp/knuu-tcpdump.stopped ]; do sleep 1; done
+`
+
+// CaptureOptions is the configuration of the packet capture of an instance
+// Empty fields are set to their defaults
+type CaptureOptions struct {
+	// Filter is a BPF filter expression, e.g. 'tcp and not port 22', which is combined with the ports
+	Filter string
+	// Ports are the registered TCP and UDP ports whose traffic is captured
+	// If empty, the traffic of all registered ports is captured, or all traffic if the instance has no ports
+	Ports []int
+	// MaxFileSizeMB is the size in MB after which a capture file is rotated, defaults to 100
+	MaxFileSizeMB int
+	// MaxFiles is the number of capture files that are kept, the oldest file is overwritten when rotating, defaults to 10
+	MaxFiles int
+	// Image is the image of the sidecar, which must provide sh, tcpdump and tar
+	Image string
+}
+
+// instanceCapture is the packet capture of an instance
+type instanceCapture struct {
+	opts       CaptureOptions
+	downloaded bool
+}
+
+// EnablePacketCapture injects a tcpdump sidecar into the instance's pod, which captures the traffic of the pod
+// into rotating pcap files from when it is started, see StopCaptureAndDownload
+// The sidecar requires the capabilities NET_ADMIN and NET_RAW, which the cluster must allow
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) EnablePacketCapture(opts CaptureOptions) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("enabling packet capture", Preparing, Committed)
+	}
+	for _, port := range opts.Ports {
+		if !i.isTCPContainerPort(port) && !i.isUDPContainerPort(port) {
+			return fmt.Errorf("port '%d' of packet capture is not registered in instance '%s'", port, i.name)
+		}
+	}
+	if opts.MaxFileSizeMB < 0 || opts.MaxFiles < 0 {
+		return fmt.Errorf("maximum file size and number of files of packet capture must not be negative")
+	}
+	if opts.MaxFileSizeMB == 0 {
+		opts.MaxFileSizeMB = captureDefaultMaxFileSizeMB
+	}
+	if opts.MaxFiles == 0 {
+		opts.MaxFiles = captureDefaultMaxFiles
+	}
+	if opts.Image == "" {
+		opts.Image = captureDefaultImage
+	}
+	opts.Ports = append([]int(nil), opts.Ports...)
+	i.capture = &instanceCapture{opts: opts}
+	i.logger().Debugf("Enabled packet capture for instance '%s' with filter '%s'", i.name, i.captureFilter())
+	return nil
+}
+
+// StopCaptureAndDownload stops the packet capture and downloads the capture files to the local directory
+// The files of every pod are written to a subdirectory named after the pod
+// The files are local once it returns, so destroying the instance afterwards does not lose the capture
+// The files are streamed to the local directory until the context is done, so captures of any size can be downloaded
+// This function can only be called in the state 'Started'
+func (i *Instance) StopCaptureAndDownload(ctx context.Context, localDir string) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("stopping packet capture", Started)
+	}
+	if i.capture == nil {
+		return fmt.Errorf("packet capture is not enabled for instance '%s'", i.name)
+	}
+
+	pods, err := i.k8sClient().ListStatefulSetPods(i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error getting pods of instance '%s': %w", i.k8sName, err)
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("instance '%s' has no pods to download the packet capture from", i.k8sName)
+	}
+	var errs []error
+	for _, pod := range pods {
+		if err := i.downloadCapture(ctx, pod.Name, filepath.Join(localDir, pod.Name)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	i.capture.downloaded = true
+	i.logger().Debugf("Downloaded packet capture of instance '%s' to '%s'", i.k8sName, localDir)
+	return nil
+}
+
+// downloadCapture stops the packet capture in the pod and extracts its capture files to the directory
+// The archive of the files is extracted while it is streamed from the pod, so it is never loaded into memory at once
+func (i *Instance) downloadCapture(ctx context.Context, podName, dir string) error {
+	_, err := i.k8sClient().RunCommandInPod(i.namespace(), podName, captureSidecarName, []string{"/bin/sh", "-c", captureStopScript})
+	if err != nil {
+		return fmt.Errorf("error stopping packet capture in pod '%s': %w", podName, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory '%s': %w", dir, err)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(i.k8sClient().StreamCommandInPod(ctx, i.namespace(), podName, captureSidecarName, []string{"tar", "cf", "-", "-C", captureDir, "."}, writer))
+	}()
+	// Closing the reader stops the stream if the archive cannot be extracted
+	defer reader.Close()
+	if err := extractCapture(reader, dir); err != nil {
+		return fmt.Errorf("error downloading packet capture from pod '%s': %w", podName, err)
+	}
+	// The end of the archive is read before the command exits, so its error is read from the rest of the stream
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("error downloading packet capture from pod '%s': %w", podName, err)
+	}
+	return nil
+}
+
+// extractCapture extracts the regular files of the tar archive of capture files flat into the directory
+func extractCapture(archive io.Reader, dir string) error {
+	tarReader := tar.NewReader(archive)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// The files are written flat into the directory, so that the archive cannot write outside of it
+		path := filepath.Join(dir, filepath.Base(header.Name))
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error creating file '%s': %w", path, err)
+		}
+		_, err = io.Copy(file, tarReader)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing file '%s': %w", path, err)
+		}
+	}
+}
+
+// captureFilter returns the BPF filter of the packet capture, combining the ports and the filter of the options
+func (i *Instance) captureFilter() string {
+	ports := i.capture.opts.Ports
+	if len(ports) == 0 {
+		ports = append(append(ports, i.portsTCP...), i.portsUDP...)
+	}
+	seen := make(map[int]bool, len(ports))
+	portFilters := make([]string, 0, len(ports))
+	for _, port := range ports {
+		if seen[port] {
+			continue
+		}
+		seen[port] = true
+		portFilters = append(portFilters, fmt.Sprintf("port %d", port))
+	}
+
+	filter := strings.Join(portFilters, " or ")
+	switch {
+	case filter == "":
+		return i.capture.opts.Filter
+	case i.capture.opts.Filter == "":
+		return filter
+	}
+	return fmt.Sprintf("(%s) and (%s)", filter, i.capture.opts.Filter)
+}
+
+// captureSidecar returns the packet capture sidecar container of the instance's pod
+func (i *Instance) captureSidecar() *k8s.SidecarConfig {
+	opts := i.capture.opts
+	return &k8s.SidecarConfig{
+		Name:         captureSidecarName,
+		Image:        opts.Image,
+		Command:      []string{"/bin/sh", "-c", captureScript},
+		Args:         []string{"capture", strconv.Itoa(opts.MaxFileSizeMB), strconv.Itoa(opts.MaxFiles), i.captureFilter()},
+		VolumePath:   captureDir,
+		Capabilities: []v1.Capability{"NET_ADMIN", "NET_RAW"},
+	}
+}
+
+// warnLostCapture warns that the packet capture of the destroyed instance is lost, if it was not downloaded
+func (i *Instance) warnLostCapture() {
+	if i.capture != nil && !i.capture.downloaded {
+		i.logger().Warnf("Packet capture of instance '%s' is lost, as it was not downloaded with StopCaptureAndDownload before destroying it", i.k8sName)
+	}
+}
+
+// cloneCapture returns a copy of the packet capture configuration for a clone of the instance
+func (i *Instance) cloneCapture() *instanceCapture {
+	if i.capture == nil {
+		return nil
+	}
+	opts := i.capture.opts
+	opts.Ports = append([]int(nil), opts.Ports...)
+	return &instanceCapture{opts: opts}
+}
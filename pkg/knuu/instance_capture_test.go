@@ -0,0 +1,51 @@
+package knuu
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractCaptureWritesFilesFlat(t *testing.T) {
+	var archive bytes.Buffer
+	tarWriter := tar.NewWriter(&archive)
+	files := map[string]string{
+		"./capture.pcap0":        "first",
+		"./nested/capture.pcap1": "second",
+		"../escape.pcap2":        "third",
+	}
+	for name, content := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("writing header: %v", err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatalf("writing file: %v", err)
+		}
+	}
+	if err := tarWriter.WriteHeader(&tar.Header{Name: "./link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}); err != nil {
+		t.Fatalf("writing header: %v", err)
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("closing archive: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := extractCapture(&archive, dir); err != nil {
+		t.Fatalf("extracting capture: %v", err)
+	}
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.Base(name)))
+		if err != nil {
+			t.Errorf("reading extracted file of '%s': %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("extracted file of '%s' contains '%s', expected '%s'", name, data, content)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "link")); !os.IsNotExist(err) {
+		t.Errorf("expected symlink not to be extracted, got %v", err)
+	}
+}
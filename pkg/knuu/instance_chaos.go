@@ -0,0 +1,143 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// restartPollInterval is the interval in which WaitForRestartCountIncrease checks the restart count
+const restartPollInterval = time.Second
+
+// sigStop is SIGSTOP in Linux containers, which syscall does not define on all platforms
+const sigStop = syscall.Signal(19)
+
+// mainProcessScript prints the PIDs of the main process of the container, using only shell builtins and /proc
+// If PID 1 is a shell or an init wrapper, its children are the main processes, otherwise PID 1 itself
+const mainProcessScript = `read -r comm < /proc/1/comm
+case "$comm" in
+sh|bash|dash|ash|busybox|tini|dumb-init)
+	for stat in /proc/[0-9]*/stat; do
+		read -r line < "$stat" 2>/dev/null || continue
+		set -- $line
+		[ "$4" = 1 ] && children="$children $1"
+	done
+	;;
+esac
+echo ${children:-1}`
+
+// KillProcess sends the signal to the main process of the instance's first pod, so that Kubernetes restarts its container
+// in place instead of replacing the pod, see WaitForRestartCountIncrease
+// For shell-wrapped entrypoints (and init wrappers like tini), the children of the shell are signaled, otherwise PID 1
+// The kernel only delivers signals to PID 1 that it handles, so SIGKILL and SIGSTOP cannot be sent to exec-form entrypoints
+// The signal number is sent as is, so it must be the number of the signal in Linux, e.g. syscall.SIGKILL or syscall.SIGTERM
+// The image must provide a shell, as the exec API cannot signal processes by itself, the kill builtin of the shell is used
+// This function can only be called in the state 'Started'
+func (i *Instance) KillProcess(signal syscall.Signal) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("killing process", Started)
+	}
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error getting pod from statefulset '%s': %w", i.k8sName, err)
+	}
+
+	output, err := i.k8sClient().RunCommandInPod(i.namespace(), pod.Name, i.k8sName, []string{"sh", "-c", mainProcessScript})
+	if err != nil {
+		if strings.Contains(err.Error(), "executable file not found") || strings.Contains(err.Error(), "no such file or directory") {
+			return fmt.Errorf("instance '%s' has no shell, which is required to signal its process, e.g. use a debug variant of a distroless image: %w", i.k8sName, err)
+		}
+		return fmt.Errorf("error detecting main process of instance '%s': %w", i.k8sName, err)
+	}
+	pids := strings.Fields(output)
+	if len(pids) == 0 {
+		return fmt.Errorf("error detecting main process of instance '%s': no output", i.k8sName)
+	}
+	if len(pids) == 1 && pids[0] == "1" && (signal == syscall.SIGKILL || signal == sigStop) {
+		return fmt.Errorf("cannot send '%s' to the main process of instance '%s', as it is PID 1, which only receives signals it handles, use a shell-wrapped entrypoint or another signal", signal, i.k8sName)
+	}
+
+	command := fmt.Sprintf("kill -%d %s", int(signal), strings.Join(pids, " "))
+	if _, err := i.k8sClient().RunCommandInPod(i.namespace(), pod.Name, i.k8sName, []string{"sh", "-c", command}); err != nil {
+		return fmt.Errorf("error sending '%s' to process '%s' of instance '%s': %w", signal, strings.Join(pids, ", "), i.k8sName, err)
+	}
+	i.logger().Debugf("Sent '%s' to process '%s' of pod '%s' of instance '%s'", signal, strings.Join(pids, ", "), pod.Name, i.k8sName)
+	i.resetPortForwards()
+	return nil
+}
+
+// RestartCount returns the sum of the restart counts of the containers of all replicas of the instance,
+// e.g. to assert how often a crashing process was restarted
+// This function can only be called in the state 'Started'
+func (i *Instance) RestartCount(ctx context.Context) (int32, error) {
+	if !i.IsInState(Started) {
+		return 0, i.errInvalidStateTransition("getting restart count", Started)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	status, err := i.Status()
+	if err != nil {
+		return 0, err
+	}
+	return status.RestartCount(), nil
+}
+
+// WaitForRestart waits until the sum of the restart counts of the containers of all replicas of the instance is at least n,
+// or until the context is done, e.g. to wait for a crash loop before asserting that the instance recovers
+// This function can only be called in the state 'Started'
+func (i *Instance) WaitForRestart(ctx context.Context, n int32) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("waiting for restart", Started)
+	}
+	restarts, err := i.waitForRestartCount(ctx, fmt.Sprintf("reach '%d'", n), func(restarts int32) bool { return restarts >= n })
+	if err != nil {
+		return err
+	}
+	i.logger().Debugf("Restart count of instance '%s' reached '%d'", i.k8sName, restarts)
+	return nil
+}
+
+// WaitForRestartCountIncrease waits until the sum of the restart counts of the instance's containers is greater than from,
+// e.g. the restart count of Status before KillProcess was called, or until the context is done
+// This function can only be called in the state 'Started'
+func (i *Instance) WaitForRestartCountIncrease(ctx context.Context, from int32) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("waiting for restart", Started)
+	}
+	restarts, err := i.waitForRestartCount(ctx, fmt.Sprintf("increase from '%d'", from), func(restarts int32) bool { return restarts > from })
+	if err != nil {
+		return err
+	}
+	i.logger().Debugf("Restart count of instance '%s' increased from '%d' to '%d'", i.k8sName, from, restarts)
+	return nil
+}
+
+// waitForRestartCount polls the restart count of the instance until reached returns true for it or the context is done,
+// and returns the last restart count, goal describes the awaited restart count in the timeout error
+func (i *Instance) waitForRestartCount(ctx context.Context, goal string, reached func(int32) bool) (int32, error) {
+	ctx, cancel := i.session().withDefaultTimeout(ctx)
+	defer cancel()
+	ticker := time.NewTicker(restartPollInterval)
+	defer ticker.Stop()
+
+	var restarts int32
+	for {
+		status, err := i.Status()
+		if err != nil {
+			return restarts, err
+		}
+		restarts = status.RestartCount()
+		if reached(restarts) {
+			return restarts, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return restarts, fmt.Errorf("timeout while waiting for restart count of instance '%s' to %s, it is '%d': %w", i.k8sName, goal, restarts, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// httpClientTimeout is the timeout of requests of the client returned by HTTPClient
+	httpClientTimeout = 30 * time.Second
+	// httpClientRetries is the number of times a request that cannot connect to the instance is retried
+	httpClientRetries = 5
+	// httpClientRetryInterval is the time between retries of a request that cannot connect to the instance
+	httpClientRetryInterval = time.Second
+	// httpReadyPollInterval is the interval in which WaitUntilHTTPEndpointIsReady requests the endpoint
+	httpReadyPollInterval = time.Second
+)
+
+// HTTPClient returns an HTTP client and the base URL (e.g. 'http://host:port') of the TCP service port of the instance
+// Inside the cluster, the URL points to the service DNS name of the instance, otherwise to a port-forward to its first pod,
+// see SetInCluster to override the detection
+// Requests that cannot connect, e.g. while the application is still starting, are retried a few times, unless their body
+// cannot be replayed
+// This function can only be called in the state 'Started'
+func (i *Instance) HTTPClient(port int) (*http.Client, string, error) {
+	if !i.IsInState(Started) {
+		return nil, "", i.errInvalidStateTransition("getting HTTP client", Started)
+	}
+	address, err := i.endpointAddress(port)
+	if err != nil {
+		return nil, "", err
+	}
+	client := &http.Client{
+		Timeout:   httpClientTimeout,
+		Transport: &retryTransport{base: http.DefaultTransport},
+	}
+	return client, "http://" + address, nil
+}
+
+// WaitUntilHTTPEndpointIsReady waits until a GET request of the path on the TCP service port of the instance returns
+// a 2xx status, or until the context is done, e.g. to wait for the health endpoint of an application
+// The endpoint is reached like with HTTPClient
+// This function can only be called in the state 'Started'
+func (i *Instance) WaitUntilHTTPEndpointIsReady(ctx context.Context, port int, path string) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("waiting for HTTP endpoint", Started)
+	}
+	client, baseURL, err := i.HTTPClient(port)
+	if err != nil {
+		return err
+	}
+	url := baseURL + "/" + strings.TrimPrefix(path, "/")
+	ctx, cancel := i.session().withDefaultTimeout(ctx)
+	defer cancel()
+	ticker := time.NewTicker(httpReadyPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("error creating request for '%s': %w", url, err)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				i.logger().Debugf("HTTP endpoint '%s' of instance '%s' is ready", url, i.name)
+				return nil
+			}
+			err = fmt.Errorf("status '%s'", resp.Status)
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout while waiting for HTTP endpoint '%s' of instance '%s' to be ready, last error: %v: %w", url, i.name, lastErr, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// GRPCTarget returns the target ('host:port') to dial the gRPC server on the TCP port of the instance with grpc.Dial
+// Inside the cluster, it is the service DNS name of the instance, otherwise a port-forward to its first pod,
+// see SetInCluster to override the detection
+// This function can only be called in the state 'Started'
+func (i *Instance) GRPCTarget(port int) (string, error) {
+	if !i.IsInState(Started) {
+		return "", i.errInvalidStateTransition("getting gRPC target", Started)
+	}
+	return i.endpointAddress(port)
+}
+
+// GRPCDial dials the gRPC server on the TCP port of the instance with the options, at the target returned by GRPCTarget
+// This function can only be called in the state 'Started'
+func (i *Instance) GRPCDial(port int, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	target, err := i.GRPCTarget(port)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing gRPC server '%s' of instance '%s': %w", target, i.k8sName, err)
+	}
+	return conn, nil
+}
+
+// endpointAddress returns the address the TCP port of the instance is reachable at from the test
+// Port-forwards are reused, so that repeated calls do not open new ones
+func (i *Instance) endpointAddress(port int) (string, error) {
+	if i.isExternal() {
+		return i.externalAddress(port)
+	}
+	if !i.isTCPServicePort(port) {
+		return "", fmt.Errorf("TCP port '%d' is not registered in instance '%s'", port, i.k8sName)
+	}
+	if i.session().inCluster {
+		host := fmt.Sprintf("%s.%s.svc.cluster.local", i.k8sName, i.namespace())
+		return net.JoinHostPort(host, strconv.Itoa(port)), nil
+	}
+
+	i.portForwardsMu.Lock()
+	defer i.portForwardsMu.Unlock()
+	localPort, ok := i.portForwards[port]
+	if !ok {
+		var err error
+		localPort, err = i.PortForwardTCP(i.tcpContainerPort(port))
+		if err != nil {
+			return "", fmt.Errorf("error forwarding port '%d' of instance '%s': %w", port, i.k8sName, err)
+		}
+		if i.portForwards == nil {
+			i.portForwards = make(map[int]int)
+		}
+		i.portForwards[port] = localPort
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort)), nil
+}
+
+// resetPortForwards forgets the port-forwards of the instance, as they forward to pods that are replaced or restarted,
+// so that the next client opens new ones
+func (i *Instance) resetPortForwards() {
+	i.portForwardsMu.Lock()
+	defer i.portForwardsMu.Unlock()
+	i.portForwards = nil
+}
+
+// retryTransport retries requests that cannot connect to the server, e.g. because it is still starting
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends the request, retrying it if it cannot connect and its body can be replayed
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil || attempt == httpClientRetries || !k8s.IsRetriableError(err) {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(httpClientRetryInterval):
+		}
+	}
+}
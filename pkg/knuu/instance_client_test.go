@@ -0,0 +1,55 @@
+package knuu
+
+import (
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestGRPCDialUsesServiceInCluster(t *testing.T) {
+	inCluster := true
+	k, _ := newTestKnuu(t, Options{InCluster: &inCluster})
+	instance := newTestInstance(t, k, "grpc", 9090)
+	if err := instance.Start(); err != nil {
+		t.Fatalf("starting instance: %v", err)
+	}
+
+	conn, err := instance.GRPCDial(9090, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing gRPC server: %v", err)
+	}
+	defer conn.Close()
+	if expected := instance.k8sName + "." + testNamespace + ".svc.cluster.local:9090"; conn.Target() != expected {
+		t.Errorf("expected target '%s', got '%s'", expected, conn.Target())
+	}
+
+	if _, err := instance.GRPCDial(9091, grpc.WithTransportCredentials(insecure.NewCredentials())); err == nil {
+		t.Error("expected error dialing a port that is not registered")
+	}
+}
+
+func TestPortForwardsAreResetWhenPodsAreReplaced(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "forwarded", 8080)
+	if err := instance.Start(); err != nil {
+		t.Fatalf("starting instance: %v", err)
+	}
+
+	// The port-forward of a previous client forwards to the pod that is evicted
+	instance.portForwards = map[int]int{8080: 30080}
+	if err := instance.Evict(); err != nil {
+		t.Fatalf("evicting instance: %v", err)
+	}
+	if len(instance.portForwards) != 0 {
+		t.Errorf("expected port-forwards to be reset after eviction, got %v", instance.portForwards)
+	}
+
+	instance.portForwards = map[int]int{8080: 30080}
+	if err := instance.Stop(); err != nil {
+		t.Fatalf("stopping instance: %v", err)
+	}
+	if len(instance.portForwards) != 0 {
+		t.Errorf("expected port-forwards to be reset after stopping, got %v", instance.portForwards)
+	}
+}
@@ -0,0 +1,79 @@
+package knuu
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// podNameEnv is the environment variable the pod name placeholder is expanded to
+// Kubernetes expands $(VAR) references in the command and arguments, and each replica gets its own pod name
+const podNameEnv = "KNUU_POD_NAME"
+
+// commandTemplateData are the placeholders available in the command and arguments of an instance
+type commandTemplateData struct {
+	// ServiceName is the name of the instance's service, which resolves to the instance in the namespace
+	ServiceName string
+	// Namespace is the namespace the instance is deployed to
+	Namespace string
+	// PodName is the name of the pod the command runs in
+	PodName string
+}
+
+// renderCommandAndArgs expands the placeholders in the command and arguments of the instance
+// The returned environment variable has to be set to the pod name if a placeholder references it, it is empty otherwise
+func (i *Instance) renderCommandAndArgs() ([]string, []string, string, error) {
+	data := commandTemplateData{
+		ServiceName: i.k8sName,
+		Namespace:   i.namespace(),
+		PodName:     fmt.Sprintf("$(%s)", podNameEnv),
+	}
+	command, err := renderCommandTemplates(i.command, data)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error rendering command of instance '%s': %w", i.name, err)
+	}
+	args, err := renderCommandTemplates(i.args, data)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error rendering args of instance '%s': %w", i.name, err)
+	}
+
+	var env string
+	for _, rendered := range append(append([]string{}, command...), args...) {
+		if strings.Contains(rendered, data.PodName) {
+			env = podNameEnv
+			break
+		}
+	}
+	return command, args, env, nil
+}
+
+// validateCommandTemplates checks that the values only use known placeholders, so that errors are reported when setting them
+func validateCommandTemplates(values []string) error {
+	_, err := renderCommandTemplates(values, commandTemplateData{})
+	return err
+}
+
+// renderCommandTemplates expands the placeholders, e.g. {{.ServiceName}}, in the values
+// Unknown placeholders are an error instead of being left in the values
+func renderCommandTemplates(values []string, data commandTemplateData) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	rendered := make([]string, len(values))
+	for j, value := range values {
+		if !strings.Contains(value, "{{") {
+			rendered[j] = value
+			continue
+		}
+		tmpl, err := template.New("command").Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid placeholder in '%s': %w", value, err)
+		}
+		var builder strings.Builder
+		if err := tmpl.Execute(&builder, data); err != nil {
+			return nil, fmt.Errorf("invalid placeholder in '%s', only {{.ServiceName}}, {{.Namespace}} and {{.PodName}} are supported: %w", value, err)
+		}
+		rendered[j] = builder.String()
+	}
+	return rendered, nil
+}
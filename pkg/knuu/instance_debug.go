@@ -0,0 +1,395 @@
+package knuu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"time"
+)
+
+// defaultDumpLogLines is the number of log lines of each container in a debug dump, if not set in DumpOptions
+const defaultDumpLogLines = 100
+
+// defaultDumpEvents is the number of recent events in a debug dump, if not set in DumpOptions
+const defaultDumpEvents = 20
+
+// DumpOptions configures the debug dump of an instance
+type DumpOptions struct {
+	// LogLines is the number of log lines of each container, defaults to 100
+	LogLines int
+	// Events is the number of most recent events, defaults to 20
+	Events int
+	// Dir is the directory the dump is written to as '<k8s name>.json', nothing is written if it is empty
+	Dir string
+}
+
+// instanceDump is the debug dump of an instance
+type instanceDump struct {
+	Name      string      `json:"name"`
+	K8sName   string      `json:"k8sName"`
+	Namespace string      `json:"namespace,omitempty"`
+	State     string      `json:"state"`
+	Time      time.Time   `json:"time"`
+	Pods      []podDump   `json:"pods,omitempty"`
+	Events    []eventDump `json:"events,omitempty"`
+	// PodSpec is the spec of the first pod, or of the pod template of the statefulSet if there is no pod
+	PodSpec *v1.PodSpec `json:"podSpec,omitempty"`
+	// Errors are the errors that occurred while gathering the dump, the dump is incomplete if there are any
+	Errors []string `json:"errors,omitempty"`
+}
+
+// podDump is the debug dump of a pod of an instance
+type podDump struct {
+	Name       string          `json:"name"`
+	Phase      v1.PodPhase     `json:"phase"`
+	Reason     string          `json:"reason,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Node       string          `json:"node,omitempty"`
+	Conditions []conditionDump `json:"conditions,omitempty"`
+	Containers []containerDump `json:"containers,omitempty"`
+}
+
+// conditionDump is the debug dump of a condition of a pod
+type conditionDump struct {
+	Type    v1.PodConditionType `json:"type"`
+	Status  v1.ConditionStatus  `json:"status"`
+	Reason  string              `json:"reason,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// containerDump is the debug dump of a container of a pod
+type containerDump struct {
+	Name         string `json:"name"`
+	Init         bool   `json:"init,omitempty"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	State        string `json:"state"`
+	// LastTermination is the reason and exit code of the last termination of the container, if it restarted
+	LastTermination string `json:"lastTermination,omitempty"`
+	Logs            string `json:"logs,omitempty"`
+	// PreviousLogs are the logs of the last run of the container, if it restarted
+	PreviousLogs string `json:"previousLogs,omitempty"`
+}
+
+// eventDump is the debug dump of an event
+type eventDump struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Object  string    `json:"object"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Count   int32     `json:"count,omitempty"`
+}
+
+// DebugDump gathers everything needed to debug the instance: the phase and conditions of its pods,
+// the statuses of their containers with restart counts and last termination reasons, recent events,
+// the last log lines of every container and the pod spec
+// It returns a human-readable report and, if opts.Dir is set, writes the dump as JSON to the directory
+// It can be called in every state, also on partially deployed or failed instances
+// Errors while gathering the dump are part of the report, only an error writing the dump is returned
+func (i *Instance) DebugDump(opts DumpOptions) (string, error) {
+	if i == nil {
+		return "", fmt.Errorf("instance must not be nil")
+	}
+	dump := i.debugDump(opts)
+	report := dump.report()
+	if opts.Dir == "" {
+		return report, nil
+	}
+	if err := dump.writeJSON(opts.Dir); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// DumpAll writes the debug dumps of all instances of the default session to the directory, see Knuu.DumpAll
+func DumpAll(dir string) error {
+	if defaultKnuu == nil {
+		return fmt.Errorf("knuu is not initialized")
+	}
+	return defaultKnuu.DumpAll(dir)
+}
+
+// DumpAll writes the debug dumps of all instances created in the session to the directory
+// For every instance, the report is written as '<k8s name>.txt' and the dump as '<k8s name>.json'
+// A failing instance does not stop the dumps of the others, all errors are returned
+func (k *Knuu) DumpAll(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("dump directory must not be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating dump directory '%s': %w", dir, err)
+	}
+
+	k.instancesMu.Lock()
+	instances := make([]*Instance, len(k.instances))
+	copy(instances, k.instances)
+	k.instancesMu.Unlock()
+
+	var errs []error
+	for _, instance := range instances {
+		dump := instance.debugDump(DumpOptions{})
+		reportPath := filepath.Join(dir, dump.fileName()+".txt")
+		if err := os.WriteFile(reportPath, []byte(dump.report()), 0644); err != nil {
+			errs = append(errs, fmt.Errorf("error writing debug report of instance '%s': %w", dump.K8sName, err))
+			continue
+		}
+		if err := dump.writeJSON(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	log.Debugf("Dumped %d instances to '%s'", len(instances), dir)
+	return errors.Join(errs...)
+}
+
+// debugDump gathers the debug dump of the instance, recording errors instead of returning them
+func (i *Instance) debugDump(opts DumpOptions) *instanceDump {
+	if opts.LogLines <= 0 {
+		opts.LogLines = defaultDumpLogLines
+	}
+	if opts.Events <= 0 {
+		opts.Events = defaultDumpEvents
+	}
+	dump := &instanceDump{
+		Name:      i.name,
+		K8sName:   i.k8sName,
+		Namespace: i.namespace(),
+		State:     i.state.String(),
+		Time:      time.Now(),
+	}
+	// Only started or stopped instances have resources that are worth inspecting
+	if !i.IsInState(Started, Stopped) || !i.k8sClient().IsInitialized() {
+		return dump
+	}
+
+	pods, err := i.k8sClient().ListPods(i.namespace(), map[string]string{"app": i.k8sName})
+	if err != nil {
+		dump.Errors = append(dump.Errors, err.Error())
+	}
+	objectNames := []string{i.k8sName}
+	for _, pod := range pods {
+		dump.Pods = append(dump.Pods, i.dumpPod(pod, int64(opts.LogLines), dump))
+		objectNames = append(objectNames, pod.Name)
+	}
+
+	if len(pods) != 0 {
+		dump.PodSpec = &pods[0].Spec
+	} else if statefulSet, err := i.k8sClient().GetStatefulSet(i.namespace(), i.k8sName); err != nil {
+		dump.Errors = append(dump.Errors, err.Error())
+	} else {
+		dump.PodSpec = &statefulSet.Spec.Template.Spec
+	}
+
+	events, err := i.k8sClient().GetEvents(i.namespace(), objectNames)
+	if err != nil {
+		dump.Errors = append(dump.Errors, err.Error())
+	}
+	if len(events) > opts.Events {
+		events = events[len(events)-opts.Events:]
+	}
+	for _, event := range events {
+		eventTime := event.LastTimestamp.Time
+		if eventTime.IsZero() {
+			eventTime = event.EventTime.Time
+		}
+		dump.Events = append(dump.Events, eventDump{
+			Time:    eventTime,
+			Type:    event.Type,
+			Object:  fmt.Sprintf("%s/%s", event.InvolvedObject.Kind, event.InvolvedObject.Name),
+			Reason:  event.Reason,
+			Message: event.Message,
+			Count:   event.Count,
+		})
+	}
+	return dump
+}
+
+// dumpPod gathers the debug dump of a pod of the instance, including the logs of its containers
+func (i *Instance) dumpPod(pod v1.Pod, logLines int64, dump *instanceDump) podDump {
+	dumped := podDump{
+		Name:    pod.Name,
+		Phase:   pod.Status.Phase,
+		Reason:  pod.Status.Reason,
+		Message: pod.Status.Message,
+		Node:    pod.Spec.NodeName,
+	}
+	for _, condition := range pod.Status.Conditions {
+		dumped.Conditions = append(dumped.Conditions, conditionDump{
+			Type:    condition.Type,
+			Status:  condition.Status,
+			Reason:  condition.Reason,
+			Message: condition.Message,
+		})
+	}
+
+	statuses := make([]v1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(append(statuses, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for j, status := range statuses {
+		container := containerDump{
+			Name:         status.Name,
+			Init:         j < len(pod.Status.InitContainerStatuses),
+			Ready:        status.Ready,
+			RestartCount: status.RestartCount,
+			State:        containerStateString(status.State),
+		}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil {
+			container.LastTermination = fmt.Sprintf("%s (exit code %d)", terminated.Reason, terminated.ExitCode)
+		}
+		// Containers that never started have no logs
+		if status.State.Waiting == nil || status.RestartCount > 0 {
+			logs, err := i.k8sClient().GetPodLogs(i.namespace(), pod.Name, status.Name, logLines, false)
+			if err != nil {
+				dump.Errors = append(dump.Errors, err.Error())
+			}
+			container.Logs = logs
+		}
+		if status.RestartCount > 0 {
+			logs, err := i.k8sClient().GetPodLogs(i.namespace(), pod.Name, status.Name, logLines, true)
+			if err != nil {
+				dump.Errors = append(dump.Errors, err.Error())
+			}
+			container.PreviousLogs = logs
+		}
+		dumped.Containers = append(dumped.Containers, container)
+	}
+	return dumped
+}
+
+// containerStateString returns a readable description of the state of a container
+func containerStateString(state v1.ContainerState) string {
+	switch {
+	case state.Running != nil:
+		return fmt.Sprintf("Running since %s", state.Running.StartedAt.Format(time.RFC3339))
+	case state.Waiting != nil:
+		if state.Waiting.Message != "" {
+			return fmt.Sprintf("Waiting: %s (%s)", state.Waiting.Reason, state.Waiting.Message)
+		}
+		return fmt.Sprintf("Waiting: %s", state.Waiting.Reason)
+	case state.Terminated != nil:
+		return fmt.Sprintf("Terminated: %s (exit code %d)", state.Terminated.Reason, state.Terminated.ExitCode)
+	}
+	return "Unknown"
+}
+
+// report returns the dump as a human-readable report
+func (d *instanceDump) report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Instance '%s' (%s) in namespace '%s'\n", d.Name, d.K8sName, d.Namespace)
+	fmt.Fprintf(&b, "State: %s\n", d.State)
+	fmt.Fprintf(&b, "Dumped at: %s\n", d.Time.Format(time.RFC3339))
+
+	if len(d.Errors) != 0 {
+		b.WriteString("\nErrors while gathering the dump:\n")
+		for _, err := range d.Errors {
+			fmt.Fprintf(&b, "  %s\n", err)
+		}
+	}
+
+	for _, pod := range d.Pods {
+		fmt.Fprintf(&b, "\nPod %s: %s", pod.Name, pod.Phase)
+		if pod.Reason != "" {
+			fmt.Fprintf(&b, ", reason %s", pod.Reason)
+		}
+		if pod.Message != "" {
+			fmt.Fprintf(&b, " (%s)", pod.Message)
+		}
+		if pod.Node != "" {
+			fmt.Fprintf(&b, ", node %s", pod.Node)
+		}
+		b.WriteString("\n")
+		if len(pod.Conditions) != 0 {
+			b.WriteString("  Conditions:\n")
+		}
+		for _, condition := range pod.Conditions {
+			fmt.Fprintf(&b, "    %s=%s", condition.Type, condition.Status)
+			if condition.Reason != "" {
+				fmt.Fprintf(&b, " %s", condition.Reason)
+			}
+			if condition.Message != "" {
+				fmt.Fprintf(&b, ": %s", condition.Message)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("  Containers:\n")
+		for _, container := range pod.Containers {
+			kind := "container"
+			if container.Init {
+				kind = "init container"
+			}
+			fmt.Fprintf(&b, "    %s %s: %s, ready %t, restarts %d", kind, container.Name, container.State, container.Ready, container.RestartCount)
+			if container.LastTermination != "" {
+				fmt.Fprintf(&b, ", last terminated: %s", container.LastTermination)
+			}
+			b.WriteString("\n")
+		}
+		for _, container := range pod.Containers {
+			if container.PreviousLogs != "" {
+				fmt.Fprintf(&b, "  Logs of the previous run of %s:\n%s", container.Name, indent(container.PreviousLogs, "    "))
+			}
+			if container.Logs != "" {
+				fmt.Fprintf(&b, "  Logs of %s:\n%s", container.Name, indent(container.Logs, "    "))
+			}
+		}
+	}
+	if len(d.Pods) == 0 && d.State == Started.String() {
+		b.WriteString("\nNo pods found\n")
+	}
+
+	if len(d.Events) != 0 {
+		b.WriteString("\nEvents:\n")
+		for _, event := range d.Events {
+			fmt.Fprintf(&b, "  %s %s %s %s: %s", event.Time.Format(time.RFC3339), event.Type, event.Object, event.Reason, event.Message)
+			if event.Count > 1 {
+				fmt.Fprintf(&b, " (x%d)", event.Count)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if d.PodSpec != nil {
+		spec, err := yaml.Marshal(d.PodSpec)
+		if err != nil {
+			fmt.Fprintf(&b, "\nError rendering pod spec: %v\n", err)
+		} else {
+			fmt.Fprintf(&b, "\nPod spec:\n%s", indent(string(spec), "  "))
+		}
+	}
+	return b.String()
+}
+
+// writeJSON writes the dump as '<k8s name>.json' to the directory
+func (d *instanceDump) writeJSON(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating dump directory '%s': %w", dir, err)
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling debug dump of instance '%s': %w", d.K8sName, err)
+	}
+	path := filepath.Join(dir, d.fileName()+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing debug dump of instance '%s': %w", d.K8sName, err)
+	}
+	return nil
+}
+
+// fileName returns the name of the files the dump is written to, without extension
+// Instances that were never committed have no k8s name yet
+func (d *instanceDump) fileName() string {
+	if d.K8sName == "" {
+		return d.Name
+	}
+	return d.K8sName
+}
+
+// indent prefixes every line of the text, which always ends with a newline
+func indent(text, prefix string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
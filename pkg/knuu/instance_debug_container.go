@@ -0,0 +1,54 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// debugContainerTimeout is the time DebugContainer waits for the debug container to be running
+const debugContainerTimeout = 2 * time.Minute
+
+// SetShareProcessNamespace sets whether all containers of the instance's pods share one process namespace,
+// so that sidecars and debug containers (see DebugContainer) see and can signal the processes of the main container
+// The main process is not PID 1 anymore if it is enabled, which changes how it receives signals
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetShareProcessNamespace(enabled bool) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting share process namespace", Preparing, Committed)
+	}
+	i.shareProcessNamespace = enabled
+	i.logger().Debugf("Set share process namespace to '%t' in instance '%s'", enabled, i.name)
+	return nil
+}
+
+// DebugContainer attaches an ephemeral container with the image and command to the first pod of the instance and returns its name,
+// e.g. to run tcpdump or a debugger against the main process, which it sees as it targets the process namespace of the main container
+// The container runs until its command exits or the pod is replaced, e.g. by a restart or an update of the instance,
+// and cannot be removed, so the command should exit on its own if the pod lives longer than the debugging
+// The cluster must support ephemeral containers (Kubernetes 1.23 or later)
+// This function can only be called in the state 'Started'
+func (i *Instance) DebugContainer(image string, cmd []string) (string, error) {
+	if !i.IsInState(Started) {
+		return "", i.errInvalidStateTransition("attaching debug container", Started)
+	}
+	if err := validateImageName(image); err != nil {
+		return "", err
+	}
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
+	if err != nil {
+		return "", fmt.Errorf("error getting pod from statefulset '%s': %w", i.k8sName, err)
+	}
+	name, err := generateK8sName("debug")
+	if err != nil {
+		return "", fmt.Errorf("error generating name of debug container: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), debugContainerTimeout)
+	defer cancel()
+	if err := i.k8sClient().AddEphemeralContainer(ctx, i.namespace(), pod.Name, i.k8sName, name, image, cmd); err != nil {
+		return "", fmt.Errorf("error attaching debug container to pod '%s' of instance '%s': %w", pod.Name, i.k8sName, err)
+	}
+	i.logger().Debugf("Attached debug container '%s' to pod '%s' of instance '%s'", name, pod.Name, i.k8sName)
+	return name, nil
+}
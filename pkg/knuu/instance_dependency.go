@@ -0,0 +1,209 @@
+package knuu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DependsOn declares that the instance depends on the other instance
+// Start waits until all dependencies are running before deploying the instance, and StartAll starts dependencies first
+// Dependency cycles are rejected
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) DependsOn(other *Instance) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding dependency", Preparing, Committed)
+	}
+	if other == nil {
+		return fmt.Errorf("dependency of instance '%s' must not be nil", i.name)
+	}
+	if other == i {
+		return fmt.Errorf("instance '%s' cannot depend on itself", i.name)
+	}
+	for _, dependency := range i.dependencies {
+		if dependency == other {
+			return nil
+		}
+	}
+	if path := other.dependencyPath(i); path != nil {
+		names := []string{i.name}
+		for _, instance := range path {
+			names = append(names, instance.name)
+		}
+		return fmt.Errorf("dependency of instance '%s' on '%s' would create a cycle: %s", i.name, other.name, strings.Join(names, " -> "))
+	}
+	i.dependencies = append(i.dependencies, other)
+	i.logger().Debugf("Added dependency on instance '%s' to instance '%s'", other.name, i.name)
+	return nil
+}
+
+// AddStartDependency declares that the instance must only be started once the dependency is running, see DependsOn
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddStartDependency(dependency *Instance) error {
+	return i.DependsOn(dependency)
+}
+
+// Dependencies returns the instances the instance depends on
+func (i *Instance) Dependencies() []*Instance {
+	return i.dependencies
+}
+
+// dependencyPath returns the path of dependencies from the instance to the target, or nil if the target is no (transitive) dependency
+func (i *Instance) dependencyPath(target *Instance) []*Instance {
+	if i == target {
+		return []*Instance{i}
+	}
+	for _, dependency := range i.dependencies {
+		if path := dependency.dependencyPath(target); path != nil {
+			return append([]*Instance{i}, path...)
+		}
+	}
+	return nil
+}
+
+// waitForDependencies waits until all dependencies of the instance are running
+func (i *Instance) waitForDependencies() error {
+	for _, dependency := range i.dependencies {
+		if !dependency.IsInState(Started) {
+			return fmt.Errorf("dependency '%s' of instance '%s' is not started. Current state is '%s'", dependency.name, i.name, dependency.state.String())
+		}
+		i.logger().Debugf("Waiting for dependency '%s' of instance '%s' to be running", dependency.name, i.name)
+		if err := dependency.WaitInstanceIsRunning(); err != nil {
+			return fmt.Errorf("error waiting for dependency '%s' of instance '%s': %w", dependency.name, i.name, err)
+		}
+	}
+	return nil
+}
+
+// StartAll starts the instances in the order of their dependencies
+// Instances whose dependencies are running are started in parallel
+// Dependencies that are not part of the given instances have to be started already
+// It stops starting further instances when the context is done or an instance fails to start
+// External instances (see NewExternalInstance) are not started, but instances that depend on them are started after them
+// If the budget of the global timeout is exhausted (see SetGlobalTimeout), no instance is started
+func StartAll(ctx context.Context, instances ...*Instance) error {
+	waves, err := startOrder(instances)
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		if err := instance.session().checkBudget(); err != nil {
+			instance.session().teardownIfBudgetExhausted()
+			return fmt.Errorf("error starting instances: %w", err)
+		}
+	}
+
+	for _, wave := range waves {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("error starting instances: %w", err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(wave))
+		for j, instance := range wave {
+			if instance.isExternal() {
+				continue
+			}
+			wg.Add(1)
+			go func(j int, instance *Instance) {
+				defer wg.Done()
+				errs[j] = instance.Start()
+			}(j, instance)
+		}
+		wg.Wait()
+
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("error starting instances: %w", err)
+		}
+	}
+	return nil
+}
+
+// DestroyAll destroys the instances in the reverse order of StartAll, so that dependents are destroyed before their dependencies
+// Instances without dependencies between them are destroyed in parallel
+// Instances that were never started are skipped, and the remaining instances are destroyed even if an instance fails to be destroyed
+func DestroyAll(ctx context.Context, instances ...*Instance) error {
+	waves, err := startOrder(instances)
+	if err != nil {
+		return err
+	}
+
+	// The teardown of an exhausted budget is done before the instances are destroyed concurrently, as it destroys them as well
+	for _, instance := range instances {
+		instance.session().teardownIfBudgetExhausted()
+	}
+
+	var errs []error
+	for w := len(waves) - 1; w >= 0; w-- {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		var wg sync.WaitGroup
+		waveErrs := make([]error, len(waves[w]))
+		for j, instance := range waves[w] {
+			if instance.IsInState(Preparing, Committed) {
+				continue
+			}
+			wg.Add(1)
+			go func(j int, instance *Instance) {
+				defer wg.Done()
+				waveErrs[j] = instance.Destroy()
+			}(j, instance)
+		}
+		wg.Wait()
+		errs = append(errs, waveErrs...)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("error destroying instances: %w", err)
+	}
+	return nil
+}
+
+// startOrder groups the instances into waves, so that every instance only depends on instances of earlier waves
+// It returns an error if the dependencies contain a cycle
+func startOrder(instances []*Instance) ([][]*Instance, error) {
+	remaining := make(map[*Instance]bool, len(instances))
+	for _, instance := range instances {
+		remaining[instance] = true
+	}
+
+	var waves [][]*Instance
+	for len(remaining) > 0 {
+		var wave []*Instance
+		// Iterate over the given slice instead of the map, so that the order is deterministic
+		for _, instance := range instances {
+			if !remaining[instance] {
+				continue
+			}
+			ready := true
+			for _, dependency := range instance.dependencies {
+				if remaining[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, instance)
+			}
+		}
+		if len(wave) == 0 {
+			var names []string
+			for _, instance := range instances {
+				if remaining[instance] {
+					names = append(names, instance.name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between instances: %s", strings.Join(names, ", "))
+		}
+		for _, instance := range wave {
+			delete(remaining, instance)
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
@@ -0,0 +1,94 @@
+package knuu
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Diff returns a human-readable list of the configuration fields that differ between the instance and the other instance,
+// e.g. "env 'FOO': 'bar' != 'baz'"
+// It compares the image, the files, the ports, the command and args, the environment, the resources, the volumes,
+// the user and the replicas, but not the names or states of the instances
+// It returns an empty list if the configurations are equal
+func (i *Instance) Diff(other *Instance) []string {
+	var diffs []string
+	diffValue := func(field string, a, b interface{}) {
+		if sa, sb := fmt.Sprint(a), fmt.Sprint(b); sa != sb {
+			diffs = append(diffs, fmt.Sprintf("%s: '%s' != '%s'", field, sa, sb))
+		}
+	}
+	diffMap := func(field string, a, b map[string]string) {
+		keys := make(map[string]struct{}, len(a)+len(b))
+		for key := range a {
+			keys[key] = struct{}{}
+		}
+		for key := range b {
+			keys[key] = struct{}{}
+		}
+		for _, key := range sortedKeys(keys) {
+			va, okA := a[key]
+			vb, okB := b[key]
+			switch {
+			case !okA:
+				diffs = append(diffs, fmt.Sprintf("%s '%s': <unset> != '%s'", field, key, vb))
+			case !okB:
+				diffs = append(diffs, fmt.Sprintf("%s '%s': '%s' != <unset>", field, key, va))
+			case va != vb:
+				diffs = append(diffs, fmt.Sprintf("%s '%s': '%s' != '%s'", field, key, va, vb))
+			}
+		}
+	}
+
+	diffValue("image", i.imageName, other.imageName)
+	diffMap("file", i.fileChecksums, other.fileChecksums)
+	diffValue("tcp ports", i.portsTCP, other.portsTCP)
+	diffValue("udp ports", i.portsUDP, other.portsUDP)
+	diffValue("command", i.command, other.command)
+	diffValue("args", i.args, other.args)
+	diffMap("env", i.env, other.env)
+	diffValue("memory request", i.memoryRequest, other.memoryRequest)
+	diffValue("memory limit", i.memoryLimit, other.memoryLimit)
+	diffValue("cpu request", i.cpuRequest, other.cpuRequest)
+	diffValue("cpu limit", i.cpuLimit, other.cpuLimit)
+	diffValue("ephemeral storage request", i.ephemeralStorageRequest, other.ephemeralStorageRequest)
+	diffValue("ephemeral storage limit", i.ephemeralStorageLimit, other.ephemeralStorageLimit)
+	diffMap("extended resource", i.extendedResources, other.extendedResources)
+	diffValue("volumes", i.volumeDescriptions(), other.volumeDescriptions())
+	diffValue("user", i.user, other.user)
+	diffValue("replicas", i.replicas, other.replicas)
+	return diffs
+}
+
+// Equal checks if the configuration of the instance equals the configuration of the other instance, see Diff
+func (i *Instance) Equal(other *Instance) bool {
+	return len(i.Diff(other)) == 0
+}
+
+// volumeDescriptions returns a sorted description of all volumes mounted by the instance
+func (i *Instance) volumeDescriptions() []string {
+	var descriptions []string
+	for _, volume := range i.volumes {
+		descriptions = append(descriptions, fmt.Sprintf("%s (size %s, owner %d, subPath '%s')", volume.Path, volume.Size, volume.Owner, volume.SubPath))
+	}
+	for _, volume := range i.hostPathVolumes {
+		descriptions = append(descriptions, fmt.Sprintf("%s (hostPath %s)", volume.MountPath, volume.HostPath))
+	}
+	for _, volume := range i.claimVolumes {
+		descriptions = append(descriptions, fmt.Sprintf("%s (claim %s)", volume.MountPath, volume.ClaimName))
+	}
+	for _, volume := range i.sharedVolumes {
+		descriptions = append(descriptions, fmt.Sprintf("shared volume %s", volume.name))
+	}
+	sort.Strings(descriptions)
+	return descriptions
+}
+
+// sortedKeys returns the keys of the set in sorted order
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,64 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/container"
+	"os"
+)
+
+// SetDockerfile builds the image of the instance from the Dockerfile at the given path and the build context directory,
+// instead of a base image set with SetImage
+// The build context is copied into the build directory of the instance, so files added with AddFile are added to it,
+// and instructions of knuu (e.g. from AddFile or SetUser) are appended to the Dockerfile
+// This function can only be called in the state 'None'
+func (i *Instance) SetDockerfile(path string, contextDir string) error {
+	if !i.IsInState(None) {
+		return i.errInvalidStateTransition("setting Dockerfile", None)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error reading Dockerfile '%s': %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("dockerfile path '%s' is a directory", path)
+	}
+	dockerfile, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading Dockerfile '%s': %w", path, err)
+	}
+	return i.SetDockerfileContent(string(dockerfile), contextDir)
+}
+
+// SetDockerfileContent builds the image of the instance from the content of a Dockerfile and the build context directory,
+// like SetDockerfile
+// This function can only be called in the state 'None'
+func (i *Instance) SetDockerfileContent(dockerfile string, contextDir string) error {
+	if !i.IsInState(None) {
+		return i.errInvalidStateTransition("setting Dockerfile", None)
+	}
+	info, err := os.Stat(contextDir)
+	if err != nil {
+		return fmt.Errorf("error reading build context '%s': %w", contextDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("build context '%s' is not a directory", contextDir)
+	}
+
+	if err := i.createBuildDir(); err != nil {
+		return err
+	}
+	factory, err := container.NewBuilderFactoryFromDockerfile(dockerfile, i.getBuildDir())
+	if err != nil {
+		i.removeBuildDir()
+		return fmt.Errorf("error creating builder: %w", err)
+	}
+	if err := copyDir(contextDir, i.getBuildDir()); err != nil {
+		i.removeBuildDir()
+		return fmt.Errorf("error copying build context '%s' of instance '%s': %w", contextDir, i.name, err)
+	}
+	i.session().configureBuilderFactory(factory)
+	i.builderFactory = factory
+	i.setState(Preparing)
+	i.logger().Debugf("Set Dockerfile with build context '%s' for instance '%s'", contextDir, i.name)
+	return nil
+}
@@ -0,0 +1,104 @@
+package knuu
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// instanceEndpoint is the endpoint of an instance's service that is injected into the environment of another instance
+type instanceEndpoint struct {
+	source *Instance
+	port   int
+}
+
+// SetEnvironmentVariableFromInstance sets the environment variable to the endpoint 'host:port' of the source instance's service
+// The endpoint is resolved when the instance is started, so the source only needs to be committed when it is called
+// The source becomes a dependency of the instance (see DependsOn), so it must be started before the instance
+// The port must be registered in the source by the time the instance is started
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetEnvironmentVariableFromInstance(envName string, source *Instance, port int) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting environment variable from instance", Preparing, Committed)
+	}
+	if envName == "" {
+		return fmt.Errorf("name of environment variable must not be empty")
+	}
+	if source == nil {
+		return fmt.Errorf("source of environment variable '%s' of instance '%s' must not be nil", envName, i.name)
+	}
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("port '%d' of environment variable '%s' of instance '%s' is invalid", port, envName, i.name)
+	}
+	if err := i.DependsOn(source); err != nil {
+		return err
+	}
+	if i.envFromInstances == nil {
+		i.envFromInstances = make(map[string]instanceEndpoint)
+	}
+	i.envFromInstances[envName] = instanceEndpoint{source: source, port: port}
+	i.logger().Debugf("Set environment variable '%s' to the endpoint of instance '%s' on port '%d' in instance '%s'", envName, source.name, port, i.name)
+	return nil
+}
+
+// checkEnvFromInstances checks that the sources of the environment variables set from instances are committed
+func (i *Instance) checkEnvFromInstances() error {
+	for envName, endpoint := range i.envFromInstances {
+		if !endpoint.source.IsInState(Committed, Started, Stopped) {
+			return fmt.Errorf("source instance '%s' of environment variable '%s' of instance '%s' was never committed. Current state is '%s'", endpoint.source.name, envName, i.name, endpoint.source.state.String())
+		}
+	}
+	return nil
+}
+
+// resolveEnvFromInstances sets the environment variables set from instances to the endpoints of the services of their sources
+func (i *Instance) resolveEnvFromInstances() error {
+	if len(i.envFromInstances) == 0 {
+		return nil
+	}
+	// The environment is shared with clones, which may be started concurrently
+	env := make(map[string]string, len(i.env)+len(i.envFromInstances))
+	for key, value := range i.env {
+		env[key] = value
+	}
+	for envName, endpoint := range i.envFromInstances {
+		source := endpoint.source
+		if !source.isTCPServicePort(endpoint.port) && !source.isUDPServicePort(endpoint.port) {
+			return fmt.Errorf("port '%d' of environment variable '%s' is not registered in instance '%s'", endpoint.port, envName, source.name)
+		}
+		if source.isExternal() {
+			address, err := source.externalAddress(endpoint.port)
+			if err != nil {
+				return fmt.Errorf("error resolving environment variable '%s' of instance '%s': %w", envName, i.name, err)
+			}
+			env[envName] = address
+			i.logger().Debugf("Resolved environment variable '%s' to '%s' in instance '%s'", envName, env[envName], i.name)
+			continue
+		}
+		var ip string
+		err := source.session().retryAPICall(fmt.Sprintf("getting IP of service '%s'", source.k8sName), func() error {
+			var err error
+			ip, err = source.k8sClient().GetServiceIP(source.namespace(), source.k8sName)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error resolving environment variable '%s' of instance '%s': %w", envName, i.name, err)
+		}
+		env[envName] = net.JoinHostPort(ip, strconv.Itoa(endpoint.port))
+		i.logger().Debugf("Resolved environment variable '%s' to '%s' in instance '%s'", envName, env[envName], i.name)
+	}
+	i.env = env
+	return nil
+}
+
+// cloneEnvFromInstances returns a copy of the environment variables set from instances for a clone of the instance
+func (i *Instance) cloneEnvFromInstances() map[string]instanceEndpoint {
+	if i.envFromInstances == nil {
+		return nil
+	}
+	envFromInstances := make(map[string]instanceEndpoint, len(i.envFromInstances))
+	for envName, endpoint := range i.envFromInstances {
+		envFromInstances[envName] = endpoint
+	}
+	return envFromInstances
+}
@@ -0,0 +1,85 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+	"time"
+)
+
+// Event represents a Kubernetes event of the instance's pod or statefulSet
+type Event struct {
+	Type      string
+	Reason    string
+	Message   string
+	Timestamp time.Time
+}
+
+// WatchEvents streams the Kubernetes events of the instance's pods and statefulSet
+// The pods are those of the replicas when it is called, so pods added by a later Scale are not watched
+// The returned channel is closed when the context is done
+// This function can only be called in the states 'Committed', 'Started' and 'Stopped'
+func (i *Instance) WatchEvents(ctx context.Context) (<-chan Event, error) {
+	if !i.IsInState(Committed, Started, Stopped) {
+		return nil, i.errInvalidStateTransition("watching events", Committed, Started, Stopped)
+	}
+	k8sEvents, err := i.k8sClient().WatchEvents(ctx, i.namespace(), i.eventObjectNames())
+	if err != nil {
+		return nil, fmt.Errorf("error watching events for instance '%s': %w", i.k8sName, err)
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for k8sEvent := range k8sEvents {
+			select {
+			case events <- newEvent(k8sEvent):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// eventObjectNames returns the names of the objects whose events are the instance's events, i.e. its statefulSet and the pods of all replicas
+func (i *Instance) eventObjectNames() []string {
+	names := []string{i.k8sName}
+	for replica := int32(0); replica < i.replicas; replica++ {
+		names = append(names, fmt.Sprintf("%s-%d", i.k8sName, replica))
+	}
+	return names
+}
+
+// newEvent normalizes a Kubernetes event
+func newEvent(event v1.Event) Event {
+	timestamp := event.LastTimestamp.Time
+	if timestamp.IsZero() {
+		timestamp = event.EventTime.Time
+	}
+	if timestamp.IsZero() {
+		timestamp = event.CreationTimestamp.Time
+	}
+	return Event{
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Timestamp: timestamp,
+	}
+}
+
+// describeEviction describes an eviction, naming the exhausted resource if it is known
+func describeEviction(message string) string {
+	for _, resource := range []v1.ResourceName{v1.ResourceEphemeralStorage, v1.ResourceMemory, v1.ResourceStorage} {
+		if strings.Contains(message, string(resource)) {
+			return fmt.Sprintf("evicted: %s (%s)", resource, message)
+		}
+	}
+	// The kubelet reports exceeded ephemeral storage limits as 'ephemeral local storage'
+	if strings.Contains(message, "ephemeral local storage") {
+		return fmt.Sprintf("evicted: %s (%s)", v1.ResourceEphemeralStorage, message)
+	}
+	return fmt.Sprintf("evicted: %s", message)
+}
@@ -0,0 +1,85 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+)
+
+// ErrEvictionBlocked is returned by Evict if the eviction would violate a pod disruption budget, see SetPodDisruptionBudget
+var ErrEvictionBlocked = k8s.ErrEvictionBlocked
+
+// Evict evicts the first pod of the instance with the eviction API, like a node drain does, so that it is rescheduled
+// Pod disruption budgets are respected, an error wrapping ErrEvictionBlocked is returned if the eviction would violate one
+// This function can only be called in the state 'Started'
+func (i *Instance) Evict() error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("evicting", Started)
+	}
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error getting pod from statefulset '%s': %w", i.k8sName, err)
+	}
+	if err := i.k8sClient().EvictPod(i.namespace(), pod.Name); err != nil {
+		return fmt.Errorf("error evicting pod of instance '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Evicted pod '%s' of instance '%s'", pod.Name, i.k8sName)
+	i.resetPortForwards()
+	return nil
+}
+
+// CordonNode cordons the node hosting the first pod of the instance, so that no new pods are scheduled on it,
+// e.g. to make the replacement of an evicted pod land on another node
+// Nodes are cluster-scoped, so this requires the cluster-scoped permission to patch nodes
+// The node is uncordoned by UncordonNode, or when the instance is destroyed
+// This function can only be called in the state 'Started'
+func (i *Instance) CordonNode() error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("cordoning node", Started)
+	}
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error getting pod from statefulset '%s': %w", i.k8sName, err)
+	}
+	node := pod.Spec.NodeName
+	if node == "" {
+		return fmt.Errorf("pod '%s' of instance '%s' is not scheduled on a node yet", pod.Name, i.k8sName)
+	}
+	if err := i.k8sClient().SetNodeUnschedulable(node, true); err != nil {
+		return fmt.Errorf("error cordoning node of instance '%s': %w", i.k8sName, err)
+	}
+	for _, cordoned := range i.cordonedNodes {
+		if cordoned == node {
+			return nil
+		}
+	}
+	i.cordonedNodes = append(i.cordonedNodes, node)
+	i.logger().Debugf("Cordoned node '%s' of instance '%s'", node, i.k8sName)
+	return nil
+}
+
+// UncordonNode uncordons the nodes cordoned by CordonNode, so that pods can be scheduled on them again
+// This function can only be called in the states 'Started' and 'Stopped'
+func (i *Instance) UncordonNode() error {
+	if !i.IsInState(Started, Stopped) {
+		return i.errInvalidStateTransition("uncordoning node", Started, Stopped)
+	}
+	return i.uncordonNodes()
+}
+
+// uncordonNodes uncordons the nodes cordoned by the instance, keeping the nodes that fail to be uncordoned
+func (i *Instance) uncordonNodes() error {
+	var remaining []string
+	var firstErr error
+	for _, node := range i.cordonedNodes {
+		if err := i.k8sClient().SetNodeUnschedulable(node, false); err != nil {
+			remaining = append(remaining, node)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error uncordoning node of instance '%s': %w", i.k8sName, err)
+			}
+			continue
+		}
+		i.logger().Debugf("Uncordoned node '%s' of instance '%s'", node, i.k8sName)
+	}
+	i.cordonedNodes = remaining
+	return firstErr
+}
@@ -0,0 +1,63 @@
+package knuu
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// NewExternalInstance creates an instance for a dependency that is not deployed by knuu, e.g. a database managed by another team,
+// which is reachable at the host (a DNS name, e.g. of a service in another namespace, or an IP) on the TCP ports
+// The instance is in the state 'Started' and is never built or deployed, so it can be used with GetServiceEndpoint, HTTPClient
+// and WaitUntilHTTPEndpointIsReady, as a dependency of other instances (see DependsOn and SetEnvironmentVariableFromInstance),
+// and with StartAll and DestroyAll, which skip it
+// Functions that need the pods of the instance fail, and Destroy does not delete anything
+func NewExternalInstance(name, host string, portsTCP []int) (*Instance, error) {
+	return defaultKnuu.NewExternalInstance(name, host, portsTCP)
+}
+
+// NewExternalInstance creates an instance for a dependency that is not deployed by knuu in the session, see NewExternalInstance
+// The instance is not part of the instances of the session, e.g. it is not dumped by DumpAll
+func (k *Knuu) NewExternalInstance(name, host string, portsTCP []int) (*Instance, error) {
+	if err := validateInstanceName(name); err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return nil, fmt.Errorf("host of external instance '%s' must not be empty", name)
+	}
+	if len(portsTCP) == 0 {
+		return nil, fmt.Errorf("external instance '%s' must have at least one TCP port", name)
+	}
+	for _, port := range portsTCP {
+		if err := validatePort(port); err != nil {
+			return nil, fmt.Errorf("invalid port of external instance '%s': %w", name, err)
+		}
+	}
+
+	instance := &Instance{
+		name:         name,
+		k8sName:      name,
+		state:        Started,
+		instanceType: ExternalInstance,
+		externalHost: host,
+		portsTCP:     append([]int(nil), portsTCP...),
+		portsUDP:     make([]int, 0),
+		env:          make(map[string]string),
+		knuu:         k,
+	}
+	instance.logger().Debugf("Created external instance '%s' at host '%s' with TCP ports '%v'", name, host, portsTCP)
+	return instance, nil
+}
+
+// isExternal returns true if the instance was created with NewExternalInstance, so it has no Kubernetes objects
+func (i *Instance) isExternal() bool {
+	return i.instanceType == ExternalInstance
+}
+
+// externalAddress returns the address of the TCP port of an external instance
+func (i *Instance) externalAddress(port int) (string, error) {
+	if !i.isTCPServicePort(port) {
+		return "", fmt.Errorf("TCP port '%d' is not registered in instance '%s'", port, i.name)
+	}
+	return net.JoinHostPort(i.externalHost, strconv.Itoa(port)), nil
+}
@@ -0,0 +1,111 @@
+package knuu
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FileSpec is a file added to the instance with AddFilesToBuilder
+type FileSpec struct {
+	// Src is the path of the file on the host
+	Src string
+	// Dest is the path of the file in the image
+	Dest string
+	// Chown is the owner of the file in the image, in the format 'user:group'
+	Chown string
+}
+
+// fileGroup are the files of a batch with the same owner and destination directory, which are added in one layer
+type fileGroup struct {
+	chown   string
+	destDir string
+	files   []FileSpec
+}
+
+// AddFilesToBuilder adds the files to the instance, like AddFile, but with one builder instruction, and thus one image layer,
+// for all files with the same owner and destination directory instead of one per file
+// All files are validated before any of them is added, and if copying a file fails, none of the files are added
+// The returned error names the file that failed
+// This function can only be called in the state 'Preparing'
+func (i *Instance) AddFilesToBuilder(files []FileSpec) error {
+	if !i.IsInState(Preparing) {
+		return i.errInvalidStateTransition("adding files", Preparing)
+	}
+
+	var groups []*fileGroup
+	groupIndex := make(map[[2]string]int)
+	dests := make(map[string]bool, len(files))
+	for n, file := range files {
+		if err := i.validateFileArgs(file.Src, file.Dest, file.Chown); err != nil {
+			return fmt.Errorf("invalid file %d of %d ('%s'): %w", n+1, len(files), file.Src, err)
+		}
+		info, err := os.Stat(file.Src)
+		if err != nil || info.IsDir() {
+			return fmt.Errorf("invalid file %d of %d: src '%s' does not exist or is a directory", n+1, len(files), file.Src)
+		}
+		if dests[file.Dest] {
+			return fmt.Errorf("invalid file %d of %d ('%s'): dest '%s' is used by another file", n+1, len(files), file.Src, file.Dest)
+		}
+		dests[file.Dest] = true
+
+		key := [2]string{file.Chown, path.Dir(file.Dest)}
+		index, ok := groupIndex[key]
+		if !ok {
+			index = len(groups)
+			groupIndex[key] = index
+			groups = append(groups, &fileGroup{chown: file.Chown, destDir: path.Dir(file.Dest)})
+		}
+		groups[index].files = append(groups[index].files, file)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	// Every group is staged in its own directory of the build context, which is added to its destination directory at once
+	checksums := make(map[string]string, len(files))
+	stageDirs := make([]string, len(groups))
+	err := i.withBuildDirLock(func() error {
+		for g, group := range groups {
+			stageDir, err := os.MkdirTemp(i.getBuildDir(), ".knuu-files-")
+			if err != nil {
+				return fmt.Errorf("error creating staging directory: %w", err)
+			}
+			stageDirs[g] = filepath.Base(stageDir)
+			for _, file := range group.files {
+				checksum, err := i.copyToBuildDir(file.Src, filepath.Join(stageDirs[g], path.Base(file.Dest)))
+				if err != nil {
+					return fmt.Errorf("error adding file '%s' to '%s': %w", file.Src, file.Dest, err)
+				}
+				checksums[file.Dest] = checksum
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, stageDir := range stageDirs {
+			if stageDir != "" {
+				os.RemoveAll(filepath.Join(i.getBuildDir(), stageDir))
+			}
+		}
+		return fmt.Errorf("error adding files to instance '%s': %w", i.name, err)
+	}
+
+	for g, group := range groups {
+		destDir := group.destDir
+		if destDir != "/" {
+			destDir += "/"
+		}
+		if err := i.builderFactory.AddToBuilder(stageDirs[g]+"/", destDir, group.chown); err != nil {
+			return fmt.Errorf("error adding files to '%s' in instance '%s': %w", group.destDir, i.name, err)
+		}
+		for _, file := range group.files {
+			i.fileChecksums[file.Dest] = checksums[file.Dest]
+			i.files = append(i.files, &instanceFile{src: file.Src, dest: file.Dest, chown: file.Chown})
+		}
+	}
+
+	i.logger().Debugf("Added %d files in %d layers to instance '%s'", len(files), len(groups), i.name)
+	return nil
+}
@@ -1,17 +1,39 @@
 package knuu
 
 import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/celestiaorg/knuu/pkg/k8s"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"github.com/docker/distribution/reference"
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
+	"io"
+	appv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"net"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+// instanceFile is a file added to the image of an instance
+// The content is only kept for files added with AddFileBytes, other files are referenced by their source path
+type instanceFile struct {
+	src     string
+	dest    string
+	chown   string
+	content []byte
+}
+
 // getImageRegistry returns the name of the temporary image registry
+// The generated name is stored in the instance, so that the image is pushed and deployed with the same name
 func (i *Instance) getImageRegistry() (string, error) {
 	if i.imageName != "" {
 		return i.imageName, nil
@@ -21,8 +43,26 @@ func (i *Instance) getImageRegistry() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("error generating UUID: %w", err)
 	}
-	imageName := fmt.Sprintf("ttl.sh/%s:1h", uuid.String())
-	return imageName, nil
+	i.imageName = fmt.Sprintf("ttl.sh/%s:1h", uuid.String())
+	return i.imageName, nil
+}
+
+// validateImageName validates that the image name is a well-formed image reference with a tag or digest
+// An image without either would be pulled as 'latest', which can change between the pulls of the nodes of a test
+func validateImageName(image string) error {
+	if image == "" {
+		return fmt.Errorf("image name must not be empty")
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Errorf("invalid image reference '%s': %w", image, err)
+	}
+	_, tagged := named.(reference.Tagged)
+	_, digested := named.(reference.Digested)
+	if !tagged && !digested {
+		return fmt.Errorf("image reference '%s' has no tag or digest, e.g. use '%s:latest'", image, image)
+	}
+	return nil
 }
 
 // validatePort validates the port
@@ -55,61 +95,143 @@ func (i *Instance) isUDPPortRegistered(port int) bool {
 	return false
 }
 
+// logger returns a logger that attaches the name, k8s name and namespace of the instance to every message
+// It logs with the level of the instance, if one is set with SetLogLevel
+func (i *Instance) logger() log.Logger {
+	logger := log.WithFields(map[string]interface{}{
+		"instance":  i.name,
+		"k8sName":   i.k8sName,
+		"namespace": i.namespace(),
+	})
+	if i.logLevel != nil {
+		logger = log.WithLevel(logger, *i.logLevel)
+	}
+	return logger
+}
+
 // getLabels returns the labels for the instance
 func (i *Instance) getLabels() map[string]string {
+	labels := i.session().labels()
+	labels["app"] = i.k8sName
+	labels["name"] = i.name
+	labels["k8s-name"] = i.k8sName
+	labels["type"] = i.instanceType.String()
+	if i.pool != "" {
+		labels[poolLabel] = i.pool
+	}
+	return labels
+}
+
+// serviceSelector returns the selector of the instance's service
+// It only contains labels that never change for the pods of the instance, so that the service keeps routing to them
+// if other labels of the pods or the service change
+func (i *Instance) serviceSelector() map[string]string {
 	return map[string]string{
-		"app":                          i.k8sName,
-		"k8s.kubernetes.io/managed-by": "knuu",
-		"test-run-id":                  identifier,
-		"test-started":                 startTime,
-		"name":                         i.name,
-		"k8s-name":                     i.k8sName,
-		"type":                         i.instanceType.String(),
+		"app":      i.k8sName,
+		"k8s-name": i.k8sName,
 	}
 }
 
+// session returns the session of the instance
+// Instances created before knuu was initialized use the default session
+func (i *Instance) session() *Knuu {
+	if i.knuu != nil {
+		return i.knuu
+	}
+	return defaultKnuu
+}
+
+// k8sClient returns the Kubernetes client of the instance's session
+func (i *Instance) k8sClient() *k8s.Client {
+	return i.session().client()
+}
+
+// namespace returns the namespace of the instance's session
+func (i *Instance) namespace() string {
+	return i.session().Namespace()
+}
+
 // deployService deploys the service for the instance
 func (i *Instance) deployService() error {
-	svc, _ := k8s.GetService(k8s.Namespace(), i.k8sName)
+	svc, _ := i.k8sClient().GetService(i.namespace(), i.k8sName)
 	if svc != nil {
 		// Service already exists, so we patch it
 		err := i.patchService()
 		if err != nil {
 			return fmt.Errorf("error patching service '%s': %w", i.k8sName, err)
 		}
+		return nil
 	}
 
 	labels := i.getLabels()
-	selectorMap := i.getLabels()
-	service, err := k8s.DeployService(k8s.Namespace(), i.k8sName, labels, selectorMap, i.portsTCP, i.portsUDP)
+	selectorMap := i.serviceSelector()
+	var service *v1.Service
+	err := i.session().retryAPICall(fmt.Sprintf("deploying service '%s'", i.k8sName), func() error {
+		var err error
+		service, err = i.k8sClient().DeployService(i.namespace(), i.k8sName, labels, selectorMap, i.servicePortsTCP(), i.servicePortsUDP(), i.deployedServiceOptions())
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("error deploying service '%s': %w", i.k8sName, err)
 	}
+	// A retry finds the service created by an attempt whose response was lost, so it is read instead
+	if service == nil {
+		if service, err = i.k8sClient().GetService(i.namespace(), i.k8sName); err != nil {
+			return fmt.Errorf("error getting deployed service '%s': %w", i.k8sName, err)
+		}
+	}
 	i.kubernetesService = service
-	logrus.Debugf("Started service '%s'", i.k8sName)
+	i.logger().Debugf("Started service '%s'", i.k8sName)
 	return nil
 }
 
 // patchService patches the service for the instance
 func (i *Instance) patchService() error {
 	if i.kubernetesService == nil {
-		svc, err := k8s.GetService(k8s.Namespace(), i.k8sName)
+		var svc *v1.Service
+		err := i.session().retryAPICall(fmt.Sprintf("getting service '%s'", i.k8sName), func() error {
+			var err error
+			svc, err = i.k8sClient().GetService(i.namespace(), i.k8sName)
+			return err
+		})
 		if err != nil {
 			return fmt.Errorf("error getting service '%s': %w", i.k8sName, err)
 		}
 		i.kubernetesService = svc
 	}
-	err := k8s.PatchService(k8s.Namespace(), i.k8sName, i.kubernetesService.ObjectMeta.Labels, i.kubernetesService.Spec.Selector, i.portsTCP, i.portsUDP)
+	err := i.session().retryAPICall(fmt.Sprintf("patching service '%s'", i.k8sName), func() error {
+		return i.k8sClient().PatchService(i.namespace(), i.k8sName, i.kubernetesService.ObjectMeta.Labels, i.serviceSelector(), i.servicePortsTCP(), i.servicePortsUDP(), i.deployedServiceOptions())
+	})
 	if err != nil {
 		return fmt.Errorf("error patching service '%s': %w", i.k8sName, err)
 	}
-	logrus.Debugf("Patched service '%s'", i.k8sName)
+	i.logger().Debugf("Patched service '%s'", i.k8sName)
+
+	// Keep the cached service in sync with the patch, so that Service returns the patched ports
+	if svc, err := i.k8sClient().GetService(i.namespace(), i.k8sName); err != nil {
+		i.logger().Warnf("Error getting patched service '%s': %v", i.k8sName, err)
+	} else {
+		i.kubernetesService = svc
+	}
+
+	// Keep the backend of a deployed ingress in sync with the ports of the service
+	if i.ingress != nil && i.ingress.deployed {
+		if err := i.deployIngress(); err != nil {
+			return fmt.Errorf("error updating ingress of service '%s': %w", i.k8sName, err)
+		}
+	}
 	return nil
 }
 
 // destroyService destroys the service for the instance
 func (i *Instance) destroyService() error {
-	k8s.DeleteService(k8s.Namespace(), i.k8sName)
+	err := i.session().retryAPICall(fmt.Sprintf("deleting service '%s'", i.k8sName), func() error {
+		return i.k8sClient().DeleteService(i.namespace(), i.k8sName)
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting service '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Destroyed service '%s'", i.k8sName)
 
 	return nil
 }
@@ -123,43 +245,99 @@ func (i *Instance) deployPod() error {
 	if err != nil {
 		return fmt.Errorf("failed to get image name: %v", err)
 	}
+	if err := validateImageName(imageName); err != nil {
+		return fmt.Errorf("failed to validate image name: %w", err)
+	}
+
+	// Fail early instead of leaving the pod pending if the priority class does not exist
+	if i.priorityClassName != "" {
+		exists, err := i.k8sClient().PriorityClassExists(i.priorityClassName)
+		if err != nil {
+			return fmt.Errorf("failed to check priority class '%s': %v", i.priorityClassName, err)
+		}
+		if !exists {
+			return fmt.Errorf("priority class '%s' does not exist", i.priorityClassName)
+		}
+	}
+	if err := i.deployServiceAccount(); err != nil {
+		return err
+	}
+
+	// The k8s name is final now, so the placeholders can be expanded
+	command, args, podNameEnv, err := i.renderCommandAndArgs()
+	if err != nil {
+		return err
+	}
 
 	// Generate the pod configuration
 	podConfig := k8s.PodConfig{
-		Namespace:          k8s.Namespace(),
-		Name:               i.k8sName,
-		Labels:             labels,
-		Image:              imageName,
-		Command:            i.command,
-		Args:               i.args,
-		Env:                i.env,
-		Volumes:            i.volumes,
-		MemoryRequest:      i.memoryRequest,
-		MemoryLimit:        i.memoryLimit,
-		CPURequest:         i.cpuRequest,
-		ServiceAccountName: i.serviceAccountName,
+		Namespace:               i.namespace(),
+		Name:                    i.k8sName,
+		Labels:                  labels,
+		Image:                   imageName,
+		Command:                 command,
+		Args:                    args,
+		Env:                     i.env,
+		Volumes:                 i.volumes,
+		HostPathVolumes:         i.hostPathVolumes,
+		ClaimVolumes:            i.claimVolumes,
+		MemoryRequest:           i.memoryRequest,
+		MemoryLimit:             i.memoryLimit,
+		CPURequest:              i.cpuRequest,
+		CPULimit:                i.cpuLimit,
+		EphemeralStorageRequest: i.ephemeralStorageRequest,
+		EphemeralStorageLimit:   i.ephemeralStorageLimit,
+		ExtendedResources:       i.extendedResources,
+		ServiceAccountName:      i.serviceAccountName,
+		PriorityClassName:       i.priorityClassName,
+		RestartPolicy:           i.restartPolicy,
+		DNSPolicy:               i.dnsPolicy,
+		DNSConfig:               i.dnsConfig,
+		TopologySpread:          i.topologySpread(),
+		Affinity:                i.affinity(),
+		Sidecars:                i.sidecars(),
+		PodNameEnv:              podNameEnv,
+		ReadinessProbe:          i.readinessProbe(),
+		HostNetwork:             i.hostNetwork,
+		ShareProcessNamespace:   i.shareProcessNamespace,
+		Annotations:             i.podAnnotations,
+		HostPorts:               i.hostPorts(),
+		VolumeClaimName:         i.adoptedVolume,
 	}
 
 	statefulSetConfig := k8s.StatefulSetConfig{
-		Namespace: k8s.Namespace(),
-		Name:      i.k8sName,
-		Labels:    labels,
-		Replicas:  1,
-		PodConfig: podConfig,
+		Namespace:      i.namespace(),
+		Name:           i.k8sName,
+		Labels:         labels,
+		Replicas:       i.replicas,
+		UpdateStrategy: i.updateStrategy,
+		ExtraLabels:    i.lifetimeLabels(),
+		PodConfig:      podConfig,
 	}
 
 	// Deploy the statefulSet
-	statefulSet, err := k8s.DeployStatefulSet(statefulSetConfig, true)
+	var statefulSet *appv1.StatefulSet
+	err = i.session().retryAPICall(fmt.Sprintf("deploying statefulSet '%s'", i.k8sName), func() error {
+		var err error
+		statefulSet, err = i.k8sClient().DeployStatefulSet(statefulSetConfig, true)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to deploy pod: %v", err)
 	}
+	// A retry finds the statefulSet created by an attempt whose response was lost, so it is read instead
+	if statefulSet == nil {
+		if statefulSet, err = i.k8sClient().GetStatefulSet(i.namespace(), i.k8sName); err != nil {
+			return fmt.Errorf("error getting deployed statefulSet '%s': %w", i.k8sName, err)
+		}
+	}
 
 	// Set the state of the instance to started
 	i.kubernetesStatefulSet = statefulSet
 
 	// Log the deployment of the pod
-	logrus.Debugf("Started statefulSet '%s'", i.k8sName)
-	logrus.Debugf("Set state of instance '%s' to '%s'", i.k8sName, i.state.String())
+	i.logger().Debugf("Started statefulSet '%s'", i.k8sName)
+	i.logger().Debugf("Set state of instance '%s' to '%s'", i.k8sName, i.state.String())
 
 	return nil
 }
@@ -168,7 +346,9 @@ func (i *Instance) deployPod() error {
 // Skips if the pod is already destroyed
 func (i *Instance) destroyPod() error {
 	grace := int64(0)
-	err := k8s.DeleteStatefulSetWithGracePeriod(k8s.Namespace(), i.k8sName, &grace)
+	err := i.session().retryAPICall(fmt.Sprintf("deleting statefulSet '%s'", i.k8sName), func() error {
+		return i.k8sClient().DeleteStatefulSetWithGracePeriod(i.namespace(), i.k8sName, &grace)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to delete pod: %v", err)
 	}
@@ -177,46 +357,239 @@ func (i *Instance) destroyPod() error {
 }
 
 // deployVolume deploys the volume for the instance
+// An adopted volume (see AdoptVolume) is not deployed, it only has to exist
 func (i *Instance) deployVolume() error {
+	if i.adoptedVolume != "" {
+		if _, err := i.k8sClient().GetPersistentVolumeClaimPhase(i.namespace(), i.adoptedVolume); err != nil {
+			return fmt.Errorf("error getting adopted persistent volume '%s': %w", i.adoptedVolume, err)
+		}
+		i.logger().Debugf("Adopted persistent volume '%s'", i.adoptedVolume)
+		return nil
+	}
 	size := resource.Quantity{}
 	for _, volume := range i.volumes {
-		size.Add(resource.MustParse(volume.Size))
+		volumeSize, err := resource.ParseQuantity(volume.Size)
+		if err != nil {
+			return fmt.Errorf("error parsing size '%s' of volume '%s': %w", volume.Size, volume.Path, err)
+		}
+		size.Add(volumeSize)
+	}
+	err := i.session().retryAPICall(fmt.Sprintf("deploying persistent volume claim '%s'", i.k8sName), func() error {
+		return i.k8sClient().DeployPersistentVolumeClaim(i.namespace(), i.k8sName, i.getLabels(), size)
+	})
+	if err != nil {
+		return fmt.Errorf("error deploying persistent volume '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Deployed persistent volume '%s'", i.k8sName)
+
+	return nil
+}
+
+// volumeClaimName returns the name of the persistent volume claim of the instance's volumes
+func (i *Instance) volumeClaimName() string {
+	if i.adoptedVolume != "" {
+		return i.adoptedVolume
+	}
+	return i.k8sName
+}
+
+// waitVolumeIsBound waits until the volume of the instance is bound
+func (i *Instance) waitVolumeIsBound() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	claimName := i.volumeClaimName()
+	err := i.k8sClient().WaitPersistentVolumeClaimIsBound(ctx, i.namespace(), claimName, i.getLabels())
+	if errors.Is(err, context.DeadlineExceeded) {
+		phase, phaseErr := i.k8sClient().GetPersistentVolumeClaimPhase(i.namespace(), claimName)
+		if phaseErr != nil {
+			return fmt.Errorf("timeout while waiting for persistent volume '%s' to be bound", claimName)
+		}
+		return fmt.Errorf("timeout while waiting for persistent volume '%s' to be bound, current phase is '%s'", claimName, phase)
 	}
-	k8s.DeployPersistentVolumeClaim(k8s.Namespace(), i.k8sName, i.getLabels(), size)
-	logrus.Debugf("Deployed persistent volume '%s'", i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error waiting for persistent volume '%s' to be bound: %w", claimName, err)
+	}
+	i.logger().Debugf("Persistent volume '%s' is bound", claimName)
 
 	return nil
 }
 
 // destroyVolume destroys the volume for the instance
+// A retained volume (see SetVolumeRetention) is kept with its labels, so that it can be deleted manually
 func (i *Instance) destroyVolume() error {
-	k8s.DeletePersistentVolumeClaim(k8s.Namespace(), i.k8sName)
-	logrus.Debugf("Destroyed persistent volume '%s'", i.k8sName)
+	claimName := i.volumeClaimName()
+	if i.retainVolume {
+		i.logger().Warnf("Retained persistent volume '%s', it is not deleted with instance '%s' and must be deleted manually", claimName, i.k8sName)
+		return nil
+	}
+	err := i.session().retryAPICall(fmt.Sprintf("deleting persistent volume claim '%s'", claimName), func() error {
+		return i.k8sClient().DeletePersistentVolumeClaim(i.namespace(), claimName)
+	})
+	if err != nil {
+		return fmt.Errorf("error destroying persistent volume '%s': %w", claimName, err)
+	}
+	i.logger().Debugf("Destroyed persistent volume '%s'", claimName)
+
+	return nil
+}
+
+// deployPodDisruptionBudget deploys the pod disruption budget for the instance
+func (i *Instance) deployPodDisruptionBudget() error {
+	if i.replicas < 2 {
+		return fmt.Errorf("a pod disruption budget requires more than one replica, instance '%s' has '%d'", i.k8sName, i.replicas)
+	}
+	selectorMap := map[string]string{
+		"app": i.k8sName,
+	}
+	err := i.k8sClient().CreatePodDisruptionBudget(i.namespace(), i.k8sName, i.getLabels(), selectorMap, *i.podDisruptionBudget)
+	if err != nil {
+		return fmt.Errorf("error creating pod disruption budget '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Deployed pod disruption budget '%s'", i.k8sName)
+
+	return nil
+}
+
+// destroyPodDisruptionBudget destroys the pod disruption budget for the instance
+func (i *Instance) destroyPodDisruptionBudget() error {
+	err := i.k8sClient().DeletePodDisruptionBudget(i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error deleting pod disruption budget '%s': %w", i.k8sName, err)
+	}
+	i.logger().Debugf("Destroyed pod disruption budget '%s'", i.k8sName)
 
 	return nil
 }
 
 // cloneWithSuffix clones the instance with a suffix
 func (i *Instance) cloneWithSuffix(suffix string) *Instance {
-	return &Instance{
-		name:                  i.name + suffix,
-		k8sName:               i.k8sName + suffix,
-		imageName:             i.imageName,
-		state:                 i.state,
-		instanceType:          i.instanceType,
-		kubernetesService:     i.kubernetesService,
-		builderFactory:        i.builderFactory,
-		kubernetesStatefulSet: i.kubernetesStatefulSet,
-		portsTCP:              i.portsTCP,
-		portsUDP:              i.portsUDP,
-		command:               i.command,
-		args:                  i.args,
-		env:                   i.env,
-		volumes:               i.volumes,
-		memoryRequest:         i.memoryRequest,
-		memoryLimit:           i.memoryLimit,
-		cpuRequest:            i.cpuRequest,
+	clone := &Instance{
+		name:                    i.name + suffix,
+		k8sName:                 i.k8sName + suffix,
+		imageName:               i.imageName,
+		state:                   i.state,
+		instanceType:            i.instanceType,
+		kubernetesService:       i.kubernetesService,
+		builderFactory:          i.builderFactory,
+		kubernetesStatefulSet:   i.kubernetesStatefulSet,
+		portsTCP:                i.portsTCP,
+		portsUDP:                i.portsUDP,
+		serviceOptions:          i.cloneServiceOptions(),
+		command:                 i.command,
+		args:                    i.args,
+		commands:                i.commands,
+		env:                     i.env,
+		volumes:                 i.volumes,
+		hostPathVolumes:         i.hostPathVolumes,
+		claimVolumes:            i.claimVolumes,
+		sharedVolumes:           i.sharedVolumes,
+		memoryRequest:           i.memoryRequest,
+		memoryLimit:             i.memoryLimit,
+		cpuRequest:              i.cpuRequest,
+		cpuLimit:                i.cpuLimit,
+		ephemeralStorageRequest: i.ephemeralStorageRequest,
+		ephemeralStorageLimit:   i.ephemeralStorageLimit,
+		extendedResources:       copyStringMap(i.extendedResources),
+		serviceAccountName:      i.serviceAccountName,
+		createServiceAccount:    i.createServiceAccount,
+		priorityClassName:       i.priorityClassName,
+		restartPolicy:           i.restartPolicy,
+		dnsPolicy:               i.dnsPolicy,
+		dnsConfig:               i.dnsConfig,
+		topologySpreadKey:       i.topologySpreadKey,
+		topologySpreadMaxSkew:   i.topologySpreadMaxSkew,
+		podAntiAffinity:         i.podAntiAffinity,
+		readinessCheck:          i.readinessCheck,
+		capture:                 i.cloneCapture(),
+		keepBuildDir:            i.keepBuildDir,
+		timeOffset:              i.timeOffset,
+		faketimeInstalled:       i.faketimeInstalled,
+		envFromInstances:        i.cloneEnvFromInstances(),
+		roleRules:               i.cloneRoleRules(),
+		clusterRoles:            append([]string(nil), i.clusterRoles...),
+		hostNetwork:             i.hostNetwork,
+		shareProcessNamespace:   i.shareProcessNamespace,
+		podAnnotations:          copyStringMap(i.podAnnotations),
+		retainVolume:            i.retainVolume,
+		hostPortsTCP:            i.cloneHostPortsTCP(),
+		fileChecksums:           copyStringMap(i.fileChecksums),
+		replicas:                i.replicas,
+		updateStrategy:          i.updateStrategy,
+		podDisruptionBudget:     i.podDisruptionBudget,
+		autoscaler:              i.cloneAutoscaler(),
+		onNameCollision:         i.onNameCollision,
+		files:                   i.files,
+		imageEnv:                i.imageEnv,
+		user:                    i.user,
+		knuu:                    i.knuu,
+		dependencies:            i.dependencies,
+		metricsPort:             i.metricsPort,
+		metricsPath:             i.metricsPath,
+		observability:           i.cloneObservability(),
+		logLevel:                i.logLevel,
+		stateHistory:            i.StateHistory(),
+		lifetime:                instanceLifetime{duration: i.lifetimeDuration()},
+	}
+	clone.portMappingsTCP, clone.portMappingsUDP = i.clonePortMappings()
+	i.cloneSharedVolumes(clone)
+	i.session().registerInstance(clone)
+	return clone
+}
+
+// maxK8sNameLength is the maximum length of the k8s name of an instance, including pool suffixes
+// StatefulSet names are limited to 52 characters, as Kubernetes appends an 11 character revision hash to them in a 63 character label
+const maxK8sNameLength = 52
+
+// k8sNameSuffixLength is the length of the random suffix generateK8sName appends to the name ("-" and 8 characters)
+const k8sNameSuffixLength = 9
+
+// maxPoolSuffixLength is the length reserved for the suffix appended to the names of instances in a pool (up to "-999")
+const maxPoolSuffixLength = 4
+
+// maxInstanceNameLength is the maximum length of an instance name
+const maxInstanceNameLength = maxK8sNameLength - k8sNameSuffixLength - maxPoolSuffixLength
+
+// validateInstanceName validates that the name can be used as part of Kubernetes resource names
+func validateInstanceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("instance name must not be empty")
+	}
+	if len(name) > maxInstanceNameLength {
+		return fmt.Errorf("invalid instance name '%s': must be no more than %d characters, got %d", name, maxInstanceNameLength, len(name))
+	}
+	if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+		return fmt.Errorf("invalid instance name '%s': %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validateVolumeSize validates that the size of a volume is a positive quantity, e.g. '1Gi'
+func validateVolumeSize(size string) error {
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return fmt.Errorf("size '%s' is not a quantity: %w", size, err)
+	}
+	if quantity.Sign() <= 0 {
+		return fmt.Errorf("size '%s' must be positive", size)
+	}
+	return nil
+}
+
+// validateSubPath validates that the subPath is a directory inside the persistent volume claim
+func validateSubPath(subPath string) error {
+	if subPath == "" {
+		return fmt.Errorf("subPath must not be empty")
+	}
+	if path.IsAbs(subPath) {
+		return fmt.Errorf("subPath '%s' must be a relative path", subPath)
+	}
+	for _, element := range strings.Split(subPath, "/") {
+		if element == ".." {
+			return fmt.Errorf("subPath '%s' must not contain '..'", subPath)
+		}
 	}
+	return nil
 }
 
 func generateK8sName(name string) (string, error) {
@@ -227,24 +600,86 @@ func generateK8sName(name string) (string, error) {
 	return fmt.Sprintf("%s-%s", name, uuid.String()[:8]), nil
 }
 
-// getFreePort returns a free port
-func getFreePortTCP() (int, error) {
-	// Get a random port
-	listener, err := net.Listen("tcp", ":0")
+// getBuildDir returns the build directory for the instance
+func (i *Instance) getBuildDir() string {
+	return i.buildDir
+}
+
+// createBuildDir creates a build directory for the instance below the build dir root that is unique across processes,
+// so that builds of instances with the same name never share a build directory
+func (i *Instance) createBuildDir() error {
+	parent := i.session().BuildDirRoot()
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("error creating build directory of instance '%s': %w", i.name, err)
+	}
+	dir, err := os.MkdirTemp(parent, i.k8sName+"-")
+	if err != nil {
+		return fmt.Errorf("error creating build directory of instance '%s': %w", i.name, err)
+	}
+	i.buildDir = dir
+	return nil
+}
+
+// withBuildDirLock runs fn while holding an exclusive file lock on the build directory of the instance,
+// so that writes to the build directory never interleave with a build
+func (i *Instance) withBuildDirLock(fn func() error) error {
+	file, err := os.OpenFile(i.getBuildDir()+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening lock of build directory of instance '%s': %w", i.name, err)
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return fmt.Errorf("error locking build directory of instance '%s': %w", i.name, err)
+	}
+	defer unlockFile(file)
+	return fn()
+}
+
+// copyToBuildDir copies the file to the destination in the build directory and returns its SHA-256 checksum
+func (i *Instance) copyToBuildDir(src, dest string) (string, error) {
+	dstPath := filepath.Join(i.getBuildDir(), dest)
+
+	// make sure dir exists
+	err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("error creating directory: %w", err)
+	}
+	// Create destination file making sure the path is writeable.
+	dst, err := os.Create(dstPath)
 	if err != nil {
-		return 0, fmt.Errorf("error getting free port: %w", err)
+		return "", fmt.Errorf("failed to create destination file '%s': %w", dstPath, err)
 	}
-	defer listener.Close()
+	defer dst.Close()
 
-	// Get the port from the listener
-	port := listener.Addr().(*net.TCPAddr).Port
+	// Open source file for reading.
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source file '%s': %w", src, err)
+	}
+	defer srcFile.Close()
 
-	return port, nil
+	// Copy the contents from source file to destination file, computing the checksum on the way
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(dst, hash), srcFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy from source '%s' to destination '%s': %w", src, dstPath, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-// getBuildDir returns the build directory for the instance
-func (i *Instance) getBuildDir() string {
-	return filepath.Join("/tmp", "knuu", i.k8sName)
+// removeBuildDir removes the build directory of the instance, unless it is kept with SetKeepBuildDir or SetKeepBuildDirs
+// A build directory that was never created is ignored
+func (i *Instance) removeBuildDir() error {
+	if i.keepBuildDir || i.getBuildDir() == "" || (i.session() != nil && i.session().keepBuildDirs) {
+		return nil
+	}
+	if err := os.RemoveAll(i.getBuildDir()); err != nil {
+		return fmt.Errorf("error removing build directory of instance '%s': %w", i.name, err)
+	}
+	if err := os.Remove(i.getBuildDir() + ".lock"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing lock of build directory of instance '%s': %w", i.name, err)
+	}
+	return nil
 }
 
 // validateFileArgs validates the file arguments
@@ -278,3 +713,69 @@ func (i *Instance) addFileToBuilder(src string, dest string, chown string) error
 	}
 	return nil
 }
+
+// copyToRunningInstance streams the file or folder at src as a tar archive into the running instance and changes its owner
+func (i *Instance) copyToRunningInstance(src string, dest string, chown string) error {
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
+	if err != nil {
+		return fmt.Errorf("error getting pod from statefulset '%s': %w", i.k8sName, err)
+	}
+
+	// Absolute destinations are extracted relative to the root, others relative to the working directory
+	extractDir := "."
+	if path.IsAbs(dest) {
+		extractDir = "/"
+	}
+	command := []string{"sh", "-c", `tar -xf - -C "$1" && chown -R "$2" "$3"`, "sh", extractDir, chown, dest}
+
+	// Write the archive in the background, so that it is streamed instead of loaded into memory
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		writer.CloseWithError(writeTarArchive(writer, src, strings.TrimPrefix(dest, "/")))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	_, err = i.k8sClient().RunCommandInPodWithStdin(ctx, i.namespace(), pod.Name, i.k8sName, command, reader)
+	if err != nil {
+		return fmt.Errorf("error extracting archive in pod '%s': %w", pod.Name, err)
+	}
+	return nil
+}
+
+// writeTarArchive writes the file or folder at src as a tar archive with the given name to the writer
+func writeTarArchive(writer io.Writer, src string, name string) error {
+	tarWriter := tar.NewWriter(writer)
+	err := filepath.Walk(src, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, filePath)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(name, filepath.ToSlash(relPath))
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error writing archive of '%s': %w", src, err)
+	}
+	return tarWriter.Close()
+}
@@ -0,0 +1,410 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// services is the resource of services in the errors of the fake clientset
+var services = schema.GroupResource{Resource: "services"}
+
+// newTestKnuuWithoutRetryDelay returns a session of the tests that retries API calls without delay
+func newTestKnuuWithoutRetryDelay(t *testing.T) (*Knuu, *testCluster) {
+	noDelay := time.Duration(0)
+	return newTestKnuu(t, Options{APIRetryMaxAttempts: 3, APIRetryBaseDelay: &noDelay})
+}
+
+func TestDeployServiceRetriesCreateWithLostResponse(t *testing.T) {
+	k, cluster := newTestKnuuWithoutRetryDelay(t)
+	instance := newTestInstance(t, k, "lost", 8080)
+
+	// The first create succeeds, but its response is lost, so the retry finds the service
+	creates := 0
+	cluster.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		creates++
+		if creates > 1 {
+			return false, nil, nil
+		}
+		service := action.(k8stesting.CreateAction).GetObject()
+		if err := cluster.Tracker().Create(action.GetResource(), service, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrs.NewServerTimeout(services, "create", 1)
+	})
+
+	if err := instance.deployService(); err != nil {
+		t.Fatalf("deploying service: %v", err)
+	}
+	if creates != 2 {
+		t.Errorf("expected the create to be retried once, got %d creates", creates)
+	}
+	if instance.kubernetesService == nil || instance.kubernetesService.Name != instance.k8sName {
+		t.Errorf("expected the deployed service to be kept, got %v", instance.kubernetesService)
+	}
+}
+
+func TestDeployServiceDoesNotRetryInvalidService(t *testing.T) {
+	k, cluster := newTestKnuuWithoutRetryDelay(t)
+	instance := newTestInstance(t, k, "invalid", 8080)
+	creates := 0
+	cluster.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		creates++
+		return true, nil, apierrs.NewInvalid(schema.GroupKind{Kind: "Service"}, instance.k8sName, nil)
+	})
+
+	if err := instance.deployService(); err == nil {
+		t.Fatalf("expected deploying an invalid service to fail")
+	}
+	if n := creates; n != 1 {
+		t.Errorf("expected an invalid service not to be retried, got %d creates", n)
+	}
+}
+
+func TestDeployServicePatchesExistingService(t *testing.T) {
+	k, cluster := newTestKnuuWithoutRetryDelay(t)
+	instance := newTestInstance(t, k, "existing", 8080)
+	if err := instance.deployService(); err != nil {
+		t.Fatalf("deploying service: %v", err)
+	}
+
+	cluster.requests.reset()
+	if err := instance.deployService(); err != nil {
+		t.Fatalf("deploying existing service: %v", err)
+	}
+	if n := cluster.requests.count("create", "services"); n != 0 {
+		t.Errorf("expected the existing service not to be created again, got %d creates", n)
+	}
+	if n := cluster.requests.count("update", "services"); n != 1 {
+		t.Errorf("expected the existing service to be patched once, got %d updates", n)
+	}
+}
+
+func TestDestroyServiceRetriesAndSkipsMissingService(t *testing.T) {
+	k, cluster := newTestKnuuWithoutRetryDelay(t)
+	instance := newTestInstance(t, k, "destroyed", 8080)
+	if err := instance.deployService(); err != nil {
+		t.Fatalf("deploying service: %v", err)
+	}
+
+	deletes := 0
+	cluster.PrependReactor("delete", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deletes++
+		if deletes == 1 {
+			return true, nil, apierrs.NewServiceUnavailable("etcd is unavailable")
+		}
+		return false, nil, nil
+	})
+	if err := instance.destroyService(); err != nil {
+		t.Fatalf("destroying service: %v", err)
+	}
+	if deletes != 2 {
+		t.Errorf("expected the delete to be retried once, got %d deletes", deletes)
+	}
+	if _, err := cluster.CoreV1().Services(testNamespace).Get(context.Background(), instance.k8sName, metav1.GetOptions{}); !apierrs.IsNotFound(err) {
+		t.Errorf("expected the service to be deleted, got %v", err)
+	}
+
+	// A service that does not exist is already destroyed, so NotFound is neither retried nor an error
+	cluster.requests.reset()
+	if err := instance.destroyService(); err != nil {
+		t.Errorf("destroying missing service: %v", err)
+	}
+	if n := cluster.requests.count("delete", "services"); n != 1 {
+		t.Errorf("expected one delete of the missing service, got %d", n)
+	}
+	if n := cluster.requests.count("get", "services"); n != 0 {
+		t.Errorf("expected the missing service not to be read, got %d gets", n)
+	}
+}
+
+func TestParallelBuildsOfSameInstanceDoNotShareBuildDir(t *testing.T) {
+	// Two test runs on one machine build the same logical instance in the same build directory root
+	root := t.TempDir()
+	instances := make([]*Instance, 2)
+	for j := range instances {
+		k, _ := newTestKnuu(t, Options{BuildDirRoot: root})
+		instance, err := k.NewInstance("app")
+		if err != nil {
+			t.Fatalf("creating instance: %v", err)
+		}
+		instances[j] = instance
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(instances))
+	for j, instance := range instances {
+		wg.Add(1)
+		go func(j int, instance *Instance) {
+			defer wg.Done()
+			errs[j] = instance.createBuildDir()
+		}(j, instance)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("creating build directory: %v", err)
+		}
+	}
+	if instances[0].getBuildDir() == instances[1].getBuildDir() {
+		t.Fatalf("expected distinct build directories, both use '%s'", instances[0].getBuildDir())
+	}
+	for _, instance := range instances {
+		if filepath.Dir(instance.getBuildDir()) != root {
+			t.Errorf("expected build directory '%s' in the root '%s'", instance.getBuildDir(), root)
+		}
+	}
+}
+
+func TestBuildDirLockSerializesWrites(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("build directories are not locked on Windows")
+	}
+	k, _ := newTestKnuu(t, Options{})
+	instance, err := k.NewInstance("app")
+	if err != nil {
+		t.Fatalf("creating instance: %v", err)
+	}
+	if err := instance.createBuildDir(); err != nil {
+		t.Fatalf("creating build directory: %v", err)
+	}
+
+	// Every writer appends a begin and an end line with a pause in between, which must not be interleaved with other writers
+	path := filepath.Join(instance.getBuildDir(), "Dockerfile")
+	var wg sync.WaitGroup
+	for writer := 0; writer < 4; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			err := instance.withBuildDirLock(func() error {
+				file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				if _, err := fmt.Fprintf(file, "begin %d\n", writer); err != nil {
+					return err
+				}
+				time.Sleep(20 * time.Millisecond)
+				_, err = fmt.Fprintf(file, "end %d\n", writer)
+				return err
+			})
+			if err != nil {
+				t.Errorf("writing with lock: %v", err)
+			}
+		}(writer)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("expected 8 lines, got %q", lines)
+	}
+	for j := 0; j < len(lines); j += 2 {
+		begin, end := strings.TrimPrefix(lines[j], "begin "), strings.TrimPrefix(lines[j+1], "end ")
+		if !strings.HasPrefix(lines[j], "begin ") || !strings.HasPrefix(lines[j+1], "end ") || begin != end {
+			t.Fatalf("writes are interleaved: %q", lines)
+		}
+	}
+}
+
+func TestServiceIsOnlyDeployedWithPorts(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	ctx := context.Background()
+
+	t.Run("with ports", func(t *testing.T) {
+		instance := newTestInstance(t, k, "with-ports", 8080)
+		if err := instance.Start(); err != nil {
+			t.Fatalf("starting instance: %v", err)
+		}
+		service, err := cluster.CoreV1().Services(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("getting service: %v", err)
+		}
+		if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != 8080 {
+			t.Errorf("expected service with port 8080, got %+v", service.Spec.Ports)
+		}
+	})
+
+	t.Run("without ports", func(t *testing.T) {
+		instance := newTestInstance(t, k, "without-ports")
+		cluster.requests.reset()
+		if err := instance.Start(); err != nil {
+			t.Fatalf("starting instance without ports: %v", err)
+		}
+		if n := cluster.requests.count("create", "services"); n != 0 {
+			t.Errorf("expected no service to be created for an instance without ports, got %d creates", n)
+		}
+	})
+
+	t.Run("deploying without ports", func(t *testing.T) {
+		instance := newTestInstance(t, k, "deploy-without-ports")
+		cluster.requests.reset()
+		err := instance.deployService()
+		if err == nil || !strings.Contains(err.Error(), "no ports specified") {
+			t.Fatalf("expected error deploying service without ports, got %v", err)
+		}
+		if n := cluster.requests.count("create", "services"); n != 0 {
+			t.Errorf("expected a service without ports not to be sent, got %d creates", n)
+		}
+	})
+}
+
+func TestServiceSelectsPodsByStableLabels(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "selected", 8080)
+	if err := instance.Start(); err != nil {
+		t.Fatalf("starting instance: %v", err)
+	}
+
+	service, err := cluster.CoreV1().Services(testNamespace).Get(context.Background(), instance.k8sName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting service: %v", err)
+	}
+	expected := map[string]string{"app": instance.k8sName, "k8s-name": instance.k8sName}
+	if len(service.Spec.Selector) != len(expected) {
+		t.Errorf("expected selector %v, got %v", expected, service.Spec.Selector)
+	}
+	for key, value := range expected {
+		if service.Spec.Selector[key] != value {
+			t.Errorf("selector has label '%s' with value '%s', expected '%s'", key, service.Spec.Selector[key], value)
+		}
+	}
+	// The service keeps all labels of the instance as metadata
+	for key, value := range instance.getLabels() {
+		if service.Labels[key] != value {
+			t.Errorf("service has label '%s' with value '%s', expected '%s'", key, service.Labels[key], value)
+		}
+	}
+}
+
+func TestImageRegistryIsGeneratedOnce(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance, err := k.NewInstance("built")
+	if err != nil {
+		t.Fatalf("creating instance: %v", err)
+	}
+
+	// The image is built and deployed with separate calls, which must refer to the same image
+	first, err := instance.getImageRegistry()
+	if err != nil {
+		t.Fatalf("getting image registry: %v", err)
+	}
+	second, err := instance.getImageRegistry()
+	if err != nil {
+		t.Fatalf("getting image registry: %v", err)
+	}
+	if first != second {
+		t.Errorf("expected the same image for both calls, got '%s' and '%s'", first, second)
+	}
+	if !strings.HasPrefix(first, "ttl.sh/") {
+		t.Errorf("expected a generated image on ttl.sh, got '%s'", first)
+	}
+}
+
+func TestValidateImageName(t *testing.T) {
+	tests := []struct {
+		image string
+		valid bool
+	}{
+		{image: testImage, valid: true},
+		{image: "alpine:3.18", valid: true},
+		{image: "alpine@sha256:" + strings.Repeat("a", 64), valid: true},
+		{image: "ttl.sh/0b1c2d3e-4f50-6172-8394-a5b6c7d8e9f0:1h", valid: true},
+		{image: "alpine", valid: false},
+		{image: "my image:latest", valid: false},
+		{image: "", valid: false},
+	}
+	for _, test := range tests {
+		err := validateImageName(test.image)
+		if test.valid && err != nil {
+			t.Errorf("expected image '%s' to be valid, got %v", test.image, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected image '%s' to be invalid", test.image)
+		}
+	}
+}
+
+func TestStartRejectsInvalidImageName(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "untagged")
+	// The image of a committed instance is not set with SetImage anymore, e.g. by an image built from a Dockerfile
+	instance.imageName = "alpine"
+
+	cluster.requests.reset()
+	if err := instance.Start(); err == nil || !strings.Contains(err.Error(), "no tag or digest") {
+		t.Fatalf("expected error starting instance with an image without tag, got %v", err)
+	}
+	if n := cluster.requests.count("create", "statefulsets"); n != 0 {
+		t.Errorf("expected no statefulSet to be created, got %d creates", n)
+	}
+}
+
+func TestClonesDoNotShareFileChecksums(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "original")
+	instance.fileChecksums["/etc/config.toml"] = "sha256:original"
+
+	clone, err := instance.Clone()
+	if err != nil {
+		t.Fatalf("cloning instance: %v", err)
+	}
+	pool, err := instance.CreatePool(2)
+	if err != nil {
+		t.Fatalf("creating pool: %v", err)
+	}
+	for _, copied := range append([]*Instance{clone}, pool.Instances()...) {
+		if copied.fileChecksums["/etc/config.toml"] != "sha256:original" {
+			t.Fatalf("expected clone '%s' to have the checksums of the original", copied.k8sName)
+		}
+		copied.fileChecksums["/etc/config.toml"] = "sha256:" + copied.k8sName
+	}
+	if checksum := instance.fileChecksums["/etc/config.toml"]; checksum != "sha256:original" {
+		t.Errorf("expected checksum of the original not to be changed by its clones, got '%s'", checksum)
+	}
+}
+
+func TestClonesDoNotShareExtendedResources(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "original")
+	if err := instance.SetExtendedResource("nvidia.com/gpu", "1"); err != nil {
+		t.Fatalf("setting extended resource: %v", err)
+	}
+
+	clone, err := instance.Clone()
+	if err != nil {
+		t.Fatalf("cloning instance: %v", err)
+	}
+	pool, err := instance.CreatePool(2)
+	if err != nil {
+		t.Fatalf("creating pool: %v", err)
+	}
+	for _, copied := range append([]*Instance{clone}, pool.Instances()...) {
+		if copied.extendedResources["nvidia.com/gpu"] != "1" {
+			t.Fatalf("expected clone '%s' to have the extended resources of the original", copied.k8sName)
+		}
+		if err := copied.SetExtendedResource("nvidia.com/gpu", "2"); err != nil {
+			t.Fatalf("setting extended resource of clone '%s': %v", copied.k8sName, err)
+		}
+	}
+	if quantity := instance.extendedResources["nvidia.com/gpu"]; quantity != "1" {
+		t.Errorf("expected extended resource of the original not to be changed by its clones, got '%s'", quantity)
+	}
+}
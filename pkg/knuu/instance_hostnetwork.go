@@ -0,0 +1,161 @@
+package knuu
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+)
+
+// SetHostNetwork runs the instance's pods in the network namespace of their nodes, bypassing the service and kube-proxy
+// All registered ports are bound to the same ports of the node, so replicas and other instances using the same ports
+// cannot be scheduled on the same node, see GetServiceEndpoint for the endpoint of a port
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetHostNetwork(enabled bool) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting host network", Preparing, Committed)
+	}
+	if enabled {
+		for containerPort, hostPort := range i.hostPortsTCP {
+			if containerPort != hostPort {
+				return fmt.Errorf("host network of instance '%s' requires host port '%d' to equal its container port '%d'", i.name, hostPort, containerPort)
+			}
+		}
+	}
+	i.hostNetwork = enabled
+	i.logger().Debugf("Set host network to '%t' in instance '%s'", enabled, i.name)
+	return nil
+}
+
+// AddHostPortTCP binds the TCP port of the instance's container to the port of its node, bypassing the service and kube-proxy
+// Replicas and other instances using the same host port cannot be scheduled on the same node
+// With the host network (see SetHostNetwork), the host port must equal the container port
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddHostPortTCP(containerPort, hostPort int) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding host port", Preparing, Committed)
+	}
+	if err := validatePort(containerPort); err != nil {
+		return err
+	}
+	if err := validatePort(hostPort); err != nil {
+		return err
+	}
+	if i.hostNetwork && containerPort != hostPort {
+		return fmt.Errorf("host network of instance '%s' requires host port '%d' to equal its container port '%d'", i.name, hostPort, containerPort)
+	}
+	for existingContainerPort, existingHostPort := range i.hostPortsTCP {
+		if existingHostPort == hostPort && existingContainerPort != containerPort {
+			return fmt.Errorf("host port '%d' is already bound to container port '%d' in instance '%s'", hostPort, existingContainerPort, i.name)
+		}
+	}
+	if i.hostPortsTCP == nil {
+		i.hostPortsTCP = make(map[int]int)
+	}
+	i.hostPortsTCP[containerPort] = hostPort
+	i.logger().Debugf("Bound TCP port '%d' to host port '%d' in instance '%s'", containerPort, hostPort, i.name)
+	return nil
+}
+
+// GetServiceEndpoint returns the endpoint ('ip:port') the port of the instance is reachable at from inside the cluster
+// For the host network or a host port (see SetHostNetwork and AddHostPortTCP), it is the IP of the node of the first pod
+// and the host port, otherwise it is the IP of the instance's service and the port
+// The port is the port of the service, which differs from the port of the container for ports added with AddPortMappingTCP
+// This function can only be called in the state 'Started'
+func (i *Instance) GetServiceEndpoint(port int) (string, error) {
+	if !i.IsInState(Started) {
+		return "", i.errInvalidStateTransition("getting service endpoint", Started)
+	}
+	if i.isExternal() {
+		return i.externalAddress(port)
+	}
+	containerPort := i.tcpContainerPort(port)
+	hostPort, ok := i.hostPortsTCP[containerPort]
+	if !ok && i.hostNetwork {
+		hostPort, ok = containerPort, true
+	}
+	if !ok {
+		if !i.isTCPServicePort(port) && !i.isUDPServicePort(port) {
+			return "", fmt.Errorf("port '%d' is not registered in instance '%s'", port, i.k8sName)
+		}
+		ip, err := i.GetIP()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s:%d", ip, port), nil
+	}
+
+	pods, err := i.k8sClient().ListStatefulSetPods(i.namespace(), i.k8sName)
+	if err != nil {
+		return "", fmt.Errorf("error getting pods of instance '%s': %w", i.k8sName, err)
+	}
+	if len(pods) == 0 || pods[0].Status.HostIP == "" {
+		return "", fmt.Errorf("instance '%s' has no pod scheduled on a node yet", i.k8sName)
+	}
+	return fmt.Sprintf("%s:%d", pods[0].Status.HostIP, hostPort), nil
+}
+
+// hostPorts returns the ports of the instance's container that are bound to ports of its node, sorted by container port
+// With the host network, all registered ports are bound, so that the scheduler detects conflicts on the node
+func (i *Instance) hostPorts() []v1.ContainerPort {
+	var ports []v1.ContainerPort
+	seenTCP := make(map[int]bool)
+	if i.hostNetwork {
+		for _, port := range i.portsTCP {
+			seenTCP[port] = true
+			ports = append(ports, hostContainerPort(port, port, v1.ProtocolTCP))
+		}
+		for _, port := range i.portsUDP {
+			ports = append(ports, hostContainerPort(port, port, v1.ProtocolUDP))
+		}
+	}
+	for containerPort, hostPort := range i.hostPortsTCP {
+		if !seenTCP[containerPort] {
+			ports = append(ports, hostContainerPort(containerPort, hostPort, v1.ProtocolTCP))
+		}
+	}
+	sort.Slice(ports, func(a, b int) bool {
+		if ports[a].ContainerPort != ports[b].ContainerPort {
+			return ports[a].ContainerPort < ports[b].ContainerPort
+		}
+		return ports[a].Protocol < ports[b].Protocol
+	})
+	return ports
+}
+
+// hostContainerPort returns the container port bound to the port of the node
+func hostContainerPort(containerPort, hostPort int, protocol v1.Protocol) v1.ContainerPort {
+	return v1.ContainerPort{
+		Name:          fmt.Sprintf("%s-%d", strings.ToLower(string(protocol)), containerPort),
+		ContainerPort: int32(containerPort),
+		HostPort:      int32(hostPort),
+		Protocol:      protocol,
+	}
+}
+
+// hostPortConflict explains a scheduling failure of the instance that is caused by its host ports, if it is
+func (i *Instance) hostPortConflict(schedulingFailure string) string {
+	if !strings.Contains(schedulingFailure, "free ports") {
+		return ""
+	}
+	var ports []string
+	for _, port := range i.hostPorts() {
+		ports = append(ports, fmt.Sprintf("%d/%s", port.HostPort, port.Protocol))
+	}
+	if len(ports) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("the host ports %s are already used on all eligible nodes, e.g. by another replica or instance binding the same host ports", strings.Join(ports, ", "))
+}
+
+// cloneHostPortsTCP returns a copy of the host ports for a clone of the instance
+func (i *Instance) cloneHostPortsTCP() map[int]int {
+	if i.hostPortsTCP == nil {
+		return nil
+	}
+	hostPorts := make(map[int]int, len(i.hostPortsTCP))
+	for containerPort, hostPort := range i.hostPortsTCP {
+		hostPorts[containerPort] = hostPort
+	}
+	return hostPorts
+}
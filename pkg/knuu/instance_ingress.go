@@ -0,0 +1,125 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"strings"
+)
+
+// IngressOptions are the options of the ingress of an instance
+type IngressOptions struct {
+	// PathPrefix is the path prefix routed to the instance, defaults to "/"
+	PathPrefix string
+	// TLSSecret is the name of an existing secret with the TLS certificate of the host, the ingress serves plain HTTP if it is empty
+	TLSSecret string
+	// ClassName is the IngressClass handling the ingress, the cluster's default class is used if it is empty
+	ClassName string
+	// Annotations are added to the ingress, e.g. to configure the ingress controller
+	Annotations map[string]string
+}
+
+// instanceIngress is the ingress of an instance
+type instanceIngress struct {
+	host     string
+	port     int
+	opts     IngressOptions
+	deployed bool
+}
+
+// AddIngress makes the given TCP port of the instance reachable through the cluster's ingress controller at the given host
+// The ingress is created when the instance is started (or immediately if it is started already) and deleted when it is destroyed
+// The port has to be added with AddPortTCP, and an instance can only have one ingress, which is not cloned with the instance
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) AddIngress(host string, port int, opts IngressOptions) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("adding ingress", Preparing, Committed, Started)
+	}
+	if i.ingress != nil {
+		return fmt.Errorf("instance '%s' already has an ingress for host '%s'", i.name, i.ingress.host)
+	}
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) != 0 {
+		return fmt.Errorf("invalid ingress host '%s': %s", host, strings.Join(errs, "; "))
+	}
+	if err := validatePort(port); err != nil {
+		return err
+	}
+	if !i.isTCPServicePort(port) {
+		return fmt.Errorf("TCP port '%d' is not registered", port)
+	}
+	if opts.PathPrefix == "" {
+		opts.PathPrefix = "/"
+	}
+	if !strings.HasPrefix(opts.PathPrefix, "/") {
+		return fmt.Errorf("ingress path prefix '%s' must start with '/'", opts.PathPrefix)
+	}
+
+	i.ingress = &instanceIngress{
+		host: host,
+		port: port,
+		opts: opts,
+	}
+	if i.state == Started {
+		if err := i.deployIngress(); err != nil {
+			i.ingress = nil
+			return err
+		}
+	}
+	i.logger().Debugf("Added ingress for host '%s' to port '%d' of instance '%s'", host, port, i.name)
+	return nil
+}
+
+// GetIngressURL returns the URL the instance is reachable at through its ingress
+// This function can be called in all states
+func (i *Instance) GetIngressURL() (string, error) {
+	if i.ingress == nil {
+		return "", fmt.Errorf("instance '%s' has no ingress", i.name)
+	}
+	scheme := "http"
+	if i.ingress.opts.TLSSecret != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, i.ingress.host, i.ingress.opts.PathPrefix), nil
+}
+
+// deployIngress creates or updates the ingress of the instance, routing to the current port of its service
+func (i *Instance) deployIngress() error {
+	if !i.isTCPServicePort(i.ingress.port) {
+		return fmt.Errorf("TCP port '%d' of the ingress of instance '%s' is not registered", i.ingress.port, i.k8sName)
+	}
+	config := k8s.IngressConfig{
+		Namespace:   i.namespace(),
+		Name:        i.k8sName,
+		Labels:      i.getLabels(),
+		Annotations: i.ingress.opts.Annotations,
+		Host:        i.ingress.host,
+		PathPrefix:  i.ingress.opts.PathPrefix,
+		ServiceName: i.k8sName,
+		ServicePort: i.ingress.port,
+		TLSSecret:   i.ingress.opts.TLSSecret,
+		ClassName:   i.ingress.opts.ClassName,
+	}
+	err := i.session().retryAPICall(fmt.Sprintf("deploying ingress '%s'", i.k8sName), func() error {
+		_, err := i.k8sClient().DeployIngress(config)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error deploying ingress '%s': %w", i.k8sName, err)
+	}
+	i.ingress.deployed = true
+	i.logger().Debugf("Deployed ingress '%s'", i.k8sName)
+	return nil
+}
+
+// destroyIngress destroys the ingress of the instance
+func (i *Instance) destroyIngress() error {
+	err := i.session().retryAPICall(fmt.Sprintf("deleting ingress '%s'", i.k8sName), func() error {
+		return i.k8sClient().DeleteIngress(i.namespace(), i.k8sName)
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting ingress '%s': %w", i.k8sName, err)
+	}
+	i.ingress.deployed = false
+	i.logger().Debugf("Destroyed ingress '%s'", i.k8sName)
+	return nil
+}
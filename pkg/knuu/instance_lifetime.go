@@ -0,0 +1,108 @@
+package knuu
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// expiresAtLabel is the label of an instance's statefulSet holding the Unix time after which the instance is destroyed
+// The timeout handler of the session deletes the resources of expired instances, even if the test process died
+const expiresAtLabel = "knuu.sh/expires-at"
+
+// instanceLifetime is the lifetime of an instance, after which it is destroyed
+type instanceLifetime struct {
+	mu        sync.Mutex
+	duration  time.Duration
+	expiresAt time.Time
+	timer     *time.Timer
+}
+
+// SetLifetime sets the time after which the instance is destroyed, counted from when it is started
+// The instance is destroyed by a timer of the test process, and by the timeout handler of the session if the test process died
+// (the timeout handler is disabled by Options.DisableTimeoutHandler)
+// Calling it in the state 'Started' extends (or shortens) the lifetime to the duration from now on
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetLifetime(d time.Duration) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting lifetime", Preparing, Committed, Started)
+	}
+	if d <= 0 {
+		return fmt.Errorf("lifetime must be positive, got '%s'", d)
+	}
+	i.lifetime.mu.Lock()
+	i.lifetime.duration = d
+	i.lifetime.mu.Unlock()
+	i.logger().Debugf("Set lifetime of instance '%s' to '%s'", i.name, d)
+
+	if i.state != Started {
+		return nil
+	}
+	expiresAt := i.startLifetime()
+	err := i.session().retryAPICall(fmt.Sprintf("labeling statefulSet '%s'", i.k8sName), func() error {
+		return i.k8sClient().SetStatefulSetLabel(i.namespace(), i.k8sName, expiresAtLabel, strconv.FormatInt(expiresAt.Unix(), 10))
+	})
+	if err != nil {
+		return fmt.Errorf("error updating lifetime of instance '%s': %w", i.k8sName, err)
+	}
+	return nil
+}
+
+// ExpiresAt returns the time after which the instance is destroyed, or the zero time if it has no lifetime or is not started
+func (i *Instance) ExpiresAt() time.Time {
+	i.lifetime.mu.Lock()
+	defer i.lifetime.mu.Unlock()
+	return i.lifetime.expiresAt
+}
+
+// startLifetime (re)starts the timer destroying the instance when its lifetime is exceeded, if it has a lifetime
+func (i *Instance) startLifetime() time.Time {
+	i.lifetime.mu.Lock()
+	defer i.lifetime.mu.Unlock()
+	if i.lifetime.duration == 0 {
+		return time.Time{}
+	}
+	if i.lifetime.timer != nil {
+		i.lifetime.timer.Stop()
+	}
+	i.lifetime.expiresAt = time.Now().Add(i.lifetime.duration)
+	lifetime := i.lifetime.duration
+	i.lifetime.timer = time.AfterFunc(lifetime, func() {
+		i.logger().Warnf("Lifetime of '%s' of instance '%s' exceeded, destroying it", lifetime, i.k8sName)
+		if err := i.Destroy(); err != nil {
+			i.logger().Errorf("Error destroying instance '%s' after its lifetime exceeded: %v", i.k8sName, err)
+		}
+	})
+	return i.lifetime.expiresAt
+}
+
+// stopLifetime stops the timer of the instance's lifetime
+func (i *Instance) stopLifetime() {
+	i.lifetime.mu.Lock()
+	defer i.lifetime.mu.Unlock()
+	if i.lifetime.timer != nil {
+		i.lifetime.timer.Stop()
+		i.lifetime.timer = nil
+	}
+	i.lifetime.expiresAt = time.Time{}
+}
+
+// lifetimeLabels returns the labels of the instance's statefulSet that mark when it expires, if it has a lifetime
+// They are not part of the selector of the statefulSet, so they can be changed while the instance is running
+func (i *Instance) lifetimeLabels() map[string]string {
+	expiresAt := i.ExpiresAt()
+	if expiresAt.IsZero() {
+		return nil
+	}
+	return map[string]string{
+		expiresAtLabel: strconv.FormatInt(expiresAt.Unix(), 10),
+	}
+}
+
+// lifetimeDuration returns the lifetime of the instance, or zero if it has none
+func (i *Instance) lifetimeDuration() time.Duration {
+	i.lifetime.mu.Lock()
+	defer i.lifetime.mu.Unlock()
+	return i.lifetime.duration
+}
@@ -0,0 +1,135 @@
+package knuu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AllContainers is the container of LogOptions that selects the logs of all containers of the pod, interleaved by time
+const AllContainers = "*"
+
+// LogOptions are the options of GetLogs
+type LogOptions struct {
+	// Container is the name of the container whose logs are returned, the main container of the instance if empty
+	// If it is AllContainers, the logs of all containers are interleaved by time and every line is prefixed with '[<container>] '
+	Container string
+	// TailLines is the number of lines returned per container, all lines if it is not positive
+	TailLines int64
+	// Previous returns the logs of the previous run of the container, e.g. after it crashed
+	Previous bool
+}
+
+// GetLogs returns the logs of a container of the first pod of the instance
+// This function can only be called in the state 'Started'
+func (i *Instance) GetLogs(opts LogOptions) (string, error) {
+	if !i.IsInState(Started) {
+		return "", i.errInvalidStateTransition("getting logs", Started)
+	}
+	podName, containers, err := i.podContainers()
+	if err != nil {
+		return "", err
+	}
+	if opts.Container == AllContainers {
+		return i.interleavedLogs(podName, containers, opts)
+	}
+
+	container := opts.Container
+	if container == "" {
+		container = i.k8sName
+	}
+	if !containsString(containers, container) {
+		return "", fmt.Errorf("container '%s' not found in pod '%s' of instance '%s', containers present: %s", container, podName, i.name, strings.Join(containers, ", "))
+	}
+	logs, err := i.k8sClient().GetPodLogs(i.namespace(), podName, container, opts.TailLines, opts.Previous)
+	if err != nil {
+		return "", fmt.Errorf("error getting logs of container '%s' of instance '%s': %w", container, i.name, err)
+	}
+	return logs, nil
+}
+
+// GetAllContainerLogs returns the logs of all init, regular and ephemeral containers of the first pod of the instance by container name
+// This function can only be called in the state 'Started'
+func (i *Instance) GetAllContainerLogs() (map[string]string, error) {
+	if !i.IsInState(Started) {
+		return nil, i.errInvalidStateTransition("getting logs", Started)
+	}
+	podName, containers, err := i.podContainers()
+	if err != nil {
+		return nil, err
+	}
+	logs := make(map[string]string, len(containers))
+	for _, container := range containers {
+		containerLogs, err := i.k8sClient().GetPodLogs(i.namespace(), podName, container, 0, false)
+		if err != nil {
+			return nil, fmt.Errorf("error getting logs of container '%s' of instance '%s': %w", container, i.name, err)
+		}
+		logs[container] = containerLogs
+	}
+	return logs, nil
+}
+
+// podContainers returns the name of the first pod of the instance and the names of its containers
+func (i *Instance) podContainers() (string, []string, error) {
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting pod from statefulset '%s': %w", i.k8sName, err)
+	}
+	containers, err := i.k8sClient().GetPodContainerNames(i.namespace(), pod.Name)
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting containers of pod '%s' of instance '%s': %w", pod.Name, i.name, err)
+	}
+	return pod.Name, containers, nil
+}
+
+// logLine is a line of the logs of a container
+type logLine struct {
+	time      time.Time
+	container string
+	text      string
+}
+
+// interleavedLogs returns the logs of the containers sorted by the time the lines were written, prefixed with the container name
+// Lines without a timestamp keep the time of the line before them, so that continuation lines stay together
+func (i *Instance) interleavedLogs(podName string, containers []string, opts LogOptions) (string, error) {
+	var lines []logLine
+	for _, container := range containers {
+		logs, err := i.k8sClient().GetPodLogsWithTimestamps(i.namespace(), podName, container, opts.TailLines, opts.Previous)
+		if err != nil {
+			return "", fmt.Errorf("error getting logs of container '%s' of instance '%s': %w", container, i.name, err)
+		}
+		var last time.Time
+		for _, line := range strings.Split(strings.TrimSuffix(logs, "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			text := line
+			if timestamp, rest, found := strings.Cut(line, " "); found {
+				if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+					last, text = t, rest
+				}
+			}
+			lines = append(lines, logLine{time: last, container: container, text: text})
+		}
+	}
+
+	sort.SliceStable(lines, func(a, b int) bool {
+		return lines[a].time.Before(lines[b].time)
+	})
+	var builder strings.Builder
+	for _, line := range lines {
+		fmt.Fprintf(&builder, "[%s] %s\n", line.container, line.text)
+	}
+	return builder.String(), nil
+}
+
+// containsString returns true if the slice contains the string
+func containsString(slice []string, s string) bool {
+	for _, element := range slice {
+		if element == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package knuu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricFamily is a Prometheus metric family, as parsed from the exposition format
+type MetricFamily = dto.MetricFamily
+
+// metricsAccept prefers OpenMetrics, but accepts the Prometheus text format
+const metricsAccept = expfmt.OpenMetricsType + `; version=` + expfmt.OpenMetricsVersion_1_0_0 + `,text/plain;version=` + expfmt.TextVersion + `;q=0.5,*/*;q=0.1`
+
+// metricsRequestTimeout is the timeout of a single request to the metrics endpoint
+const metricsRequestTimeout = 10 * time.Second
+
+// metricsPollInterval is the interval in which WaitForMetric fetches the metrics
+const metricsPollInterval = 2 * time.Second
+
+// SetPrometheusMetricsEndpoint sets the port and path of the Prometheus metrics endpoint used by WaitForMetric
+// The port has to be added with AddPortTCP
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetPrometheusMetricsEndpoint(port int, path string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting prometheus metrics endpoint", Preparing, Committed)
+	}
+	if err := validatePort(port); err != nil {
+		return err
+	}
+	i.metricsPort = port
+	i.metricsPath = path
+	i.logger().Debugf("Set prometheus metrics endpoint of instance '%s' to port '%d' and path '%s'", i.name, port, path)
+	return nil
+}
+
+// EnablePrometheusScraping sets the 'prometheus.io/scrape', 'prometheus.io/port' and 'prometheus.io/path' annotations of the
+// instance's pods, so that a Prometheus in the cluster scrapes the metrics endpoint on the port and path, and sets the endpoint
+// used by WaitForMetric (see SetPrometheusMetricsEndpoint)
+// The port is the port of the container and has to be added with AddPortTCP or AddPortMappingTCP
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) EnablePrometheusScraping(port int, path string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("enabling prometheus scraping", Preparing, Committed)
+	}
+	if err := validatePort(port); err != nil {
+		return err
+	}
+	if !i.isTCPContainerPort(port) {
+		return fmt.Errorf("TCP port '%d' is not registered in instance '%s'", port, i.name)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	annotations := map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   strconv.Itoa(port),
+		"prometheus.io/path":   path,
+	}
+	for key, value := range annotations {
+		if err := i.SetPodAnnotation(key, value); err != nil {
+			return err
+		}
+	}
+	return i.SetPrometheusMetricsEndpoint(port, path)
+}
+
+// GetPrometheusMetrics fetches and parses the Prometheus metrics the instance exposes on the given port and path
+// The endpoint is reached through a port-forward to the instance's pod, which is kept open for further calls
+// Both the Prometheus text format and the OpenMetrics format are supported
+// The port has to be added with AddPortTCP
+// This function can only be called in the state 'Started'
+func (i *Instance) GetPrometheusMetrics(port int, path string) (map[string]*MetricFamily, error) {
+	if !i.IsInState(Started) {
+		return nil, i.errInvalidStateTransition("getting prometheus metrics", Started)
+	}
+	if err := validatePort(port); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	localPort, ok := i.metricsForwards[port]
+	if !ok {
+		var err error
+		localPort, err = i.PortForwardTCP(port)
+		if err != nil {
+			return nil, fmt.Errorf("error forwarding metrics port of instance '%s': %w", i.k8sName, err)
+		}
+		if i.metricsForwards == nil {
+			i.metricsForwards = make(map[int]int)
+		}
+		i.metricsForwards[port] = localPort
+	}
+
+	families, err := fetchPrometheusMetrics(fmt.Sprintf("http://localhost:%d%s", localPort, path))
+	if err != nil {
+		// The port-forward breaks when the pod is restarted, so it is recreated on the next call
+		delete(i.metricsForwards, port)
+		return nil, fmt.Errorf("error getting prometheus metrics of instance '%s': %w", i.k8sName, err)
+	}
+	return families, nil
+}
+
+// WaitForMetric polls the metrics endpoint set by SetPrometheusMetricsEndpoint until the predicate holds for the value of the metric
+// The value of counters, gauges and untyped metrics is summed over all label sets, for histograms and summaries it is the sample count
+// Failed fetches and missing metrics are retried until the context is done
+// This function can only be called in the state 'Started'
+func (i *Instance) WaitForMetric(ctx context.Context, name string, predicate func(float64) bool) error {
+	if !i.IsInState(Started) {
+		return i.errInvalidStateTransition("waiting for metric", Started)
+	}
+	if i.metricsPort == 0 {
+		return fmt.Errorf("prometheus metrics endpoint of instance '%s' is not set", i.k8sName)
+	}
+	ctx, cancel := i.session().withDefaultTimeout(ctx)
+	defer cancel()
+
+	ticker := time.NewTicker(metricsPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		families, err := i.GetPrometheusMetrics(i.metricsPort, i.metricsPath)
+		if err != nil {
+			lastErr = err
+		} else if family, ok := families[name]; !ok {
+			lastErr = fmt.Errorf("metric '%s' not found", name)
+		} else {
+			value := metricFamilyValue(family)
+			if predicate(value) {
+				return nil
+			}
+			lastErr = fmt.Errorf("predicate does not hold for value '%g' of metric '%s'", value, name)
+		}
+		i.logger().Debugf("Waiting for metric '%s' of instance '%s': %v", name, i.k8sName, lastErr)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting for metric '%s' of instance '%s': %w (last error: %v)", name, i.k8sName, ctx.Err(), lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchPrometheusMetrics fetches and parses the metrics from the given url
+func fetchPrometheusMetrics(url string) (map[string]*MetricFamily, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", metricsAccept)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code '%d' requesting metrics", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading metrics: %w", err)
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), expfmt.OpenMetricsType) {
+		body = openMetricsToText(body)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing metrics: %w", err)
+	}
+	return families, nil
+}
+
+// openMetricsToText converts the OpenMetrics format to the Prometheus text format, as expfmt can only parse the latter
+// Counter and info families are renamed to the names of their samples, exemplars and timestamps are dropped,
+// and types that the text format does not know are parsed as untyped
+func openMetricsToText(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+
+	// The TYPE line decides how the HELP line, which usually comes first, is renamed
+	renames := make(map[string]string)
+	dropped := make(map[string]bool)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "#" || fields[1] != "TYPE" {
+			continue
+		}
+		switch fields[3] {
+		case "counter":
+			if !strings.HasSuffix(fields[2], "_total") {
+				renames[fields[2]] = fields[2] + "_total"
+			}
+		case "info":
+			renames[fields[2]] = fields[2] + "_info"
+		case "gauge", "histogram", "summary", "unknown", "stateset":
+		default:
+			// e.g. gaugehistogram, whose samples are parsed as untyped metrics
+			dropped[fields[2]] = true
+		}
+	}
+
+	var out bytes.Buffer
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 3 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				// EOF, UNIT and comments
+				continue
+			}
+			if dropped[fields[2]] {
+				continue
+			}
+			if rename, ok := renames[fields[2]]; ok {
+				fields[2] = rename
+			}
+			if fields[1] == "TYPE" && len(fields) == 4 {
+				switch fields[3] {
+				case "info", "stateset":
+					fields[3] = "gauge"
+				case "unknown":
+					fields[3] = "untyped"
+				}
+			}
+			fmt.Fprintln(&out, strings.Join(fields, " "))
+			continue
+		}
+		if sample := openMetricsSample(line); sample != "" {
+			fmt.Fprintln(&out, sample)
+		}
+	}
+	return out.Bytes()
+}
+
+// openMetricsSample returns the sample line without exemplar and timestamp
+// OpenMetrics timestamps are in seconds while the text format expects milliseconds, so they are dropped
+func openMetricsSample(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return ""
+	}
+
+	// The name and labels end at the first space outside of the label set
+	end := len(line)
+	inLabels, inQuotes, escaped := false, false, false
+	for pos, char := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes:
+			if char == '\\' {
+				escaped = true
+			} else if char == '"' {
+				inQuotes = false
+			}
+		case char == '"':
+			inQuotes = true
+		case char == '{':
+			inLabels = true
+		case char == '}':
+			inLabels = false
+		case char == ' ' && !inLabels:
+			end = pos
+		}
+		if end != len(line) {
+			break
+		}
+	}
+
+	fields := strings.Fields(line[end:])
+	if len(fields) == 0 {
+		return line
+	}
+	return line[:end] + " " + fields[0]
+}
+
+// metricFamilyValue returns the value of the metric family, summed over all label sets
+// For histograms and summaries it is the sample count
+func metricFamilyValue(family *MetricFamily) float64 {
+	var value float64
+	for _, metric := range family.GetMetric() {
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			value += metric.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			value += metric.GetGauge().GetValue()
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			value += float64(metric.GetHistogram().GetSampleCount())
+		case dto.MetricType_SUMMARY:
+			value += float64(metric.GetSummary().GetSampleCount())
+		default:
+			value += metric.GetUntyped().GetValue()
+		}
+	}
+	return value
+}
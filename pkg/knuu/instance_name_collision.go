@@ -0,0 +1,78 @@
+package knuu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxNameRegenerations is the number of times the k8s name of an instance is regenerated on a collision
+const maxNameRegenerations = 3
+
+// ErrNameCollision is returned by Start if a statefulSet or service with the k8s name of the instance exists in the namespace,
+// which was not created by the instance, and the collision policy is NameCollisionError
+var ErrNameCollision = errors.New("k8s name collision")
+
+// NameCollisionPolicy is what Start does if the k8s name of the instance is already taken, see SetOnNameCollision
+type NameCollisionPolicy int
+
+const (
+	// NameCollisionRegenerate generates a new k8s name for the instance
+	NameCollisionRegenerate NameCollisionPolicy = iota
+	// NameCollisionError makes Start return an error wrapping ErrNameCollision
+	NameCollisionError
+)
+
+// String returns the name of the policy
+func (p NameCollisionPolicy) String() string {
+	switch p {
+	case NameCollisionRegenerate:
+		return "Regenerate"
+	case NameCollisionError:
+		return "Error"
+	}
+	return "Unknown"
+}
+
+// SetOnNameCollision sets what Start does if a statefulSet or service with the k8s name of the instance exists in the namespace
+// and was not created by the instance, e.g. by another test, so that the instance never patches an object it does not own
+// By default, a new k8s name is generated
+// This function can only be called in the states 'None', 'Preparing' and 'Committed'
+func (i *Instance) SetOnNameCollision(policy NameCollisionPolicy) error {
+	if !i.IsInState(None, Preparing, Committed) {
+		return i.errInvalidStateTransition("setting name collision policy", None, Preparing, Committed)
+	}
+	if policy != NameCollisionRegenerate && policy != NameCollisionError {
+		return fmt.Errorf("invalid name collision policy '%d'", policy)
+	}
+	i.onNameCollision = policy
+	i.logger().Debugf("Set name collision policy of instance '%s' to '%s'", i.name, policy)
+	return nil
+}
+
+// resolveNameCollision checks that no statefulSet or service with the k8s name of the instance exists that was not created by it,
+// and regenerates the k8s name or returns an error, depending on the collision policy
+func (i *Instance) resolveNameCollision() error {
+	for attempt := 0; ; attempt++ {
+		labels, err := i.k8sClient().GetWorkloadLabels(i.namespace(), i.k8sName)
+		if err != nil {
+			return fmt.Errorf("error checking if k8s name '%s' of instance '%s' is taken: %w", i.k8sName, i.name, err)
+		}
+		// Objects of the instance exist if a previous start failed after deploying them
+		if labels == nil || (labels["test-run-id"] == i.session().Identifier() && labels["k8s-name"] == i.k8sName) {
+			return nil
+		}
+		if i.onNameCollision == NameCollisionError {
+			return fmt.Errorf("%w: k8s name '%s' of instance '%s' is used by an object of test run '%s'", ErrNameCollision, i.k8sName, i.name, labels["test-run-id"])
+		}
+		if attempt == maxNameRegenerations {
+			return fmt.Errorf("%w: k8s name of instance '%s' was taken %d times", ErrNameCollision, i.name, attempt+1)
+		}
+
+		k8sName, err := generateK8sName(i.name)
+		if err != nil {
+			return fmt.Errorf("error generating k8s name for instance '%s': %w", i.name, err)
+		}
+		i.logger().Warnf("K8s name '%s' of instance '%s' is taken, using '%s'", i.k8sName, i.name, k8sName)
+		i.k8sName = k8sName
+	}
+}
@@ -0,0 +1,174 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"strings"
+)
+
+const (
+	// obsSidecarName is the container name of the observability sidecar
+	obsSidecarName = "otel-collector"
+	// obsDefaultImage is the collector image used if none is configured
+	obsDefaultImage = "otel/opentelemetry-collector-contrib:0.83.0"
+	// obsDefaultSharedDir is the path of the directory shared between the instance and the collector
+	obsDefaultSharedDir = "/otel"
+	// obsOTLPGRPCPort is the port of the collector's OTLP gRPC receiver
+	obsOTLPGRPCPort = 4317
+	// obsOTLPHTTPPort is the port of the collector's OTLP HTTP receiver
+	obsOTLPHTTPPort = 4318
+	// obsConfigEnv is the environment variable the collector reads its configuration from
+	obsConfigEnv = "KNUU_OTEL_CONFIG"
+)
+
+// ObsConfig is the configuration of the observability sidecar
+// Empty fields are set to their defaults
+type ObsConfig struct {
+	// Endpoint is the OTLP gRPC endpoint (host:port) the collector exports traces, metrics and logs to
+	// If it and Collector are empty, the telemetry is only written to the collector's log
+	Endpoint string
+	// Collector is an instance running an OpenTelemetry collector with an OTLP gRPC receiver on port 4317,
+	// which the sidecar exports to if Endpoint is empty
+	Collector *Instance
+	// Image is the OpenTelemetry collector image of the sidecar
+	Image string
+	// SharedDir is the path of an emptyDir volume shared between the instance and the sidecar, e.g. for file-based exporters
+	SharedDir string
+	// MemoryRequest is the memory request of the sidecar, defaults to 32Mi
+	MemoryRequest string
+	// MemoryLimit is the memory limit of the sidecar, defaults to 128Mi
+	MemoryLimit string
+	// CPURequest is the CPU request of the sidecar, defaults to 10m
+	CPURequest string
+}
+
+// EnableObservabilitySidecar injects an OpenTelemetry collector sidecar into the instance's pod
+// The standard OTEL_* environment variables of the instance are set to export to the sidecar, unless they are set explicitly
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) EnableObservabilitySidecar(cfg ObsConfig) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("enabling observability sidecar", Preparing, Committed)
+	}
+	if cfg.Image == "" {
+		cfg.Image = obsDefaultImage
+	}
+	if cfg.SharedDir == "" {
+		cfg.SharedDir = obsDefaultSharedDir
+	}
+	if !strings.HasPrefix(cfg.SharedDir, "/") {
+		return fmt.Errorf("shared directory '%s' of observability sidecar must be an absolute path", cfg.SharedDir)
+	}
+	if cfg.MemoryRequest == "" {
+		cfg.MemoryRequest = "32Mi"
+	}
+	if cfg.MemoryLimit == "" {
+		cfg.MemoryLimit = "128Mi"
+	}
+	if cfg.CPURequest == "" {
+		cfg.CPURequest = "10m"
+	}
+	for _, quantity := range []string{cfg.MemoryRequest, cfg.MemoryLimit, cfg.CPURequest} {
+		if _, err := resource.ParseQuantity(quantity); err != nil {
+			return fmt.Errorf("invalid quantity '%s' of observability sidecar: %w", quantity, err)
+		}
+	}
+	if cfg.Endpoint == "" && cfg.Collector != nil {
+		if cfg.Collector == i {
+			return fmt.Errorf("instance '%s' cannot export to itself", i.name)
+		}
+		cfg.Endpoint = fmt.Sprintf("%s:%d", cfg.Collector.k8sName, obsOTLPGRPCPort)
+	}
+	i.observability = &cfg
+	i.logger().Debugf("Enabled observability sidecar for instance '%s' exporting to '%s'", i.name, cfg.Endpoint)
+	return nil
+}
+
+// DisableObservabilitySidecar removes the observability sidecar from the instance
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) DisableObservabilitySidecar() error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("disabling observability sidecar", Preparing, Committed)
+	}
+	i.observability = nil
+	i.logger().Debugf("Disabled observability sidecar for instance '%s'", i.name)
+	return nil
+}
+
+// sidecars returns the sidecar containers of the instance's pod
+func (i *Instance) sidecars() []*k8s.SidecarConfig {
+	var sidecars []*k8s.SidecarConfig
+	if i.observability != nil {
+		sidecars = append(sidecars, i.obsSidecar())
+	}
+	if i.capture != nil {
+		sidecars = append(sidecars, i.captureSidecar())
+	}
+	return sidecars
+}
+
+// obsSidecar returns the observability sidecar container of the instance's pod
+func (i *Instance) obsSidecar() *k8s.SidecarConfig {
+	cfg := i.observability
+	return &k8s.SidecarConfig{
+		Name:  obsSidecarName,
+		Image: cfg.Image,
+		Args:  []string{fmt.Sprintf("--config=env:%s", obsConfigEnv)},
+		Env: map[string]string{
+			obsConfigEnv: obsCollectorConfig(cfg.Endpoint),
+		},
+		MainEnv: map[string]string{
+			"OTEL_SERVICE_NAME":           i.name,
+			"OTEL_EXPORTER_OTLP_ENDPOINT": fmt.Sprintf("http://localhost:%d", obsOTLPGRPCPort),
+			"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+			"OTEL_TRACES_EXPORTER":        "otlp",
+			"OTEL_METRICS_EXPORTER":       "otlp",
+			"OTEL_LOGS_EXPORTER":          "otlp",
+		},
+		MemoryRequest:    cfg.MemoryRequest,
+		MemoryLimit:      cfg.MemoryLimit,
+		CPURequest:       cfg.CPURequest,
+		SharedVolumePath: cfg.SharedDir,
+	}
+}
+
+// obsCollectorConfig returns the configuration of the collector sidecar
+// It receives OTLP on localhost and exports to the endpoint, or to its log if the endpoint is empty
+func obsCollectorConfig(endpoint string) string {
+	exporter := "logging"
+	exporterConfig := "  logging: {}"
+	if endpoint != "" {
+		exporter = "otlp"
+		exporterConfig = fmt.Sprintf("  otlp:\n    endpoint: %q\n    tls:\n      insecure: true", endpoint)
+	}
+	pipeline := fmt.Sprintf("      receivers: [otlp]\n      processors: [batch]\n      exporters: [%s]", exporter)
+	return fmt.Sprintf(`receivers:
+  otlp:
+    protocols:
+      grpc:
+        endpoint: "localhost:%d"
+      http:
+        endpoint: "localhost:%d"
+processors:
+  batch: {}
+exporters:
+%s
+service:
+  pipelines:
+    traces:
+%s
+    metrics:
+%s
+    logs:
+%s
+`, obsOTLPGRPCPort, obsOTLPHTTPPort, exporterConfig, pipeline, pipeline, pipeline)
+}
+
+// cloneObservability returns a copy of the observability sidecar configuration, or nil if it is disabled
+func (i *Instance) cloneObservability() *ObsConfig {
+	if i.observability == nil {
+		return nil
+	}
+	cfg := *i.observability
+	return &cfg
+}
@@ -2,13 +2,13 @@ package knuu
 
 import (
 	"fmt"
-	"github.com/sirupsen/logrus"
 )
 
 // InstancePool is a struct that represents a pool of instances
 type InstancePool struct {
-	instances []*Instance
-	amount    int
+	instances        []*Instance
+	amount           int
+	prePullThreshold int
 }
 
 // Instances returns the instances in the instance pool
@@ -20,15 +20,18 @@ func (i *InstancePool) Instances() []*Instance {
 // This function can only be called in the state 'Committed'
 func (i *Instance) CreatePool(amount int) (*InstancePool, error) {
 	if !i.IsInState(Committed) {
-		return nil, fmt.Errorf("creating a pool is only allowed in state 'Committed' or 'Destroyed'. Current state is '%s'", i.state.String())
+		return nil, i.errInvalidStateTransition("creating a pool", Committed)
+	}
+	if maxSuffix := fmt.Sprintf("-%d", amount-1); len(i.k8sName)+len(maxSuffix) > maxK8sNameLength {
+		return nil, fmt.Errorf("pool of %d instances exceeds the maximum name length of %d characters for instance '%s'", amount, maxK8sNameLength, i.k8sName)
 	}
 	instances := make([]*Instance, amount)
 	for j := 0; j < amount; j++ {
 		instances[j] = i.cloneWithSuffix(fmt.Sprintf("-%d", j))
+		instances[j].pool = i.k8sName
 	}
 
-	i.state = Destroyed
-	logrus.Debugf("Set state of instance '%s' to '%s'", i.name, i.state.String())
+	i.setState(Destroyed)
 
 	return &InstancePool{
 		instances: instances,
@@ -36,8 +39,24 @@ func (i *Instance) CreatePool(amount int) (*InstancePool, error) {
 	}, nil
 }
 
+// SetPrePullThreshold makes Start pre-pull the images of the instances on all nodes (see PrePullImages)
+// if the pool has at least the given number of instances, zero disables pre-pulling
+func (i *InstancePool) SetPrePullThreshold(threshold int) error {
+	if threshold < 0 {
+		return fmt.Errorf("pre-pull threshold must not be negative, got %d", threshold)
+	}
+	i.prePullThreshold = threshold
+	return nil
+}
+
 // Start starts all instances in the instance pool
+// If the pool reaches the pre-pull threshold (see SetPrePullThreshold), the images are pre-pulled on all nodes first
 func (i *InstancePool) Start() error {
+	if i.prePullThreshold > 0 && len(i.instances) >= i.prePullThreshold {
+		if err := i.prePullImages(); err != nil {
+			return err
+		}
+	}
 	for _, instance := range i.instances {
 		err := instance.Start()
 		if err != nil {
@@ -68,3 +87,17 @@ func (i *InstancePool) WaitInstancePoolIsRunning() error {
 	}
 	return nil
 }
+
+// prePullImages pre-pulls the images of the pool's instances and their sidecars on all nodes
+func (i *InstancePool) prePullImages() error {
+	instance := i.instances[0]
+	image, err := instance.getImageRegistry()
+	if err != nil {
+		return fmt.Errorf("error getting image of instance '%s': %w", instance.k8sName, err)
+	}
+	images := []string{image}
+	for _, sidecar := range instance.sidecars() {
+		images = append(images, sidecar.Image)
+	}
+	return instance.session().PrePullImages(images)
+}
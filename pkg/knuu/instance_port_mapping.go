@@ -0,0 +1,157 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"sort"
+)
+
+// AddPortMappingTCP publishes the TCP port of the container as a different port of the instance's service,
+// e.g. to make an application that listens on 26657 reachable on port 80
+// Clients inside the cluster use the service port (see GetServiceEndpoint), while port forwards use the container port
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddPortMappingTCP(servicePort, containerPort int) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding port mapping", Preparing, Committed)
+	}
+	if err := validatePortMapping(servicePort, containerPort); err != nil {
+		return err
+	}
+	if i.isTCPServicePort(servicePort) {
+		return fmt.Errorf("TCP service port '%d' is already registered in instance '%s'", servicePort, i.name)
+	}
+	if i.portMappingsTCP == nil {
+		i.portMappingsTCP = make(map[int]int)
+	}
+	i.portMappingsTCP[servicePort] = containerPort
+	i.logger().Debugf("Added TCP port mapping from service port '%d' to container port '%d' to instance '%s'", servicePort, containerPort, i.name)
+	return nil
+}
+
+// AddPortTCPMapped exposes the service port of the instance with the target port of its container, see AddPortMappingTCP
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddPortTCPMapped(servicePort, targetPort int) error {
+	return i.AddPortMappingTCP(servicePort, targetPort)
+}
+
+// AddPortMappingUDP publishes the UDP port of the container as a different port of the instance's service, like AddPortMappingTCP
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddPortMappingUDP(servicePort, containerPort int) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding port mapping", Preparing, Committed)
+	}
+	if err := validatePortMapping(servicePort, containerPort); err != nil {
+		return err
+	}
+	if i.isUDPServicePort(servicePort) {
+		return fmt.Errorf("UDP service port '%d' is already registered in instance '%s'", servicePort, i.name)
+	}
+	if i.portMappingsUDP == nil {
+		i.portMappingsUDP = make(map[int]int)
+	}
+	i.portMappingsUDP[servicePort] = containerPort
+	i.logger().Debugf("Added UDP port mapping from service port '%d' to container port '%d' to instance '%s'", servicePort, containerPort, i.name)
+	return nil
+}
+
+// validatePortMapping validates the ports of a port mapping
+func validatePortMapping(servicePort, containerPort int) error {
+	if err := validatePort(servicePort); err != nil {
+		return fmt.Errorf("invalid service port: %w", err)
+	}
+	if err := validatePort(containerPort); err != nil {
+		return fmt.Errorf("invalid container port: %w", err)
+	}
+	return nil
+}
+
+// isTCPServicePort returns true if the port is a TCP port of the instance's service, added with AddPortTCP or AddPortMappingTCP
+func (i *Instance) isTCPServicePort(port int) bool {
+	_, mapped := i.portMappingsTCP[port]
+	return mapped || i.isTCPPortRegistered(port)
+}
+
+// isUDPServicePort returns true if the port is a UDP port of the instance's service, added with AddPortUDP or AddPortMappingUDP
+func (i *Instance) isUDPServicePort(port int) bool {
+	_, mapped := i.portMappingsUDP[port]
+	return mapped || i.isUDPPortRegistered(port)
+}
+
+// isTCPContainerPort returns true if the port is a TCP port of the instance's container, added with AddPortTCP or AddPortMappingTCP
+func (i *Instance) isTCPContainerPort(port int) bool {
+	for _, containerPort := range i.portMappingsTCP {
+		if containerPort == port {
+			return true
+		}
+	}
+	return i.isTCPPortRegistered(port)
+}
+
+// isUDPContainerPort returns true if the port is a UDP port of the instance's container, added with AddPortUDP or AddPortMappingUDP
+func (i *Instance) isUDPContainerPort(port int) bool {
+	for _, containerPort := range i.portMappingsUDP {
+		if containerPort == port {
+			return true
+		}
+	}
+	return i.isUDPPortRegistered(port)
+}
+
+// tcpContainerPort returns the container port the TCP service port targets
+func (i *Instance) tcpContainerPort(servicePort int) int {
+	if containerPort, ok := i.portMappingsTCP[servicePort]; ok {
+		return containerPort
+	}
+	return servicePort
+}
+
+// servicePortsTCP returns the TCP ports of the instance's service, the registered ports followed by the mapped ones
+func (i *Instance) servicePortsTCP() []int {
+	return append(append([]int(nil), i.portsTCP...), sortedPorts(i.portMappingsTCP)...)
+}
+
+// servicePortsUDP returns the UDP ports of the instance's service, the registered ports followed by the mapped ones
+func (i *Instance) servicePortsUDP() []int {
+	return append(append([]int(nil), i.portsUDP...), sortedPorts(i.portMappingsUDP)...)
+}
+
+// hasServicePorts returns true if the instance's service has at least one port
+func (i *Instance) hasServicePorts() bool {
+	return len(i.portsTCP) != 0 || len(i.portsUDP) != 0 || len(i.portMappingsTCP) != 0 || len(i.portMappingsUDP) != 0
+}
+
+// deployedServiceOptions returns the options of the instance's service including the port mappings, so that patching the
+// service keeps them
+func (i *Instance) deployedServiceOptions() k8s.ServiceOptions {
+	opts := i.serviceOptions
+	opts.TargetPortsTCP = i.portMappingsTCP
+	opts.TargetPortsUDP = i.portMappingsUDP
+	return opts
+}
+
+// clonePortMappings returns copies of the TCP and UDP port mappings of the instance
+func (i *Instance) clonePortMappings() (map[int]int, map[int]int) {
+	return copyPortMap(i.portMappingsTCP), copyPortMap(i.portMappingsUDP)
+}
+
+// sortedPorts returns the keys of the port map in ascending order
+func sortedPorts(ports map[int]int) []int {
+	keys := make([]int, 0, len(ports))
+	for port := range ports {
+		keys = append(keys, port)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// copyPortMap returns a copy of the port map, nil if the map is nil
+func copyPortMap(ports map[int]int) map[int]int {
+	if ports == nil {
+		return nil
+	}
+	copied := make(map[int]int, len(ports))
+	for key, value := range ports {
+		copied[key] = value
+	}
+	return copied
+}
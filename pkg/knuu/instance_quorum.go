@@ -0,0 +1,102 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/knuu/pkg/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// quorumPollInterval is the interval in which WaitForQuorum checks the readiness of the instances
+const quorumPollInterval = 2 * time.Second
+
+// ReadyCount returns the number of instances whose pods are all ready
+// Instances that are not in the state 'Started' are not ready
+func ReadyCount(instances []*Instance) (int, error) {
+	statuses, err := statusesOf(instances)
+	if err != nil {
+		return 0, err
+	}
+	ready := 0
+	for _, instance := range instances {
+		if isReady, _ := instance.quorumStatus(statuses); isReady {
+			ready++
+		}
+	}
+	return ready, nil
+}
+
+// WaitForQuorum waits until at least k of the instances are ready, i.e. all of their pods are ready
+// Instances that are not in the state 'Started' yet are not ready, so they can be started while waiting
+// Failed status requests are retried until the context is done
+// The pods of all instances are listed with one request per check, so that many instances do not exhaust the rate limit
+// If the context is done, the error lists the instances that are not ready and the phases of their pods
+func WaitForQuorum(ctx context.Context, instances []*Instance, k int) error {
+	if k < 1 || k > len(instances) {
+		return fmt.Errorf("quorum must be between 1 and the number of instances (%d), got %d", len(instances), k)
+	}
+	// The instances of a quorum belong to one session, whose default timeout is used
+	ctx, cancel := instances[0].session().withDefaultTimeout(ctx)
+	defer cancel()
+
+	ticker := time.NewTicker(quorumPollInterval)
+	defer ticker.Stop()
+
+	for {
+		ready := 0
+		var notReady []string
+		statuses, lastErr := statusesOf(instances)
+		for _, instance := range instances {
+			isReady, phase := instance.quorumStatus(statuses)
+			switch {
+			case lastErr != nil && instance.IsInState(Started):
+				notReady = append(notReady, fmt.Sprintf("%s (unknown)", instance.k8sName))
+			case isReady:
+				ready++
+			default:
+				notReady = append(notReady, fmt.Sprintf("%s (%s)", instance.k8sName, phase))
+			}
+		}
+		if ready >= k {
+			log.Debugf("Quorum of %d/%d instances is ready", ready, len(instances))
+			return nil
+		}
+		log.Debugf("Waiting for quorum of %d instances, %d/%d are ready", k, ready, len(instances))
+
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("error waiting for quorum of %d instances, %d/%d are ready, not ready: %s: %w", k, ready, len(instances), strings.Join(notReady, ", "), ctx.Err())
+			if lastErr != nil {
+				return fmt.Errorf("%w (last error: %v)", err, lastErr)
+			}
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// quorumStatus returns if the instance is ready and the phases of its pods in the statuses, or its state if it is not started
+func (i *Instance) quorumStatus(statuses map[*Instance]InstanceStatus) (bool, string) {
+	if !i.IsInState(Started) {
+		return false, fmt.Sprintf("state %s", i.state.String())
+	}
+	status := statuses[i]
+	if len(status.Pods) == 0 {
+		return false, "no pods"
+	}
+	phases := make([]string, 0, len(status.Pods))
+	for _, pod := range status.Pods {
+		phase := string(pod.Phase)
+		if pod.Phase == v1.PodRunning && !pod.Ready {
+			phase += ", not ready"
+		}
+		if len(status.Pods) > 1 {
+			phase = fmt.Sprintf("%s: %s", pod.Name, phase)
+		}
+		phases = append(phases, phase)
+	}
+	return status.Ready(), strings.Join(phases, "; ")
+}
@@ -0,0 +1,134 @@
+package knuu
+
+import (
+	"errors"
+	"fmt"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// AddClusterRoleBinding binds the cluster role to the service account of the instance when it is started
+// The binding grants the permissions of the cluster role in all namespaces, and it is deleted when the instance is destroyed
+// If the instance uses the default service account, a service account named after the instance is created for it
+// (see SetServiceAccount), so that the permissions are not granted to all pods in the namespace
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddClusterRoleBinding(clusterRole string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding cluster role binding", Preparing, Committed)
+	}
+	if clusterRole == "" {
+		return fmt.Errorf("cluster role of instance '%s' must not be empty", i.name)
+	}
+	for _, existing := range i.clusterRoles {
+		if existing == clusterRole {
+			return nil
+		}
+	}
+	i.ensureOwnServiceAccount()
+	i.clusterRoles = append(i.clusterRoles, clusterRole)
+	i.logger().Debugf("Added cluster role binding of '%s' to instance '%s'", clusterRole, i.name)
+	return nil
+}
+
+// AddRole grants the rules in the namespace of the instance to its service account when it is started
+// The rules of all calls are combined into one role, which is bound to the service account and deleted with its binding
+// when the instance is destroyed
+// If the instance uses the default service account, a service account named after the instance is created for it
+// (see SetServiceAccount), so that the permissions are not granted to all pods in the namespace
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddRole(rules []rbacv1.PolicyRule) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("adding role", Preparing, Committed)
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("rules of role of instance '%s' must not be empty", i.name)
+	}
+	for _, rule := range rules {
+		if len(rule.Verbs) == 0 {
+			return fmt.Errorf("rules of role of instance '%s' must have verbs", i.name)
+		}
+	}
+	i.ensureOwnServiceAccount()
+	for _, rule := range rules {
+		i.roleRules = append(i.roleRules, *rule.DeepCopy())
+	}
+	i.logger().Debugf("Added %d rules to role of instance '%s'", len(rules), i.name)
+	return nil
+}
+
+// ensureOwnServiceAccount makes the instance create a service account named after it, if it uses the default service account
+func (i *Instance) ensureOwnServiceAccount() {
+	if i.serviceAccountName != "" && i.serviceAccountName != defaultServiceAccount {
+		return
+	}
+	i.serviceAccountName = i.k8sName
+	i.createServiceAccount = true
+	i.logger().Debugf("Set service account to '%s' (create: true) in instance '%s'", i.serviceAccountName, i.name)
+}
+
+// deployRBAC creates the role, the role binding and the cluster role bindings of the instance
+func (i *Instance) deployRBAC() error {
+	if len(i.roleRules) != 0 {
+		if err := i.k8sClient().CreateRoleWithRules(i.k8sName, i.namespace(), i.getLabels(), i.roleRules); err != nil {
+			return fmt.Errorf("error creating role '%s': %w", i.k8sName, err)
+		}
+		if err := i.k8sClient().CreateRoleBinding(i.k8sName, i.namespace(), i.getLabels(), i.k8sName, i.serviceAccountName); err != nil {
+			return fmt.Errorf("error creating role binding '%s': %w", i.k8sName, err)
+		}
+		i.logger().Debugf("Deployed role and role binding '%s'", i.k8sName)
+	}
+	for _, clusterRole := range i.clusterRoles {
+		name := i.clusterRoleBindingName(clusterRole)
+		// The binding is granted to the timeout handler first, so that it is deleted even if the test process dies right after
+		if err := i.session().allowTimeoutHandlerToDelete(name); err != nil {
+			return err
+		}
+		if err := i.k8sClient().CreateClusterRoleBinding(name, i.getLabels(), clusterRole, i.serviceAccountName, i.namespace()); err != nil {
+			return fmt.Errorf("error creating cluster role binding '%s': %w", name, err)
+		}
+		i.logger().Debugf("Deployed cluster role binding '%s'", name)
+	}
+	return nil
+}
+
+// destroyRBAC deletes the role, the role binding and the cluster role bindings of the instance
+// It deletes as much as possible, as the cluster role bindings are not removed with the namespace
+func (i *Instance) destroyRBAC() error {
+	if len(i.roleRules) == 0 && len(i.clusterRoles) == 0 {
+		return nil
+	}
+	var errs []error
+	if len(i.roleRules) != 0 {
+		if err := i.k8sClient().DeleteRoleBinding(i.k8sName, i.namespace()); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting role binding '%s': %w", i.k8sName, err))
+		}
+		if err := i.k8sClient().DeleteRole(i.k8sName, i.namespace()); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting role '%s': %w", i.k8sName, err))
+		}
+	}
+	for _, clusterRole := range i.clusterRoles {
+		name := i.clusterRoleBindingName(clusterRole)
+		if err := i.k8sClient().DeleteClusterRoleBinding(name); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting cluster role binding '%s': %w", name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	i.logger().Debugf("Destroyed RBAC of instance '%s'", i.k8sName)
+	return nil
+}
+
+// clusterRoleBindingName returns the name of the binding of the cluster role, which is unique in the cluster
+// as the k8s name of the instance contains a random suffix
+func (i *Instance) clusterRoleBindingName(clusterRole string) string {
+	return fmt.Sprintf("%s-%s", i.k8sName, clusterRole)
+}
+
+// cloneRoleRules returns a copy of the role rules for a clone of the instance
+func (i *Instance) cloneRoleRules() []rbacv1.PolicyRule {
+	var roleRules []rbacv1.PolicyRule
+	for _, rule := range i.roleRules {
+		roleRules = append(roleRules, *rule.DeepCopy())
+	}
+	return roleRules
+}
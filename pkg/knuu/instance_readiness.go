@@ -0,0 +1,107 @@
+package knuu
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"math"
+	"strings"
+	"time"
+)
+
+// defaultReadinessInterval is the interval in which a readiness check is run, if not set
+const defaultReadinessInterval = 2 * time.Second
+
+// readinessProbeFailedPrefix is the prefix of the message of the event Kubernetes emits when a readiness probe fails
+const readinessProbeFailedPrefix = "Readiness probe failed: "
+
+// ReadinessCheck is a check that must pass before the instance is considered running, see Instance.SetReadinessCheck
+type ReadinessCheck struct {
+	// Command is run in the instance's container, the check passes if it exits with code 0
+	Command []string
+	// Interval is the time between two runs of the check, rounded up to full seconds, defaults to 2 seconds
+	// It is also the time the command may run before it fails
+	Interval time.Duration
+	// Timeout is the time Start waits for the check to pass, defaults to the default timeout (see SetDefaultTimeout)
+	Timeout time.Duration
+}
+
+// NewCommandReadinessCheck returns a readiness check that passes once the command exits with code 0
+func NewCommandReadinessCheck(command ...string) ReadinessCheck {
+	return ReadinessCheck{
+		Command: command,
+	}
+}
+
+// NewFileReadinessCheck returns a readiness check that passes once the file exists in the instance's container
+// The image of the instance must provide the 'test' command, e.g. from a shell or coreutils
+func NewFileReadinessCheck(path string) ReadinessCheck {
+	return ReadinessCheck{
+		Command: []string{"test", "-e", path},
+	}
+}
+
+// SetReadinessCheck sets a check that must pass before the instance is considered running
+// It is run as a Kubernetes readiness probe in the instance's container, so Start, WaitInstanceIsRunning and the service
+// of the instance wait for it to pass
+// If it does not pass in time, the error of Start reports the last output of the check
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetReadinessCheck(check ReadinessCheck) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting readiness check", Preparing, Committed)
+	}
+	if len(check.Command) == 0 || check.Command[0] == "" {
+		return fmt.Errorf("command of readiness check must not be empty")
+	}
+	if check.Interval < 0 {
+		return fmt.Errorf("interval of readiness check must not be negative, got '%s'", check.Interval)
+	}
+	if check.Timeout < 0 {
+		return fmt.Errorf("timeout of readiness check must not be negative, got '%s'", check.Timeout)
+	}
+	if check.Interval == 0 {
+		check.Interval = defaultReadinessInterval
+	}
+	check.Command = append([]string(nil), check.Command...)
+	i.readinessCheck = &check
+	i.logger().Debugf("Set readiness check '%s' in instance '%s'", strings.Join(check.Command, " "), i.name)
+	return nil
+}
+
+// readinessProbe returns the readiness probe of the instance's container, or nil if it has no readiness check
+func (i *Instance) readinessProbe() *v1.Probe {
+	if i.readinessCheck == nil {
+		return nil
+	}
+	seconds := int32(math.Ceil(i.readinessCheck.Interval.Seconds()))
+	return &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{
+			Exec: &v1.ExecAction{
+				Command: i.readinessCheck.Command,
+			},
+		},
+		PeriodSeconds:    seconds,
+		TimeoutSeconds:   seconds,
+		SuccessThreshold: 1,
+		FailureThreshold: 1,
+	}
+}
+
+// readinessTimeout returns the time Start waits for the instance to be running
+func (i *Instance) readinessTimeout() time.Duration {
+	if i.readinessCheck == nil || i.readinessCheck.Timeout == 0 {
+		return i.session().defaultWaitTimeout
+	}
+	return i.readinessCheck.Timeout
+}
+
+// readinessFailure returns the last output of the failing readiness check, if the last warning reports it
+func (i *Instance) readinessFailure(lastWarning *Event) string {
+	if i.readinessCheck == nil || lastWarning == nil || !strings.HasPrefix(lastWarning.Message, readinessProbeFailedPrefix) {
+		return ""
+	}
+	output := strings.TrimSpace(strings.TrimPrefix(lastWarning.Message, readinessProbeFailedPrefix))
+	if output == "" {
+		return fmt.Sprintf("readiness check '%s' does not pass", strings.Join(i.readinessCheck.Command, " "))
+	}
+	return fmt.Sprintf("readiness check '%s' does not pass, last output: %s", strings.Join(i.readinessCheck.Command, " "), output)
+}
@@ -0,0 +1,165 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"time"
+)
+
+// ResourceSummary is the aggregated resource footprint of one or more instances
+// Quantities that are not set are zero
+type ResourceSummary struct {
+	CPURequest    resource.Quantity
+	CPULimit      resource.Quantity
+	MemoryRequest resource.Quantity
+	MemoryLimit   resource.Quantity
+	VolumeSize    resource.Quantity
+}
+
+// Add adds the quantities of the other summary to the summary
+func (s *ResourceSummary) Add(other ResourceSummary) {
+	s.CPURequest.Add(other.CPURequest)
+	s.CPULimit.Add(other.CPULimit)
+	s.MemoryRequest.Add(other.MemoryRequest)
+	s.MemoryLimit.Add(other.MemoryLimit)
+	s.VolumeSize.Add(other.VolumeSize)
+}
+
+// String returns a readable description of the summary, e.g. for error messages
+func (s ResourceSummary) String() string {
+	return fmt.Sprintf("%s CPU (limit %s), %s memory (limit %s), %s volumes", s.CPURequest.String(), s.CPULimit.String(), s.MemoryRequest.String(), s.MemoryLimit.String(), s.VolumeSize.String())
+}
+
+// ResourceSummary returns the resources requested by the instance
+// CPU and memory, including the ones of sidecars, are multiplied by the number of replicas, while the volumes are shared by all replicas
+func (i *Instance) ResourceSummary() ResourceSummary {
+	var summary ResourceSummary
+	for replica := int32(0); replica < i.replicas; replica++ {
+		summary.CPURequest.Add(parseQuantityOrZero(i.cpuRequest))
+		summary.CPULimit.Add(parseQuantityOrZero(i.cpuLimit))
+		summary.MemoryRequest.Add(parseQuantityOrZero(i.memoryRequest))
+		summary.MemoryLimit.Add(parseQuantityOrZero(i.memoryLimit))
+		for _, sidecar := range i.sidecars() {
+			summary.CPURequest.Add(parseQuantityOrZero(sidecar.CPURequest))
+			summary.MemoryRequest.Add(parseQuantityOrZero(sidecar.MemoryRequest))
+			summary.MemoryLimit.Add(parseQuantityOrZero(sidecar.MemoryLimit))
+		}
+	}
+	for _, volume := range i.volumes {
+		summary.VolumeSize.Add(parseQuantityOrZero(volume.Size))
+	}
+	return summary
+}
+
+// ResourceSummary returns the resources requested by all instances in the instance pool
+func (i *InstancePool) ResourceSummary() ResourceSummary {
+	var summary ResourceSummary
+	for _, instance := range i.instances {
+		summary.Add(instance.ResourceSummary())
+	}
+	return summary
+}
+
+// parseQuantityOrZero parses the quantity, returning zero if it is not set or invalid
+func parseQuantityOrZero(quantity string) resource.Quantity {
+	if quantity == "" {
+		return resource.Quantity{}
+	}
+	parsed, err := resource.ParseQuantity(quantity)
+	if err != nil {
+		return resource.Quantity{}
+	}
+	return parsed
+}
+
+// ErrMetricsUnavailable is returned by GetResourceUsage and SampleResourceUsage if metrics-server is not installed
+var ErrMetricsUnavailable = k8s.ErrMetricsUnavailable
+
+// ResourceUsage is the current resource usage of an instance, summed over all its pods
+type ResourceUsage struct {
+	// CPU is the CPU usage in millicores
+	CPU int64
+	// Memory is the memory usage in bytes
+	Memory int64
+	// Pods is the number of pods the usage was measured for
+	Pods int
+	// Timestamp is the time of the most recent measurement
+	Timestamp time.Time
+}
+
+// GetResourceUsage returns the current CPU and memory usage of the instance from the metrics.k8s.io API
+// It returns an error wrapping ErrMetricsUnavailable if metrics-server is not installed
+// This function can only be called in the state 'Started'
+func (i *Instance) GetResourceUsage() (ResourceUsage, error) {
+	if !i.IsInState(Started) {
+		return ResourceUsage{}, i.errInvalidStateTransition("getting resource usage", Started)
+	}
+	podMetrics, err := i.k8sClient().GetPodMetrics(i.namespace(), map[string]string{"app": i.k8sName})
+	if err != nil {
+		return ResourceUsage{}, fmt.Errorf("error getting resource usage of instance '%s': %w", i.k8sName, err)
+	}
+	if len(podMetrics) == 0 {
+		return ResourceUsage{}, fmt.Errorf("no metrics available yet for instance '%s'", i.k8sName)
+	}
+
+	var usage ResourceUsage
+	for _, metrics := range podMetrics {
+		usage.CPU += metrics.CPU.MilliValue()
+		usage.Memory += metrics.Memory.Value()
+		usage.Pods++
+		if metrics.Timestamp.After(usage.Timestamp) {
+			usage.Timestamp = metrics.Timestamp
+		}
+	}
+	return usage, nil
+}
+
+// SampleResourceUsage samples the resource usage of the instance at the given interval until the context is done
+// The first sample is taken immediately, so that an unavailable metrics API is reported as error
+// Failed samples are logged and skipped, the returned channel is closed when the context is done
+// This function can only be called in the state 'Started'
+func (i *Instance) SampleResourceUsage(ctx context.Context, interval time.Duration) (<-chan ResourceUsage, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got '%s'", interval)
+	}
+	first, err := i.GetResourceUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(chan ResourceUsage)
+	go func() {
+		defer close(samples)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		usage := first
+		for {
+			select {
+			case samples <- usage:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			for {
+				usage, err = i.GetResourceUsage()
+				if err == nil {
+					break
+				}
+				i.logger().Warnf("Error sampling resource usage of instance '%s': %v", i.k8sName, err)
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return samples, nil
+}
@@ -0,0 +1,58 @@
+package knuu
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourceSummaryIncludesCPULimit(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "limited", 8080)
+	if err := instance.SetCPU("250m"); err != nil {
+		t.Fatalf("setting cpu: %v", err)
+	}
+	if err := instance.SetCPULimit("500m"); err != nil {
+		t.Fatalf("setting cpu limit: %v", err)
+	}
+	if err := instance.SetCPULimit("half"); err == nil {
+		t.Error("expected error setting an invalid cpu limit")
+	}
+	if err := instance.SetReplicas(2); err != nil {
+		t.Fatalf("setting replicas: %v", err)
+	}
+
+	summary := instance.ResourceSummary()
+	if expected := resource.MustParse("1"); summary.CPULimit.Cmp(expected) != 0 {
+		t.Errorf("expected cpu limit '%s' for 2 replicas, got '%s'", expected.String(), summary.CPULimit.String())
+	}
+	if expected := resource.MustParse("500m"); summary.CPURequest.Cmp(expected) != 0 {
+		t.Errorf("expected cpu request '%s' for 2 replicas, got '%s'", expected.String(), summary.CPURequest.String())
+	}
+
+	pool, err := instance.CreatePool(3)
+	if err != nil {
+		t.Fatalf("creating pool: %v", err)
+	}
+	poolSummary := pool.ResourceSummary()
+	if expected := resource.MustParse("3"); poolSummary.CPULimit.Cmp(expected) != 0 {
+		t.Errorf("expected cpu limit '%s' for the pool, got '%s'", expected.String(), poolSummary.CPULimit.String())
+	}
+
+	// The limit is set on the containers of the instances of the pool
+	started := pool.Instances()[0]
+	if err := started.Start(); err != nil {
+		t.Fatalf("starting instance: %v", err)
+	}
+	statefulSet, err := cluster.AppsV1().StatefulSets(testNamespace).Get(context.Background(), started.k8sName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting statefulSet: %v", err)
+	}
+	limit := statefulSet.Spec.Template.Spec.Containers[0].Resources.Limits[v1.ResourceCPU]
+	if expected := resource.MustParse("500m"); limit.Cmp(expected) != 0 {
+		t.Errorf("expected container cpu limit '%s', got '%s'", expected.String(), limit.String())
+	}
+}
@@ -0,0 +1,103 @@
+package knuu
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// poolLabel is the label of the instances of a pool, set to the k8s name of the instance the pool was created from
+// The pods of a pool are spread as a group, as every instance of the pool has its own statefulSet
+const poolLabel = "knuu.sh/pool"
+
+// hostnameTopologyKey is the node label that identifies a single node
+const hostnameTopologyKey = "kubernetes.io/hostname"
+
+// SetTopologySpread spreads the pods of the instance (or of its pool, see CreatePool) evenly across the topology domains
+// of the given node label, e.g. 'kubernetes.io/hostname' for nodes or 'topology.kubernetes.io/zone' for zones
+// The number of pods in two domains differs by at most maxSkew, pods that would exceed it are not scheduled
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetTopologySpread(topologyKey string, maxSkew int) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting topology spread", Preparing, Committed)
+	}
+	if topologyKey == "" {
+		return fmt.Errorf("topology key must not be empty")
+	}
+	if maxSkew < 1 {
+		return fmt.Errorf("maxSkew must be at least 1, got '%d'", maxSkew)
+	}
+	i.topologySpreadKey = topologyKey
+	i.topologySpreadMaxSkew = int32(maxSkew)
+	i.logger().Debugf("Set topology spread with topology key '%s' and maxSkew '%d' in instance '%s'", topologyKey, maxSkew, i.name)
+	return nil
+}
+
+// SetPodAntiAffinity keeps the pods of the instance (or of its pool, see CreatePool) on different nodes
+// If required is true, pods that would share a node are not scheduled, otherwise the scheduler only prefers different nodes
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetPodAntiAffinity(required bool) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting pod anti-affinity", Preparing, Committed)
+	}
+	i.podAntiAffinity = &required
+	i.logger().Debugf("Set pod anti-affinity (required: %t) in instance '%s'", required, i.name)
+	return nil
+}
+
+// spreadSelector selects the pods the instance's pods are spread against: the pods of its pool, or its own pods
+func (i *Instance) spreadSelector() *metav1.LabelSelector {
+	labels := i.getLabels()
+	matchLabels := map[string]string{
+		"test-run-id": labels["test-run-id"],
+	}
+	if i.pool != "" {
+		matchLabels[poolLabel] = i.pool
+	} else {
+		matchLabels["app"] = labels["app"]
+	}
+	return &metav1.LabelSelector{MatchLabels: matchLabels}
+}
+
+// topologySpread returns the topology spread constraints of the instance's pods
+func (i *Instance) topologySpread() []v1.TopologySpreadConstraint {
+	if i.topologySpreadKey == "" {
+		return nil
+	}
+	return []v1.TopologySpreadConstraint{
+		{
+			MaxSkew:           i.topologySpreadMaxSkew,
+			TopologyKey:       i.topologySpreadKey,
+			WhenUnsatisfiable: v1.DoNotSchedule,
+			LabelSelector:     i.spreadSelector(),
+		},
+	}
+}
+
+// affinity returns the affinity of the instance's pods
+func (i *Instance) affinity() *v1.Affinity {
+	if i.podAntiAffinity == nil {
+		return nil
+	}
+	term := v1.PodAffinityTerm{
+		LabelSelector: i.spreadSelector(),
+		TopologyKey:   hostnameTopologyKey,
+	}
+	if *i.podAntiAffinity {
+		return &v1.Affinity{
+			PodAntiAffinity: &v1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{term},
+			},
+		}
+	}
+	return &v1.Affinity{
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+				{
+					Weight:          100,
+					PodAffinityTerm: term,
+				},
+			},
+		},
+	}
+}
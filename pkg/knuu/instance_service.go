@@ -0,0 +1,152 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maxSessionAffinityTimeoutSeconds is the maximum timeout of ClientIP session affinity accepted by Kubernetes
+const maxSessionAffinityTimeoutSeconds = 86400
+
+// SetServiceSessionAffinity makes the instance's service route all connections of a client IP to the same pod
+// The affinity expires after the given number of seconds without traffic, zero uses the Kubernetes default of three hours
+// In the state 'Started', the service is updated if it is deployed
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetServiceSessionAffinity(clientIPTimeoutSeconds int) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting service session affinity", Preparing, Committed, Started)
+	}
+	if clientIPTimeoutSeconds < 0 || clientIPTimeoutSeconds > maxSessionAffinityTimeoutSeconds {
+		return fmt.Errorf("session affinity timeout must be between 0 and %d seconds, got '%d'", maxSessionAffinityTimeoutSeconds, clientIPTimeoutSeconds)
+	}
+	i.serviceOptions.SessionAffinity = v1.ServiceAffinityClientIP
+	i.serviceOptions.SessionAffinityTimeoutSeconds = nil
+	if clientIPTimeoutSeconds != 0 {
+		timeoutSeconds := int32(clientIPTimeoutSeconds)
+		i.serviceOptions.SessionAffinityTimeoutSeconds = &timeoutSeconds
+	}
+	i.logger().Debugf("Set service session affinity with timeout '%d' in instance '%s'", clientIPTimeoutSeconds, i.name)
+	return i.updateServiceOptions()
+}
+
+// SetSessionAffinity sets the session affinity of the instance's service, 'None' or 'ClientIP'
+// The timeout in seconds can only be set for 'ClientIP', nil uses the Kubernetes default of three hours
+// In the state 'Started', the service is updated if it is deployed
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetSessionAffinity(affinity v1.ServiceAffinity, timeoutSeconds *int32) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting session affinity", Preparing, Committed, Started)
+	}
+	switch affinity {
+	case v1.ServiceAffinityNone, v1.ServiceAffinityClientIP:
+	default:
+		return fmt.Errorf("unknown session affinity '%s'", affinity)
+	}
+	if timeoutSeconds != nil {
+		if affinity != v1.ServiceAffinityClientIP {
+			return fmt.Errorf("session affinity timeout can only be set for session affinity '%s'", v1.ServiceAffinityClientIP)
+		}
+		if *timeoutSeconds <= 0 || *timeoutSeconds > maxSessionAffinityTimeoutSeconds {
+			return fmt.Errorf("session affinity timeout must be between 1 and %d seconds, got '%d'", maxSessionAffinityTimeoutSeconds, *timeoutSeconds)
+		}
+		timeout := *timeoutSeconds
+		timeoutSeconds = &timeout
+	}
+	i.serviceOptions.SessionAffinity = affinity
+	i.serviceOptions.SessionAffinityTimeoutSeconds = timeoutSeconds
+	i.logger().Debugf("Set session affinity to '%s' in instance '%s'", affinity, i.name)
+	return i.updateServiceOptions()
+}
+
+// SetServiceTrafficPolicy sets the internal and external traffic policy of the instance's service, each 'Cluster' or 'Local'
+// An empty policy keeps the Kubernetes default 'Cluster'
+// The external traffic policy 'Local' requires a NodePort or LoadBalancer service, see SetServiceType
+// In the state 'Started', the service is updated if it is deployed
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetServiceTrafficPolicy(internal, external string) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting service traffic policy", Preparing, Committed, Started)
+	}
+	switch v1.ServiceInternalTrafficPolicyType(internal) {
+	case "", v1.ServiceInternalTrafficPolicyCluster, v1.ServiceInternalTrafficPolicyLocal:
+	default:
+		return fmt.Errorf("unknown internal traffic policy '%s'", internal)
+	}
+	switch v1.ServiceExternalTrafficPolicyType(external) {
+	case "", v1.ServiceExternalTrafficPolicyTypeCluster, v1.ServiceExternalTrafficPolicyTypeLocal:
+	default:
+		return fmt.Errorf("unknown external traffic policy '%s'", external)
+	}
+	if external == string(v1.ServiceExternalTrafficPolicyTypeLocal) && i.serviceOptions.Type != v1.ServiceTypeNodePort && i.serviceOptions.Type != v1.ServiceTypeLoadBalancer {
+		return fmt.Errorf("external traffic policy '%s' requires service type '%s' or '%s', use SetServiceType first", external, v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer)
+	}
+	i.serviceOptions.InternalTrafficPolicy = v1.ServiceInternalTrafficPolicyType(internal)
+	i.serviceOptions.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyType(external)
+	i.logger().Debugf("Set service traffic policy to internal '%s' and external '%s' in instance '%s'", internal, external, i.name)
+	return i.updateServiceOptions()
+}
+
+// SetServiceType sets the type of the instance's service, 'ClusterIP' (the default), 'NodePort' or 'LoadBalancer'
+// In the state 'Started', the service is updated if it is deployed
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetServiceType(serviceType v1.ServiceType) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting service type", Preparing, Committed, Started)
+	}
+	switch serviceType {
+	case v1.ServiceTypeClusterIP:
+		if i.serviceOptions.ExternalTrafficPolicy != "" && i.serviceOptions.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeCluster {
+			return fmt.Errorf("service type '%s' does not support external traffic policy '%s'", serviceType, i.serviceOptions.ExternalTrafficPolicy)
+		}
+	case v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("unsupported service type '%s'", serviceType)
+	}
+	i.serviceOptions.Type = serviceType
+	i.logger().Debugf("Set service type to '%s' in instance '%s'", serviceType, i.name)
+	return i.updateServiceOptions()
+}
+
+// SetExternalTrafficPolicy sets the external traffic policy of the instance's service, 'Cluster' (the default) or 'Local'
+// 'Local' preserves the client source IP, but only routes to pods on the node that received the traffic
+// The service type must be set to 'NodePort' or 'LoadBalancer' with SetServiceType before
+// In the state 'Started', the service is updated if it is deployed
+// This function can only be called in the states 'Preparing', 'Committed' and 'Started'
+func (i *Instance) SetExternalTrafficPolicy(p v1.ServiceExternalTrafficPolicyType) error {
+	if !i.IsInState(Preparing, Committed, Started) {
+		return i.errInvalidStateTransition("setting external traffic policy", Preparing, Committed, Started)
+	}
+	switch p {
+	case v1.ServiceExternalTrafficPolicyTypeCluster, v1.ServiceExternalTrafficPolicyTypeLocal:
+	default:
+		return fmt.Errorf("unknown external traffic policy '%s'", p)
+	}
+	if i.serviceOptions.Type != v1.ServiceTypeNodePort && i.serviceOptions.Type != v1.ServiceTypeLoadBalancer {
+		return fmt.Errorf("external traffic policy requires service type '%s' or '%s', use SetServiceType first", v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer)
+	}
+	i.serviceOptions.ExternalTrafficPolicy = p
+	i.logger().Debugf("Set external traffic policy to '%s' in instance '%s'", p, i.name)
+	return i.updateServiceOptions()
+}
+
+// updateServiceOptions applies the service options to the service if the instance is started and its service is deployed
+func (i *Instance) updateServiceOptions() error {
+	if i.state != Started || i.kubernetesService == nil {
+		return nil
+	}
+	if err := i.patchService(); err != nil {
+		return fmt.Errorf("error updating service of instance '%s': %w", i.k8sName, err)
+	}
+	return nil
+}
+
+// cloneServiceOptions returns a copy of the service options
+func (i *Instance) cloneServiceOptions() k8s.ServiceOptions {
+	opts := i.serviceOptions
+	if opts.SessionAffinityTimeoutSeconds != nil {
+		timeoutSeconds := *opts.SessionAffinityTimeoutSeconds
+		opts.SessionAffinityTimeoutSeconds = &timeoutSeconds
+	}
+	return opts
+}
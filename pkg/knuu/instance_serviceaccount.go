@@ -0,0 +1,76 @@
+package knuu
+
+import (
+	"fmt"
+)
+
+// defaultServiceAccount is the service account Kubernetes creates in every namespace
+const defaultServiceAccount = "default"
+
+// SetServiceAccount sets the service account of the instance's pods
+// If create is true, the service account is created when the instance is started, unless it exists already,
+// and deleted when the last started instance using it is destroyed
+// Otherwise it must exist when the instance is started, instead of leaving its pods pending
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetServiceAccount(serviceAccount string, create bool) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting service account", Preparing, Committed)
+	}
+	if serviceAccount == "" {
+		return fmt.Errorf("service account of instance '%s' must not be empty", i.name)
+	}
+	if create && serviceAccount == defaultServiceAccount {
+		return fmt.Errorf("service account '%s' of instance '%s' cannot be created, as it is managed by Kubernetes", serviceAccount, i.name)
+	}
+	i.serviceAccountName = serviceAccount
+	i.createServiceAccount = create
+	i.logger().Debugf("Set service account to '%s' (create: %t) in instance '%s'", serviceAccount, create, i.name)
+	return nil
+}
+
+// deployServiceAccount creates the service account of the instance if it should be created, or checks that it exists
+// A created service account is shared by the clones of the instance, so it is only created if it does not exist yet
+func (i *Instance) deployServiceAccount() error {
+	if i.serviceAccountName == "" || i.serviceAccountName == defaultServiceAccount {
+		return nil
+	}
+	exists, err := i.k8sClient().ServiceAccountExists(i.serviceAccountName, i.namespace())
+	if err != nil {
+		return fmt.Errorf("failed to check service account '%s': %w", i.serviceAccountName, err)
+	}
+	if exists {
+		return nil
+	}
+	if !i.createServiceAccount {
+		return fmt.Errorf("service account '%s' does not exist, set it with create to create it", i.serviceAccountName)
+	}
+
+	if err := i.k8sClient().CreateServiceAccount(i.serviceAccountName, i.namespace(), i.session().labels()); err != nil {
+		return fmt.Errorf("error creating service account '%s': %w", i.serviceAccountName, err)
+	}
+	i.logger().Debugf("Created service account '%s'", i.serviceAccountName)
+	return nil
+}
+
+// destroyServiceAccount deletes the service account created for the instance, unless another deployed instance uses it
+func (i *Instance) destroyServiceAccount() error {
+	if !i.createServiceAccount {
+		return nil
+	}
+	k := i.session()
+	k.instancesMu.Lock()
+	instances := make([]*Instance, len(k.instances))
+	copy(instances, k.instances)
+	k.instancesMu.Unlock()
+	for _, instance := range instances {
+		if instance != i && instance.createServiceAccount && instance.serviceAccountName == i.serviceAccountName && instance.IsInState(Started, Stopped) {
+			return nil
+		}
+	}
+
+	if err := i.k8sClient().DeleteServiceAccount(i.serviceAccountName, i.namespace()); err != nil {
+		return fmt.Errorf("error deleting service account '%s': %w", i.serviceAccountName, err)
+	}
+	i.logger().Debugf("Deleted service account '%s'", i.serviceAccountName)
+	return nil
+}
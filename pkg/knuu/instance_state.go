@@ -1,5 +1,12 @@
 package knuu
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 // InstanceState represents the state of the instance
 type InstanceState int
 
@@ -30,3 +37,74 @@ func (i *Instance) IsInState(states ...InstanceState) bool {
 	}
 	return false
 }
+
+// ErrInvalidStateTransition is returned (wrapped in an InvalidStateTransitionError) if an operation is not allowed in the current state of the instance
+var ErrInvalidStateTransition = errors.New("invalid state transition")
+
+// InvalidStateTransitionError describes an operation that is not allowed in the current state of the instance
+type InvalidStateTransitionError struct {
+	Instance  string
+	Operation string
+	Current   InstanceState
+	Allowed   []InstanceState
+}
+
+// Error returns the error message, naming the states in which the operation would be allowed
+func (e *InvalidStateTransitionError) Error() string {
+	allowed := make([]string, len(e.Allowed))
+	for j, state := range e.Allowed {
+		allowed[j] = fmt.Sprintf("'%s'", state.String())
+	}
+	allowedText := strings.Join(allowed, " or ")
+	if len(allowed) > 2 {
+		allowedText = strings.Join(allowed[:len(allowed)-1], ", ") + " or " + allowed[len(allowed)-1]
+	}
+	return fmt.Sprintf("%s is only allowed in state %s. Current state of instance '%s' is '%s'", e.Operation, allowedText, e.Instance, e.Current.String())
+}
+
+// Is makes errors.Is(err, ErrInvalidStateTransition) true for all invalid state transitions
+func (e *InvalidStateTransitionError) Is(target error) bool {
+	return target == ErrInvalidStateTransition
+}
+
+// StateTransition is a change of the state of an instance
+type StateTransition struct {
+	From InstanceState
+	To   InstanceState
+	Time time.Time
+}
+
+// CurrentState returns the current state of the instance
+func (i *Instance) CurrentState() InstanceState {
+	return i.state
+}
+
+// StateHistory returns all state changes of the instance, in the order they happened
+func (i *Instance) StateHistory() []StateTransition {
+	i.stateHistoryMu.Lock()
+	defer i.stateHistoryMu.Unlock()
+	return append([]StateTransition(nil), i.stateHistory...)
+}
+
+// setState changes the state of the instance and records the change in the state history
+func (i *Instance) setState(state InstanceState) {
+	i.stateHistoryMu.Lock()
+	i.stateHistory = append(i.stateHistory, StateTransition{
+		From: i.state,
+		To:   state,
+		Time: time.Now(),
+	})
+	i.stateHistoryMu.Unlock()
+	i.state = state
+	i.logger().Debugf("Set state of instance '%s' to '%s'", i.name, i.state.String())
+}
+
+// errInvalidStateTransition returns the error for an operation that is only allowed in the given states
+func (i *Instance) errInvalidStateTransition(operation string, allowed ...InstanceState) error {
+	return &InvalidStateTransitionError{
+		Instance:  i.name,
+		Operation: operation,
+		Current:   i.state,
+		Allowed:   allowed,
+	}
+}
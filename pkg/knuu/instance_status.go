@@ -0,0 +1,220 @@
+package knuu
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
+// InstanceStatus is the status of the pods of an instance
+type InstanceStatus struct {
+	// Pods are the statuses of the instance's pods, one per replica, sorted by name
+	Pods []PodStatus
+}
+
+// PodStatus is the status of a pod of an instance
+type PodStatus struct {
+	Name  string
+	Phase v1.PodPhase
+	// Ready is true if the pod's Ready condition is true
+	Ready bool
+	// StartTime is when the pod was acknowledged by its node, or the zero time if it is not scheduled yet
+	StartTime time.Time
+	// Containers are the statuses of the pod's init containers followed by its containers
+	Containers []ContainerStatus
+}
+
+// ContainerStatus is the status of a container of a pod
+type ContainerStatus struct {
+	Name         string
+	Init         bool
+	Ready        bool
+	RestartCount int32
+	// WaitingReason is why the container is waiting, e.g. CrashLoopBackOff, or empty if it is not waiting
+	WaitingReason string
+	// LastTerminationReason and LastExitCode describe the last termination of the container, e.g. OOMKilled and 137
+	// They are empty if the container never terminated
+	LastTerminationReason string
+	LastExitCode          int32
+}
+
+// RestartCount returns the sum of the restart counts of all containers of the instance's pods
+func (s InstanceStatus) RestartCount() int32 {
+	var restarts int32
+	for _, pod := range s.Pods {
+		restarts += pod.RestartCount()
+	}
+	return restarts
+}
+
+// Ready checks if the instance has pods and all of them are ready
+func (s InstanceStatus) Ready() bool {
+	if len(s.Pods) == 0 {
+		return false
+	}
+	for _, pod := range s.Pods {
+		if !pod.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// RestartCount returns the sum of the restart counts of all containers of the pod
+func (s PodStatus) RestartCount() int32 {
+	var restarts int32
+	for _, container := range s.Containers {
+		restarts += container.RestartCount
+	}
+	return restarts
+}
+
+// Status returns the status of the instance's pods, retrieved from the pods selected by its statefulSet
+// The pods do not need to be running, e.g. the status of pending pods or of containers in CrashLoopBackOff is returned as well
+// This function can only be called in the states 'Started' and 'Stopped'
+func (i *Instance) Status() (InstanceStatus, error) {
+	if !i.IsInState(Started, Stopped) {
+		return InstanceStatus{}, i.errInvalidStateTransition("getting status", Started, Stopped)
+	}
+	var pods []v1.Pod
+	err := i.session().retryAPICall(fmt.Sprintf("listing pods of statefulSet '%s'", i.k8sName), func() error {
+		var err error
+		pods, err = i.k8sClient().ListStatefulSetPods(i.namespace(), i.k8sName)
+		return err
+	})
+	if err != nil {
+		return InstanceStatus{}, fmt.Errorf("error getting status of instance '%s': %w", i.k8sName, err)
+	}
+
+	status := InstanceStatus{
+		Pods: make([]PodStatus, 0, len(pods)),
+	}
+	for _, pod := range pods {
+		status.Pods = append(status.Pods, podStatus(pod))
+	}
+	return status, nil
+}
+
+// Status returns the statuses of all instances in the instance pool, in the order of the instances
+// The pods of all instances are listed with one request
+func (i *InstancePool) Status() ([]InstanceStatus, error) {
+	for _, instance := range i.instances {
+		if !instance.IsInState(Started, Stopped) {
+			return nil, instance.errInvalidStateTransition("getting status", Started, Stopped)
+		}
+	}
+	byInstance, err := statusesOf(i.instances)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]InstanceStatus, 0, len(i.instances))
+	for _, instance := range i.instances {
+		statuses = append(statuses, byInstance[instance])
+	}
+	return statuses, nil
+}
+
+// statusesOf returns the statuses of the instances in the states 'Started' and 'Stopped' like Status, by instance
+// Instead of requests for every instance, the pods of all instances of a session are listed with one request by the
+// 'test-run-id' label, and assigned to the instances by their 'k8s-name' label
+func statusesOf(instances []*Instance) (map[*Instance]InstanceStatus, error) {
+	bySession := make(map[*Knuu][]*Instance)
+	for _, instance := range instances {
+		if instance.IsInState(Started, Stopped) && !instance.isExternal() {
+			bySession[instance.session()] = append(bySession[instance.session()], instance)
+		}
+	}
+
+	statuses := make(map[*Instance]InstanceStatus, len(instances))
+	for session, sessionInstances := range bySession {
+		var pods []v1.Pod
+		err := session.retryAPICall(fmt.Sprintf("listing pods of test run '%s'", session.Identifier()), func() error {
+			var err error
+			pods, err = session.client().ListPods(session.Namespace(), map[string]string{"test-run-id": session.Identifier()})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting status of instances: %w", err)
+		}
+		podsByK8sName := make(map[string][]v1.Pod)
+		for _, pod := range pods {
+			podsByK8sName[pod.Labels["k8s-name"]] = append(podsByK8sName[pod.Labels["k8s-name"]], pod)
+		}
+		for _, instance := range sessionInstances {
+			instancePods := podsByK8sName[instance.k8sName]
+			status := InstanceStatus{
+				Pods: make([]PodStatus, 0, len(instancePods)),
+			}
+			for _, pod := range instancePods {
+				status.Pods = append(status.Pods, podStatus(pod))
+			}
+			statuses[instance] = status
+		}
+	}
+	return statuses, nil
+}
+
+// testRunPod returns the pod with the name from the pods of the session's test run, or nil if it does not exist
+// The pods are listed with one request by the 'test-run-id' label at most once per podFailureCheckInterval, and the list is
+// shared by all callers, so that the waits of many instances that are started at once do not send a request each
+func (k *Knuu) testRunPod(name string) (*v1.Pod, error) {
+	k.testRunPodsMu.Lock()
+	defer k.testRunPodsMu.Unlock()
+	if time.Since(k.testRunPodsListed) >= podFailureCheckInterval {
+		pods, err := k.client().ListPods(k.Namespace(), map[string]string{"test-run-id": k.Identifier()})
+		if err != nil {
+			return nil, fmt.Errorf("error listing pods of test run '%s': %w", k.Identifier(), err)
+		}
+		k.testRunPods = make(map[string]v1.Pod, len(pods))
+		for _, pod := range pods {
+			k.testRunPods[pod.Name] = pod
+		}
+		k.testRunPodsListed = time.Now()
+	}
+	pod, ok := k.testRunPods[name]
+	if !ok {
+		return nil, nil
+	}
+	return &pod, nil
+}
+
+// podStatus returns the status of a pod
+func podStatus(pod v1.Pod) PodStatus {
+	status := PodStatus{
+		Name:  pod.Name,
+		Phase: pod.Status.Phase,
+	}
+	if pod.Status.StartTime != nil {
+		status.StartTime = pod.Status.StartTime.Time
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			status.Ready = condition.Status == v1.ConditionTrue
+		}
+	}
+
+	containerStatuses := make([]v1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	containerStatuses = append(append(containerStatuses, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for j, containerStatus := range containerStatuses {
+		container := ContainerStatus{
+			Name:         containerStatus.Name,
+			Init:         j < len(pod.Status.InitContainerStatuses),
+			Ready:        containerStatus.Ready,
+			RestartCount: containerStatus.RestartCount,
+		}
+		if waiting := containerStatus.State.Waiting; waiting != nil {
+			container.WaitingReason = waiting.Reason
+		}
+		// A container that terminated and was not restarted yet only has a current termination
+		terminated := containerStatus.LastTerminationState.Terminated
+		if terminated == nil {
+			terminated = containerStatus.State.Terminated
+		}
+		if terminated != nil {
+			container.LastTerminationReason = terminated.Reason
+			container.LastExitCode = terminated.ExitCode
+		}
+		status.Containers = append(status.Containers, container)
+	}
+	return status
+}
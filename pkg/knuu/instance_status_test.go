@@ -0,0 +1,153 @@
+package knuu
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTopologySize is the number of instances deployed by the tests of the requests sent for large topologies
+const testTopologySize = 20
+
+// startTestPool starts a pool of testTopologySize instances with a TCP port in the session
+func startTestPool(t *testing.T, k *Knuu) *InstancePool {
+	t.Helper()
+	pool, err := newTestInstance(t, k, "node", 8080).CreatePool(testTopologySize)
+	if err != nil {
+		t.Fatalf("creating pool: %v", err)
+	}
+	if err := pool.Start(); err != nil {
+		t.Fatalf("starting pool: %v", err)
+	}
+	return pool
+}
+
+func TestCachedReadsSendNoRequests(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{CacheAPIReads: true})
+	pool := startTestPool(t, k)
+	testRun := map[string]string{"test-run-id": k.Identifier()}
+
+	// The cache is updated by a watch, so it may not contain the last pods yet
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		pods, err := k.client().ListPods(k.Namespace(), testRun)
+		if err != nil {
+			t.Fatalf("listing pods: %v", err)
+		}
+		if len(pods) == testTopologySize {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cache contains %d pods, expected %d", len(pods), testTopologySize)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cluster.requests.reset()
+	for _, instance := range pool.Instances() {
+		if _, err := k.client().ListPods(k.Namespace(), instance.getLabels()); err != nil {
+			t.Fatalf("listing pods of instance '%s': %v", instance.k8sName, err)
+		}
+		if _, err := k.client().GetServiceIP(k.Namespace(), instance.k8sName); err != nil {
+			t.Fatalf("getting IP of instance '%s': %v", instance.k8sName, err)
+		}
+		if _, err := instance.GetIP(); err != nil {
+			t.Fatalf("getting IP of instance '%s': %v", instance.k8sName, err)
+		}
+	}
+	if _, err := pool.Status(); err != nil {
+		t.Fatalf("getting status of pool: %v", err)
+	}
+	for _, request := range []string{"get pods", "list pods", "get services", "list services"} {
+		verb, resource, _ := strings.Cut(request, " ")
+		if n := cluster.requests.count(verb, resource); n != 0 {
+			t.Errorf("expected no '%s' requests with the cache, got %d", request, n)
+		}
+	}
+}
+
+func TestPoolStatusListsPodsOnce(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	pool := startTestPool(t, k)
+
+	cluster.requests.reset()
+	statuses, err := pool.Status()
+	if err != nil {
+		t.Fatalf("getting status of pool: %v", err)
+	}
+	for j, status := range statuses {
+		if !status.Ready() {
+			t.Errorf("instance %d is not ready: %+v", j, status)
+		}
+	}
+	if n := cluster.requests.count("list", "pods"); n != 1 {
+		t.Errorf("expected 1 request listing pods for the status of %d instances, got %d", testTopologySize, n)
+	}
+	if n := cluster.requests.count("get", "pods") + cluster.requests.count("get", "statefulsets"); n != 0 {
+		t.Errorf("expected no requests getting single objects, got %d", n)
+	}
+
+	cluster.requests.reset()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := WaitForQuorum(ctx, pool.Instances(), testTopologySize); err != nil {
+		t.Fatalf("waiting for quorum: %v", err)
+	}
+	if n := cluster.requests.count("list", "pods"); n != 1 {
+		t.Errorf("expected 1 request listing pods for one check of the quorum of %d instances, got %d", testTopologySize, n)
+	}
+	if n := cluster.requests.count("get", "pods"); n != 0 {
+		t.Errorf("expected no requests getting pods, got %d", n)
+	}
+}
+
+func TestTestRunPodIsSharedByWaits(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	pool := startTestPool(t, k)
+
+	// The failure checks of WaitInstanceIsRunning of all instances read their pods at the same time
+	cluster.requests.reset()
+	var wg sync.WaitGroup
+	errs := make([]error, testTopologySize)
+	for j, instance := range pool.Instances() {
+		wg.Add(1)
+		go func(j int, instance *Instance) {
+			defer wg.Done()
+			pod, err := k.testRunPod(instance.k8sName + "-0")
+			if err == nil && pod == nil {
+				t.Errorf("pod of instance '%s' not found", instance.k8sName)
+			}
+			errs[j] = err
+		}(j, instance)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("getting pod: %v", err)
+		}
+	}
+	if n := cluster.requests.count("list", "pods"); n != 1 {
+		t.Errorf("expected 1 request listing pods for %d instances, got %d", testTopologySize, n)
+	}
+	if n := cluster.requests.count("get", "pods"); n != 0 {
+		t.Errorf("expected no requests getting pods, got %d", n)
+	}
+}
+
+func TestDestroyDoesNotReadBeforeDeleting(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	pool := startTestPool(t, k)
+
+	cluster.requests.reset()
+	if err := pool.Destroy(); err != nil {
+		t.Fatalf("destroying pool: %v", err)
+	}
+	if n := cluster.requests.count("get", "statefulsets") + cluster.requests.count("get", "services"); n != 0 {
+		t.Errorf("expected no requests reading the objects that are deleted, got %d", n)
+	}
+	if n := cluster.requests.count("delete", "statefulsets"); n != testTopologySize {
+		t.Errorf("expected %d requests deleting statefulSets, got %d", testTopologySize, n)
+	}
+}
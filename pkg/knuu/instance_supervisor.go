@@ -0,0 +1,110 @@
+package knuu
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// supervisorPath is the path of the supervisor script in the image of an instance with multiple commands
+const supervisorPath = "/knuu/supervisor.sh"
+
+// supervisorScript starts all commands passed as arguments and stops all of them when one exits
+// For each command, the arguments are the number of its words followed by the words
+// The output of each command is prefixed with its index and the name of its executable, e.g. '[1:exporter]'
+// A signal sent to the supervisor is forwarded to all commands
+const supervisorScript = `#!/bin/sh
+# Supervisor generated by knuu, see Instance.SetCommands
+dir="/tmp/knuu-supervisor-$$"
+mkdir -p "$dir" && mkfifo "$dir/exit" || exit 1
+trap 'trap "" TERM INT; kill -TERM 0 2>/dev/null; wait; rm -rf "$dir"; exit 143' TERM INT
+
+index=0
+while [ "$#" -gt 0 ]; do
+  count=$1
+  shift
+  if [ "$count" -lt 1 ] || [ "$#" -lt "$count" ]; then
+    echo "[knuu] invalid supervisor arguments" >&2
+    exit 1
+  fi
+  prefix="[$index:$(basename "$1")]"
+  mkfifo "$dir/log-$index" || exit 1
+  # The prefixing ignores signals, so that it forwards the output until the command exits
+  (trap "" TERM INT; while IFS= read -r line; do printf '%s %s\n' "$prefix" "$line"; done < "$dir/log-$index") &
+  (
+    # Keep only the words of this command as arguments
+    total=$#
+    i=0
+    for arg do
+      if [ "$i" -lt "$count" ]; then set -- "$@" "$arg"; fi
+      i=$((i + 1))
+    done
+    shift "$total"
+    "$@" > "$dir/log-$index" 2>&1
+    code=$?
+    { echo "$index $code" > "$dir/exit"; } 2>/dev/null
+  ) &
+  shift "$count"
+  index=$((index + 1))
+done
+
+# Opening the pipe is interrupted if the supervisor is signaled, which the trap handles
+{ read -r exited code < "$dir/exit"; } 2>/dev/null
+echo "[knuu] command $exited exited with code $code, stopping all commands" >&2
+trap "" TERM INT
+kill -TERM 0 2>/dev/null
+wait
+rm -rf "$dir"
+if [ "$code" -eq 0 ]; then
+  code=1
+fi
+exit "$code"
+`
+
+// SetCommands runs multiple commands in the instance's container, instead of a single command with arguments
+// A supervisor script is added to the image, which starts all commands and forwards signals to them
+// If any command exits, the others are stopped and the container exits with a non-zero code
+// The output of each command is prefixed with its index and the name of its executable, e.g. '[1:exporter]', so that the logs are attributable
+// The placeholders {{.ServiceName}}, {{.Namespace}} and {{.PodName}} are expanded when the instance is deployed
+// It cannot be combined with SetCommand or SetArgs
+// This function can only be called in the state 'Preparing'
+func (i *Instance) SetCommands(cmds [][]string) error {
+	if !i.IsInState(Preparing) {
+		return i.errInvalidStateTransition("setting commands", Preparing)
+	}
+	if i.commands == nil && (len(i.command) != 0 || len(i.args) != 0) {
+		return fmt.Errorf("cannot set commands of instance '%s', as its command or args are set with SetCommand or SetArgs", i.name)
+	}
+	if len(cmds) == 0 {
+		return fmt.Errorf("at least one command is required")
+	}
+	var args []string
+	for j, cmd := range cmds {
+		if len(cmd) == 0 || cmd[0] == "" {
+			return fmt.Errorf("command %d of instance '%s' is empty", j, i.name)
+		}
+		if err := validateCommandTemplates(cmd); err != nil {
+			return fmt.Errorf("error setting command %d of instance '%s': %w", j, i.name, err)
+		}
+		args = append(append(args, strconv.Itoa(len(cmd))), cmd...)
+	}
+
+	// The supervisor is only added once, setting the commands again only changes its arguments
+	if i.commands == nil {
+		if err := i.AddFileBytes([]byte(supervisorScript), supervisorPath, "0:0"); err != nil {
+			return fmt.Errorf("error adding supervisor to instance '%s': %w", i.name, err)
+		}
+	}
+	i.commands = cmds
+	i.command = []string{"/bin/sh", supervisorPath}
+	i.args = args
+	i.logger().Debugf("Set %d supervised commands in instance '%s'", len(cmds), i.name)
+	return nil
+}
+
+// errCommandsSet returns an error if the commands of the instance are set with SetCommands
+func (i *Instance) errCommandsSet(operation string) error {
+	if i.commands == nil {
+		return nil
+	}
+	return fmt.Errorf("cannot %s of instance '%s', as it runs multiple commands set with SetCommands", operation, i.name)
+}
@@ -0,0 +1,224 @@
+package knuu
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// InstanceTemplate is a reusable instance configuration, which is configured with the setters of Instance (e.g. SetImage,
+// AddPortTCP, SetEnvironmentVariable, AddVolume, AddFile) and deployed as many instances as needed with Instantiate
+// A template is never committed nor started itself, and is not part of the instances of the session
+type InstanceTemplate struct {
+	*Instance
+}
+
+// NewInstanceTemplate creates a new instance template in the default session
+func NewInstanceTemplate(name string) (*InstanceTemplate, error) {
+	return defaultKnuu.NewInstanceTemplate(name)
+}
+
+// NewInstanceTemplate creates a new instance template in the session
+// The name is only used in logs and errors, the instances of the template are named by Instantiate
+func (k *Knuu) NewInstanceTemplate(name string) (*InstanceTemplate, error) {
+	instance, err := k.NewInstance(name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating template '%s': %w", name, err)
+	}
+	k.unregisterInstance(instance)
+	return &InstanceTemplate{Instance: instance}, nil
+}
+
+// Instantiate creates a new instance with the given name and its own k8s name, in the state of the template ('None' or
+// 'Preparing'), with a deep copy of the configuration of the template
+// Files added to the template are copied from the build directory of the template, which holds them with the content
+// they had when they were added, so changes to the source files and to the template after instantiation do not affect the instance
+// Instantiate can be called concurrently, but not concurrently with the setters of the template
+func (t *InstanceTemplate) Instantiate(name string) (*Instance, error) {
+	if !t.IsInState(None, Preparing) {
+		return nil, t.errInvalidStateTransition("instantiating template", None, Preparing)
+	}
+	instance, err := t.session().NewInstance(name)
+	if err != nil {
+		return nil, fmt.Errorf("error instantiating template '%s': %w", t.name, err)
+	}
+	if err := t.copyConfig(instance); err != nil {
+		t.session().unregisterInstance(instance)
+		instance.removeBuildDir()
+		return nil, fmt.Errorf("error instantiating template '%s' as '%s': %w", t.name, name, err)
+	}
+	t.logger().Debugf("Instantiated template '%s' as instance '%s'", t.name, instance.k8sName)
+	return instance, nil
+}
+
+// Close removes the build directory of the template, after which it cannot be instantiated anymore
+// Existing instances of the template are not affected
+func (t *InstanceTemplate) Close() error {
+	if err := t.removeBuildDir(); err != nil {
+		return err
+	}
+	t.setState(Destroyed)
+	return nil
+}
+
+// copyConfig copies the configuration of the template into the new instance, copying all slices, maps and pointers
+func (t *InstanceTemplate) copyConfig(instance *Instance) error {
+	i := t.Instance
+	if i.state == Preparing {
+		if err := instance.createBuildDir(); err != nil {
+			return err
+		}
+		if err := i.withBuildDirLock(func() error {
+			return copyDir(i.getBuildDir(), instance.getBuildDir())
+		}); err != nil {
+			return fmt.Errorf("error copying build directory: %w", err)
+		}
+		instance.builderFactory = i.builderFactory.Copy(instance.getBuildDir())
+		instance.setState(Preparing)
+	}
+
+	instance.instanceType = i.instanceType
+	instance.serviceOptions = i.cloneServiceOptions()
+	instance.portsTCP = append([]int(nil), i.portsTCP...)
+	instance.portsUDP = append([]int(nil), i.portsUDP...)
+	instance.portMappingsTCP, instance.portMappingsUDP = i.clonePortMappings()
+	instance.command = append([]string(nil), i.command...)
+	instance.args = append([]string(nil), i.args...)
+	for _, command := range i.commands {
+		instance.commands = append(instance.commands, append([]string(nil), command...))
+	}
+	instance.env = copyStringMap(i.env)
+	for _, volume := range i.volumes {
+		v := *volume
+		instance.volumes = append(instance.volumes, &v)
+	}
+	for _, volume := range i.hostPathVolumes {
+		v := *volume
+		if volume.Type != nil {
+			hostPathType := *volume.Type
+			v.Type = &hostPathType
+		}
+		instance.hostPathVolumes = append(instance.hostPathVolumes, &v)
+	}
+	for _, volume := range i.claimVolumes {
+		v := *volume
+		instance.claimVolumes = append(instance.claimVolumes, &v)
+	}
+	for _, volume := range i.sharedVolumes {
+		if err := volume.mount(instance); err != nil {
+			return err
+		}
+		instance.sharedVolumes = append(instance.sharedVolumes, volume)
+	}
+	instance.memoryRequest = i.memoryRequest
+	instance.memoryLimit = i.memoryLimit
+	instance.cpuRequest = i.cpuRequest
+	instance.cpuLimit = i.cpuLimit
+	instance.ephemeralStorageRequest = i.ephemeralStorageRequest
+	instance.ephemeralStorageLimit = i.ephemeralStorageLimit
+	instance.extendedResources = copyStringMap(i.extendedResources)
+	instance.serviceAccountName = i.serviceAccountName
+	instance.createServiceAccount = i.createServiceAccount
+	instance.priorityClassName = i.priorityClassName
+	instance.restartPolicy = i.restartPolicy
+	instance.dnsPolicy = i.dnsPolicy
+	instance.dnsConfig = i.dnsConfig.DeepCopy()
+	instance.replicas = i.replicas
+	instance.updateStrategy = *i.updateStrategy.DeepCopy()
+	if i.podDisruptionBudget != nil {
+		podDisruptionBudget := *i.podDisruptionBudget
+		instance.podDisruptionBudget = &podDisruptionBudget
+	}
+	instance.autoscaler = i.cloneAutoscaler()
+	instance.onNameCollision = i.onNameCollision
+	instance.fileChecksums = copyStringMap(i.fileChecksums)
+	for _, file := range i.files {
+		f := *file
+		f.content = append([]byte(nil), file.content...)
+		instance.files = append(instance.files, &f)
+	}
+	instance.imageEnv = copyStringMap(i.imageEnv)
+	instance.user = i.user
+	instance.dependencies = append([]*Instance(nil), i.dependencies...)
+	instance.metricsPort = i.metricsPort
+	instance.metricsPath = i.metricsPath
+	instance.observability = i.cloneObservability()
+	if i.logLevel != nil {
+		logLevel := *i.logLevel
+		instance.logLevel = &logLevel
+	}
+	if i.ingress != nil {
+		instance.ingress = &instanceIngress{host: i.ingress.host, port: i.ingress.port, opts: i.ingress.opts}
+	}
+	instance.lifetime = instanceLifetime{duration: i.lifetimeDuration()}
+	instance.topologySpreadKey = i.topologySpreadKey
+	instance.topologySpreadMaxSkew = i.topologySpreadMaxSkew
+	if i.podAntiAffinity != nil {
+		podAntiAffinity := *i.podAntiAffinity
+		instance.podAntiAffinity = &podAntiAffinity
+	}
+	if i.readinessCheck != nil {
+		readinessCheck := *i.readinessCheck
+		readinessCheck.Command = append([]string(nil), i.readinessCheck.Command...)
+		instance.readinessCheck = &readinessCheck
+	}
+	instance.capture = i.cloneCapture()
+	instance.keepBuildDir = i.keepBuildDir
+	instance.timeOffset = i.timeOffset
+	instance.faketimeInstalled = i.faketimeInstalled
+	instance.envFromInstances = i.cloneEnvFromInstances()
+	instance.roleRules = i.cloneRoleRules()
+	instance.clusterRoles = append([]string(nil), i.clusterRoles...)
+	instance.hostNetwork = i.hostNetwork
+	instance.shareProcessNamespace = i.shareProcessNamespace
+	instance.podAnnotations = copyStringMap(i.podAnnotations)
+	instance.hostPortsTCP = i.cloneHostPortsTCP()
+	instance.retainVolume = i.retainVolume
+	instance.adoptedVolume = i.adoptedVolume
+	return nil
+}
+
+// copyStringMap returns a copy of the map, nil if the map is nil
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
+
+// copyDir copies the files and directories in src into the existing directory dest
+func copyDir(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(dest, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(destPath, info.Mode().Perm())
+		}
+
+		srcFile, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer srcFile.Close()
+		destFile, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(destFile, srcFile); err != nil {
+			destFile.Close()
+			return err
+		}
+		return destFile.Close()
+	})
+}
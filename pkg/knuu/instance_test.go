@@ -0,0 +1,125 @@
+package knuu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestVolumeSizesAreValidated(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "volumes", 8080)
+
+	for _, size := range []string{"", "1 GB", "lots", "-1Gi", "0"} {
+		if err := instance.AddVolume("/data", size); err == nil {
+			t.Errorf("expected AddVolume to fail for size '%s'", size)
+		}
+		if err := instance.AddVolumeWithOwner("/data", size, 1000); err == nil {
+			t.Errorf("expected AddVolumeWithOwner to fail for size '%s'", size)
+		}
+		if err := instance.AddVolumeWithSubPath("/data", "data", size, 1000); err == nil {
+			t.Errorf("expected AddVolumeWithSubPath to fail for size '%s'", size)
+		}
+	}
+	if len(instance.volumes) != 0 {
+		t.Fatalf("expected no volumes to be added, got %d", len(instance.volumes))
+	}
+
+	if err := instance.AddVolume("/data", "1Gi"); err != nil {
+		t.Fatalf("adding volume: %v", err)
+	}
+	if err := instance.Start(); err != nil {
+		t.Fatalf("starting instance: %v", err)
+	}
+	for _, size := range []string{"lots", "512Mi", "1Gi"} {
+		if err := instance.ExpandVolume("/data", size); err == nil {
+			t.Errorf("expected ExpandVolume to fail for size '%s'", size)
+		}
+	}
+	if err := instance.ExpandVolume("/other", "2Gi"); err == nil {
+		t.Errorf("expected ExpandVolume to fail for a path without volume")
+	}
+}
+
+func TestEventObjectNamesIncludeAllReplicas(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "replicated", 8080)
+	if err := instance.SetReplicas(3); err != nil {
+		t.Fatalf("setting replicas: %v", err)
+	}
+	names := instance.eventObjectNames()
+	expected := []string{instance.k8sName, instance.k8sName + "-0", instance.k8sName + "-1", instance.k8sName + "-2"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events of %v, got %v", expected, names)
+	}
+}
+
+func TestServiceTrafficPolicyRequiresExternalServiceType(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "traffic", 8080)
+
+	if err := instance.SetServiceTrafficPolicy("Local", "Local"); err == nil {
+		t.Fatal("expected external traffic policy 'Local' to be rejected for a ClusterIP service")
+	}
+	if instance.serviceOptions.InternalTrafficPolicy != "" || instance.serviceOptions.ExternalTrafficPolicy != "" {
+		t.Errorf("expected a rejected traffic policy not to be recorded, got %+v", instance.serviceOptions)
+	}
+	if err := instance.SetServiceTrafficPolicy("Local", "Cluster"); err != nil {
+		t.Errorf("setting external traffic policy 'Cluster' for a ClusterIP service: %v", err)
+	}
+
+	if err := instance.SetServiceType(v1.ServiceTypeNodePort); err != nil {
+		t.Fatalf("setting service type: %v", err)
+	}
+	if err := instance.SetServiceTrafficPolicy("Local", "Local"); err != nil {
+		t.Errorf("setting external traffic policy 'Local' for a NodePort service: %v", err)
+	}
+}
+
+func TestPortsAreValidated(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "ports")
+
+	for _, port := range []int{-1, 0, 65536} {
+		if err := instance.AddPortTCP(port); err == nil {
+			t.Errorf("expected AddPortTCP to fail for port '%d'", port)
+		}
+		if err := instance.AddPortUDP(port); err == nil {
+			t.Errorf("expected AddPortUDP to fail for port '%d'", port)
+		}
+	}
+	if len(instance.portsTCP) != 0 || len(instance.portsUDP) != 0 {
+		t.Errorf("expected invalid ports not to be registered, got TCP %v and UDP %v", instance.portsTCP, instance.portsUDP)
+	}
+}
+
+func TestFileArgsAreValidated(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	instance, err := k.NewInstance("files")
+	if err != nil {
+		t.Fatalf("creating instance: %v", err)
+	}
+	if err := instance.SetImage(testImage); err != nil {
+		t.Fatalf("setting image: %v", err)
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(src, []byte("moniker = 'test'"), 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	for _, chown := range []string{"", "root", "0:0:0"} {
+		if err := instance.AddFile(src, "/etc/config.toml", chown); err == nil {
+			t.Errorf("expected AddFile to fail for chown '%s'", chown)
+		}
+		if err := instance.AddFolder(dir, "/etc/config", chown); err == nil {
+			t.Errorf("expected AddFolder to fail for chown '%s'", chown)
+		}
+	}
+	if len(instance.files) != 0 || len(instance.fileChecksums) != 0 {
+		t.Errorf("expected invalid files not to be recorded, got %d files and %d checksums", len(instance.files), len(instance.fileChecksums))
+	}
+}
@@ -0,0 +1,95 @@
+package knuu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// faketimeLibPath is the path libfaketime is linked to in the image of an instance with a time offset
+const faketimeLibPath = "/usr/lib/knuu/libfaketime.so.1"
+
+// faketimeInstallCommand installs libfaketime with the package manager of the image and links it to faketimeLibPath
+const faketimeInstallCommand = `set -e; ` +
+	`if command -v apt-get >/dev/null 2>&1; then apt-get update && apt-get install -y --no-install-recommends libfaketime && rm -rf /var/lib/apt/lists/*; ` +
+	`elif command -v apk >/dev/null 2>&1; then apk add --no-cache libfaketime; ` +
+	`else echo "knuu: cannot install libfaketime, the image has neither apt-get nor apk" >&2; exit 1; fi; ` +
+	`lib=$(find / -name libfaketime.so.1 -not -path "/proc/*" 2>/dev/null | head -n 1); ` +
+	`test -n "$lib"; mkdir -p "$(dirname ` + faketimeLibPath + `)"; ln -sf "$lib" ` + faketimeLibPath
+
+// maxTimeOffsetTolerance is the maximum difference between the expected and the actual clock of an instance with a time offset
+const maxTimeOffsetTolerance = 30 * time.Second
+
+// SetTimeOffset runs the instance with its clock shifted by the offset, e.g. minutes ahead or behind
+// libfaketime is installed in the image with its package manager (apt-get or apk) and preloaded with LD_PRELOAD,
+// so the image must have a shell and the user must be root when it is called, i.e. it must be called before SetUser
+// Monotonic clocks are not shifted, so that timeouts are not affected
+// Start verifies that the clock of the instance is shifted and fails if libfaketime cannot be preloaded,
+// e.g. because the main process is statically linked
+// An offset of zero removes the time offset, but libfaketime stays installed
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) SetTimeOffset(offset time.Duration) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("setting time offset", Preparing, Committed)
+	}
+	if offset != 0 && !i.faketimeInstalled {
+		if i.state != Preparing {
+			return fmt.Errorf("time offset of instance '%s' must first be set in state 'Preparing', as libfaketime is installed in its image", i.name)
+		}
+		if _, err := i.builderFactory.ExecuteCmdInBuilder([]string{faketimeInstallCommand}); err != nil {
+			return fmt.Errorf("error installing libfaketime in instance '%s': %w", i.name, err)
+		}
+		i.faketimeInstalled = true
+	}
+
+	i.timeOffset = offset
+	if offset == 0 {
+		delete(i.env, "LD_PRELOAD")
+		delete(i.env, "FAKETIME")
+		delete(i.env, "FAKETIME_DONT_FAKE_MONOTONIC")
+	} else {
+		i.env["LD_PRELOAD"] = faketimeLibPath
+		// A relative offset in seconds, e.g. '+300' or '-120'
+		i.env["FAKETIME"] = fmt.Sprintf("%+d", int64(offset.Round(time.Second)/time.Second))
+		i.env["FAKETIME_DONT_FAKE_MONOTONIC"] = "1"
+	}
+	i.logger().Debugf("Set time offset of instance '%s' to '%s'", i.name, offset)
+	return nil
+}
+
+// verifyTimeOffset checks that the clock of the started instance is shifted by its time offset
+// It checks the clock inside the container and that the main process preloaded libfaketime
+func (i *Instance) verifyTimeOffset() error {
+	if i.timeOffset == 0 {
+		return nil
+	}
+	output, err := i.ExecuteCommand("date", "+%s")
+	if err != nil {
+		return fmt.Errorf("error checking time offset of instance '%s', libfaketime may be incompatible with the image (e.g. musl and glibc mixed): %w", i.k8sName, err)
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return fmt.Errorf("error parsing time '%s' of instance '%s': %w", strings.TrimSpace(output), i.k8sName, err)
+	}
+
+	tolerance := i.timeOffset.Abs() / 2
+	if tolerance > maxTimeOffsetTolerance {
+		tolerance = maxTimeOffsetTolerance
+	}
+	expected := time.Now().Add(i.timeOffset)
+	if diff := time.Unix(seconds, 0).Sub(expected).Abs(); diff > tolerance {
+		return fmt.Errorf("time offset of instance '%s' did not take effect, its clock differs by '%s' from the expected time", i.k8sName, diff)
+	}
+
+	// A statically linked main process ignores LD_PRELOAD, even if other processes in the container are shifted
+	maps, err := i.ExecuteCommand("cat", "/proc/1/maps")
+	if err != nil {
+		return fmt.Errorf("error checking if the main process of instance '%s' loaded libfaketime: %w", i.k8sName, err)
+	}
+	if !strings.Contains(maps, "libfaketime") {
+		return fmt.Errorf("main process of instance '%s' did not load libfaketime, e.g. because it is statically linked, so its clock is not shifted", i.k8sName)
+	}
+	i.logger().Debugf("Verified time offset '%s' of instance '%s'", i.timeOffset, i.k8sName)
+	return nil
+}
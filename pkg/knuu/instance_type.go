@@ -7,6 +7,8 @@ type InstanceType int
 const (
 	BasicInstance InstanceType = iota
 	ExecutorInstance
+	// ExternalInstance is a dependency that is not deployed by knuu, see NewExternalInstance
+	ExternalInstance
 )
 
 // String returns the string representation of the type
@@ -14,5 +16,5 @@ func (s InstanceType) String() string {
 	if s < 0 || s > 2 {
 		return "Unknown"
 	}
-	return [...]string{"BasicInstance", "ExecutorInstance"}[s]
+	return [...]string{"BasicInstance", "ExecutorInstance", "ExternalInstance"}[s]
 }
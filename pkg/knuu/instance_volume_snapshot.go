@@ -0,0 +1,190 @@
+package knuu
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VolumeSnapshot is the content of a volume of an instance at a point in time, see SnapshotVolume
+type VolumeSnapshot struct {
+	// Path is the path of the volume the snapshot was taken of
+	Path string
+	// File is the tar archive of the content of the volume on the local disk
+	File string
+	// Digest is the digest of the archive ('sha256:<hex>'), which is verified before the snapshot is restored
+	Digest string
+}
+
+// Delete removes the archive of the snapshot from the local disk
+func (s *VolumeSnapshot) Delete() error {
+	if err := os.Remove(s.File); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting volume snapshot '%s': %w", s.File, err)
+	}
+	return nil
+}
+
+// SnapshotVolume archives the content of the volume at the path of the instance's first pod into a file on the local disk,
+// e.g. to restore the data of one test phase into several instances of the next phase with RestoreVolume
+// The archive is created with 'tar' in the instance, so the instance's image needs to contain it, and the volume should not be
+// written to while the snapshot is taken; the file is kept until Delete is called
+// The archive is streamed to the file until the context is done, so volumes of any size can be snapshotted
+// This function can only be called in the state 'Started'
+func (i *Instance) SnapshotVolume(ctx context.Context, path string) (*VolumeSnapshot, error) {
+	if !i.IsInState(Started) {
+		return nil, i.errInvalidStateTransition("snapshotting volume", Started)
+	}
+	if i.volume(path) == nil {
+		return nil, fmt.Errorf("no volume at path '%s' in instance '%s'", path, i.name)
+	}
+	pod, err := i.k8sClient().GetFirstPodFromStatefulSet(i.namespace(), i.k8sName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting pod from statefulset '%s': %w", i.k8sName, err)
+	}
+
+	file, err := os.CreateTemp("", "knuu-volume-snapshot-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("error creating file of volume snapshot: %w", err)
+	}
+	digest, err := streamArchive(ctx, i.k8sClient(), i.namespace(), pod.Name, i.k8sName, path, file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error writing volume snapshot '%s': %w", file.Name(), closeErr)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("error archiving volume '%s' in pod '%s': %w", path, pod.Name, err)
+	}
+
+	snapshot := &VolumeSnapshot{
+		Path:   path,
+		File:   file.Name(),
+		Digest: digest,
+	}
+	i.logger().Debugf("Snapshotted volume '%s' of instance '%s' to '%s' with digest '%s'", path, i.name, snapshot.File, snapshot.Digest)
+	return snapshot, nil
+}
+
+// streamArchive streams a tar archive of the directory in the container of the pod to the writer, and returns its digest
+// ('sha256:<hex>'), which is computed while the archive is written
+func streamArchive(ctx context.Context, client *k8s.Client, namespace, podName, containerName, dir string, out io.Writer) (string, error) {
+	hash := sha256.New()
+	if err := client.StreamCommandInPod(ctx, namespace, podName, containerName, []string{"tar", "cf", "-", "-C", dir, "."}, io.MultiWriter(out, hash)); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// RestoreVolume adds the content of the snapshot to the volume at the path of the instance, owned by the owner of the volume,
+// so that the volume contains it when the instance is started the first time
+// The volume must be added before, and the digest of the snapshot is verified, so that a modified archive is never restored
+// This function can only be called in the state 'Preparing'
+func (i *Instance) RestoreVolume(snapshot *VolumeSnapshot, path string) error {
+	if !i.IsInState(Preparing) {
+		return i.errInvalidStateTransition("restoring volume", Preparing)
+	}
+	if snapshot == nil {
+		return fmt.Errorf("volume snapshot must not be nil")
+	}
+	volume := i.volume(path)
+	if volume == nil {
+		return fmt.Errorf("no volume at path '%s' in instance '%s'", path, i.name)
+	}
+	if err := snapshot.verify(); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "knuu-volume-restore-")
+	if err != nil {
+		return fmt.Errorf("error creating directory to restore volume snapshot: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := snapshot.extract(dir); err != nil {
+		return err
+	}
+	chown := fmt.Sprintf("%d:%d", volume.Owner, volume.Owner)
+	if err := i.AddFolder(dir, path, chown); err != nil {
+		return fmt.Errorf("error restoring volume snapshot '%s' to '%s' in instance '%s': %w", snapshot.File, path, i.name, err)
+	}
+	i.logger().Debugf("Restored volume snapshot '%s' to volume '%s' of instance '%s'", snapshot.Digest, path, i.name)
+	return nil
+}
+
+// verify checks that the digest of the archive of the snapshot matches the digest of the snapshot
+func (s *VolumeSnapshot) verify() error {
+	file, err := os.Open(s.File)
+	if err != nil {
+		return fmt.Errorf("error opening volume snapshot '%s': %w", s.File, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("error reading volume snapshot '%s': %w", s.File, err)
+	}
+	if digest := "sha256:" + hex.EncodeToString(hash.Sum(nil)); digest != s.Digest {
+		return fmt.Errorf("digest of volume snapshot '%s' is '%s', expected '%s'", s.File, digest, s.Digest)
+	}
+	return nil
+}
+
+// extract extracts the directories and regular files of the archive of the snapshot to the directory
+// Other entries, e.g. symlinks, are skipped, and entries outside of the directory are rejected
+func (s *VolumeSnapshot) extract(dir string) error {
+	file, err := os.Open(s.File)
+	if err != nil {
+		return fmt.Errorf("error opening volume snapshot '%s': %w", s.File, err)
+	}
+	defer file.Close()
+
+	tarReader := tar.NewReader(file)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading volume snapshot '%s': %w", s.File, err)
+		}
+		name := filepath.Clean(header.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("volume snapshot '%s' contains the path '%s' outside of the volume", s.File, header.Name)
+		}
+		path := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("error creating directory '%s': %w", path, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("error creating directory of '%s': %w", path, err)
+			}
+			out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return fmt.Errorf("error creating file '%s': %w", path, err)
+			}
+			_, err = io.Copy(out, tarReader)
+			out.Close()
+			if err != nil {
+				return fmt.Errorf("error writing file '%s': %w", path, err)
+			}
+		}
+	}
+}
+
+// volume returns the volume of the instance at the path, or nil if there is none
+func (i *Instance) volume(path string) *k8s.Volume {
+	for _, volume := range i.volumes {
+		if volume.Path == path {
+			return volume
+		}
+	}
+	return nil
+}
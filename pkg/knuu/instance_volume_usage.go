@@ -0,0 +1,107 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"strconv"
+	"strings"
+)
+
+// ErrVolumeExpansionNotSupported is returned by ResizeVolume and ExpandVolume if the storage class of the volume does not allow expansion
+var ErrVolumeExpansionNotSupported = k8s.ErrVolumeExpansionNotSupported
+
+// VolumeUsage is the disk usage of the filesystem a volume of an instance is mounted from
+type VolumeUsage struct {
+	// Path is the path the volume is mounted at
+	Path string
+	// Capacity is the size of the filesystem in bytes
+	Capacity int64
+	// Used is the number of bytes used on the filesystem
+	Used int64
+	// Available is the number of bytes available to the instance on the filesystem
+	Available int64
+	// UsedPercent is the percentage of the filesystem that is used, as reported by df
+	UsedPercent float64
+	// ClaimCapacity is the capacity of the persistent volume claim of the volume in bytes
+	ClaimCapacity int64
+}
+
+// GetVolumeUsage returns the disk usage of the volumes of the instance, as reported by df in the first pod of the instance,
+// so the image must provide df (e.g. from busybox or coreutils)
+// All volumes of the instance share one persistent volume claim, so they report the usage of the same filesystem
+// This function can only be called in the state 'Started'
+func (i *Instance) GetVolumeUsage() ([]VolumeUsage, error) {
+	if !i.IsInState(Started) {
+		return nil, i.errInvalidStateTransition("getting volume usage", Started)
+	}
+	if len(i.volumes) == 0 {
+		return nil, nil
+	}
+
+	claimCapacity, err := i.k8sClient().GetPersistentVolumeClaimCapacity(i.namespace(), i.volumeClaimName())
+	if err != nil {
+		return nil, fmt.Errorf("error getting capacity of volume of instance '%s': %w", i.k8sName, err)
+	}
+
+	command := []string{"df", "-P", "-k"}
+	for _, volume := range i.volumes {
+		command = append(command, volume.Path)
+	}
+	output, err := i.ExecuteCommand(command...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting volume usage of instance '%s': %w", i.k8sName, err)
+	}
+
+	usages, err := parseDiskUsage(output, len(i.volumes))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing volume usage of instance '%s': %w", i.k8sName, err)
+	}
+	for j, volume := range i.volumes {
+		usages[j].Path = volume.Path
+		usages[j].ClaimCapacity = claimCapacity.Value()
+	}
+	return usages, nil
+}
+
+// ResizeVolume changes the size of the volume mounted at the given path, see ExpandVolume
+// Volumes can only grow, and an error wrapping ErrVolumeExpansionNotSupported is returned if the storage class does not allow it
+// This function can only be called in the state 'Started'
+func (i *Instance) ResizeVolume(path, newSize string) error {
+	return i.ExpandVolume(path, newSize)
+}
+
+// parseDiskUsage parses the output of 'df -P -k' for the given number of paths, in the order of the paths
+func parseDiskUsage(output string, paths int) ([]VolumeUsage, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != paths+1 {
+		return nil, fmt.Errorf("expected %d lines of df output, got %d: %s", paths+1, len(lines), output)
+	}
+
+	usages := make([]VolumeUsage, 0, paths)
+	// The first line is the header: Filesystem 1024-blocks Used Available Capacity Mounted on
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("unexpected df output line '%s'", line)
+		}
+		var kilobytes [3]int64
+		for j := range kilobytes {
+			value, err := strconv.ParseInt(fields[j+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected df output line '%s': %w", line, err)
+			}
+			kilobytes[j] = value
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(fields[4], "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected df output line '%s': %w", line, err)
+		}
+		usages = append(usages, VolumeUsage{
+			Capacity:    kilobytes[0] * 1024,
+			Used:        kilobytes[1] * 1024,
+			Available:   kilobytes[2] * 1024,
+			UsedPercent: percent,
+		})
+	}
+	return usages, nil
+}
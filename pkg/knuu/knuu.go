@@ -2,41 +2,314 @@
 package knuu
 
 import (
+	"errors"
 	"fmt"
+	"github.com/celestiaorg/knuu/pkg/container"
 	"github.com/celestiaorg/knuu/pkg/k8s"
+	"github.com/celestiaorg/knuu/pkg/log"
 	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Identifier is the identifier of the current knuu instance
-var identifier string
-var startTime string
-var timeout time.Duration
+// Knuu is a knuu session
+// It owns the identifier, the start time, the namespace and the Kubernetes client of a test run,
+// so that independent sessions (e.g. of parallel tests or for different clusters) can be used in the same process
+type Knuu struct {
+	identifier       string
+	startTime        string
+	timeout          time.Duration
+	k8sClient        *k8s.Client
+	namespaceCreated bool
+	deleteNamespace  bool
+	builder          Builder
+	buildDirRoot     string
+	keepBuildDirs    bool
+	inCluster        bool
+	deadline         time.Time
+	teardownReserve  time.Duration
+	budgetTimer      *time.Timer
+	budgetDumpDir    string
+	pushRetries      int
+	buildTimeout     time.Duration
+	// defaultWaitTimeout is the timeout of waits whose context has no deadline, see SetDefaultTimeout
+	defaultWaitTimeout time.Duration
+	// apiRetryMaxAttempts and apiRetryBaseDelay are the retry policy of Kubernetes API calls, see SetAPIRetryPolicy
+	apiRetryMaxAttempts int
+	apiRetryBaseDelay   time.Duration
+
+	// budgetExhausted is set by the timer of the global timeout, budgetTornDown once the session was torn down
+	budgetExhausted atomic.Bool
+	budgetTornDown  atomic.Bool
+
+	// appliedObjects are the objects applied by ApplyManifest, they are deleted by CleanUp
+	appliedObjects   []AppliedObject
+	appliedObjectsMu sync.Mutex
+
+	// instances are all instances created in the session, in order of creation
+	instances   []*Instance
+	instancesMu sync.Mutex
+
+	// timeoutHandlerName is the k8s name of the timeout handler, empty if it is disabled
+	// timeoutHandlerBindings are the cluster role bindings of instances the handler may delete, see allowTimeoutHandlerToDelete
+	timeoutHandlerName       string
+	timeoutHandlerBindings   []string
+	timeoutHandlerBindingsMu sync.Mutex
+
+	// sharedVolumes are the shared volumes created in the session, unused ones are deleted by CleanUp
+	sharedVolumes   []*SharedVolume
+	sharedVolumesMu sync.Mutex
+
+	// testRunPods are the pods of the test run by name, listed by testRunPod for the waits of all instances
+	testRunPods       map[string]v1.Pod
+	testRunPodsListed time.Time
+	testRunPodsMu     sync.Mutex
+}
+
+// Options are the options of a knuu session
+type Options struct {
+	// Identifier is used as the 'test-run-id' label of all resources, so it must be a valid Kubernetes label value
+	// If it is empty, an identifier is generated from the current time
+	Identifier string
+	// Namespace is the namespace all resources are deployed to
+	// If it is empty, the namespace is read from the KNUU_NAMESPACE environment variable
+	Namespace string
+	// CreateNamespace creates the namespace during initialization, labeled with the test-run-id
+	CreateNamespace bool
+	// Timeout is the time after which all resources of the session are deleted
+	// If it is zero, it is read from the KNUU_TIMEOUT environment variable, and defaults to 60 minutes
+	Timeout time.Duration
+	// DisableTimeoutHandler disables the deletion of all resources after the timeout,
+	// e.g. for unit tests with a fake clientset, in which the timeout handler never becomes ready
+	DisableTimeoutHandler bool
+	// Clientset is the Kubernetes clientset all resources are deployed with, e.g. a fake clientset in unit tests
+	// DynamicClient is optional and used to apply manifests and read pod metrics
+	// Executing commands in instances and port forwarding are not supported with an injected clientset
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+	// RESTConfig is the config of the cluster all resources are deployed to, e.g. loaded with k8s.LoadConfig for a specific kubeconfig and context
+	// It is ignored if Clientset is set, and if both are nil the in-cluster config or the default kubeconfig is used
+	RESTConfig *rest.Config
+	// Builder is the backend that builds the images of instances, docker if it is not set
+	// If CheckBuilder is true, its availability is checked, so that an unavailable builder fails New instead of a test
+	Builder      Builder
+	CheckBuilder bool
+	// BuildDirRoot is the directory in which the build directories of the instances are created, see SetBuildDirRoot
+	// If it is empty, the knuu directory in the temporary directory is used
+	BuildDirRoot string
+	// KeepBuildDirs keeps the build directories of all instances after their images are built, see Instance.SetKeepBuildDir
+	KeepBuildDirs bool
+	// InCluster sets whether the test runs inside the cluster, so that instances are reached via their service DNS names
+	// instead of port-forwards (see Instance.HTTPClient), if it is nil it is detected from the service account of the pod
+	InCluster *bool
+	// GlobalTimeout is the time the whole test may take, see SetGlobalTimeout, zero disables it
+	// TeardownReserve is the time reserved for the teardown before its deadline, if it is zero it defaults to 2 minutes
+	// BudgetDumpDir is the directory the instances are dumped to before they are destroyed at the deadline, see SetBudgetDumpDir
+	GlobalTimeout   time.Duration
+	TeardownReserve time.Duration
+	BudgetDumpDir   string
+	// PushRetries is how often pushing an image that failed with a transient error is retried, see SetPushRetries,
+	// if it is nil, pushes are retried 3 times
+	PushRetries *int
+	// BuildTimeout is the time the build and the push of an image may take, see SetBuildTimeout, zero disables it
+	BuildTimeout time.Duration
+	// DefaultTimeout is the time waits whose context has no deadline wait at most, see SetDefaultTimeout,
+	// if it is zero it defaults to 5 minutes
+	DefaultTimeout time.Duration
+	// APIRetryMaxAttempts and APIRetryBaseDelay are the retry policy of transient Kubernetes API errors, see SetAPIRetryPolicy,
+	// if they are zero and nil they default to 5 attempts and 500 milliseconds
+	APIRetryMaxAttempts int
+	APIRetryBaseDelay   *time.Duration
+	// QPS and Burst are the client-side rate limit of requests to the API server, see SetAPIRateLimit
+	// They are ignored if Clientset is set, and if they are zero the defaults of client-go are used
+	QPS   float32
+	Burst int
+	// CacheAPIReads serves the pods and services of the test run from an informer cache, see SetAPICache
+	CacheAPIReads bool
+}
+
+// defaultKnuu is the session used by the package-level functions
+var defaultKnuu *Knuu
+
+// namespace is the namespace set by SetNamespace, if empty the namespace is read from the environment
+var namespace string
+
+// createNamespace is true if the namespace should be created during initialization
+var createNamespace bool
+
+// deleteNamespace is true if a namespace created by knuu should be deleted by CleanUp
+var deleteNamespace bool
+
+// inCluster is set by SetInCluster, if nil it is detected whether the test runs inside the cluster
+var inCluster *bool
+
+// apiQPS and apiBurst are the client-side rate limit set by SetAPIRateLimit, zero for the defaults of client-go
+var (
+	apiQPS   float32
+	apiBurst int
+)
+
+// cacheAPIReads is true if the pods and services of the test run are served from a cache, set by SetAPICache
+var cacheAPIReads bool
+
+// clientset is the clientset set by SetClient, if nil the clientset is created from the Kubernetes config
+var clientset kubernetes.Interface
+
+// New creates and initializes a knuu session
+func New(opts Options) (*Knuu, error) {
+	if opts.Identifier == "" {
+		opts.Identifier = timestamp(time.Now())
+	}
+	if errs := validation.IsValidLabelValue(opts.Identifier); len(errs) != 0 {
+		return nil, fmt.Errorf("invalid identifier '%s': %s", opts.Identifier, strings.Join(errs, "; "))
+	}
+	if opts.Namespace != "" {
+		if errs := validation.IsDNS1123Label(opts.Namespace); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid namespace '%s': %s", opts.Namespace, strings.Join(errs, "; "))
+		}
+	}
+
+	k := &Knuu{
+		identifier: opts.Identifier,
+		startTime:  timestamp(time.Now()),
+		timeout:    opts.Timeout,
+	}
+
+	var err error
+	switch {
+	case opts.Clientset != nil:
+		k.k8sClient, err = k8s.NewClientWithClientset(opts.Clientset, opts.DynamicClient, opts.Namespace)
+	case opts.RESTConfig != nil:
+		k.k8sClient, err = k8s.NewClientFromConfig(k8s.WithRateLimit(opts.RESTConfig, opts.QPS, opts.Burst), opts.Namespace)
+	default:
+		k.k8sClient, err = k8s.NewClientWithRateLimit(opts.Namespace, opts.QPS, opts.Burst)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := opts.Builder.validate(); err != nil {
+		return nil, err
+	}
+	k.builder = opts.Builder
+	if opts.CheckBuilder {
+		if err := k.checkBuilder(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.BuildDirRoot != "" {
+		if err := validateBuildDirRoot(opts.BuildDirRoot); err != nil {
+			return nil, err
+		}
+	}
+	k.buildDirRoot = opts.BuildDirRoot
+	k.inCluster = k8s.IsClusterEnvironment()
+	if opts.InCluster != nil {
+		k.inCluster = *opts.InCluster
+	}
+	k.keepBuildDirs = opts.KeepBuildDirs
+	k.pushRetries = container.DefaultPushRetries
+	if opts.PushRetries != nil {
+		if err := k.SetPushRetries(*opts.PushRetries); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.SetBuildTimeout(opts.BuildTimeout); err != nil {
+		return nil, err
+	}
+	k.defaultWaitTimeout = fallbackWaitTimeout
+	maxAttempts, baseDelay := defaultAPIRetryMaxAttempts, defaultAPIRetryBaseDelay
+	if opts.APIRetryMaxAttempts != 0 {
+		maxAttempts = opts.APIRetryMaxAttempts
+	}
+	if opts.APIRetryBaseDelay != nil {
+		baseDelay = *opts.APIRetryBaseDelay
+	}
+	if err := k.SetAPIRetryPolicy(maxAttempts, baseDelay); err != nil {
+		return nil, err
+	}
+	if opts.DefaultTimeout != 0 {
+		if err := k.SetDefaultTimeout(opts.DefaultTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.CreateNamespace {
+		k.namespaceCreated, err = k.k8sClient.CreateNamespace(k.k8sClient.Namespace(), k.labels())
+		if err != nil {
+			return nil, fmt.Errorf("cannot create namespace: %w", err)
+		}
+	}
+
+	if k.timeout == 0 {
+		// read timeout from env
+		timeoutString := os.Getenv("KNUU_TIMEOUT")
+
+		if timeoutString == "" {
+			k.timeout = 60 * time.Minute
+		} else {
+			parsedTimeout, err := time.ParseDuration(timeoutString)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse timeout: %s", err)
+			}
+			k.timeout = parsedTimeout
+		}
+	}
+
+	if opts.CacheAPIReads {
+		if err := k.k8sClient.StartCache(map[string]string{"test-run-id": k.identifier}); err != nil {
+			return nil, fmt.Errorf("cannot start cache of the API server: %w", err)
+		}
+	}
+
+	if !opts.DisableTimeoutHandler {
+		if err := k.handleTimeout(); err != nil {
+			return nil, fmt.Errorf("cannot handle timeout: %s", err)
+		}
+	}
+
+	k.budgetDumpDir = opts.BudgetDumpDir
+	if opts.GlobalTimeout > 0 {
+		if err := k.startBudget(opts.GlobalTimeout, opts.TeardownReserve); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
 
 // Initialize initializes knuug
 func Initialize() error {
-
-	t := time.Now()
-	identifier = fmt.Sprintf("%s_%03d", t.Format("20060102_150405"), t.Nanosecond()/1e6)
-	return InitializeWithIdentifier(identifier)
+	return InitializeWithIdentifier(timestamp(time.Now()))
 }
 
 // Identifier returns the identifier of the current knuu instance
 func Identifier() string {
-	return identifier
+	return defaultKnuu.Identifier()
+}
+
+// StartTime returns the time knuu was initialized, as used in the 'test-started' label of all resources
+func StartTime() string {
+	return defaultKnuu.StartTime()
 }
 
 // InitializeWithIdentifier initializes knuu with a unique identifier
+// The identifier is used as the 'test-run-id' label of all resources, so it must be a valid Kubernetes label value
 // Default timeout is 60 minutes and can be changed by setting the KNUU_TIMEOUT environment variable
 func InitializeWithIdentifier(uniqueIdentifier string) error {
 	if uniqueIdentifier == "" {
 		return fmt.Errorf("cannot initialize knuu with empty identifier")
 	}
-	identifier = uniqueIdentifier
-
-	t := time.Now()
-	startTime = fmt.Sprintf("%s_%03d", t.Format("20060102_150405"), t.Nanosecond()/1e6)
 
 	switch os.Getenv("LOG_LEVEL") {
 	case "debug":
@@ -51,43 +324,241 @@ func InitializeWithIdentifier(uniqueIdentifier string) error {
 		logrus.SetLevel(logrus.InfoLevel)
 	}
 
-	err := k8s.Initialize()
+	k, err := New(Options{
+		Identifier:          uniqueIdentifier,
+		Namespace:           namespace,
+		CreateNamespace:     createNamespace,
+		Clientset:           clientset,
+		Builder:             builder,
+		CheckBuilder:        builderSet,
+		BuildDirRoot:        buildDirRoot,
+		KeepBuildDirs:       keepBuildDirs,
+		InCluster:           inCluster,
+		GlobalTimeout:       globalTimeout,
+		TeardownReserve:     teardownReserve,
+		BudgetDumpDir:       budgetDumpDir,
+		PushRetries:         &pushRetries,
+		BuildTimeout:        buildTimeout,
+		DefaultTimeout:      defaultWaitTimeout,
+		APIRetryMaxAttempts: apiRetryMaxAttempts,
+		APIRetryBaseDelay:   &apiRetryBaseDelay,
+		QPS:                 apiQPS,
+		Burst:               apiBurst,
+		CacheAPIReads:       cacheAPIReads,
+	})
 	if err != nil {
 		return err
 	}
+	k.deleteNamespace = deleteNamespace
+	defaultKnuu = k
+	return nil
+}
 
-	// read timeout from env
-	timeoutString := os.Getenv("KNUU_TIMEOUT")
+// Logger is the interface a logger has to implement to be used by knuu
+// If it also implements log.FieldLogger, instance log lines carry the instance name and k8s name as structured fields
+type Logger = log.Logger
 
-	if timeoutString == "" {
-		timeout = 60 * time.Minute
-	} else {
-		parsedTimeout, err := time.ParseDuration(timeoutString)
-		if err != nil {
-			return fmt.Errorf("cannot parse timeout: %s", err)
-		}
-		timeout = parsedTimeout
+// SetLogger sets the logger used by knuu
+// By default, or when setting nil, knuu logs through the global logrus logger
+func SetLogger(l Logger) {
+	if l == nil {
+		l = log.NewLogrusLogger(logrus.StandardLogger())
 	}
+	log.SetLogger(l)
+}
 
-	if err := handleTimeout(); err != nil {
-		return fmt.Errorf("cannot handle timeout: %s", err)
+// SetLogLevel sets the level of the log messages of knuu, e.g. "debug" or "warn"
+// Instances log with this level, unless a level is set for them with Instance.SetLogLevel
+// It returns an error if the logger set by SetLogger does not support levels
+func SetLogLevel(level string) error {
+	parsedLevel, err := log.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("error setting log level: %w", err)
+	}
+	if err := log.SetLevel(parsedLevel); err != nil {
+		return fmt.Errorf("error setting log level: %w", err)
 	}
+	return nil
+}
+
+// SetNamespace sets the namespace all resources are deployed to, instead of the one of the KNUU_NAMESPACE environment variable
+// If create is true, the namespace is created during initialization, labeled with the test-run-id
+// This function can only be called before knuu is initialized
+func SetNamespace(name string, create bool) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the namespace is only allowed before knuu is initialized")
+	}
+	if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+		return fmt.Errorf("invalid namespace '%s': %s", name, strings.Join(errs, "; "))
+	}
+	namespace = name
+	createNamespace = create
+	log.Debugf("Set namespace to '%s' (create: %t)", name, create)
+	return nil
+}
+
+// SetClient sets the Kubernetes clientset all resources are deployed with, instead of the one created from the Kubernetes config
+// It allows unit tests to use a fake clientset, see Options for the features that are not supported with an injected clientset
+// This function can only be called before knuu is initialized
+func SetClient(c kubernetes.Interface) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the client is only allowed before knuu is initialized")
+	}
+	clientset = c
+	log.Debugf("Set Kubernetes client")
+	return nil
+}
+
+// SetInCluster sets whether the test runs inside the cluster, instead of detecting it from the service account of the pod
+// Inside the cluster, instances are reached via their service DNS names, otherwise via port-forwards (see Instance.HTTPClient)
+// This function can only be called before knuu is initialized
+func SetInCluster(enabled bool) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting in-cluster is only allowed before knuu is initialized")
+	}
+	inCluster = &enabled
+	log.Debugf("Set in-cluster to '%t'", enabled)
+	return nil
+}
+
+// SetAPIRateLimit sets how many requests per second (qps) knuu sends to the API server on average, and how many at once (burst)
+// The defaults of client-go (5 and 10) throttle tests with many instances, which then wait for the client-side rate limit
+// This function can only be called before knuu is initialized
+func SetAPIRateLimit(qps float32, burst int) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the API rate limit is only allowed before knuu is initialized")
+	}
+	if qps <= 0 || burst <= 0 {
+		return fmt.Errorf("API rate limit must be positive, got qps '%g' and burst '%d'", qps, burst)
+	}
+	apiQPS = qps
+	apiBurst = burst
+	log.Debugf("Set API rate limit to '%g' requests per second with bursts of '%d'", qps, burst)
+	return nil
+}
 
+// SetAPICache sets whether the pods and services of the test run are read from an informer cache instead of the API server,
+// so that repeated status lookups of many instances (e.g. Status, WaitForQuorum and GetIP) do not send requests
+// Cached reads may not reflect changes of the last moments, which waits tolerate, as they check again
+// This function can only be called before knuu is initialized
+func SetAPICache(enabled bool) error {
+	if IsInitialized() {
+		return fmt.Errorf("setting the API cache is only allowed before knuu is initialized")
+	}
+	cacheAPIReads = enabled
+	log.Debugf("Set API cache to '%t'", enabled)
 	return nil
 }
 
+// DeleteNamespaceOnCleanUp sets whether CleanUp deletes the namespace, which deletes all resources of the test at once
+// Only a namespace that was created by knuu is deleted, never a pre-existing one
+func DeleteNamespaceOnCleanUp(enabled bool) {
+	deleteNamespace = enabled
+	if defaultKnuu != nil {
+		defaultKnuu.DeleteNamespaceOnCleanUp(enabled)
+	}
+}
+
 // IsInitialized returns true if knuu is initialized, and false otherwise
 func IsInitialized() bool {
-	return k8s.IsInitialized()
+	return defaultKnuu != nil
+}
+
+// CleanUp deletes the resources that are not owned by an instance, e.g. the objects applied by ApplyManifest
+// Instances have to be destroyed separately, unless the namespace is deleted (see DeleteNamespaceOnCleanUp)
+// It should be called (or deferred) at the end of the test
+func CleanUp() error {
+	if defaultKnuu == nil {
+		return nil
+	}
+	return defaultKnuu.CleanUp()
+}
+
+// Identifier returns the identifier of the session
+func (k *Knuu) Identifier() string {
+	if k == nil {
+		return ""
+	}
+	return k.identifier
+}
+
+// StartTime returns the time the session was created, as used in the 'test-started' label of all resources
+func (k *Knuu) StartTime() string {
+	if k == nil {
+		return ""
+	}
+	return k.startTime
+}
+
+// Namespace returns the namespace all resources of the session are deployed to
+func (k *Knuu) Namespace() string {
+	return k.client().Namespace()
+}
+
+// DeleteNamespaceOnCleanUp sets whether CleanUp deletes the namespace, which deletes all resources of the session at once
+// Only a namespace that was created by the session is deleted, never a pre-existing one
+func (k *Knuu) DeleteNamespaceOnCleanUp(enabled bool) {
+	k.deleteNamespace = enabled
+}
+
+// CleanUp deletes the resources of the session that are not owned by an instance, e.g. the objects applied by ApplyManifest
+// Instances have to be destroyed separately, unless the namespace is deleted (see DeleteNamespaceOnCleanUp)
+// It should be called (or deferred) at the end of the test
+// If a global timeout is set, its deadline no longer tears down the session
+func (k *Knuu) CleanUp() error {
+	k.stopBudget()
+	if err := k.deleteAppliedObjects(); err != nil {
+		return fmt.Errorf("cannot clean up: %w", err)
+	}
+	if err := k.deleteUnusedSharedVolumes(); err != nil {
+		return fmt.Errorf("cannot clean up: %w", err)
+	}
+	if err := k.deleteTimeoutHandlerRBAC(); err != nil {
+		return fmt.Errorf("cannot clean up: %w", err)
+	}
+	if k.deleteNamespace && k.namespaceCreated {
+		if err := k.k8sClient.DeleteNamespace(k.k8sClient.Namespace()); err != nil {
+			return fmt.Errorf("cannot clean up: %w", err)
+		}
+		k.namespaceCreated = false
+	}
+	k.k8sClient.StopCache()
+	return nil
+}
+
+// client returns the Kubernetes client of the session, or nil if there is no session
+func (k *Knuu) client() *k8s.Client {
+	if k == nil {
+		return nil
+	}
+	return k.k8sClient
+}
+
+// labels returns the labels of the test run, which are added to all resources of the session
+// The timeout handler and the cleanup of the test run select resources by these labels, so every resource must carry all of them
+func (k *Knuu) labels() map[string]string {
+	return map[string]string{
+		"k8s.kubernetes.io/managed-by": "knuu",
+		"test-run-id":                  k.Identifier(),
+		"test-started":                 k.StartTime(),
+	}
+}
+
+// timestamp formats the time as used for identifiers and the 'test-started' label
+func timestamp(t time.Time) string {
+	return fmt.Sprintf("%s_%03d", t.Format("20060102_150405"), t.Nanosecond()/1e6)
 }
 
 // handleTimeout creates a timeout handler that will delete all resources with the identifier after the timeout
-func handleTimeout() error {
+func (k *Knuu) handleTimeout() error {
 
-	instance, err := NewInstance("timeout-handler")
+	instance, err := k.NewInstance("timeout-handler")
 	if err != nil {
 		return fmt.Errorf("cannot create instance: %s", err)
 	}
+	// The timeout handler is part of knuu, not of the topology of the test
+	k.unregisterInstance(instance)
+	k.timeoutHandlerName = instance.k8sName
 	// FIXME: use supported kubernetes version images (use of latest could break) (https://github.com/celestiaorg/knuu/issues/116)
 	if err := instance.SetImage("docker.io/bitnami/kubectl:latest"); err != nil {
 		return fmt.Errorf("cannot set image: %s", err)
@@ -95,28 +566,48 @@ func handleTimeout() error {
 	if err := instance.Commit(); err != nil {
 		return fmt.Errorf("cannot commit instance: %s", err)
 	}
-	timeoutSeconds := int64(timeout.Seconds())
+	timeoutSeconds := int64(k.timeout.Seconds())
 
 	// command to wait for timeout and delete all resources with the identifier
 	var command = []string{"sh", "-c"}
 	// Command runs in-cluster to delete resources post-test. Chosen for simplicity over a separate Go app.
-	cmd := fmt.Sprintf("sleep %d && kubectl delete all,pvc,netpol,roles,serviceaccounts,rolebindings -l test-run-id=%s -n %s --wait=false", timeoutSeconds, identifier, k8s.Namespace())
+	// Until the timeout, it deletes the resources of instances whose lifetime (see Instance.SetLifetime) expired every 30 seconds
+	expiredInstances := fmt.Sprintf(`kubectl get statefulsets -n %s -l 'test-run-id=%s,%s' -o jsonpath='{range .items[*]}{.metadata.labels.app}={.metadata.labels.%s}{" "}{end}'`,
+		k.Namespace(), k.identifier, expiresAtLabel, strings.ReplaceAll(expiresAtLabel, ".", `\.`))
+	// The cluster role bindings of instances are cluster-scoped, so they are deleted by name with the cluster role of the handler,
+	// which only exists if an instance has one (see allowTimeoutHandlerToDelete) and lists their names
+	bindings := fmt.Sprintf(`kubectl get clusterrole %s -o jsonpath='{.rules[0].resourceNames[*]}' 2>/dev/null`, instance.k8sName)
+	deleteExpired := fmt.Sprintf(`kubectl delete all,pvc,netpol,pdb,ingress -l "app=${entry%%=*},test-run-id=%s" -n %s --wait=false; `+
+		`for name in $(%s); do `+
+		`if [ "$(kubectl get clusterrolebinding "$name" -o jsonpath='{.metadata.labels.app}' 2>/dev/null)" = "${entry%%=*}" ]; then kubectl delete clusterrolebinding "$name" --wait=false; fi; `+
+		`done`, k.identifier, k.Namespace(), bindings)
+	// The cluster role of the handler is deleted after the bindings, which deletes the handler's binding it owns
+	cmd := fmt.Sprintf(`end=$(($(date +%%s) + %d)); `+
+		`while [ "$(date +%%s)" -lt "$end" ]; do `+
+		`now=$(date +%%s); `+
+		`for entry in $(%s); do if [ "${entry#*=}" -le "$now" ]; then %s; fi; done; `+
+		`sleep 30; `+
+		`done; `+
+		`for name in $(%s); do kubectl delete clusterrolebinding "$name" --wait=false; done; `+
+		"kubectl delete clusterrole %s --wait=false 2>/dev/null; "+
+		"kubectl delete all,pvc,netpol,pdb,ingress,configmaps,secrets,roles,serviceaccounts,rolebindings -l test-run-id=%s -n %s --wait=false",
+		timeoutSeconds, expiredInstances, deleteExpired, bindings, instance.k8sName, k.identifier, k.Namespace())
 	command = append(command, cmd)
 
 	if err := instance.SetCommand(command...); err != nil {
 		return fmt.Errorf("cannot set command: %s", err)
 	}
 
-	if err := k8s.CreateRole(instance.k8sName, k8s.Namespace(), instance.getLabels(), []string{"*"}, []string{"*"}, []string{"*"}); err != nil {
+	if err := k.k8sClient.CreateRole(instance.k8sName, k.Namespace(), instance.getLabels(), []string{"*"}, []string{"*"}, []string{"*"}); err != nil {
 		return fmt.Errorf("cannot create role: %s", err)
 	}
-	if err := k8s.CreateServiceAccount(instance.k8sName, k8s.Namespace(), instance.getLabels()); err != nil {
+	if err := k.k8sClient.CreateServiceAccount(instance.k8sName, k.Namespace(), instance.getLabels()); err != nil {
 		return fmt.Errorf("cannot create service account: %s", err)
 	}
-	if err := k8s.CreateRoleBinding(instance.k8sName, k8s.Namespace(), instance.getLabels(), instance.k8sName, instance.k8sName); err != nil {
+	if err := k.k8sClient.CreateRoleBinding(instance.k8sName, k.Namespace(), instance.getLabels(), instance.k8sName, instance.k8sName); err != nil {
 		return fmt.Errorf("cannot create role binding: %s", err)
 	}
-	if err := instance.SetServiceAccount(instance.k8sName); err != nil {
+	if err := instance.SetServiceAccount(instance.k8sName, false); err != nil {
 		return fmt.Errorf("cannot set service account: %s", err)
 	}
 
@@ -126,3 +617,75 @@ func handleTimeout() error {
 
 	return nil
 }
+
+// allowTimeoutHandlerToDelete grants the timeout handler of the session to delete the cluster role binding of an instance
+// Cluster role bindings are not namespaced, so the role of the handler in the namespace cannot grant deleting them
+// The cluster role and binding of the handler are only created for the first binding, so that sessions without cluster role
+// bindings only need permissions in their namespace; RBAC cannot restrict deletions to the labels of the test run, so the
+// cluster role only grants deleting the bindings by name, and a rule without names would grant deleting all of them
+func (k *Knuu) allowTimeoutHandlerToDelete(binding string) error {
+	if k == nil || k.timeoutHandlerName == "" {
+		return nil
+	}
+	k.timeoutHandlerBindingsMu.Lock()
+	defer k.timeoutHandlerBindingsMu.Unlock()
+	for _, existing := range k.timeoutHandlerBindings {
+		if existing == binding {
+			return nil
+		}
+	}
+
+	bindings := append(append([]string(nil), k.timeoutHandlerBindings...), binding)
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups:     []string{rbacv1.GroupName},
+			Resources:     []string{"clusterrolebindings"},
+			Verbs:         []string{"get", "delete"},
+			ResourceNames: bindings,
+		},
+		{
+			APIGroups:     []string{rbacv1.GroupName},
+			Resources:     []string{"clusterroles"},
+			Verbs:         []string{"get", "delete"},
+			ResourceNames: []string{k.timeoutHandlerName},
+		},
+	}
+	clusterRole, err := k.k8sClient.CreateOrUpdateClusterRole(k.timeoutHandlerName, k.labels(), rules)
+	if err != nil {
+		return fmt.Errorf("error granting timeout handler to delete cluster role binding '%s': %w", binding, err)
+	}
+	if len(k.timeoutHandlerBindings) == 0 {
+		err := k.k8sClient.CreateClusterRoleBindingOwnedBy(k.timeoutHandlerName, k.labels(), clusterRole, k.timeoutHandlerName, k.Namespace())
+		if err != nil && !k8s.IsAlreadyExistsError(err) {
+			return fmt.Errorf("error creating cluster role binding of timeout handler '%s': %w", k.timeoutHandlerName, err)
+		}
+	}
+	k.timeoutHandlerBindings = bindings
+	log.Debugf("Granted timeout handler '%s' to delete cluster role binding '%s'", k.timeoutHandlerName, binding)
+	return nil
+}
+
+// deleteTimeoutHandlerRBAC deletes the cluster role bindings the timeout handler may delete, and the cluster role and binding
+// of the handler, as the handler cannot delete cluster role bindings anymore afterwards
+func (k *Knuu) deleteTimeoutHandlerRBAC() error {
+	k.timeoutHandlerBindingsMu.Lock()
+	defer k.timeoutHandlerBindingsMu.Unlock()
+	if len(k.timeoutHandlerBindings) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, binding := range append(k.timeoutHandlerBindings, k.timeoutHandlerName) {
+		if err := k.k8sClient.DeleteClusterRoleBinding(binding); err != nil && !k8s.IsNotFoundError(err) {
+			errs = append(errs, fmt.Errorf("error deleting cluster role binding '%s': %w", binding, err))
+		}
+	}
+	if err := k.k8sClient.DeleteClusterRole(k.timeoutHandlerName); err != nil && !k8s.IsNotFoundError(err) {
+		errs = append(errs, fmt.Errorf("error deleting cluster role '%s': %w", k.timeoutHandlerName, err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	k.timeoutHandlerBindings = nil
+	return nil
+}
@@ -0,0 +1,316 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	appv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testNamespace is the namespace of the sessions of the tests
+const testNamespace = "knuu-test"
+
+// testImage is the image of the instances of the tests, it is never pulled
+const testImage = "docker.io/library/alpine:3.18"
+
+// testCluster is the fake clientset of a session of the tests, which counts the requests sent to it
+type testCluster struct {
+	*fake.Clientset
+	requests *requestCounter
+}
+
+// newTestKnuu returns a session on a fake clientset, which acts like the controllers of a cluster for the objects of instances:
+// created statefulSets are ready and their pods are running, and created persistent volume claims are bound
+func newTestKnuu(t *testing.T, opts Options) (*Knuu, *testCluster) {
+	t.Helper()
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		statefulSet := action.(k8stesting.CreateAction).GetObject().(*appv1.StatefulSet)
+		replicas := *statefulSet.Spec.Replicas
+		statefulSet.Status.Replicas = replicas
+		statefulSet.Status.ReadyReplicas = replicas
+		statefulSet.Status.UpdatedReplicas = replicas
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s-%d", statefulSet.Name, ordinal),
+					Namespace: statefulSet.Namespace,
+					Labels:    statefulSet.Spec.Template.Labels,
+				},
+				Spec: statefulSet.Spec.Template.Spec,
+				Status: v1.PodStatus{
+					Phase:      v1.PodRunning,
+					Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+				},
+			}
+			// The reactors hold the lock of the clientset, so the pod is added to the tracker directly
+			if err := clientset.Tracker().Add(pod); err != nil {
+				return true, nil, err
+			}
+		}
+		return false, nil, nil
+	})
+	clientset.PrependReactor("delete", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.DeleteAction).GetName()
+		for ordinal := 0; ; ordinal++ {
+			err := clientset.Tracker().Delete(v1.SchemeGroupVersion.WithResource("pods"), action.GetNamespace(), fmt.Sprintf("%s-%d", name, ordinal))
+			if err != nil {
+				return false, nil, nil
+			}
+		}
+	})
+	clientset.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		claim := action.(k8stesting.CreateAction).GetObject().(*v1.PersistentVolumeClaim)
+		claim.Status.Phase = v1.ClaimBound
+		return false, nil, nil
+	})
+	// The reactors must not be changed while the session sends requests, so the counter is added before it is created
+	cluster := &testCluster{Clientset: clientset, requests: countRequests(clientset)}
+
+	opts.Clientset = clientset
+	opts.Namespace = testNamespace
+	opts.DisableTimeoutHandler = true
+	if opts.BuildDirRoot == "" {
+		opts.BuildDirRoot = t.TempDir()
+	}
+	k, err := New(opts)
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := k.CleanUp(); err != nil {
+			t.Errorf("cleaning up session: %v", err)
+		}
+	})
+	return k, cluster
+}
+
+// newTestInstance returns a committed instance of the session with the image of the tests and the TCP ports
+func newTestInstance(t *testing.T, k *Knuu, name string, portsTCP ...int) *Instance {
+	t.Helper()
+	instance, err := k.NewInstance(name)
+	if err != nil {
+		t.Fatalf("creating instance '%s': %v", name, err)
+	}
+	if err := instance.SetImage(testImage); err != nil {
+		t.Fatalf("setting image of instance '%s': %v", name, err)
+	}
+	for _, port := range portsTCP {
+		if err := instance.AddPortTCP(port); err != nil {
+			t.Fatalf("adding port '%d' to instance '%s': %v", port, name, err)
+		}
+	}
+	if err := instance.Commit(); err != nil {
+		t.Fatalf("committing instance '%s': %v", name, err)
+	}
+	return instance
+}
+
+// requestCounter counts the requests to the fake clientset by verb and resource, e.g. 'get pods'
+type requestCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// countRequests counts all requests to the clientset from now on
+func countRequests(clientset *fake.Clientset) *requestCounter {
+	counter := &requestCounter{counts: make(map[string]int)}
+	clientset.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		counter.add(action)
+		return false, nil, nil
+	})
+	clientset.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		counter.add(action)
+		return false, nil, nil
+	})
+	return counter
+}
+
+// add counts the request of the action
+func (c *requestCounter) add(action k8stesting.Action) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[action.GetVerb()+" "+action.GetResource().Resource]++
+}
+
+// count returns the number of requests with the verb to the resource
+func (c *requestCounter) count(verb, resource string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[verb+" "+resource]
+}
+
+// reset forgets all counted requests
+func (c *requestCounter) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = make(map[string]int)
+}
+
+func TestResourcesCarryTestRunLabels(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	instance := newTestInstance(t, k, "labeled", 8080)
+	if err := instance.AddVolume("/data", "1Gi"); err != nil {
+		t.Fatalf("adding volume: %v", err)
+	}
+	if err := instance.SetReplicas(2); err != nil {
+		t.Fatalf("setting replicas: %v", err)
+	}
+	if err := instance.SetPodDisruptionBudget(intstr.FromInt(1)); err != nil {
+		t.Fatalf("setting pod disruption budget: %v", err)
+	}
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}
+	if err := instance.AddRole(rules); err != nil {
+		t.Fatalf("adding role: %v", err)
+	}
+	if err := instance.AddClusterRoleBinding("view"); err != nil {
+		t.Fatalf("adding cluster role binding: %v", err)
+	}
+	if err := instance.Start(); err != nil {
+		t.Fatalf("starting instance: %v", err)
+	}
+	if err := instance.DisableNetwork(); err != nil {
+		t.Fatalf("disabling network: %v", err)
+	}
+
+	ctx := context.Background()
+	labeled := make(map[string]map[string]string)
+	statefulSet, err := cluster.AppsV1().StatefulSets(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting statefulSet: %v", err)
+	}
+	labeled["statefulSet"] = statefulSet.Labels
+	labeled["pod template"] = statefulSet.Spec.Template.Labels
+	objects := map[string]func() (metav1.Object, error){
+		"service": func() (metav1.Object, error) {
+			return cluster.CoreV1().Services(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		},
+		"persistentVolumeClaim": func() (metav1.Object, error) {
+			return cluster.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		},
+		"podDisruptionBudget": func() (metav1.Object, error) {
+			return cluster.PolicyV1().PodDisruptionBudgets(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		},
+		"networkPolicy": func() (metav1.Object, error) {
+			return cluster.NetworkingV1().NetworkPolicies(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		},
+		"role": func() (metav1.Object, error) {
+			return cluster.RbacV1().Roles(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		},
+		"roleBinding": func() (metav1.Object, error) {
+			return cluster.RbacV1().RoleBindings(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		},
+		"serviceAccount": func() (metav1.Object, error) {
+			return cluster.CoreV1().ServiceAccounts(testNamespace).Get(ctx, instance.k8sName, metav1.GetOptions{})
+		},
+		"clusterRoleBinding": func() (metav1.Object, error) {
+			return cluster.RbacV1().ClusterRoleBindings().Get(ctx, instance.clusterRoleBindingName("view"), metav1.GetOptions{})
+		},
+	}
+	for kind, get := range objects {
+		object, err := get()
+		if err != nil {
+			t.Fatalf("getting %s: %v", kind, err)
+		}
+		labeled[kind] = object.GetLabels()
+	}
+
+	for kind, labels := range labeled {
+		for key, value := range k.labels() {
+			if labels[key] != value {
+				t.Errorf("%s has label '%s' with value '%s', expected '%s'", kind, key, labels[key], value)
+			}
+		}
+	}
+}
+
+func TestTimeoutHandlerDeletesClusterRoleBindings(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	if err := k.handleTimeout(); err != nil {
+		t.Fatalf("creating timeout handler: %v", err)
+	}
+
+	ctx := context.Background()
+	statefulSets, err := cluster.AppsV1().StatefulSets(testNamespace).List(ctx, metav1.ListOptions{LabelSelector: "name=timeout-handler"})
+	if err != nil || len(statefulSets.Items) != 1 {
+		t.Fatalf("listing statefulSet of timeout handler: %v, found %d", err, len(statefulSets.Items))
+	}
+	handler := statefulSets.Items[0]
+	command := strings.Join(handler.Spec.Template.Spec.Containers[0].Command, " ")
+	if !strings.Contains(command, fmt.Sprintf("kubectl delete clusterrole %s", handler.Name)) {
+		t.Errorf("command of timeout handler does not delete its cluster role: %s", command)
+	}
+	if strings.Contains(command, "clusterrolebindings -l") {
+		t.Errorf("command of timeout handler deletes cluster role bindings by label: %s", command)
+	}
+
+	// Without cluster role bindings of instances, the session only needs permissions in its namespace
+	assertClusterScopedObjects := func(roles, bindings int) {
+		t.Helper()
+		clusterRoles, err := cluster.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("listing cluster roles: %v", err)
+		}
+		clusterRoleBindings, err := cluster.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("listing cluster role bindings: %v", err)
+		}
+		if len(clusterRoles.Items) != roles || len(clusterRoleBindings.Items) != bindings {
+			t.Fatalf("expected %d cluster roles and %d cluster role bindings, found %d and %d", roles, bindings, len(clusterRoles.Items), len(clusterRoleBindings.Items))
+		}
+	}
+	assertClusterScopedObjects(0, 0)
+
+	var names []string
+	for _, name := range []string{"viewer", "editor"} {
+		instance := newTestInstance(t, k, name)
+		if err := instance.AddClusterRoleBinding("view"); err != nil {
+			t.Fatalf("adding cluster role binding: %v", err)
+		}
+		if err := instance.Start(); err != nil {
+			t.Fatalf("starting instance: %v", err)
+		}
+		names = append(names, instance.clusterRoleBindingName("view"))
+	}
+	assertClusterScopedObjects(1, 3)
+
+	clusterRole, err := cluster.RbacV1().ClusterRoles().Get(ctx, handler.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting cluster role of timeout handler: %v", err)
+	}
+	for _, rule := range clusterRole.Rules {
+		if len(rule.ResourceNames) == 0 {
+			t.Errorf("cluster role of timeout handler grants '%v' on all '%v'", rule.Verbs, rule.Resources)
+		}
+	}
+	if granted := strings.Join(clusterRole.Rules[0].ResourceNames, ","); granted != strings.Join(names, ",") {
+		t.Errorf("cluster role of timeout handler grants deleting '%s', expected '%s'", granted, strings.Join(names, ","))
+	}
+	binding, err := cluster.RbacV1().ClusterRoleBindings().Get(ctx, handler.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting cluster role binding of timeout handler: %v", err)
+	}
+	if binding.RoleRef.Name != handler.Name || binding.Subjects[0].Name != handler.Spec.Template.Spec.ServiceAccountName {
+		t.Errorf("cluster role binding of timeout handler binds '%s' to '%s'", binding.RoleRef.Name, binding.Subjects[0].Name)
+	}
+	if len(binding.OwnerReferences) != 1 || binding.OwnerReferences[0].UID != clusterRole.UID {
+		t.Errorf("cluster role binding of timeout handler is not owned by its cluster role: %+v", binding.OwnerReferences)
+	}
+
+	// The handler cannot delete the bindings once its cluster role is deleted, so CleanUp deletes all of them
+	if err := k.CleanUp(); err != nil {
+		t.Fatalf("cleaning up: %v", err)
+	}
+	assertClusterScopedObjects(0, 0)
+}
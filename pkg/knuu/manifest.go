@@ -0,0 +1,89 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"github.com/celestiaorg/knuu/pkg/log"
+)
+
+// AppliedObject identifies a Kubernetes object that was applied from a manifest
+type AppliedObject = k8s.AppliedObject
+
+// ApplyManifest applies all objects of a (multi-document) YAML manifest into the namespace of the default session, see Knuu.ApplyManifest
+func ApplyManifest(yaml []byte) ([]AppliedObject, error) {
+	if defaultKnuu == nil {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	return defaultKnuu.ApplyManifest(yaml)
+}
+
+// ApplyManifest applies all objects of a (multi-document) YAML manifest server-side into the test namespace
+// The objects get the knuu labels of the test run and are deleted by CleanUp
+// Objects in other namespaces and cluster-scoped objects are rejected, so that nothing leaks outside the test namespace
+// If applying an object fails, the objects applied before are returned together with the error
+func (k *Knuu) ApplyManifest(yaml []byte) ([]AppliedObject, error) {
+	objects, err := k8s.DecodeManifest(yaml)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := k.labels()
+
+	var applied []AppliedObject
+	for _, obj := range objects {
+		var appliedObject *AppliedObject
+		err := k.retryAPICall(fmt.Sprintf("applying %s/%s", obj.GetKind(), obj.GetName()), func() error {
+			var err error
+			appliedObject, err = k.k8sClient.ApplyObject(k.Namespace(), obj, labels)
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("error applying manifest: %w", err)
+		}
+		applied = append(applied, *appliedObject)
+		k.trackAppliedObject(*appliedObject)
+	}
+
+	log.Debugf("Applied manifest with %d objects", len(applied))
+	return applied, nil
+}
+
+// trackAppliedObject remembers the object for CleanUp, unless it is already tracked
+func (k *Knuu) trackAppliedObject(obj AppliedObject) {
+	k.appliedObjectsMu.Lock()
+	defer k.appliedObjectsMu.Unlock()
+	for _, tracked := range k.appliedObjects {
+		if tracked == obj {
+			return
+		}
+	}
+	k.appliedObjects = append(k.appliedObjects, obj)
+}
+
+// untrackAppliedObject forgets an object that was deleted before CleanUp
+func (k *Knuu) untrackAppliedObject(obj AppliedObject) {
+	k.appliedObjectsMu.Lock()
+	defer k.appliedObjectsMu.Unlock()
+	for j, tracked := range k.appliedObjects {
+		if tracked == obj {
+			k.appliedObjects = append(k.appliedObjects[:j], k.appliedObjects[j+1:]...)
+			return
+		}
+	}
+}
+
+// deleteAppliedObjects deletes all objects applied by ApplyManifest in reverse order
+func (k *Knuu) deleteAppliedObjects() error {
+	k.appliedObjectsMu.Lock()
+	defer k.appliedObjectsMu.Unlock()
+	for len(k.appliedObjects) > 0 {
+		obj := k.appliedObjects[len(k.appliedObjects)-1]
+		if err := k.retryAPICall(fmt.Sprintf("deleting %s", obj), func() error {
+			return k.k8sClient.DeleteObject(obj)
+		}); err != nil {
+			return fmt.Errorf("error deleting applied object %s: %w", obj, err)
+		}
+		k.appliedObjects = k.appliedObjects[:len(k.appliedObjects)-1]
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package knuu
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ReserveFreePortsTCP reserves n distinct free TCP ports on the host by listening on them
+// The ports stay reserved until the returned release function is called, which closes the listeners
+// Release the ports right before binding them, e.g. for port-forwards, so that no other process can take them in the meantime
+// The release function can be called multiple times
+func ReserveFreePortsTCP(n int) ([]int, func(), error) {
+	if n < 1 {
+		return nil, nil, fmt.Errorf("number of ports must be at least 1, got '%d'", n)
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+		})
+	}
+
+	ports := make([]int, 0, n)
+	for len(ports) < n {
+		// The listeners are held open, so the operating system never returns the same port twice
+		listener, err := net.Listen("tcp", ":0")
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("error reserving %d free ports, reserved %d: %w", n, len(ports), err)
+		}
+		listeners = append(listeners, listener)
+		ports = append(ports, listener.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, release, nil
+}
+
+const (
+	// autoPortMin is the first port allocated by AddPortTCPAuto
+	autoPortMin = 20000
+	// autoPortMax is the last port allocated by AddPortTCPAuto, below the default NodePort range of Kubernetes
+	autoPortMax = 29999
+)
+
+// autoPorts are the ports allocated by AddPortTCPAuto in this process, so that no port is allocated twice in a run
+var autoPorts = struct {
+	sync.Mutex
+	allocated map[int]bool
+	next      int
+}{allocated: make(map[int]bool), next: autoPortMin}
+
+// reservedLocalPorts are the local ports handed out by ReserveLocalPort in this process, which are not handed out again
+// even after their listener is released, as the consumer might not have bound them yet
+var reservedLocalPorts = struct {
+	sync.Mutex
+	ports map[int]bool
+}{ports: make(map[int]bool)}
+
+// AddPortTCPAuto allocates a TCP port that is not registered by any other instance of the session nor allocated before
+// in this process, registers it with the instance and returns it, so that it can be passed to the application via env or args
+// Ports are allocated from the range 20000-29999
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) AddPortTCPAuto() (int, error) {
+	if !i.IsInState(Preparing, Committed) {
+		return 0, i.errInvalidStateTransition("adding port", Preparing, Committed)
+	}
+	port, err := i.session().allocatePort()
+	if err != nil {
+		return 0, fmt.Errorf("error allocating port for instance '%s': %w", i.name, err)
+	}
+	if err := i.AddPortTCP(port); err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// allocatePort allocates the next port that is neither allocated nor registered by an instance of the session
+func (k *Knuu) allocatePort() (int, error) {
+	registered := make(map[int]bool)
+	if k != nil {
+		k.instancesMu.Lock()
+		for _, instance := range k.instances {
+			for _, port := range instance.portsTCP {
+				registered[port] = true
+			}
+			for _, port := range instance.portsUDP {
+				registered[port] = true
+			}
+		}
+		k.instancesMu.Unlock()
+	}
+
+	autoPorts.Lock()
+	defer autoPorts.Unlock()
+	for n := 0; n <= autoPortMax-autoPortMin; n++ {
+		port := autoPorts.next
+		autoPorts.next++
+		if autoPorts.next > autoPortMax {
+			autoPorts.next = autoPortMin
+		}
+		if !autoPorts.allocated[port] && !registered[port] {
+			autoPorts.allocated[port] = true
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("all ports from '%d' to '%d' are allocated", autoPortMin, autoPortMax)
+}
+
+// ReservedPort is a free local TCP port whose listener is kept open until the consumer takes it over,
+// so that no other process can bind the port in between
+type ReservedPort struct {
+	// Port is the reserved port
+	Port     int
+	listener net.Listener
+	mu       sync.Mutex
+}
+
+// ReserveLocalPort reserves a free local TCP port, e.g. for port forwarding, by keeping a listener on it open
+// Unlike ReserveFreePortsTCP, the consumer can take over the listener with Listener, or calls Release right before binding
+// the port itself, and a port is never reserved twice in this process, even after it is released
+func ReserveLocalPort() (*ReservedPort, error) {
+	reservedLocalPorts.Lock()
+	defer reservedLocalPorts.Unlock()
+
+	// Ports handed out before are skipped while their listener is held, so that the OS assigns another one
+	var skipped []net.Listener
+	defer func() {
+		for _, listener := range skipped {
+			listener.Close()
+		}
+	}()
+	for attempt := 0; attempt < 100; attempt++ {
+		listener, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return nil, fmt.Errorf("error reserving local port: %w", err)
+		}
+		port := listener.Addr().(*net.TCPAddr).Port
+		if reservedLocalPorts.ports[port] {
+			skipped = append(skipped, listener)
+			continue
+		}
+		reservedLocalPorts.ports[port] = true
+		return &ReservedPort{Port: port, listener: listener}, nil
+	}
+	return nil, fmt.Errorf("error reserving local port: no port was free that was not reserved before")
+}
+
+// Listener returns the open listener of the port, so that the consumer can serve on it directly
+// The consumer owns the listener afterwards and has to close it, Release does not close it anymore
+func (p *ReservedPort) Listener() net.Listener {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	listener := p.listener
+	p.listener = nil
+	return listener
+}
+
+// Release closes the listener of the port, so that the consumer can bind the port itself
+// It should be called right before binding the port, releasing a port twice or after Listener is a no-op
+func (p *ReservedPort) Release() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener == nil {
+		return nil
+	}
+	err := p.listener.Close()
+	p.listener = nil
+	if err != nil {
+		return fmt.Errorf("error releasing local port '%d': %w", p.Port, err)
+	}
+	return nil
+}
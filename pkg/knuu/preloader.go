@@ -2,7 +2,6 @@ package knuu
 
 import (
 	"fmt"
-	"github.com/celestiaorg/knuu/pkg/k8s"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -12,10 +11,16 @@ import (
 type Preloader struct {
 	k8sName string   `json:"k8sName"`
 	images  []string `json:"images"`
+	knuu    *Knuu
 }
 
-// NewPreloader creates a new preloader
+// NewPreloader creates a new preloader in the default session
 func NewPreloader() (*Preloader, error) {
+	return defaultKnuu.NewPreloader()
+}
+
+// NewPreloader creates a new preloader in the session
+func (k *Knuu) NewPreloader() (*Preloader, error) {
 	k8sName, err := generateK8sName("knuu-preloader")
 	if err != nil {
 		return nil, fmt.Errorf("error generating k8s name for preloader: %w", err)
@@ -23,6 +28,7 @@ func NewPreloader() (*Preloader, error) {
 	return &Preloader{
 		k8sName: k8sName,
 		images:  []string{},
+		knuu:    k,
 	}, nil
 }
 
@@ -63,7 +69,7 @@ func (p *Preloader) EmptyImages() error {
 func (p *Preloader) preloadImages() error {
 	// delete the daemonset if no images are preloaded
 	if len(p.images) == 0 {
-		return k8s.DeleteDaemonSet(k8s.Namespace(), p.k8sName)
+		return p.session().client().DeleteDaemonSet(p.session().Namespace(), p.k8sName)
 	}
 	var initContainers []v1.Container
 
@@ -86,25 +92,30 @@ func (p *Preloader) preloadImages() error {
 		Image: "k8s.gcr.io/pause",
 	})
 
-	labels := map[string]string{
-		"app":                          p.k8sName,
-		"k8s.kubernetes.io/managed-by": "knuu",
-		"test-run-id":                  identifier,
-		"test-started":                 startTime,
-	}
+	labels := p.session().labels()
+	labels["app"] = p.k8sName
 
-	exists, err := k8s.DaemonSetExists(k8s.Namespace(), p.k8sName)
+	exists, err := p.session().client().DaemonSetExists(p.session().Namespace(), p.k8sName)
 	if err != nil {
 		return err
 	}
 
 	// update the daemonset if it already exists
 	if exists {
-		_, err = k8s.UpdateDaemonSet(k8s.Namespace(), p.k8sName, labels, initContainers, containers)
+		_, err = p.session().client().UpdateDaemonSet(p.session().Namespace(), p.k8sName, labels, initContainers, containers)
 		return err
 	}
 
 	// create the daemonset if it doesn't exist
-	_, err = k8s.CreateDaemonSet(k8s.Namespace(), p.k8sName, labels, initContainers, containers)
+	_, err = p.session().client().CreateDaemonSet(p.session().Namespace(), p.k8sName, labels, initContainers, containers)
 	return err
 }
+
+// session returns the session of the preloader
+// Preloaders created before knuu was initialized use the default session
+func (p *Preloader) session() *Knuu {
+	if p.knuu != nil {
+		return p.knuu
+	}
+	return defaultKnuu
+}
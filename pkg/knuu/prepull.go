@@ -0,0 +1,175 @@
+package knuu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+	"time"
+)
+
+// prePullTimeout is the time PrePullImages waits for the images to be pulled on all nodes
+const prePullTimeout = 15 * time.Minute
+
+// prePullPollInterval is the interval in which PrePullImages checks the progress of the pulls
+const prePullPollInterval = 2 * time.Second
+
+// imagePullFailureReasons are the reasons of waiting containers whose image cannot be pulled
+var imagePullFailureReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// PrePullImages pulls the images on all nodes of the default session, see Knuu.PrePullImages
+func PrePullImages(images []string) error {
+	return defaultKnuu.PrePullImages(images)
+}
+
+// PrePullImages pulls the images on all nodes, so that starting many pods of them does not pull them concurrently
+// It creates a short-lived daemonSet whose init containers run a no-op command with the images, so the images must provide /bin/sh
+// It waits until every node pulled the images or failed to, and deletes the daemonSet afterwards
+// Nodes that fail to pull an image are logged as warnings, an error is only returned if no node pulled the images
+func (k *Knuu) PrePullImages(images []string) error {
+	if len(images) == 0 {
+		return nil
+	}
+	for _, image := range images {
+		if err := validateImageName(image); err != nil {
+			return err
+		}
+	}
+	k8sName, err := generateK8sName("knuu-prepull")
+	if err != nil {
+		return fmt.Errorf("error generating k8s name for pre-pull: %w", err)
+	}
+
+	var initContainers []v1.Container
+	for j, image := range images {
+		initContainers = append(initContainers, v1.Container{
+			Name:            fmt.Sprintf("image%d-prepull", j),
+			Image:           image,
+			ImagePullPolicy: v1.PullIfNotPresent,
+			Command:         []string{"/bin/sh", "-c", "exit 0"},
+		})
+	}
+	containers := []v1.Container{
+		{
+			Name:  "pause-container",
+			Image: "k8s.gcr.io/pause",
+		},
+	}
+	labels := k.labels()
+	labels["app"] = k8sName
+
+	if _, err := k.client().CreateDaemonSet(k.Namespace(), k8sName, labels, initContainers, containers); err != nil {
+		return fmt.Errorf("error creating pre-pull daemonSet: %w", err)
+	}
+	defer func() {
+		if err := k.client().DeleteDaemonSet(k.Namespace(), k8sName); err != nil {
+			log.Warnf("Error deleting pre-pull daemonSet '%s': %v", k8sName, err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), prePullTimeout)
+	defer cancel()
+	pulled, failures, err := k.waitForPrePull(ctx, k8sName, labels)
+	if err != nil {
+		return err
+	}
+
+	nodes := make([]string, 0, len(failures))
+	for node := range failures {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	var errs []error
+	for _, node := range nodes {
+		log.Warnf("Pre-pulling images on node '%s' failed: %s", node, failures[node])
+		errs = append(errs, fmt.Errorf("node '%s': %s", node, failures[node]))
+	}
+	if pulled == 0 && len(errs) != 0 {
+		return fmt.Errorf("error pre-pulling images '%s' on all nodes: %w", strings.Join(images, "', '"), errors.Join(errs...))
+	}
+	log.Debugf("Pre-pulled images '%s' on %d nodes", strings.Join(images, "', '"), pulled)
+	return nil
+}
+
+// waitForPrePull waits until the pods of the pre-pull daemonSet pulled the images or failed to
+// It returns the number of nodes that pulled the images and the failure of every other node, by node name
+// Nodes that are still pulling when the context is done are reported as failures
+func (k *Knuu) waitForPrePull(ctx context.Context, k8sName string, labels map[string]string) (int, map[string]string, error) {
+	ticker := time.NewTicker(prePullPollInterval)
+	defer ticker.Stop()
+
+	for {
+		daemonSet, err := k.client().GetDaemonSet(k.Namespace(), k8sName)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error getting pre-pull daemonSet: %w", err)
+		}
+		pods, err := k.client().ListPods(k.Namespace(), labels)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error getting pods of pre-pull daemonSet: %w", err)
+		}
+
+		pulled := 0
+		failures := make(map[string]string)
+		pending := make(map[string]string)
+		for _, pod := range pods {
+			node := pod.Spec.NodeName
+			if node == "" {
+				node = pod.Name
+			}
+			done, failure := prePullPodStatus(pod)
+			switch {
+			case done:
+				pulled++
+			case failure != "":
+				failures[node] = failure
+			default:
+				pending[node] = "images are still being pulled"
+			}
+		}
+		desired := int(daemonSet.Status.DesiredNumberScheduled)
+		if desired > 0 && len(pods) >= desired && len(pending) == 0 {
+			return pulled, failures, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			for node, reason := range pending {
+				failures[node] = reason
+			}
+			if pulled == 0 && len(failures) == 0 {
+				return 0, nil, fmt.Errorf("timeout while waiting for pre-pull daemonSet '%s' to be scheduled: %w", k8sName, ctx.Err())
+			}
+			return pulled, failures, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// prePullPodStatus checks if the pod of the pre-pull daemonSet pulled all images, or returns why it failed to
+func prePullPodStatus(pod v1.Pod) (bool, string) {
+	if pod.Status.Phase == v1.PodRunning {
+		return true, ""
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil {
+			if imagePullFailureReasons[waiting.Reason] {
+				return false, fmt.Sprintf("cannot pull image '%s': %s: %s", status.Image, waiting.Reason, waiting.Message)
+			}
+			if waiting.Reason == "CrashLoopBackOff" || waiting.Reason == "CreateContainerError" || waiting.Reason == "RunContainerError" {
+				return false, fmt.Sprintf("image '%s' cannot run the no-op command, it must provide /bin/sh: %s", status.Image, waiting.Reason)
+			}
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			return false, fmt.Sprintf("image '%s' cannot run the no-op command, it must provide /bin/sh: exit code %d", status.Image, terminated.ExitCode)
+		}
+	}
+	return false, ""
+}
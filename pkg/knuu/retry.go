@@ -0,0 +1,85 @@
+package knuu
+
+import (
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"time"
+)
+
+// defaultAPIRetryMaxAttempts is the maximum number of attempts of a Kubernetes API call if SetAPIRetryPolicy is not used
+const defaultAPIRetryMaxAttempts = 5
+
+// defaultAPIRetryBaseDelay is the delay before the first retry if SetAPIRetryPolicy is not used
+const defaultAPIRetryBaseDelay = 500 * time.Millisecond
+
+// apiRetryMaxAttempts is the maximum number of attempts of a Kubernetes API call, set by SetAPIRetryPolicy
+var apiRetryMaxAttempts = defaultAPIRetryMaxAttempts
+
+// apiRetryBaseDelay is the delay before the first retry, it is doubled for every further retry, set by SetAPIRetryPolicy
+var apiRetryBaseDelay = defaultAPIRetryBaseDelay
+
+// SetAPIRetryPolicy sets how often and how fast transient Kubernetes API errors are retried
+// Only timeouts, throttling, server and connection errors are retried, never validation errors
+// The delay between retries starts at baseDelay and doubles with every attempt
+func SetAPIRetryPolicy(maxAttempts int, baseDelay time.Duration) error {
+	if err := validateAPIRetryPolicy(maxAttempts, baseDelay); err != nil {
+		return err
+	}
+	apiRetryMaxAttempts = maxAttempts
+	apiRetryBaseDelay = baseDelay
+	if defaultKnuu != nil {
+		return defaultKnuu.SetAPIRetryPolicy(maxAttempts, baseDelay)
+	}
+	return nil
+}
+
+// SetAPIRetryPolicy sets how often and how fast transient Kubernetes API errors of the session are retried, see SetAPIRetryPolicy
+func (k *Knuu) SetAPIRetryPolicy(maxAttempts int, baseDelay time.Duration) error {
+	if err := validateAPIRetryPolicy(maxAttempts, baseDelay); err != nil {
+		return err
+	}
+	k.apiRetryMaxAttempts = maxAttempts
+	k.apiRetryBaseDelay = baseDelay
+	log.Debugf("Set API retry policy to '%d' attempts with a base delay of '%s'", maxAttempts, baseDelay)
+	return nil
+}
+
+// validateAPIRetryPolicy checks the arguments of SetAPIRetryPolicy
+func validateAPIRetryPolicy(maxAttempts int, baseDelay time.Duration) error {
+	if maxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, got '%d'", maxAttempts)
+	}
+	if baseDelay < 0 {
+		return fmt.Errorf("base delay must not be negative, got '%s'", baseDelay)
+	}
+	return nil
+}
+
+// retryAPICall calls the given function until it succeeds, fails with a non-retriable error or the attempts are exhausted
+// If a retried call fails because the object already exists, the previous attempt created it although its response was lost,
+// so the call succeeded
+func (k *Knuu) retryAPICall(description string, call func() error) error {
+	maxAttempts, delay := apiRetryMaxAttempts, apiRetryBaseDelay
+	if k != nil {
+		maxAttempts, delay = k.apiRetryMaxAttempts, k.apiRetryBaseDelay
+	}
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = call()
+		if err != nil && attempt > 1 && k8s.IsAlreadyExistsError(err) {
+			log.Debugf("Succeeded %s in a previous attempt, as the object already exists", description)
+			return nil
+		}
+		if err == nil || !k8s.IsRetriableError(err) {
+			return err
+		}
+		if attempt == maxAttempts {
+			break
+		}
+		log.Debugf("Retrying %s in '%s' (attempt %d/%d) after error: %v", description, delay, attempt+1, maxAttempts, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return fmt.Errorf("giving up on %s after %d attempts: %w", description, maxAttempts, err)
+}
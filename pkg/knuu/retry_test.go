@@ -0,0 +1,63 @@
+package knuu
+
+import (
+	"testing"
+	"time"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetryAPICall(t *testing.T) {
+	noDelay := time.Duration(0)
+	k, _ := newTestKnuu(t, Options{APIRetryMaxAttempts: 3, APIRetryBaseDelay: &noDelay})
+	pods := schema.GroupResource{Resource: "pods"}
+	timeout := apierrs.NewServerTimeout(pods, "create", 0)
+	alreadyExists := apierrs.NewAlreadyExists(pods, "pod")
+
+	tests := []struct {
+		name     string
+		errs     []error
+		wantErr  bool
+		attempts int
+	}{
+		{name: "success", errs: []error{nil}, attempts: 1},
+		{name: "retried until success", errs: []error{timeout, timeout, nil}, attempts: 3},
+		{name: "gives up after the attempts of the session", errs: []error{timeout, timeout, timeout, nil}, wantErr: true, attempts: 3},
+		{name: "already exists after a lost response", errs: []error{timeout, alreadyExists}, attempts: 2},
+		{name: "already exists in the first attempt", errs: []error{alreadyExists}, wantErr: true, attempts: 1},
+		{name: "not retriable", errs: []error{apierrs.NewBadRequest("invalid"), nil}, wantErr: true, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := k.retryAPICall("creating pod", func() error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got %v", tt.wantErr, err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+		})
+	}
+}
+
+func TestAPIRetryPolicyIsPerSession(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{APIRetryMaxAttempts: 2})
+	other, _ := newTestKnuu(t, Options{})
+	if k.apiRetryMaxAttempts != 2 || k.apiRetryBaseDelay != defaultAPIRetryBaseDelay {
+		t.Errorf("expected 2 attempts with the default delay, got %d attempts with '%s'", k.apiRetryMaxAttempts, k.apiRetryBaseDelay)
+	}
+	if other.apiRetryMaxAttempts != defaultAPIRetryMaxAttempts {
+		t.Errorf("expected the default attempts in another session, got %d", other.apiRetryMaxAttempts)
+	}
+	if err := k.SetAPIRetryPolicy(0, 0); err == nil {
+		t.Errorf("expected an invalid policy to fail")
+	}
+	if _, err := New(Options{Clientset: k.client().Clientset(), Namespace: testNamespace, DisableTimeoutHandler: true, APIRetryMaxAttempts: -1}); err == nil {
+		t.Errorf("expected a session with an invalid policy to fail")
+	}
+}
@@ -0,0 +1,251 @@
+package knuu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/k8s"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"path"
+	"sync"
+	"time"
+)
+
+// sharedVolumeBindTimeout is the time to wait for a shared volume to be provisioned by the default storage class
+const sharedVolumeBindTimeout = 1 * time.Minute
+
+// SharedVolume is a ReadWriteMany volume that can be mounted by multiple instances, e.g. to exchange files between them
+// Its persistent volume claim is deleted when the last instance mounting it is destroyed, or by CleanUp if no started instance mounts it
+type SharedVolume struct {
+	name    string
+	k8sName string
+	size    string
+	knuu    *Knuu
+
+	mu sync.Mutex
+	// mounts are the instances mounting the volume that are not destroyed yet
+	mounts  map[*Instance]struct{}
+	deleted bool
+}
+
+// NewSharedVolume creates a shared volume of the given size in the default session
+func NewSharedVolume(name, size string) (*SharedVolume, error) {
+	if defaultKnuu == nil {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	return defaultKnuu.NewSharedVolume(name, size)
+}
+
+// NewSharedVolume creates a shared volume of the given size in the session
+// It fails if the cluster's default storage class cannot provision ReadWriteMany volumes
+// If the storage class only provisions volumes when a pod is scheduled, this is only detected when the first instance mounting it is started
+func (k *Knuu) NewSharedVolume(name, size string) (*SharedVolume, error) {
+	if err := validateInstanceName(name); err != nil {
+		return nil, fmt.Errorf("invalid shared volume name: %w", err)
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid size '%s' of shared volume '%s': %w", size, name, err)
+	}
+	k8sName, err := generateK8sName(name)
+	if err != nil {
+		return nil, fmt.Errorf("error generating k8s name for shared volume '%s': %w", name, err)
+	}
+	v := &SharedVolume{
+		name:    name,
+		k8sName: k8sName,
+		size:    size,
+		knuu:    k,
+		mounts:  make(map[*Instance]struct{}),
+	}
+
+	err = k.retryAPICall(fmt.Sprintf("deploying shared persistent volume claim '%s'", k8sName), func() error {
+		return k.k8sClient.DeploySharedPersistentVolumeClaim(k.Namespace(), k8sName, v.labels(), quantity)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error deploying shared volume '%s': %w", k8sName, err)
+	}
+	if err := v.waitIsBound(); err != nil {
+		if deleteErr := v.delete(); deleteErr != nil {
+			return nil, errors.Join(err, deleteErr)
+		}
+		return nil, err
+	}
+
+	k.registerSharedVolume(v)
+	log.Debugf("Created shared volume '%s' of size '%s'", k8sName, size)
+	return v, nil
+}
+
+// Name returns the name of the shared volume
+func (v *SharedVolume) Name() string {
+	return v.name
+}
+
+// K8sName returns the name of the shared volume's persistent volume claim
+func (v *SharedVolume) K8sName() string {
+	return v.k8sName
+}
+
+// MountSharedVolume mounts the shared volume at the given path in the instance
+// The shared volume must belong to the same session as the instance
+// This function can only be called in the states 'Preparing' and 'Committed'
+func (i *Instance) MountSharedVolume(v *SharedVolume, mountPath string) error {
+	if !i.IsInState(Preparing, Committed) {
+		return i.errInvalidStateTransition("mounting shared volume", Preparing, Committed)
+	}
+	if v == nil {
+		return fmt.Errorf("shared volume must not be nil")
+	}
+	if v.knuu != i.session() {
+		return fmt.Errorf("shared volume '%s' belongs to another session than instance '%s'", v.k8sName, i.name)
+	}
+	if !path.IsAbs(mountPath) {
+		return fmt.Errorf("mount path '%s' must be absolute", mountPath)
+	}
+	if err := v.mount(i); err != nil {
+		return err
+	}
+	i.sharedVolumes = append(i.sharedVolumes, v)
+	i.claimVolumes = append(i.claimVolumes, k8s.NewClaimVolume(v.k8sName, mountPath))
+	i.logger().Debugf("Mounted shared volume '%s' at '%s' in instance '%s'", v.k8sName, mountPath, i.name)
+	return nil
+}
+
+// mount registers the instance as mounting the volume
+func (v *SharedVolume) mount(i *Instance) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.deleted {
+		return fmt.Errorf("shared volume '%s' is already deleted", v.k8sName)
+	}
+	v.mounts[i] = struct{}{}
+	return nil
+}
+
+// release unregisters the destroyed instance and deletes the volume if no other instance mounts it
+func (v *SharedVolume) release(i *Instance) error {
+	v.mu.Lock()
+	delete(v.mounts, i)
+	remaining := len(v.mounts)
+	v.mu.Unlock()
+
+	if remaining != 0 {
+		return nil
+	}
+	return v.delete()
+}
+
+// inUse checks if a started or stopped instance mounts the volume
+func (v *SharedVolume) inUse() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.mounts {
+		if i.IsInState(Started, Stopped) {
+			return true
+		}
+	}
+	return false
+}
+
+// delete deletes the volume's persistent volume claim
+func (v *SharedVolume) delete() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.deleted {
+		return nil
+	}
+	err := v.knuu.retryAPICall(fmt.Sprintf("deleting shared persistent volume claim '%s'", v.k8sName), func() error {
+		return v.knuu.k8sClient.DeletePersistentVolumeClaim(v.knuu.Namespace(), v.k8sName)
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting shared volume '%s': %w", v.k8sName, err)
+	}
+	v.deleted = true
+	log.Debugf("Deleted shared volume '%s'", v.k8sName)
+	return nil
+}
+
+// waitIsBound waits until the volume is provisioned, if the default storage class provisions volumes immediately
+// A volume that is not provisioned in time usually means the storage class does not support ReadWriteMany,
+// so the error includes the last warning of the persistent volume claim
+func (v *SharedVolume) waitIsBound() error {
+	bindsImmediately, err := v.knuu.k8sClient.StorageClassBindsImmediately("")
+	if err != nil {
+		return fmt.Errorf("cannot create shared volume '%s': %w", v.k8sName, err)
+	}
+	if !bindsImmediately {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedVolumeBindTimeout)
+	defer cancel()
+	err = v.knuu.k8sClient.WaitPersistentVolumeClaimIsBound(ctx, v.knuu.Namespace(), v.k8sName, v.labels())
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("error waiting for shared volume '%s' to be bound: %w", v.k8sName, err)
+	}
+	warnings, warningsErr := v.knuu.k8sClient.GetWarningEvents(v.knuu.Namespace(), v.k8sName)
+	if warningsErr != nil || len(warnings) == 0 {
+		return fmt.Errorf("timeout while waiting for shared volume '%s' to be bound, the default storage class may not support ReadWriteMany volumes", v.k8sName)
+	}
+	lastWarning := warnings[len(warnings)-1]
+	return fmt.Errorf("timeout while waiting for shared volume '%s' to be bound, the default storage class may not support ReadWriteMany volumes: %s: %s", v.k8sName, lastWarning.Reason, lastWarning.Message)
+}
+
+// labels returns the labels of the volume's persistent volume claim
+func (v *SharedVolume) labels() map[string]string {
+	labels := v.knuu.labels()
+	labels["app"] = v.k8sName
+	labels["name"] = v.name
+	labels["k8s-name"] = v.k8sName
+	return labels
+}
+
+// releaseSharedVolumes releases the shared volumes mounted by the destroyed instance
+func (i *Instance) releaseSharedVolumes() error {
+	var errs []error
+	for _, v := range i.sharedVolumes {
+		if err := v.release(i); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cloneSharedVolumes registers the clone as mounting the shared volumes of the instance
+func (i *Instance) cloneSharedVolumes(clone *Instance) {
+	for _, v := range i.sharedVolumes {
+		// The volume can only be deleted once the instance is destroyed, which is not possible in the states clones are created in
+		_ = v.mount(clone)
+	}
+}
+
+// registerSharedVolume adds the shared volume to the volumes of the session
+func (k *Knuu) registerSharedVolume(v *SharedVolume) {
+	k.sharedVolumesMu.Lock()
+	defer k.sharedVolumesMu.Unlock()
+	k.sharedVolumes = append(k.sharedVolumes, v)
+}
+
+// deleteUnusedSharedVolumes deletes the shared volumes of the session that are not mounted by a started or stopped instance
+// Volumes mounted by such instances are deleted when the last of them is destroyed
+func (k *Knuu) deleteUnusedSharedVolumes() error {
+	k.sharedVolumesMu.Lock()
+	volumes := append([]*SharedVolume(nil), k.sharedVolumes...)
+	k.sharedVolumesMu.Unlock()
+
+	var errs []error
+	for _, v := range volumes {
+		if v.inUse() {
+			continue
+		}
+		if err := v.delete(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+package knuu
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSharedVolumeIsMountedByMultipleInstances(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	ctx := context.Background()
+	defaultStorageClass := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "standard", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+		Provisioner: "fake",
+	}
+	if _, err := cluster.StorageV1().StorageClasses().Create(ctx, defaultStorageClass, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating storage class: %v", err)
+	}
+
+	volume, err := k.NewSharedVolume("exchange", "1Gi")
+	if err != nil {
+		t.Fatalf("creating shared volume: %v", err)
+	}
+	claim, err := cluster.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, volume.K8sName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting persistent volume claim of shared volume: %v", err)
+	}
+	if len(claim.Spec.AccessModes) != 1 || claim.Spec.AccessModes[0] != v1.ReadWriteMany {
+		t.Errorf("persistent volume claim of shared volume has access modes %v, expected ReadWriteMany", claim.Spec.AccessModes)
+	}
+
+	// One instance writes a snapshot to the volume and the other one imports it
+	mountPaths := map[string]string{"writer": "/snapshots", "reader": "/import"}
+	instances := make(map[string]*Instance)
+	for name, mountPath := range mountPaths {
+		instance := newTestInstance(t, k, name)
+		if err := instance.MountSharedVolume(volume, mountPath); err != nil {
+			t.Fatalf("mounting shared volume in instance '%s': %v", name, err)
+		}
+		if err := instance.Start(); err != nil {
+			t.Fatalf("starting instance '%s': %v", name, err)
+		}
+		instances[name] = instance
+	}
+
+	for name, instance := range instances {
+		pod, err := cluster.CoreV1().Pods(testNamespace).Get(ctx, instance.k8sName+"-0", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("getting pod of instance '%s': %v", name, err)
+		}
+		if !mountsClaim(pod, volume.K8sName(), mountPaths[name]) {
+			t.Errorf("pod of instance '%s' does not mount the claim '%s' at '%s'", name, volume.K8sName(), mountPaths[name])
+		}
+	}
+
+	// The claim is only deleted once the last instance mounting it is destroyed
+	if err := instances["writer"].Destroy(); err != nil {
+		t.Fatalf("destroying writer: %v", err)
+	}
+	if _, err := cluster.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, volume.K8sName(), metav1.GetOptions{}); err != nil {
+		t.Fatalf("getting persistent volume claim of shared volume mounted by reader: %v", err)
+	}
+	if err := instances["reader"].Destroy(); err != nil {
+		t.Fatalf("destroying reader: %v", err)
+	}
+	if _, err := cluster.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, volume.K8sName(), metav1.GetOptions{}); !apierrs.IsNotFound(err) {
+		t.Errorf("expected persistent volume claim of shared volume to be deleted, got %v", err)
+	}
+}
+
+func TestSharedVolumeRequiresDefaultStorageClass(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{})
+	if _, err := k.NewSharedVolume("exchange", "1Gi"); err == nil {
+		t.Fatal("expected error creating shared volume without a default storage class")
+	}
+	claims, err := cluster.CoreV1().PersistentVolumeClaims(testNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing persistent volume claims: %v", err)
+	}
+	if len(claims.Items) != 0 {
+		t.Errorf("expected the persistent volume claim of the failed shared volume to be deleted, found %d", len(claims.Items))
+	}
+}
+
+// mountsClaim checks if the container of the pod mounts the persistent volume claim at the path
+func mountsClaim(pod *v1.Pod, claimName, mountPath string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil || volume.PersistentVolumeClaim.ClaimName != claimName {
+			continue
+		}
+		for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+			if mount.Name == volume.Name && mount.MountPath == mountPath {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,350 @@
+package knuu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"io"
+	"os"
+	"sigs.k8s.io/yaml"
+	"sort"
+)
+
+// topologyVersion is the version of the topology schema
+const topologyVersion = "knuu.topology/v1"
+
+// topology is the serialized form of all instances of a test
+type topology struct {
+	Version   string             `json:"version"`
+	Instances []topologyInstance `json:"instances"`
+}
+
+// topologyInstance is the serialized form of an instance
+type topologyInstance struct {
+	Name      string            `json:"name"`
+	Image     string            `json:"image"`
+	User      string            `json:"user,omitempty"`
+	Command   []string          `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	PortsTCP  []int             `json:"portsTCP,omitempty"`
+	PortsUDP  []int             `json:"portsUDP,omitempty"`
+	Volumes   []topologyVolume  `json:"volumes,omitempty"`
+	Resources topologyResources `json:"resources,omitempty"`
+	Files     []topologyFile    `json:"files,omitempty"`
+}
+
+// topologyVolume is the serialized form of a volume
+type topologyVolume struct {
+	Path    string `json:"path"`
+	Size    string `json:"size"`
+	Owner   int64  `json:"owner,omitempty"`
+	SubPath string `json:"subPath,omitempty"`
+}
+
+// topologyResources is the serialized form of the resources of an instance
+type topologyResources struct {
+	MemoryRequest           string            `json:"memoryRequest,omitempty"`
+	MemoryLimit             string            `json:"memoryLimit,omitempty"`
+	CPURequest              string            `json:"cpuRequest,omitempty"`
+	CPULimit                string            `json:"cpuLimit,omitempty"`
+	EphemeralStorageRequest string            `json:"ephemeralStorageRequest,omitempty"`
+	EphemeralStorageLimit   string            `json:"ephemeralStorageLimit,omitempty"`
+	ExtendedResources       map[string]string `json:"extendedResources,omitempty"`
+}
+
+// topologyFile is the serialized form of a file added to an instance
+// Content is embedded for files added with AddFileBytes, other files are referenced by their source path and digest
+type topologyFile struct {
+	Dest    string `json:"dest"`
+	Chown   string `json:"chown"`
+	SHA256  string `json:"sha256"`
+	Source  string `json:"source,omitempty"`
+	Content []byte `json:"content,omitempty"`
+}
+
+// registerInstance adds the instance to the instances exported by ExportTopology
+// Instances without a session (created before knuu was initialized) are not registered, and instances are unregistered when
+// they are destroyed
+func (k *Knuu) registerInstance(instance *Instance) {
+	if k == nil {
+		return
+	}
+	k.instancesMu.Lock()
+	defer k.instancesMu.Unlock()
+	k.instances = append(k.instances, instance)
+}
+
+// unregisterInstance removes a destroyed instance, or an instance that is internal to knuu, from the instances exported
+// by ExportTopology
+func (k *Knuu) unregisterInstance(instance *Instance) {
+	if k == nil {
+		return
+	}
+	k.instancesMu.Lock()
+	defer k.instancesMu.Unlock()
+	for j, registered := range k.instances {
+		if registered == instance {
+			k.instances = append(k.instances[:j], k.instances[j+1:]...)
+			return
+		}
+	}
+}
+
+// ExportTopology serializes all instances of the default session to versioned YAML, see Knuu.ExportTopology
+func ExportTopology() ([]byte, error) {
+	if defaultKnuu == nil {
+		return nil, fmt.Errorf("knuu is not initialized")
+	}
+	return defaultKnuu.ExportTopology()
+}
+
+// ExportTopology serializes all instances of the session that have an image and are not destroyed to versioned YAML
+// It captures image, user, command, args, env, ports, volumes, resources and the files added to the image
+// Files added with AddFileBytes are embedded, other files are referenced by their source path and SHA-256 digest
+// Executor instances and instances of knuu itself are not exported
+func (k *Knuu) ExportTopology() ([]byte, error) {
+	k.instancesMu.Lock()
+	instances := make([]*Instance, len(k.instances))
+	copy(instances, k.instances)
+	k.instancesMu.Unlock()
+
+	t := topology{
+		Version:   topologyVersion,
+		Instances: []topologyInstance{},
+	}
+	for _, instance := range instances {
+		if instance.IsInState(None, Destroyed) || instance.instanceType != BasicInstance {
+			continue
+		}
+		t.Instances = append(t.Instances, instance.toTopology())
+	}
+
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling topology: %w", err)
+	}
+	log.Debugf("Exported topology with %d instances", len(t.Instances))
+	return data, nil
+}
+
+// ImportTopology creates the instances of a topology in the default session, see Knuu.ImportTopology
+func ImportTopology(data []byte) ([]*Instance, error) {
+	return defaultKnuu.ImportTopology(data)
+}
+
+// ImportTopology creates the instances of a topology exported with ExportTopology in the session
+// The instances are in state 'Preparing', so they can be modified before they are committed
+// Files referenced by their source path must exist and match their digest
+func (k *Knuu) ImportTopology(data []byte) ([]*Instance, error) {
+	var t topology
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return nil, fmt.Errorf("error unmarshalling topology: %w", err)
+	}
+	if t.Version != topologyVersion {
+		return nil, fmt.Errorf("unsupported topology version '%s', expected '%s'", t.Version, topologyVersion)
+	}
+
+	instances := make([]*Instance, 0, len(t.Instances))
+	for _, ti := range t.Instances {
+		instance, err := ti.toInstance(k)
+		if err != nil {
+			return nil, fmt.Errorf("error importing instance '%s': %w", ti.Name, err)
+		}
+		instances = append(instances, instance)
+	}
+	log.Debugf("Imported topology with %d instances", len(instances))
+	return instances, nil
+}
+
+// toTopology serializes the instance
+func (i *Instance) toTopology() topologyInstance {
+	env := make(map[string]string, len(i.imageEnv)+len(i.env))
+	for key, value := range i.imageEnv {
+		env[key] = value
+	}
+	for key, value := range i.env {
+		env[key] = value
+	}
+
+	volumes := make([]topologyVolume, 0, len(i.volumes))
+	for _, volume := range i.volumes {
+		volumes = append(volumes, topologyVolume{
+			Path:    volume.Path,
+			Size:    volume.Size,
+			Owner:   volume.Owner,
+			SubPath: volume.SubPath,
+		})
+	}
+
+	files := make([]topologyFile, 0, len(i.files))
+	for _, file := range i.files {
+		files = append(files, topologyFile{
+			Dest:    file.dest,
+			Chown:   file.chown,
+			SHA256:  i.fileChecksums[file.dest],
+			Source:  file.src,
+			Content: file.content,
+		})
+	}
+
+	image := i.imageName
+	if i.builderFactory != nil {
+		image = i.builderFactory.ImageNameFrom()
+	}
+
+	return topologyInstance{
+		Name:     i.name,
+		Image:    image,
+		User:     i.user,
+		Command:  i.command,
+		Args:     i.args,
+		Env:      env,
+		PortsTCP: i.portsTCP,
+		PortsUDP: i.portsUDP,
+		Volumes:  volumes,
+		Resources: topologyResources{
+			MemoryRequest:           i.memoryRequest,
+			MemoryLimit:             i.memoryLimit,
+			CPURequest:              i.cpuRequest,
+			CPULimit:                i.cpuLimit,
+			EphemeralStorageRequest: i.ephemeralStorageRequest,
+			EphemeralStorageLimit:   i.ephemeralStorageLimit,
+			ExtendedResources:       i.extendedResources,
+		},
+		Files: files,
+	}
+}
+
+// toInstance creates an instance in state 'Preparing' in the session from its serialized form
+func (ti topologyInstance) toInstance(k *Knuu) (*Instance, error) {
+	instance, err := k.NewInstance(ti.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := instance.SetImage(ti.Image); err != nil {
+		return nil, err
+	}
+	if ti.User != "" {
+		if err := instance.SetUser(ti.User); err != nil {
+			return nil, err
+		}
+	}
+	if len(ti.Command) != 0 {
+		if err := instance.SetCommand(ti.Command...); err != nil {
+			return nil, err
+		}
+	}
+	if len(ti.Args) != 0 {
+		if err := instance.SetArgs(ti.Args...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sort the keys, so that the image is built the same way every time
+	keys := make([]string, 0, len(ti.Env))
+	for key := range ti.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := instance.SetEnvironmentVariable(key, ti.Env[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, port := range ti.PortsTCP {
+		if err := instance.AddPortTCP(port); err != nil {
+			return nil, err
+		}
+	}
+	for _, port := range ti.PortsUDP {
+		if err := instance.AddPortUDP(port); err != nil {
+			return nil, err
+		}
+	}
+	for _, volume := range ti.Volumes {
+		if volume.SubPath != "" {
+			err = instance.AddVolumeWithSubPath(volume.Path, volume.SubPath, volume.Size, volume.Owner)
+		} else {
+			err = instance.AddVolumeWithOwner(volume.Path, volume.Size, volume.Owner)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resources := ti.Resources
+	if resources.MemoryRequest != "" || resources.MemoryLimit != "" {
+		if err := instance.SetMemory(resources.MemoryRequest, resources.MemoryLimit); err != nil {
+			return nil, err
+		}
+	}
+	if resources.CPURequest != "" {
+		if err := instance.SetCPU(resources.CPURequest); err != nil {
+			return nil, err
+		}
+	}
+	if resources.CPULimit != "" {
+		if err := instance.SetCPULimit(resources.CPULimit); err != nil {
+			return nil, err
+		}
+	}
+	if resources.EphemeralStorageRequest != "" || resources.EphemeralStorageLimit != "" {
+		if err := instance.SetEphemeralStorage(resources.EphemeralStorageRequest, resources.EphemeralStorageLimit); err != nil {
+			return nil, err
+		}
+	}
+	for name, quantity := range resources.ExtendedResources {
+		if err := instance.SetExtendedResource(name, quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range ti.Files {
+		if err := instance.importFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return instance, nil
+}
+
+// importFile adds a serialized file to the instance, verifying its digest
+func (i *Instance) importFile(file topologyFile) error {
+	if file.Source == "" {
+		if file.SHA256 != "" && sha256Hex(file.Content) != file.SHA256 {
+			return fmt.Errorf("embedded content of file '%s' does not match its digest", file.Dest)
+		}
+		return i.AddFileBytes(file.Content, file.Dest, file.Chown)
+	}
+
+	digest, err := fileSHA256(file.Source)
+	if err != nil {
+		return fmt.Errorf("error reading source '%s' of file '%s': %w", file.Source, file.Dest, err)
+	}
+	if file.SHA256 != "" && digest != file.SHA256 {
+		return fmt.Errorf("source '%s' of file '%s' has digest '%s', expected '%s'", file.Source, file.Dest, digest, file.SHA256)
+	}
+	return i.AddFile(file.Source, file.Dest, file.Chown)
+}
+
+// sha256Hex returns the hex encoded SHA-256 digest of the data
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// fileSHA256 returns the hex encoded SHA-256 digest of the file
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,24 @@
+package knuu
+
+import "testing"
+
+func TestDestroyedInstancesAreUnregistered(t *testing.T) {
+	k, _ := newTestKnuu(t, Options{})
+	destroyed := newTestInstance(t, k, "destroyed", 8080)
+	kept := newTestInstance(t, k, "kept", 8080)
+	for _, instance := range []*Instance{destroyed, kept} {
+		if err := instance.Start(); err != nil {
+			t.Fatalf("starting instance '%s': %v", instance.name, err)
+		}
+	}
+	if err := destroyed.Destroy(); err != nil {
+		t.Fatalf("destroying instance: %v", err)
+	}
+
+	k.instancesMu.Lock()
+	registered := append([]*Instance(nil), k.instances...)
+	k.instancesMu.Unlock()
+	if len(registered) != 1 || registered[0] != kept {
+		t.Errorf("expected only the instance that is not destroyed to be registered, got %d instances", len(registered))
+	}
+}
@@ -0,0 +1,52 @@
+package knuu
+
+import (
+	"context"
+	"fmt"
+	"github.com/celestiaorg/knuu/pkg/log"
+	"time"
+)
+
+// fallbackWaitTimeout is the default timeout of waits whose context has no deadline, if SetDefaultTimeout is not used
+const fallbackWaitTimeout = 5 * time.Minute
+
+// defaultWaitTimeout is the timeout of waits whose context has no deadline, set by SetDefaultTimeout
+var defaultWaitTimeout = fallbackWaitTimeout
+
+// SetDefaultTimeout sets the time the wait functions (e.g. WaitInstanceIsRunning, WaitInstanceIsStopped, WaitForDeleted,
+// WaitForRestart, WaitForMetric, WaitForQuorum and WaitUntilHTTPEndpointIsReady) wait at most if their context has no deadline,
+// so that a misconfigured test does not hang forever, 5 minutes by default
+// A context with a deadline overrides it for a single call
+func SetDefaultTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("default timeout must be positive, got '%s'", timeout)
+	}
+	defaultWaitTimeout = timeout
+	if defaultKnuu != nil {
+		return defaultKnuu.SetDefaultTimeout(timeout)
+	}
+	return nil
+}
+
+// SetDefaultTimeout sets the time the wait functions of the session's instances wait at most, see SetDefaultTimeout
+func (k *Knuu) SetDefaultTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("default timeout must be positive, got '%s'", timeout)
+	}
+	k.defaultWaitTimeout = timeout
+	log.Debugf("Set default timeout to '%s'", timeout)
+	return nil
+}
+
+// withDefaultTimeout returns the context with the default timeout of the session if it has no deadline, see SetDefaultTimeout
+// Without a session, the default timeout set by SetDefaultTimeout is used
+func (k *Knuu) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	timeout := defaultWaitTimeout
+	if k != nil && k.defaultWaitTimeout != 0 {
+		timeout = k.defaultWaitTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
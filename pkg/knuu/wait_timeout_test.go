@@ -0,0 +1,54 @@
+package knuu
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWaitsUseDefaultTimeoutOfSession(t *testing.T) {
+	k, cluster := newTestKnuu(t, Options{DefaultTimeout: 200 * time.Millisecond})
+	ctx, cancel := k.withDefaultTimeout(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > 200*time.Millisecond {
+		t.Errorf("expected the deadline of the default timeout of the session, got %v", deadline)
+	}
+
+	// A context with a deadline overrides the default timeout
+	withDeadline, cancelDeadline := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelDeadline()
+	ctx, cancel = k.withDefaultTimeout(withDeadline)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) < 59*time.Minute {
+		t.Errorf("expected the deadline of the context, got %v", deadline)
+	}
+
+	// The statefulSet of the stopped instance stays ready, so the wait only ends by its timeout
+	instance := newTestInstance(t, k, "stopped", 8080)
+	if err := instance.Start(); err != nil {
+		t.Fatalf("starting instance: %v", err)
+	}
+	if err := instance.Stop(); err != nil {
+		t.Fatalf("stopping instance: %v", err)
+	}
+	one := int32(1)
+	cluster.PrependReactor("get", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &appv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: instance.k8sName, Namespace: testNamespace},
+			Spec:       appv1.StatefulSetSpec{Replicas: &one},
+			Status:     appv1.StatefulSetStatus{ReadyReplicas: one},
+		}, nil
+	})
+	started := time.Now()
+	if err := instance.WaitInstanceIsStopped(); err == nil {
+		t.Errorf("expected waiting for the stopped instance to time out")
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("waiting for the stopped instance took '%s', longer than the default timeout of the session", elapsed)
+	}
+}
@@ -0,0 +1,213 @@
+// Package log provides the logger used by all packages of knuu.
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is the minimal interface a logger has to implement to be used by knuu.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// FieldLogger is a Logger that supports structured fields.
+// If the logger in use implements it, fields are passed to it, otherwise they are prepended to the messages.
+type FieldLogger interface {
+	Logger
+	WithFields(fields map[string]interface{}) Logger
+}
+
+// Level is the level of a log message, the levels are the ones of logrus.
+type Level = logrus.Level
+
+// ParseLevel parses a level name, e.g. "debug" or "info".
+func ParseLevel(name string) (Level, error) {
+	return logrus.ParseLevel(name)
+}
+
+// LevelLogger is a Logger whose level can be changed.
+type LevelLogger interface {
+	Logger
+	SetLevel(level Level)
+	// WithLevel returns a logger writing to the same output, but with its own level.
+	WithLevel(level Level) Logger
+}
+
+// logger is the logger in use, it defaults to the global logrus logger.
+var logger Logger = NewLogrusLogger(logrus.StandardLogger())
+
+// SetLogger sets the logger in use.
+func SetLogger(l Logger) {
+	logger = l
+}
+
+// GetLogger returns the logger in use.
+func GetLogger() Logger {
+	return logger
+}
+
+// Debugf logs a message at debug level.
+func Debugf(format string, args ...interface{}) {
+	logger.Debugf(format, args...)
+}
+
+// Infof logs a message at info level.
+func Infof(format string, args ...interface{}) {
+	logger.Infof(format, args...)
+}
+
+// Warnf logs a message at warn level.
+func Warnf(format string, args ...interface{}) {
+	logger.Warnf(format, args...)
+}
+
+// Errorf logs a message at error level.
+func Errorf(format string, args ...interface{}) {
+	logger.Errorf(format, args...)
+}
+
+// SetLevel sets the level of the logger in use.
+// It returns an error if the logger does not implement LevelLogger.
+func SetLevel(level Level) error {
+	levelLogger, ok := logger.(LevelLogger)
+	if !ok {
+		return fmt.Errorf("logger does not support setting the level")
+	}
+	levelLogger.SetLevel(level)
+	return nil
+}
+
+// WithLevel returns a logger that logs with the given level instead of the one of the given logger.
+// If the logger does not implement LevelLogger, messages are only filtered, so the level cannot be more verbose than the logger's.
+func WithLevel(l Logger, level Level) Logger {
+	if levelLogger, ok := l.(LevelLogger); ok {
+		return levelLogger.WithLevel(level)
+	}
+	return &levelFilterLogger{logger: l, level: level}
+}
+
+// WithFields returns a logger that attaches the given fields to every message.
+func WithFields(fields map[string]interface{}) Logger {
+	if fieldLogger, ok := logger.(FieldLogger); ok {
+		return fieldLogger.WithFields(fields)
+	}
+	return &prefixLogger{logger: logger, prefix: formatFields(fields)}
+}
+
+// logrusLogger is a FieldLogger backed by logrus.
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// NewLogrusLogger creates a FieldLogger backed by the given logrus logger.
+func NewLogrusLogger(l *logrus.Logger) FieldLogger {
+	return &logrusLogger{entry: logrus.NewEntry(l)}
+}
+
+func (l *logrusLogger) Debugf(format string, args ...interface{}) {
+	l.entry.Debugf(format, args...)
+}
+
+func (l *logrusLogger) Infof(format string, args ...interface{}) {
+	l.entry.Infof(format, args...)
+}
+
+func (l *logrusLogger) Warnf(format string, args ...interface{}) {
+	l.entry.Warnf(format, args...)
+}
+
+func (l *logrusLogger) Errorf(format string, args ...interface{}) {
+	l.entry.Errorf(format, args...)
+}
+
+func (l *logrusLogger) WithFields(fields map[string]interface{}) Logger {
+	return &logrusLogger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l *logrusLogger) SetLevel(level Level) {
+	l.entry.Logger.SetLevel(level)
+}
+
+func (l *logrusLogger) WithLevel(level Level) Logger {
+	// The copy shares the output, formatter and hooks, but not the level
+	copied := &logrus.Logger{
+		Out:          l.entry.Logger.Out,
+		Hooks:        l.entry.Logger.Hooks,
+		Formatter:    l.entry.Logger.Formatter,
+		ReportCaller: l.entry.Logger.ReportCaller,
+		Level:        level,
+		ExitFunc:     l.entry.Logger.ExitFunc,
+		BufferPool:   l.entry.Logger.BufferPool,
+	}
+	return &logrusLogger{entry: logrus.NewEntry(copied).WithFields(l.entry.Data)}
+}
+
+// levelFilterLogger drops the messages above its level before passing them to a logger that does not support levels.
+type levelFilterLogger struct {
+	logger Logger
+	level  Level
+}
+
+func (l *levelFilterLogger) Debugf(format string, args ...interface{}) {
+	if l.level >= logrus.DebugLevel {
+		l.logger.Debugf(format, args...)
+	}
+}
+
+func (l *levelFilterLogger) Infof(format string, args ...interface{}) {
+	if l.level >= logrus.InfoLevel {
+		l.logger.Infof(format, args...)
+	}
+}
+
+func (l *levelFilterLogger) Warnf(format string, args ...interface{}) {
+	if l.level >= logrus.WarnLevel {
+		l.logger.Warnf(format, args...)
+	}
+}
+
+func (l *levelFilterLogger) Errorf(format string, args ...interface{}) {
+	if l.level >= logrus.ErrorLevel {
+		l.logger.Errorf(format, args...)
+	}
+}
+
+// prefixLogger prepends the fields to the messages of a logger that does not support structured fields.
+type prefixLogger struct {
+	logger Logger
+	prefix string
+}
+
+func (l *prefixLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(l.prefix+format, args...)
+}
+
+func (l *prefixLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(l.prefix+format, args...)
+}
+
+func (l *prefixLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Warnf(l.prefix+format, args...)
+}
+
+func (l *prefixLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(l.prefix+format, args...)
+}
+
+// formatFields formats the fields as a sorted list of key=value pairs.
+func formatFields(fields map[string]interface{}) string {
+	pairs := make([]string, 0, len(fields))
+	for key, value := range fields {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(pairs)
+	// Escape percent signs, as the prefix becomes part of the format string
+	return strings.ReplaceAll("["+strings.Join(pairs, " ")+"] ", "%", "%%")
+}